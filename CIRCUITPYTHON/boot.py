@@ -0,0 +1,4 @@
+import usb_cdc
+
+# Second USB serial port for frames pushed by picoHWMon; the console stays on the first
+usb_cdc.enable(console=True, data=True)
@@ -1,12 +1,15 @@
 import time
 import math
+import hashlib
+import struct
 import analogio
 import board
 import wifi
 import socketpool
+import usb_cdc
 import adafruit_requests
 import microcontroller
-from adafruit_httpserver import Server, Request, Response, FileResponse, JSONResponse
+from adafruit_httpserver import Server, Request, Response, FileResponse, JSONResponse, POST
 
 # === Termistor config ===
 VCC = 3.3
@@ -47,6 +50,126 @@ def temps(request: Request):
         ]
     })
 
+# === Alerts pushed by picoHWMon ===
+MAX_ALERTS = 8
+alerts = []
+
+@server.route("/api/alert", POST)
+def alert(request: Request):
+    data = request.json()
+    # Replace the previous state of the same rule; resolved alerts are dropped
+    for a in alerts:
+        if a["rule"] == data.get("rule"):
+            alerts.remove(a)
+            break
+    if data.get("status") == "firing":
+        alerts.insert(0, data)
+        del alerts[MAX_ALERTS:]
+    return JSONResponse(request, {"ok": True})
+
+@server.route("/api/alerts")
+def list_alerts(request: Request):
+    return JSONResponse(request, {"alerts": alerts})
+
+# === Snapshots pushed by picoHWMon ===
+# picoHWMon pushes compact binary snapshots over UDP, or over the USB data
+# channel when boot.py enables it. Set HOST_IP to the PC running picoHWMon,
+# and DEVICE_KEY to its integrations.display.key if one is configured.
+HOST_IP = "192.168.1.10"
+HOST_PORT = 9123
+DEVICE_NAME = "pico-display"
+DEVICE_KEY = ""
+PING_INTERVAL = 10
+
+FRAME_HELLO, FRAME_WELCOME, FRAME_SNAPSHOT, FRAME_PING, FRAME_BYE, FRAME_ERROR = 1, 2, 3, 4, 5, 6
+FRAME_CHALLENGE, FRAME_ANSWER = 7, 8
+SCHEMA_COMPACT = 1
+SEVERITIES = (None, "info", "warning", "critical")
+
+host = {"connected": False, "snapshot": None}
+seq = 0
+
+def crc16(data):
+    crc = 0xFFFF
+    for b in data:
+        crc ^= b << 8
+        for _ in range(8):
+            crc = ((crc << 1) ^ 0x1021) & 0xFFFF if crc & 0x8000 else (crc << 1) & 0xFFFF
+    return crc
+
+def encode_frame(kind, payload=b""):
+    global seq
+    seq = (seq + 1) & 0xFFFF
+    body = struct.pack(">BBHH", 1, kind, seq, len(payload)) + payload
+    return b"PH" + body + struct.pack(">H", crc16(body))
+
+def decode_frame(data):
+    """Returns (kind, payload, consumed); consumed is 0 for a partial frame"""
+    if len(data) < 8:
+        return None, None, 0
+    if data[0:2] != b"PH":
+        return None, None, 1
+    version, kind, _, length = struct.unpack(">BBHH", data[2:8])
+    if len(data) < 10 + length:
+        return None, None, 0
+    if crc16(data[2:8 + length]) != struct.unpack(">H", data[8 + length:10 + length])[0]:
+        return None, None, 1
+    return kind, data[8:8 + length], 10 + length
+
+def hello_frame():
+    name = DEVICE_NAME.encode()
+    # Only the compact schema: it needs no parser beyond struct
+    payload = bytes([len(name)]) + name + bytes([1, SCHEMA_COMPACT]) + struct.pack(">HH", 1000, 256)
+    return encode_frame(FRAME_HELLO, payload)
+
+def hmac_sha256(key, message):
+    key = key + bytes(64 - len(key)) if len(key) <= 64 else hashlib.new("sha256", key).digest() + bytes(32)
+    inner = hashlib.new("sha256", bytes(b ^ 0x36 for b in key) + message).digest()
+    return hashlib.new("sha256", bytes(b ^ 0x5C for b in key) + inner).digest()
+
+def answer_frame(nonce):
+    # The nonce proves we own our address; the HMAC proves we know the key
+    answer = hmac_sha256(DEVICE_KEY.encode(), bytes(nonce)) if DEVICE_KEY else bytes(nonce)
+    return encode_frame(FRAME_ANSWER, answer)
+
+def scaled(value, scale, missing):
+    return None if value == missing else value / scale
+
+def handle_frame(kind, payload):
+    if kind == FRAME_CHALLENGE:
+        send(answer_frame(payload))
+    elif kind == FRAME_WELCOME:
+        host["connected"] = True
+        print("Registered with picoHWMon, schema", payload[0])
+    elif kind == FRAME_SNAPSHOT and payload[0] == SCHEMA_COMPACT:
+        (t, cpu, cpu_mhz, cpu_temp, gpu, gpu_temp, gpu_power,
+         mem, disk, alerts, severity, fan_count) = struct.unpack(">IHHhHhHHHBBB", payload[1:24])
+        fans = struct.unpack(">%dH" % fan_count, payload[24:24 + 2 * fan_count])
+        host["snapshot"] = {
+            "time": t,
+            "cpu_usage": scaled(cpu, 10, 0xFFFF),
+            "cpu_frequency": scaled(cpu_mhz, 1, 0xFFFF),
+            "cpu_temperature": scaled(cpu_temp, 10, 0x7FFF),
+            "gpu_usage": scaled(gpu, 10, 0xFFFF),
+            "gpu_temperature": scaled(gpu_temp, 10, 0x7FFF),
+            "gpu_power": scaled(gpu_power, 10, 0xFFFF),
+            "memory_usage": scaled(mem, 10, 0xFFFF),
+            "disk_usage": scaled(disk, 10, 0xFFFF),
+            "alerts": alerts,
+            "severity": SEVERITIES[severity] if severity < len(SEVERITIES) else None,
+            "fan_rpm": [scaled(rpm, 1, 0xFFFF) for rpm in fans],
+        }
+    elif kind == FRAME_BYE:
+        # picoHWMon restarted or forgot us; register again
+        host["connected"] = False
+    elif kind == FRAME_ERROR:
+        print("picoHWMon rejected us:", payload[0], bytes(payload[2:]))
+        host["connected"] = False
+
+@server.route("/api/host")
+def host_snapshot(request: Request):
+    return JSONResponse(request, host)
+
 @server.route("/api/health")
 def health(request: Request):
     return JSONResponse(request, {
@@ -61,4 +184,45 @@ def index(request: Request):
     return FileResponse(request, "/index.html")
 
 print("Starting server on http://%s" % wifi.radio.ipv4_address)
-server.serve_forever(str(wifi.radio.ipv4_address))
+server.start(str(wifi.radio.ipv4_address))
+
+udp = pool.socket(pool.AF_INET, pool.SOCK_DGRAM)
+udp.settimeout(0)
+udp_buffer = bytearray(1034)
+# The USB data channel exists only when boot.py enables it
+serial = usb_cdc.data
+serial_buffer = b""
+
+def send(frame):
+    if serial is not None and serial.connected:
+        serial.write(frame)
+    else:
+        udp.sendto(frame, (HOST_IP, HOST_PORT))
+
+last_ping = -PING_INTERVAL
+while True:
+    server.poll()
+
+    now = time.monotonic()
+    if now - last_ping >= PING_INTERVAL:
+        send(encode_frame(FRAME_PING) if host["connected"] else hello_frame())
+        last_ping = now
+
+    try:
+        size, _ = udp.recvfrom_into(udp_buffer)
+        kind, payload, _ = decode_frame(udp_buffer[:size])
+        if kind:
+            handle_frame(kind, payload)
+    except OSError:
+        pass
+
+    if serial is not None and serial.in_waiting:
+        serial_buffer += serial.read(serial.in_waiting)
+        while serial_buffer:
+            kind, payload, consumed = decode_frame(serial_buffer)
+            if not consumed:
+                break
+            serial_buffer = serial_buffer[consumed:]
+            if kind:
+                handle_frame(kind, payload)
+        serial_buffer = serial_buffer[-1034:]
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/CristiGvl/picoHWMon/internal/alerting"
+	"github.com/gofiber/fiber/v2"
+)
+
+// getMetrics returns the latest sampled metrics
+func (s *Server) getMetrics(c *fiber.Ctx) error {
+	snapshot := s.sampler.Latest()
+	if snapshot == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "no metrics collected yet"})
+	}
+
+	return c.JSON(snapshot)
+}
+
+// getAlerts returns the pending and firing alerts
+func (s *Server) getAlerts(c *fiber.Ctx) error {
+	alerts := s.alerts.Alerts()
+	if state := c.Query("state"); state != "" {
+		if state != alerting.StatePending && state != alerting.StateFiring {
+			return c.Status(400).JSON(fiber.Map{"error": "state must be pending or firing"})
+		}
+		filtered := []*alerting.Alert{}
+		for _, alert := range alerts {
+			if alert.State == state {
+				filtered = append(filtered, alert)
+			}
+		}
+		alerts = filtered
+	}
+
+	return c.JSON(alerts)
+}
+
+// getAlertRules returns the configured alert rules
+func (s *Server) getAlertRules(c *fiber.Ctx) error {
+	return c.JSON(s.alerts.Rules())
+}
+
+// getAlertHistory returns resolved alerts, newest first
+func (s *Server) getAlertHistory(c *fiber.Ctx) error {
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid limit: must be between 1 and 1000"})
+		}
+		limit = parsed
+	}
+
+	return c.JSON(s.alerts.History(limit))
+}
+
+// getAlertNotifiers returns the delivery status of every notifier
+func (s *Server) getAlertNotifiers(c *fiber.Ctx) error {
+	return c.JSON(s.alerts.Notifiers())
+}
+
+// testAlertNotifier sends a test notification
+func (s *Server) testAlertNotifier(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	name := c.Params("name")
+	if err := s.alerts.TestNotifier(ctx, name); err != nil {
+		if errors.Is(err, alerting.ErrNotifierNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(502).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "notifier": name})
+}
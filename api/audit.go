@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/auth"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+	"github.com/gofiber/fiber/v2"
+)
+
+// auditKey is the fiber.Ctx locals key holding the *audit.Entry of a control request
+const auditKey = "audit"
+
+const (
+	// deniedInterval is how often a denied request is recorded per client
+	// address; the ones in between are only counted
+	deniedInterval = time.Minute
+	// maxDeniedClients bounds the client addresses tracked for denials
+	maxDeniedClients = 1024
+)
+
+// deniedLimiter rate-limits the entries of denied requests, so clients
+// without credentials can't rotate control actions out of the audit log
+type deniedLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*deniedClient
+}
+
+// deniedClient tracks the denied requests of one client address
+type deniedClient struct {
+	recorded   time.Time
+	suppressed int
+}
+
+// allow reports whether a denied request from addr is recorded, and how many
+// were left out since the last one that was
+func (l *deniedLimiter) allow(addr string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.clients == nil {
+		l.clients = make(map[string]*deniedClient)
+	}
+	client, ok := l.clients[addr]
+	if ok && now.Sub(client.recorded) < deniedInterval {
+		client.suppressed++
+		return false, 0
+	}
+	if !ok {
+		if len(l.clients) >= maxDeniedClients {
+			for a, c := range l.clients {
+				if now.Sub(c.recorded) >= deniedInterval {
+					delete(l.clients, a)
+				}
+			}
+		}
+		if len(l.clients) >= maxDeniedClients {
+			return false, 0
+		}
+		client = &deniedClient{}
+		l.clients[addr] = client
+	}
+	suppressed := client.suppressed
+	client.recorded, client.suppressed = now, 0
+	return true, suppressed
+}
+
+// auditMiddleware records state-changing API requests in the audit log.
+// Requests that were denied or never authenticated are rate-limited per
+// client address. Handlers enrich the entry with the target, previous value
+// and detailed result.
+func (s *Server) auditMiddleware(c *fiber.Ctx) error {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return c.Next()
+	}
+
+	entry := &audit.Entry{
+		Time:       time.Now(),
+		ClientAddr: c.IP(),
+		Method:     c.Method(),
+		Endpoint:   c.Path(),
+	}
+	if body := c.Body(); len(body) > 0 && json.Valid(body) {
+		entry.New = append(json.RawMessage{}, body...)
+	}
+	c.Locals(auditKey, entry)
+
+	err := c.Next()
+
+	identity := requestIdentity(c)
+	entry.Identity = identity.Name
+	entry.AuthMethod = identity.Method
+	entry.Status = c.Response().StatusCode()
+	if err != nil {
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			entry.Status = fiberErr.Code
+		}
+		entry.Error = err.Error()
+	}
+
+	switch {
+	case entry.Status == 401 || entry.Status == 403:
+		entry.Result = audit.ResultDenied
+	case entry.Status == 422:
+		entry.Result = audit.ResultPartial
+	case entry.Status >= 400:
+		entry.Result = audit.ResultFailure
+	default:
+		entry.Result = audit.ResultSuccess
+	}
+
+	if entry.Error == "" && entry.Status >= 400 {
+		var response struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(c.Response().Body(), &response) == nil {
+			entry.Error = response.Error
+		}
+	}
+
+	_, authenticated := c.Locals(identityKey).(*auth.Identity)
+	if !authenticated || entry.Result == audit.ResultDenied {
+		record, suppressed := s.denied.allow(entry.ClientAddr, entry.Time)
+		if !record {
+			return err
+		}
+		entry.Suppressed = suppressed
+	}
+
+	if recordErr := s.auditLog.Record(entry); recordErr != nil {
+		slog.Error("Failed to write audit log", "error", recordErr)
+	}
+
+	return err
+}
+
+// auditEntry returns the audit entry of the current request, or nil for reads
+func auditEntry(c *fiber.Ctx) *audit.Entry {
+	entry, _ := c.Locals(auditKey).(*audit.Entry)
+	return entry
+}
+
+// auditTarget records what a control request acts on, e.g. "fan:0" or "gpu:1"
+func auditTarget(c *fiber.Ctx, target string) {
+	if entry := auditEntry(c); entry != nil {
+		entry.Target = target
+	}
+}
+
+// auditPrevious records the value a control request replaces
+func auditPrevious(c *fiber.Ctx, previous interface{}) {
+	entry := auditEntry(c)
+	if entry == nil || previous == nil {
+		return
+	}
+	if data, err := json.Marshal(previous); err == nil {
+		entry.Previous = data
+	}
+}
+
+// auditResult records the detailed result of a control request and lifts
+// its warnings and errors into the entry
+func auditResult(c *fiber.Ctx, result interface{}) {
+	entry := auditEntry(c)
+	if entry == nil || result == nil {
+		return
+	}
+	if data, err := json.Marshal(result); err == nil {
+		entry.Details = data
+	}
+
+	var messages []string
+	switch r := result.(type) {
+	case *gpu.OverclockResult:
+		messages = overclockResultMessages(r)
+	case *overclock.ProfileResult:
+		messages = profileResultMessages(r)
+	case *overclock.Transaction:
+		messages = append(messages, r.Warnings...)
+		if r.Result != nil {
+			messages = append(messages, profileResultMessages(r.Result)...)
+		}
+	}
+	entry.Warnings = append(entry.Warnings, messages...)
+}
+
+// overclockResultMessages collects the warnings and errors of a GPU result
+func overclockResultMessages(result *gpu.OverclockResult) []string {
+	messages := append([]string{}, result.Warnings...)
+	return append(messages, result.Errors...)
+}
+
+// profileResultMessages collects the warnings and errors of a profile result
+func profileResultMessages(result *overclock.ProfileResult) []string {
+	messages := append([]string{}, result.Warnings...)
+	for _, gpuResult := range result.GPUs {
+		messages = append(messages, overclockResultMessages(gpuResult)...)
+	}
+	for _, component := range result.Components {
+		if component.Error != "" {
+			messages = append(messages, component.Component+": "+component.Error)
+		}
+	}
+	return messages
+}
+
+// Audit log endpoint
+func (s *Server) getAudit(c *fiber.Ctx) error {
+	filter := audit.Filter{
+		Identity: c.Query("identity"),
+		Endpoint: c.Query("endpoint"),
+		Target:   c.Query("target"),
+		Result:   c.Query("result"),
+		Limit:    100,
+	}
+
+	for name, field := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "invalid " + name + ": use RFC 3339, e.g. 2024-01-02T15:04:05Z"})
+			}
+			*field = parsed
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > 1000 {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid limit: must be between 1 and 1000"})
+		}
+		filter.Limit = limit
+	}
+	filter.Result = strings.ToLower(filter.Result)
+
+	entries, err := s.auditLog.Query(filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(entries)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/auth"
+	"github.com/CristiGvl/picoHWMon/internal/certs"
+	"github.com/gofiber/fiber/v2"
+)
+
+// identityKey is the fiber.Ctx locals key holding the client's *auth.Identity
+const identityKey = "identity"
+
+// HMAC request headers
+const (
+	headerKey       = "X-PicoHWMon-Key"
+	headerTimestamp = "X-PicoHWMon-Timestamp"
+	headerNonce     = "X-PicoHWMon-Nonce"
+	headerSignature = "X-PicoHWMon-Signature"
+)
+
+// hostMiddleware rejects requests whose Host header isn't localhost, an IP
+// address or a configured host name. A DNS rebinding page reaches the server
+// under the page's own domain, which resolves to this machine.
+func (s *Server) hostMiddleware(c *fiber.Ctx) error {
+	if host := c.Hostname(); !s.hostAllowed(host) {
+		return c.Status(403).JSON(fiber.Map{"error": fmt.Sprintf("host %s is not allowed; add it to server.hosts", host)})
+	}
+	return c.Next()
+}
+
+// hostAllowed reports whether the server may be reached by host, with or
+// without a port. IP addresses are always allowed: DNS rebinding needs a
+// name.
+func (s *Server) hostAllowed(host string) bool {
+	name := hostName(host)
+	if name == "" || isLocalhost(name) || net.ParseIP(name) != nil {
+		return true
+	}
+	for _, allowed := range s.currentConfig().Server.Hosts {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostName strips the port and IPv6 brackets from a Host header
+func hostName(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// isLocalhost reports whether name is localhost or a .localhost subdomain,
+// which browsers resolve to the loopback interface themselves
+func isLocalhost(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return name == "localhost" || strings.HasSuffix(name, ".localhost")
+}
+
+// loopbackHost reports whether a Host header names the loopback interface
+func loopbackHost(host string) bool {
+	name := hostName(host)
+	if isLocalhost(name) {
+		return true
+	}
+	ip := net.ParseIP(name)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authMiddleware authenticates every API request and requires the admin role
+// for anything that changes state. Without configured tokens, clients on the
+// loopback interface that address it by a loopback name get the admin role
+// and everyone else read-only access.
+func (s *Server) authMiddleware(c *fiber.Ctx) error {
+	if c.Path() == "/api/health" {
+		return c.Next()
+	}
+	return s.authorize(c, auth.RoleAdmin)
+}
+
+// sensorAuthMiddleware authenticates pushes of external sensor readings,
+// which the sensor role is allowed to make
+func (s *Server) sensorAuthMiddleware(c *fiber.Ctx) error {
+	return s.authorize(c, auth.RoleSensor)
+}
+
+// authorize authenticates the client and requires the read role for reads
+// and writeRole for anything else
+func (s *Server) authorize(c *fiber.Ctx, writeRole auth.Role) error {
+	required := auth.RoleRead
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+	default:
+		required = writeRole
+		if status, err := s.checkCrossSite(c); err != nil {
+			return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	identity, err := s.authenticate(c)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="picoHWMon"`)
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	}
+	c.Locals(identityKey, identity)
+	if !identity.Role.Allows(required) {
+		return c.Status(403).JSON(fiber.Map{"error": "the " + string(required) + " role is required for this endpoint"})
+	}
+	return c.Next()
+}
+
+// requireAdmin restricts a read endpoint to the admin role. It is attached to
+// the route rather than matched on the path, since routes are matched
+// case-insensitively.
+func requireAdmin(c *fiber.Ctx) error {
+	if !requestIdentity(c).Role.Allows(auth.RoleAdmin) {
+		return c.Status(403).JSON(fiber.Map{"error": "the " + string(auth.RoleAdmin) + " role is required for this endpoint"})
+	}
+	return c.Next()
+}
+
+// checkCrossSite rejects state-changing requests that a web page in the
+// user's browser could send without a CORS preflight, which would otherwise
+// get the admin role on localhost. Browsers send Origin with every
+// cross-site POST, and without a preflight may only send form content types.
+func (s *Server) checkCrossSite(c *fiber.Ctx) (int, error) {
+	if origin := c.Get(fiber.HeaderOrigin); origin != "" && !s.sameOrigin(c, origin) && !s.corsOriginAllowed(origin) {
+		return 403, fmt.Errorf("requests from origin %s are not allowed; add it to the CORS origins", origin)
+	}
+	if len(c.Body()) > 0 || c.Get(fiber.HeaderContentType) != "" {
+		if !strings.HasPrefix(strings.ToLower(c.Get(fiber.HeaderContentType)), fiber.MIMEApplicationJSON) {
+			return 415, fmt.Errorf("request bodies must be sent as %s", fiber.MIMEApplicationJSON)
+		}
+	}
+	return 0, nil
+}
+
+// sameOrigin reports whether origin is the server itself under an allowed
+// host. Comparing with the Host header alone isn't enough, since a DNS
+// rebinding page sets both to its own domain.
+func (s *Server) sameOrigin(c *fiber.Ctx, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && s.hostAllowed(u.Host) && strings.EqualFold(origin, c.BaseURL())
+}
+
+// authenticate resolves the identity of the client from its credentials
+func (s *Server) authenticate(c *fiber.Ctx) (*auth.Identity, error) {
+	if !s.tokens.Enabled() {
+		role := auth.RoleRead
+		if ip := c.Context().RemoteIP(); ip != nil && ip.IsLoopback() && loopbackHost(c.Hostname()) {
+			role = auth.RoleAdmin
+		}
+		return &auth.Identity{Name: c.IP(), Role: role, Method: auth.MethodAnonymous}, nil
+	}
+
+	if header := c.Get(fiber.HeaderAuthorization); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			return nil, auth.ErrUnauthorized
+		}
+		return s.tokens.AuthenticateBearer(strings.TrimSpace(token))
+	}
+
+	if signature := c.Get(headerSignature); signature != "" {
+		return s.tokens.AuthenticateHMAC(c.Get(headerKey), c.Get(headerTimestamp), c.Get(headerNonce), signature,
+			c.Method(), c.OriginalURL(), c.Body(), time.Now())
+	}
+
+	if commonName, ok := certs.ClientCommonName(c.Context().TLSConnectionState()); ok {
+		return s.tokens.AuthenticateClientCert(commonName)
+	}
+
+	return nil, auth.ErrUnauthorized
+}
+
+// requestIdentity returns the identity the auth middleware attached to the request
+func requestIdentity(c *fiber.Ctx) *auth.Identity {
+	if identity, ok := c.Locals(identityKey).(*auth.Identity); ok {
+		return identity
+	}
+	return &auth.Identity{Name: c.IP(), Method: auth.MethodAnonymous}
+}
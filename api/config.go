@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// getConfig returns the active configuration
+func (s *Server) getConfig(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"path":   s.configPath,
+		"config": s.currentConfig(),
+	})
+}
+
+// reloadConfig re-reads the configuration file, tokens and certificates
+func (s *Server) reloadConfig(c *fiber.Ctx) error {
+	restartRequired, err := s.Reload()
+	if restartRequired == nil {
+		restartRequired = []string{}
+	}
+	if err != nil {
+		return c.Status(422).JSON(fiber.Map{
+			"error":            err.Error(),
+			"restart_required": restartRequired,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"reloaded":         true,
+		"path":             s.configPath,
+		"restart_required": restartRequired,
+	})
+}
+
+// collectorDisabled responds to a request for a collector that is turned off in the configuration
+func collectorDisabled(c *fiber.Ctx, name string) error {
+	return c.Status(404).JSON(fiber.Map{"error": "the " + name + " collector is disabled in the configuration"})
+}
@@ -2,18 +2,32 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
 	"strconv"
 	"time"
 
+	"github.com/CristiGvl/picoHWMon/internal/auth"
+	"github.com/CristiGvl/picoHWMon/internal/container"
+	"github.com/CristiGvl/picoHWMon/internal/cpu"
 	"github.com/CristiGvl/picoHWMon/internal/fan"
 	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/memory"
 	"github.com/CristiGvl/picoHWMon/internal/overclock"
+	"github.com/CristiGvl/picoHWMon/internal/process"
+	"github.com/CristiGvl/picoHWMon/internal/rules"
 	"github.com/gofiber/fiber/v2"
 )
 
 // CPU endpoint
 func (s *Server) getCPU(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if !s.currentConfig().Collectors.CPU {
+		return collectorDisabled(c, "cpu")
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	info, err := s.cpuReader.GetInfo(ctx)
@@ -21,12 +35,23 @@ func (s *Server) getCPU(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	// The host's usage, next to the container's quota
+	if stats := s.ownCgroup(ctx); stats != nil {
+		return c.JSON(struct {
+			*cpu.Info
+			Cgroup *container.CPU `json:"cgroup"`
+		}{info, &stats.CPU})
+	}
 	return c.JSON(info)
 }
 
 // GPU endpoint
 func (s *Server) getGPU(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if !s.currentConfig().Collectors.GPU {
+		return collectorDisabled(c, "gpu")
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	info, err := s.gpuReader.GetInfo(ctx)
@@ -39,20 +64,47 @@ func (s *Server) getGPU(c *fiber.Ctx) error {
 
 // Memory endpoint
 func (s *Server) getMemory(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if !s.currentConfig().Collectors.Memory {
+		return collectorDisabled(c, "memory")
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
+	switch c.Query("detail") {
+	case "":
+	case "full":
+		detail, err := s.memoryDetail.GetDetail(ctx)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(detail)
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "detail must be full"})
+	}
+
 	info, err := s.memoryReader.GetInfo(ctx)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	// The host's memory, next to the container's limit
+	if stats := s.ownCgroup(ctx); stats != nil {
+		return c.JSON(struct {
+			*memory.Info
+			Cgroup *container.Memory `json:"cgroup"`
+		}{info, &stats.Memory})
+	}
 	return c.JSON(info)
 }
 
 // Disk endpoint
 func (s *Server) getDisk(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if !s.currentConfig().Collectors.Disk {
+		return collectorDisabled(c, "disk")
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	info, err := s.diskReader.GetInfo(ctx)
@@ -63,9 +115,217 @@ func (s *Server) getDisk(c *fiber.Ctx) error {
 	return c.JSON(info)
 }
 
+// Physical disks endpoint
+func (s *Server) getDiskDevices(c *fiber.Ctx) error {
+	if !s.currentConfig().Collectors.Disk {
+		return collectorDisabled(c, "disk")
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	devices, err := s.deviceReader.GetDevices(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(devices)
+}
+
+// Disk I/O endpoint
+func (s *Server) getDiskIO(c *fiber.Ctx) error {
+	if !s.currentConfig().Collectors.Disk {
+		return collectorDisabled(c, "disk")
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	stats, err := s.ioReader.GetIO(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(stats)
+}
+
+// Network endpoint
+func (s *Server) getNetwork(c *fiber.Ctx) error {
+	if !s.currentConfig().Collectors.Network {
+		return collectorDisabled(c, "network")
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	ifaces, err := s.networkReader.GetInfo(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(ifaces)
+}
+
+// Power endpoint
+func (s *Server) getPower(c *fiber.Ctx) error {
+	if !s.currentConfig().Collectors.Power {
+		return collectorDisabled(c, "power")
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	info, err := s.powerReader.GetInfo(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(info)
+}
+
+// Energy meter endpoint
+func (s *Server) getEnergy(c *fiber.Ctx) error {
+	return c.JSON(s.energyMeter.GetEnergy())
+}
+
+// Voltage rails endpoint
+func (s *Server) getVoltages(c *fiber.Ctx) error {
+	if !s.currentConfig().Collectors.Voltages {
+		return collectorDisabled(c, "voltages")
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	info, err := s.voltageReader.GetInfo(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(info)
+}
+
+// Process endpoint: the top processes by a resource, optionally filtered
+func (s *Server) getProcesses(c *fiber.Ctx) error {
+	if !s.currentConfig().Collectors.Processes {
+		return collectorDisabled(c, "processes")
+	}
+
+	query := process.Query{
+		Sort:      c.Query("sort", process.SortCPU),
+		Limit:     25,
+		User:      c.Query("user"),
+		Name:      c.Query("name"),
+		Container: c.Query("container"),
+	}
+	if !process.ValidSort(query.Sort) {
+		return c.Status(400).JSON(fiber.Map{"error": "sort must be cpu, memory, io, gpu_memory or gpu"})
+	}
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid limit: must be 0 (all) or more"})
+		}
+		query.Limit = parsed
+	}
+	if _, err := filepath.Match(query.Name, ""); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid name pattern"})
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	processes, window, err := s.processReader.GetProcesses(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	selected, total := process.Select(processes, query)
+	if !requestIdentity(c).Role.Allows(auth.RoleAdmin) {
+		// Command lines often carry secrets; the processes are shared, so copy them
+		for i, p := range selected {
+			redacted := *p
+			redacted.Command = ""
+			selected[i] = &redacted
+		}
+	}
+	return c.JSON(process.List{Processes: selected, Total: total, Window: window})
+}
+
+// Container endpoint: whether picoHWMon runs in a container, what it can
+// see of the host, and the limits and usage of its own cgroup
+func (s *Server) getContainer(c *fiber.Ctx) error {
+	response := struct {
+		*container.Environment
+		Stats *container.Stats `json:"stats,omitempty"`
+	}{Environment: s.containerReader.Environment()}
+
+	if s.currentConfig().Collectors.Cgroups && response.CgroupVersion == 2 {
+		ctx, cancel := s.requestContext()
+		defer cancel()
+
+		stats, err := s.containerReader.GetOwn(ctx)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		response.Stats = stats
+	}
+	return c.JSON(response)
+}
+
+// Cgroup endpoint: the limits and usage per cgroup and per container
+func (s *Server) getCgroups(c *fiber.Ctx) error {
+	if !s.currentConfig().Collectors.Cgroups {
+		return collectorDisabled(c, "cgroups")
+	}
+
+	query := container.Query{
+		Sort:       c.Query("sort", container.SortPath),
+		Containers: c.Query("containers") == "true",
+	}
+	if !container.ValidSort(query.Sort) {
+		return c.Status(400).JSON(fiber.Map{"error": "sort must be path, cpu, memory or io"})
+	}
+	if value := c.Query("depth"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid depth: must be 0 (all) or more"})
+		}
+		query.Depth = parsed
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	groups, window, err := s.containerReader.GetCgroups(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(container.Breakdown{Cgroups: container.Select(groups, query), Window: window})
+}
+
+// ownCgroup returns the stats of picoHWMon's cgroup when it runs in a
+// container on cgroup v2, or nil
+func (s *Server) ownCgroup(ctx context.Context) *container.Stats {
+	env := s.containerReader.Environment()
+	if !s.currentConfig().Collectors.Cgroups || !env.InContainer || env.CgroupVersion != 2 {
+		return nil
+	}
+	stats, err := s.containerReader.GetOwn(ctx)
+	if err != nil {
+		return nil
+	}
+	return stats
+}
+
 // Temperature endpoint
 func (s *Server) getTemps(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if !s.currentConfig().Collectors.Temps {
+		return collectorDisabled(c, "temps")
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	info, err := s.tempsReader.GetInfo(ctx)
@@ -78,7 +338,7 @@ func (s *Server) getTemps(c *fiber.Ctx) error {
 
 // Fan endpoints
 func (s *Server) getFans(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	fans, err := s.fanController.GetFans(ctx)
@@ -100,9 +360,14 @@ func (s *Server) setFanSettings(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
+	auditTarget(c, fmt.Sprintf("fan:%d", fanID))
+	if previous, err := s.fanController.GetSettings(ctx, fanID); err == nil {
+		auditPrevious(c, previous)
+	}
+
 	if err := s.fanController.SetSettings(ctx, fanID, &settings); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -117,7 +382,7 @@ func (s *Server) getFanSettings(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid fan ID"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	settings, err := s.fanController.GetSettings(ctx, fanID)
@@ -135,7 +400,7 @@ func (s *Server) getGPUOverclock(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid device ID"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	settings, err := s.gpuReader.GetOverclockSettings(ctx, deviceID)
@@ -146,45 +411,100 @@ func (s *Server) getGPUOverclock(c *fiber.Ctx) error {
 	return c.JSON(settings)
 }
 
-func (s *Server) setGPUOverclock(c *fiber.Ctx) error {
+func (s *Server) getGPUCapabilities(c *fiber.Ctx) error {
 	deviceID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid device ID"})
 	}
 
-	var reqSettings struct {
-		CoreClockOffset   int `json:"core_clock_offset_mhz"`
-		MemoryClockOffset int `json:"memory_clock_offset_mhz"`
-		PowerLimit        int `json:"power_limit_percent"`
-		FanSpeed          int `json:"fan_speed_percent"`
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	caps, err := s.gpuReader.GetCapabilities(ctx, deviceID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if err := c.BodyParser(&reqSettings); err != nil {
+	return c.JSON(caps)
+}
+
+func (s *Server) setGPUOverclock(c *fiber.Ctx) error {
+	deviceID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid device ID"})
+	}
+
+	var settings overclock.Settings
+	if err := c.BodyParser(&settings); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
 	}
+	settings.DeviceID = deviceID
+
+	return s.applyOverclockSettings(c, &settings)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// applyOverclockSettings applies settings directly, or as a pending
+// transaction when the request carries a confirm_timeout
+func (s *Server) applyOverclockSettings(c *fiber.Ctx, settings *overclock.Settings) error {
+	timeout, err := confirmTimeout(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
-	// Convert to GPU overclock settings type
-	settings := &gpu.OverclockSettings{
-		CoreClockOffset:   reqSettings.CoreClockOffset,
-		MemoryClockOffset: reqSettings.MemoryClockOffset,
-		PowerLimit:        reqSettings.PowerLimit,
-		FanSpeed:          reqSettings.FanSpeed,
+	auditTarget(c, fmt.Sprintf("gpu:%d", settings.DeviceID))
+	if previous, err := s.overclockController.GetSettings(ctx, settings.DeviceID); err == nil {
+		auditPrevious(c, previous)
 	}
 
-	result, err := s.gpuReader.SetOverclockSettings(ctx, deviceID, settings)
+	if timeout > 0 {
+		tx, err := s.overclockController.BeginSettings(ctx, settings, timeout)
+		if err != nil {
+			return profileErrorResponse(c, err)
+		}
+		return transactionResponse(c, tx)
+	}
+
+	result, err := s.overclockController.SetSettings(ctx, settings)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return profileErrorResponse(c, err)
 	}
 
-	// Return detailed result with status code based on success
+	return overclockResultResponse(c, result)
+}
+
+// overclockResultResponse returns a detailed result with status code based on success
+func overclockResultResponse(c *fiber.Ctx, result *gpu.OverclockResult) error {
+	auditResult(c, result)
 	if result.Success {
 		return c.JSON(result)
-	} else {
-		return c.Status(422).JSON(result) // Unprocessable Entity for partial failures
 	}
+	return c.Status(422).JSON(result) // Unprocessable Entity for partial failures
+}
+
+// transactionResponse returns a pending transaction, flagging partial failures
+func transactionResponse(c *fiber.Ctx, tx *overclock.Transaction) error {
+	auditResult(c, tx)
+	if tx.Success() {
+		return c.Status(202).JSON(tx)
+	}
+	return c.Status(422).JSON(tx)
+}
+
+// confirmTimeout parses the optional confirm_timeout query parameter (seconds)
+func confirmTimeout(c *fiber.Ctx) (time.Duration, error) {
+	value := c.Query("confirm_timeout")
+	if value == "" {
+		return 0, nil
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("invalid confirm_timeout: must be a positive number of seconds")
+	}
+	return time.Duration(seconds) * time.Second, nil
 }
 
 // Overclocking endpoints
@@ -194,7 +514,7 @@ func (s *Server) getOverclockSettings(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid device ID"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
 	settings, err := s.overclockController.GetSettings(ctx, deviceID)
@@ -211,56 +531,398 @@ func (s *Server) setOverclockSettings(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	return s.applyOverclockSettings(c, &settings)
+}
+
+func (s *Server) getOverclockProfiles(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
-	if err := s.overclockController.SetSettings(ctx, &settings); err != nil {
+	profiles, err := s.overclockController.GetProfiles(ctx)
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"status": "success"})
+	return c.JSON(profiles)
 }
 
-func (s *Server) getOverclockProfiles(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (s *Server) getOverclockProfile(c *fiber.Ctx) error {
+	profileName, err := profileNameParam(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
-	profiles, err := s.overclockController.GetProfiles(ctx)
+	profile, err := s.overclockController.GetProfile(ctx, profileName)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return profileErrorResponse(c, err)
 	}
 
-	return c.JSON(profiles)
+	return c.JSON(profile)
 }
 
 func (s *Server) saveOverclockProfile(c *fiber.Ctx) error {
-	var profile overclock.Profile
-	if err := c.BodyParser(&profile); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	// Accepts both the current format and legacy {"name", "settings"} bodies
+	profile, err := overclock.DecodeProfile(c.Body())
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
-	if err := s.overclockController.SaveProfile(ctx, &profile); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	s.auditProfile(ctx, c, profile.Name)
+
+	saved, err := s.overclockController.SaveProfile(ctx, profile)
+	if err != nil {
+		return profileErrorResponse(c, err)
+	}
+
+	return c.JSON(saved)
+}
+
+func (s *Server) deleteOverclockProfile(c *fiber.Ctx) error {
+	profileName, err := profileNameParam(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	s.auditProfile(ctx, c, profileName)
+
+	if err := s.overclockController.DeleteProfile(ctx, profileName); err != nil {
+		return profileErrorResponse(c, err)
 	}
 
 	return c.JSON(fiber.Map{"status": "success"})
 }
 
+func (s *Server) renameOverclockProfile(c *fiber.Ctx) error {
+	profileName, err := profileNameParam(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	s.auditProfile(ctx, c, profileName)
+
+	profile, err := s.overclockController.RenameProfile(ctx, profileName, body.Name)
+	if err != nil {
+		return profileErrorResponse(c, err)
+	}
+
+	return c.JSON(profile)
+}
+
+func (s *Server) exportOverclockProfile(c *fiber.Ctx) error {
+	profileName, err := profileNameParam(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	export, err := s.overclockController.ExportProfile(ctx, profileName)
+	if err != nil {
+		return profileErrorResponse(c, err)
+	}
+
+	c.Attachment(profileName + ".picohwmon.json")
+	return c.JSON(export)
+}
+
+func (s *Server) importOverclockProfile(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	profile, err := s.overclockController.ImportProfile(ctx, c.Body(), c.Query("name"), c.QueryBool("overwrite"))
+	if err != nil {
+		return profileErrorResponse(c, err)
+	}
+	auditTarget(c, "profile:"+profile.Name)
+
+	return c.Status(201).JSON(profile)
+}
+
 func (s *Server) loadOverclockProfile(c *fiber.Ctx) error {
-	profileName := c.Params("name")
-	if profileName == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "profile name required"})
+	profileName, err := profileNameParam(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	timeout, err := confirmTimeout(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := s.requestContext()
 	defer cancel()
 
-	if err := s.overclockController.LoadProfile(ctx, profileName); err != nil {
+	auditTarget(c, "profile:"+profileName)
+	s.auditProfileDevices(ctx, c, profileName)
+
+	if timeout > 0 {
+		tx, err := s.overclockController.BeginProfile(ctx, profileName, timeout)
+		if err != nil {
+			return profileErrorResponse(c, err)
+		}
+		return transactionResponse(c, tx)
+	}
+
+	result, err := s.overclockController.LoadProfile(ctx, profileName)
+	if err != nil {
+		return profileErrorResponse(c, err)
+	}
+	auditResult(c, result)
+
+	if result.Success {
+		return c.JSON(result)
+	}
+	return c.Status(422).JSON(result)
+}
+
+// auditProfile records a profile as the target and its saved state as the previous value
+func (s *Server) auditProfile(ctx context.Context, c *fiber.Ctx, profileName string) {
+	auditTarget(c, "profile:"+profileName)
+	if previous, err := s.overclockController.GetProfile(ctx, profileName); err == nil {
+		auditPrevious(c, previous)
+	}
+}
+
+// auditProfileDevices records the current settings of the GPUs a profile will change
+func (s *Server) auditProfileDevices(ctx context.Context, c *fiber.Ctx, profileName string) {
+	profile, err := s.overclockController.GetProfile(ctx, profileName)
+	if err != nil {
+		return
+	}
+
+	previous := []*overclock.Settings{}
+	for _, settings := range profile.GPUs {
+		if current, err := s.overclockController.GetSettings(ctx, settings.DeviceID); err == nil {
+			previous = append(previous, current)
+		}
+	}
+	auditPrevious(c, previous)
+}
+
+// profileNameParam returns the unescaped :name route parameter
+func profileNameParam(c *fiber.Ctx) (string, error) {
+	profileName, err := url.PathUnescape(c.Params("name"))
+	if err != nil || profileName == "" {
+		return "", fmt.Errorf("profile name required")
+	}
+	return profileName, nil
+}
+
+// profileErrorResponse maps overclock and profile errors to status codes
+func profileErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, overclock.ErrInvalidProfileName), errors.Is(err, overclock.ErrInvalidSettings):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, overclock.ErrProfileNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, overclock.ErrProfileExists), errors.Is(err, overclock.ErrBlocked):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	default:
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+}
+
+// Overclock transaction endpoints
+func (s *Server) getOverclockTransactions(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	transactions, err := s.overclockController.GetTransactions(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(transactions)
+}
+
+func (s *Server) confirmOverclockTransaction(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	auditTarget(c, "transaction:"+c.Params("id"))
+
+	tx, err := s.overclockController.ConfirmTransaction(ctx, c.Params("id"))
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	auditResult(c, tx)
+
+	return c.JSON(tx)
+}
+
+func (s *Server) rollbackOverclockTransaction(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	auditTarget(c, "transaction:"+c.Params("id"))
+
+	tx, err := s.overclockController.RollbackTransaction(ctx, c.Params("id"))
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	auditResult(c, tx)
+
+	return c.JSON(tx)
+}
+
+// Profile switching rules endpoints
+func (s *Server) getRulesConfig(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	config, err := s.rulesEngine.GetConfig(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(config)
+}
+
+func (s *Server) setRulesConfig(c *fiber.Ctx) error {
+	var config rules.Config
+	if err := c.BodyParser(&config); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	auditTarget(c, "rules")
+	if previous, err := s.rulesEngine.GetConfig(ctx); err == nil {
+		auditPrevious(c, previous)
+	}
+
+	saved, err := s.rulesEngine.SetConfig(ctx, &config)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(saved)
+}
+
+func (s *Server) getRule(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	rule, err := s.rulesEngine.GetRule(ctx, c.Params("id"))
+	if err != nil {
+		return ruleErrorResponse(c, err)
+	}
+
+	return c.JSON(rule)
+}
+
+func (s *Server) saveRule(c *fiber.Ctx) error {
+	var rule rules.Rule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	auditTarget(c, "rule:"+rule.ID)
+	if previous, err := s.rulesEngine.GetRule(ctx, rule.ID); err == nil {
+		auditPrevious(c, previous)
+	}
+
+	saved, err := s.rulesEngine.SaveRule(ctx, &rule)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(saved)
+}
+
+func (s *Server) updateRule(c *fiber.Ctx) error {
+	var rule rules.Rule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	rule.ID = c.Params("id")
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	auditTarget(c, "rule:"+rule.ID)
+	previous, err := s.rulesEngine.GetRule(ctx, rule.ID)
+	if err != nil {
+		return ruleErrorResponse(c, err)
+	}
+	auditPrevious(c, previous)
+
+	saved, err := s.rulesEngine.SaveRule(ctx, &rule)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(saved)
+}
+
+func (s *Server) deleteRule(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	auditTarget(c, "rule:"+c.Params("id"))
+	if previous, err := s.rulesEngine.GetRule(ctx, c.Params("id")); err == nil {
+		auditPrevious(c, previous)
+	}
+
+	if err := s.rulesEngine.DeleteRule(ctx, c.Params("id")); err != nil {
+		return ruleErrorResponse(c, err)
+	}
 
 	return c.JSON(fiber.Map{"status": "success"})
 }
+
+func (s *Server) getRulesStatus(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	status, err := s.rulesEngine.GetStatus(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(status)
+}
+
+func (s *Server) getRuleEvents(c *fiber.Ctx) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	events, err := s.rulesEngine.GetEvents(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(events)
+}
+
+// ruleErrorResponse maps rule errors to status codes
+func ruleErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, rules.ErrRuleNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/CristiGvl/picoHWMon/internal/remote"
+	"github.com/gofiber/fiber/v2"
+)
+
+// getExternalSensors returns every external source with its readings and staleness
+func (s *Server) getExternalSensors(c *fiber.Ctx) error {
+	return c.JSON(s.external.Sources())
+}
+
+// pushExternalSensors stores readings pushed by an external source
+func (s *Server) pushExternalSensors(c *fiber.Ctx) error {
+	source := c.Params("source")
+	if !requestIdentity(c).AllowsSource(source) {
+		return c.Status(403).JSON(fiber.Map{"error": "this token may not push readings for source " + source})
+	}
+
+	err := s.external.Push(source, c.Body())
+	if errors.Is(err, remote.ErrSourceNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": "success"})
+}
+
+// lookupSensor resolves a fan curve input such as external/pico/T1
+func (s *Server) lookupSensor(ctx context.Context, name string) (float64, error) {
+	info, err := s.tempsReader.GetInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sensor := info.Find(name)
+	if sensor == nil {
+		return 0, fmt.Errorf("sensor %s not found or stale", name)
+	}
+	return sensor.Temperature, nil
+}
@@ -1,16 +1,43 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/CristiGvl/picoHWMon/internal/alerting"
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/auth"
+	"github.com/CristiGvl/picoHWMon/internal/certs"
+	"github.com/CristiGvl/picoHWMon/internal/config"
+	"github.com/CristiGvl/picoHWMon/internal/container"
 	"github.com/CristiGvl/picoHWMon/internal/cpu"
 	"github.com/CristiGvl/picoHWMon/internal/disk"
+	"github.com/CristiGvl/picoHWMon/internal/display"
 	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/governor"
 	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/hwmon"
 	"github.com/CristiGvl/picoHWMon/internal/memory"
+	"github.com/CristiGvl/picoHWMon/internal/metrics"
+	"github.com/CristiGvl/picoHWMon/internal/mqtt"
+	"github.com/CristiGvl/picoHWMon/internal/network"
 	"github.com/CristiGvl/picoHWMon/internal/overclock"
 	"github.com/CristiGvl/picoHWMon/internal/platform"
+	"github.com/CristiGvl/picoHWMon/internal/power"
+	"github.com/CristiGvl/picoHWMon/internal/process"
+	"github.com/CristiGvl/picoHWMon/internal/remote"
+	"github.com/CristiGvl/picoHWMon/internal/rules"
 	"github.com/CristiGvl/picoHWMon/internal/temps"
+	"github.com/CristiGvl/picoHWMon/internal/voltage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -22,19 +49,60 @@ type Server struct {
 	cpuReader           cpu.Reader
 	gpuReader           gpu.Reader
 	memoryReader        memory.Reader
+	memoryDetail        memory.DetailReader
+	networkReader       network.Reader
+	powerReader         power.Reader
+	voltageReader       voltage.Reader
+	processReader       process.Reader
+	containerReader     container.Reader
+	energyMeter         *power.Meter
 	diskReader          disk.Reader
+	deviceReader        disk.DeviceReader
+	ioReader            disk.IOReader
 	tempsReader         temps.Reader
 	fanController       fan.Controller
 	overclockController overclock.Controller
+	rulesEngine         rules.Engine
+	tokens              *auth.Store
+	certs               *certs.Reloader
+	tlsConfig           *tls.Config
+	auditLog            *audit.Log
+	denied              deniedLimiter
+	sampler             *metrics.Sampler
+	alerts              *alerting.Engine
+	governor            *governor.Governor
+	mqtt                *mqtt.Publisher
+	display             *display.Pusher
+	external            *remote.Collector
+
+	// address is the listen address; changing it requires a restart
+	address    string
+	configPath string
+	loadConfig ConfigLoader
+	configMu   sync.RWMutex
+	config     *config.Config
+	// configured is when config was applied; control loops are given time
+	// to pick it up before they count as stalled
+	configured time.Time
 }
 
-// NewServer creates a new API server
-func NewServer() (*Server, error) {
+// ConfigLoader reads and validates the configuration. The server calls it
+// on startup and again on every reload.
+type ConfigLoader func() (*config.Config, error)
+
+// NewServer creates a new API server. configPath is only reported by the
+// config endpoint; loadConfig is what actually reads it.
+func NewServer(configPath string, loadConfig ConfigLoader) (*Server, error) {
 	// Validate platform support
 	if err := platform.ValidateSupport(); err != nil {
 		return nil, err
 	}
 
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	app := fiber.New(fiber.Config{
 		ReadTimeout:        30 * time.Second,
 		WriteTimeout:       30 * time.Second,
@@ -45,56 +113,155 @@ func NewServer() (*Server, error) {
 		AppName:            "picoHardwareMonitor v1.0",
 	})
 
+	tokens, err := auth.LoadStore(cfg.Server.TokensFile)
+	if err != nil {
+		return nil, err
+	}
+	if !tokens.Enabled() {
+		slog.Warn("No API tokens configured: control endpoints are only available from localhost")
+		if ip := net.ParseIP(cfg.Server.Bind); ip != nil && !ip.IsLoopback() {
+			slog.Warn("Listening beyond localhost without API tokens: every client on the network can read all telemetry",
+				"bind", cfg.Server.Bind)
+		}
+	}
+
+	auditDir := cfg.Server.AuditDir
+	if auditDir == "" {
+		auditDir = filepath.Join(platform.ConfigDir(), "audit")
+	}
+	auditLog, err := audit.Open(auditDir, audit.DefaultMaxSize, audit.DefaultMaxFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	gpuReader := gpu.NewReader()
+	fanController := fan.NewController()
+	overclockController := overclock.NewController(gpuReader, fanController, cpu.NewPolicyController(), cfg.Overclock.ProfilesDir)
+	cpuReader := cpu.NewReader()
+	memoryReader := memory.NewReader()
+	networkReader := network.NewReader()
+	powerReader := power.NewReader()
+	voltageReader := voltage.NewReader()
+	processReader := process.NewReader()
+	containerReader := container.NewReader()
+	if env := containerReader.Environment(); env.InContainer {
+		slog.Info("Running in a container", "runtime", env.Runtime)
+		for _, warning := range env.Warnings {
+			slog.Warn("Container: " + warning)
+		}
+	}
+	energyMeter := power.NewMeter(powerReader, gpuReader)
+	diskReader := disk.NewReader()
+	deviceReader := disk.NewDeviceReader()
+	ioReader := disk.NewIOReader()
+	external := remote.NewCollector()
+	tempsReader := external.Wrap(disk.WrapTemps(temps.NewReader(), deviceReader))
+
+	server := &Server{
+		app:                 app,
+		cpuReader:           cpuReader,
+		gpuReader:           gpuReader,
+		memoryReader:        memoryReader,
+		memoryDetail:        memory.NewDetailReader(),
+		networkReader:       networkReader,
+		powerReader:         powerReader,
+		voltageReader:       voltageReader,
+		processReader:       processReader,
+		containerReader:     containerReader,
+		energyMeter:         energyMeter,
+		diskReader:          diskReader,
+		deviceReader:        deviceReader,
+		ioReader:            ioReader,
+		tempsReader:         tempsReader,
+		external:            external,
+		fanController:       fanController,
+		overclockController: overclockController,
+		rulesEngine:         rules.NewEngine(overclockController, fanController, gpuReader, cfg.Overclock.ProfilesDir, auditLog),
+		tokens:              tokens,
+		auditLog:            auditLog,
+		sampler: metrics.NewSampler(metrics.Sources{
+			CPU:       cpuReader,
+			GPU:       gpuReader,
+			Memory:    memoryReader,
+			Disk:      diskReader,
+			Devices:   deviceReader,
+			IO:        ioReader,
+			Temps:     tempsReader,
+			Fans:      fanController,
+			Network:   networkReader,
+			Power:     powerReader,
+			Energy:    energyMeter,
+			Voltage:   voltageReader,
+			Processes: processReader,
+			Container: containerReader,
+		}),
+		alerts:     alerting.NewEngine(),
+		governor:   governor.New(tempsReader, gpuReader, fanController, overclockController, auditLog),
+		address:    net.JoinHostPort(cfg.Server.Bind, strconv.Itoa(cfg.Server.Port)),
+		configPath: configPath,
+		loadConfig: loadConfig,
+		config:     cfg,
+	}
+
 	// Middleware
 	app.Use(logger.New())
+	app.Use(server.hostMiddleware)
+	// CORS origins are read from the current configuration so reloads apply immediately
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:     "*",
+		AllowOriginsFunc: server.corsOriginAllowed,
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "*",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization," + headerKey + "," + headerTimestamp + "," + headerNonce + "," + headerSignature,
 		AllowCredentials: false,
-		ExposeHeaders:    "Content-Length,Content-Type,Access-Control-Allow-Origin",
+		ExposeHeaders:    "Content-Length,Content-Type,Content-Disposition",
 		MaxAge:           86400, // 24 hours
 	}))
 
-	// Add explicit CORS headers middleware
-	app.Use(func(c *fiber.Ctx) error {
-		c.Set("Access-Control-Allow-Origin", "*")
-		c.Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS,PATCH")
-		c.Set("Access-Control-Allow-Headers", "*")
-
-		// Handle preflight requests
-		if c.Method() == "OPTIONS" {
-			return c.SendStatus(204)
+	if cfg.Server.TLS.Enabled {
+		if err := server.setupTLS(cfg.Server.TLS); err != nil {
+			return nil, err
 		}
-
-		return c.Next()
-	})
-
-	server := &Server{
-		app:                 app,
-		cpuReader:           cpu.NewReader(),
-		gpuReader:           gpu.NewReader(),
-		memoryReader:        memory.NewReader(),
-		diskReader:          disk.NewReader(),
-		tempsReader:         temps.NewReader(),
-		fanController:       fan.NewController(),
-		overclockController: overclock.NewController(),
 	}
 
+	server.mqtt = mqtt.NewPublisher(server.sampler, fanController, overclockController, auditLog)
+	server.display = display.NewPusher(server.sampler, server.alerts)
+	server.applyConfig(cfg)
+	server.applyFanDefaults(cfg)
+
 	server.setupRoutes()
+	server.governor.Start()
+	server.energyMeter.Start()
+	server.rulesEngine.Start()
+
+	snapshots, _ := server.sampler.Subscribe()
+	server.alerts.Start(snapshots)
+	server.sampler.Start()
 	return server, nil
 }
 
 // setupRoutes configures all API routes
 func (s *Server) setupRoutes() {
-	api := s.app.Group("/api")
+	// Sensor pushes only need the sensor role, so they are routed ahead of the
+	// /api group, whose middleware requires admin for every write. They change
+	// no settings and would flood the audit log, so they aren't audited.
+	s.app.Post("/api/sensors/external/:source", s.sensorAuthMiddleware, s.pushExternalSensors)
+
+	api := s.app.Group("/api", s.auditMiddleware, s.authMiddleware)
 
 	// System information endpoints
 	api.Get("/cpu", s.getCPU)
 	api.Get("/gpu", s.getGPU)
 	api.Get("/memory", s.getMemory)
 	api.Get("/disk", s.getDisk)
+	api.Get("/disk/devices", s.getDiskDevices)
+	api.Get("/disk/io", s.getDiskIO)
 	api.Get("/temps", s.getTemps)
+	api.Get("/network", s.getNetwork)
+	api.Get("/power", s.getPower)
+	api.Get("/power/energy", s.getEnergy)
+	api.Get("/voltages", s.getVoltages)
+	api.Get("/processes", s.getProcesses)
+	api.Get("/container", s.getContainer)
+	api.Get("/cgroups", s.getCgroups)
 
 	// Fan control endpoints
 	api.Get("/fan", s.getFans)
@@ -102,35 +269,424 @@ func (s *Server) setupRoutes() {
 	api.Post("/fan/:id/settings", s.setFanSettings)
 
 	// GPU overclocking endpoints
+	api.Get("/gpu/:id/capabilities", s.getGPUCapabilities)
 	api.Get("/gpu/:id/overclock", s.getGPUOverclock)
 	api.Post("/gpu/:id/overclock", s.setGPUOverclock)
 
 	// Overclocking endpoints
 	api.Get("/overclock/profiles", s.getOverclockProfiles)
 	api.Post("/overclock/profiles", s.saveOverclockProfile)
+	api.Post("/overclock/profiles/import", s.importOverclockProfile)
+	api.Get("/overclock/profiles/:name", s.getOverclockProfile)
+	api.Delete("/overclock/profiles/:name", s.deleteOverclockProfile)
+	api.Post("/overclock/profiles/:name/rename", s.renameOverclockProfile)
+	api.Get("/overclock/profiles/:name/export", s.exportOverclockProfile)
 	api.Post("/overclock/profiles/:name/load", s.loadOverclockProfile)
+	api.Get("/overclock/transactions", s.getOverclockTransactions)
+	api.Post("/overclock/transactions/:id/confirm", s.confirmOverclockTransaction)
+	api.Post("/overclock/transactions/:id/rollback", s.rollbackOverclockTransaction)
 	api.Get("/overclock/:deviceId", s.getOverclockSettings)
 	api.Post("/overclock", s.setOverclockSettings)
 
+	// Profile switching rules endpoints
+	api.Get("/rules", s.getRulesConfig)
+	api.Put("/rules", s.setRulesConfig)
+	api.Post("/rules", s.saveRule)
+	api.Get("/rules/status", s.getRulesStatus)
+	api.Get("/rules/events", s.getRuleEvents)
+	api.Get("/rules/:id", s.getRule)
+	api.Put("/rules/:id", s.updateRule)
+	api.Delete("/rules/:id", s.deleteRule)
+
+	// Audit log
+	api.Get("/audit", requireAdmin, s.getAudit)
+
+	// Thermal protection governor
+	api.Get("/governor", s.getGovernor)
+
+	// Metrics and alerting
+	api.Get("/metrics", s.getMetrics)
+	api.Get("/alerts", s.getAlerts)
+	api.Get("/alerts/rules", s.getAlertRules)
+	api.Get("/alerts/history", s.getAlertHistory)
+	api.Get("/alerts/notifiers", s.getAlertNotifiers)
+	api.Post("/alerts/notifiers/:name/test", s.testAlertNotifier)
+
+	// External sensors
+	api.Get("/sensors/external", s.getExternalSensors)
+
+	// Integrations
+	api.Get("/integrations/mqtt", s.getMQTTStatus)
+	api.Get("/integrations/display", s.getDisplayStatus)
+
+	// Configuration
+	api.Get("/config", requireAdmin, s.getConfig)
+	api.Post("/config/reload", s.reloadConfig)
+
 	// Health check
 	api.Get("/health", s.healthCheck)
 }
 
-// Start starts the API server
-func (s *Server) Start(address string) error {
-	return s.app.Listen(address)
+// setupTLS loads or generates the server certificate
+func (s *Server) setupTLS(options config.TLS) error {
+	certFile, keyFile := options.CertFile, options.KeyFile
+	if certFile == "" {
+		certFile = filepath.Join(platform.ConfigDir(), "tls", "cert.pem")
+	}
+	if keyFile == "" {
+		keyFile = filepath.Join(platform.ConfigDir(), "tls", "key.pem")
+	}
+
+	created, err := certs.EnsureSelfSigned(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to generate self-signed certificate: %w", err)
+	}
+
+	reloader, err := certs.NewReloader(certFile, keyFile, options.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	if created {
+		slog.Info("Generated self-signed certificate", "file", certFile, "sha256", reloader.Fingerprint())
+	}
+	if options.RequireClientCert && options.ClientCAFile == "" {
+		return fmt.Errorf("requiring client certificates needs a client CA file")
+	}
+
+	s.certs = reloader
+	s.tlsConfig = reloader.TLSConfig(options.RequireClientCert)
+	return nil
+}
+
+// URL returns the address the server listens on
+func (s *Server) URL() string {
+	scheme := "http"
+	if s.tlsConfig != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + s.address
+}
+
+// Start starts the API server on the configured address
+func (s *Server) Start() error {
+	ln, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Listen opens the configured address, so the caller knows the server is
+// reachable before Serve blocks
+func (s *Server) Listen() (net.Listener, error) {
+	return net.Listen("tcp", s.address)
+}
+
+// Serve serves the API on a listener until shutdown, e.g. one passed by
+// systemd socket activation; the configured address is then unused
+func (s *Server) Serve(ln net.Listener) error {
+	if s.tlsConfig != nil {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	return s.app.Listener(ln)
+}
+
+// Reload re-reads the configuration file, the tokens file and the TLS
+// certificates and applies them without restarting. An invalid configuration
+// file leaves the current configuration in place. Established connections are
+// not interrupted; new requests and handshakes use the reloaded credentials.
+// It returns the changed settings that only take effect after a restart.
+func (s *Server) Reload() ([]string, error) {
+	var restartRequired []string
+	var errs []error
+
+	cfg, err := s.loadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		restartRequired = config.RestartRequired(s.currentConfig(), cfg)
+		s.applyConfig(cfg)
+	}
+
+	if err := s.tokens.Reload(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.certs != nil {
+		if err := s.certs.Reload(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return restartRequired, errors.Join(errs...)
+}
+
+// currentConfig returns the active configuration
+func (s *Server) currentConfig() *config.Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	return s.config
+}
+
+// applyConfig makes cfg the active configuration and pushes the settings that
+// can change at runtime to the controllers
+func (s *Server) applyConfig(cfg *config.Config) {
+	s.configMu.Lock()
+	s.config, s.configured = cfg, time.Now()
+	s.configMu.Unlock()
+
+	s.fanController.Configure(fan.Config{
+		CurveInterval: cfg.Fans.CurveInterval.Std(),
+		MinFixedSpeed: cfg.Safety.MinFixedFanSpeed,
+		Sensors:       s.lookupSensor,
+	})
+	s.external.Configure(cfg.Collectors.External)
+	s.networkReader.Configure(network.Filter{
+		IncludeVirtual: cfg.Collectors.Interfaces.IncludeVirtual,
+		Include:        cfg.Collectors.Interfaces.Include,
+		Exclude:        cfg.Collectors.Interfaces.Exclude,
+	})
+	s.applySensorsConfig(cfg.Collectors.LMSensors)
+	s.voltageReader.Configure(voltageOverrides(cfg.Collectors.VoltageRails))
+	fs := cfg.Collectors.Filesystems
+	s.diskReader.Configure(disk.Filter{
+		IncludeTypes:       fs.IncludeTypes,
+		ExcludeTypes:       fs.ExcludeTypes,
+		IncludeMountpoints: fs.IncludeMountpoints,
+		ExcludeMountpoints: fs.ExcludeMountpoints,
+		IncludeDevices:     fs.IncludeDevices,
+		ExcludeDevices:     fs.ExcludeDevices,
+	})
+
+	disabled := make(map[string]bool)
+	for name, enabled := range map[string]bool{
+		metrics.CollectorCPU:     cfg.Collectors.CPU,
+		metrics.CollectorGPU:     cfg.Collectors.GPU,
+		metrics.CollectorMemory:  cfg.Collectors.Memory,
+		metrics.CollectorDisk:    cfg.Collectors.Disk,
+		metrics.CollectorTemps:   cfg.Collectors.Temps,
+		metrics.CollectorFans:    cfg.Collectors.Fans,
+		metrics.CollectorNetwork: cfg.Collectors.Network,
+		metrics.CollectorPower:   cfg.Collectors.Power,
+		metrics.CollectorVoltage: cfg.Collectors.Voltages,
+		metrics.CollectorProcess: cfg.Collectors.Processes,
+		metrics.CollectorCgroup:  cfg.Collectors.Cgroups,
+	} {
+		disabled[name] = !enabled
+	}
+	s.sampler.Configure(metrics.Config{
+		Interval:     cfg.Collectors.Interval.Std(),
+		Disabled:     disabled,
+		TopProcesses: cfg.Collectors.TopProcesses,
+	})
+	for _, failed := range s.alerts.Configure(cfg.Alerting) {
+		slog.Error("Alert notifier configuration failed; the rules still run without it", "notifier", failed.Name, "error", failed.Err)
+	}
+
+	gov := cfg.Safety.Governor
+	s.governor.Configure(governor.Config{
+		Enabled:         gov.Enabled,
+		Interval:        gov.Interval.Std(),
+		Margin:          gov.MarginCelsius,
+		DefaultCritical: gov.DefaultCriticalCelsius,
+		StepDelay:       gov.StepDelay.Std(),
+		PowerLimit:      gov.PowerLimitPercent,
+		Command:         gov.Command,
+		CommandTimeout:  gov.CommandTimeout.Std(),
+		RecoveryMargin:  gov.RecoveryMarginCelsius,
+		RecoveryDelay:   gov.RecoveryDelay.Std(),
+	})
+
+	stateFile := cfg.Energy.StateFile
+	if stateFile == "" {
+		stateFile = filepath.Join(platform.ConfigDir(), "energy.json")
+	}
+	s.energyMeter.Configure(power.MeterConfig{
+		Enabled:     cfg.Energy.Enabled,
+		Interval:    cfg.Energy.Interval.Std(),
+		PricePerKWh: cfg.Energy.PricePerKWh,
+		Currency:    cfg.Energy.Currency,
+		StateFile:   stateFile,
+	})
+
+	s.mqtt.Configure(cfg.Integrations.MQTT)
+	if err := s.display.Configure(cfg.Integrations.Display); err != nil {
+		slog.Error("Display pushing disabled", "error", err)
+	}
+
+	safety := cfg.Safety
+	s.overclockController.Configure(overclock.SafetyLimits{
+		MaxCoreClockOffset:   safety.MaxCoreClockOffset,
+		MaxMemoryClockOffset: safety.MaxMemoryClockOffset,
+		MaxPowerLimit:        safety.MaxPowerLimit,
+		MaxTempLimit:         safety.MaxTempLimit,
+		MaxVoltageOffset:     safety.MaxVoltageOffset,
+	}, overclock.WatchdogConfig{
+		PollInterval:   safety.Watchdog.PollInterval.Std(),
+		TempSpikeDelta: safety.Watchdog.TempSpikeDelta,
+		TempCeiling:    safety.Watchdog.TempCeiling,
+	})
+}
+
+// applyFanDefaults applies the startup fan settings from the configuration
+func (s *Server) applyFanDefaults(cfg *config.Config) {
+	for _, d := range cfg.Fans.Defaults {
+		ctx, cancel := s.requestContext()
+		if err := s.fanController.SetSettings(ctx, d.FanID, d.Settings()); err != nil {
+			slog.Warn("Failed to apply default fan settings", "fan", d.FanID, "error", err)
+		}
+		cancel()
+	}
+}
+
+// applySensorsConfig loads the lm-sensors configuration used for hwmon
+// labels, ignores and scaling. Statements it cannot parse are logged and
+// skipped, as the rest of the file still applies.
+func (s *Server) applySensorsConfig(options config.LMSensors) {
+	if !options.Enabled {
+		hwmon.SetConfig(nil)
+		return
+	}
+	files := options.Files
+	if len(files) == 0 {
+		files = hwmon.SystemConfigFiles()
+	}
+	sensors, err := hwmon.LoadConfig(files)
+	if err != nil {
+		slog.Warn("Problems in the lm-sensors configuration", "error", err)
+	}
+	hwmon.SetConfig(sensors)
+}
+
+// voltageOverrides converts the configured voltage rails; compute
+// expressions were checked when the configuration was validated
+func voltageOverrides(rails []config.VoltageRail) []voltage.Override {
+	overrides := make([]voltage.Override, 0, len(rails))
+	for _, rail := range rails {
+		override := voltage.Override{
+			Chip:   rail.Chip,
+			Input:  rail.Input,
+			Label:  rail.Label,
+			Min:    rail.Min,
+			Max:    rail.Max,
+			Ignore: rail.Ignore,
+		}
+		if rail.Compute != "" {
+			override.Compute, _ = hwmon.ParseExpr(rail.Compute)
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides
+}
+
+// requestContext returns a context bounded by the configured request timeout
+func (s *Server) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.currentConfig().Server.RequestTimeout.Std())
+}
+
+// corsOriginAllowed reports whether a browser origin may call the API
+func (s *Server) corsOriginAllowed(origin string) bool {
+	for _, allowed := range s.currentConfig().Server.CORSOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
 }
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown() error {
-	return s.app.Shutdown()
+	s.rulesEngine.Stop()
+	s.mqtt.Stop()
+	s.display.Stop()
+	s.external.Stop()
+	s.governor.Stop()
+	s.energyMeter.Stop()
+	s.sampler.Stop()
+	s.alerts.Stop()
+	err := s.app.Shutdown()
+	s.auditLog.Close()
+	return err
+}
+
+// StalledLoops describes the control loops that stopped running: the thermal
+// governor, fan curves, the rules engine and the metrics sampler. A loop
+// counts as stalled after missing three rounds plus the time one round may take.
+func (s *Server) StalledLoops() []string {
+	s.configMu.RLock()
+	cfg, configured := s.config, s.configured
+	s.configMu.RUnlock()
+
+	stalled := []string{}
+	check := func(name string, last *time.Time, interval, timeout time.Duration) {
+		since := configured
+		if last != nil && last.After(since) {
+			since = *last
+		}
+		if age := time.Since(since); age > 3*interval+timeout {
+			stalled = append(stalled, fmt.Sprintf("%s has not run for %s", name, age.Round(time.Second)))
+		}
+	}
+
+	if status := s.governor.GetStatus(); status.Enabled {
+		check("thermal governor", status.LastChecked, cfg.Safety.Governor.Interval.Std(), 10*time.Second)
+	}
+
+	var sampled *time.Time
+	if latest := s.sampler.Latest(); latest != nil {
+		sampled = &latest.Time
+	}
+	interval := cfg.Collectors.Interval.Std()
+	check("metrics sampler", sampled, interval, max(interval, 5*time.Second))
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+	if status, err := s.rulesEngine.GetStatus(ctx); err == nil && status.Enabled {
+		if rulesConfig, err := s.rulesEngine.GetConfig(ctx); err == nil {
+			check("rules engine", status.LastEvaluated, time.Duration(rulesConfig.Interval)*time.Second, 30*time.Second)
+		}
+	}
+
+	for _, id := range s.fanController.StalledCurves(3*cfg.Fans.CurveInterval.Std() + 10*time.Second) {
+		stalled = append(stalled, fmt.Sprintf("curve of fan %d has stopped", id))
+	}
+	return stalled
 }
 
 // Health check endpoint
 func (s *Server) healthCheck(c *fiber.Ctx) error {
+	status := "ok"
+	stalled := s.StalledLoops()
+	if len(stalled) > 0 {
+		status = "degraded"
+	}
+	thermal := s.governor.GetStatus()
+	if thermal.Engaged {
+		status = "thermal_protection"
+	}
+
+	return c.JSON(fiber.Map{
+		"status":        status,
+		"platform":      platform.GetOS(),
+		"timestamp":     time.Now().Unix(),
+		"governor":      thermal,
+		"stalled_loops": stalled,
+	})
+}
+
+// getMQTTStatus returns the MQTT connection state
+func (s *Server) getMQTTStatus(c *fiber.Ctx) error {
+	return c.JSON(s.mqtt.GetStatus())
+}
+
+// getDisplayStatus returns the display pusher state and its devices
+func (s *Server) getDisplayStatus(c *fiber.Ctx) error {
+	return c.JSON(s.display.GetStatus())
+}
+
+// getGovernor returns the thermal governor state and its events
+func (s *Server) getGovernor(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
-		"status":    "ok",
-		"platform":  platform.GetOS(),
-		"timestamp": time.Now().Unix(),
+		"status": s.governor.GetStatus(),
+		"events": s.governor.GetEvents(),
 	})
 }
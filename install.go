@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/config"
+	"github.com/CristiGvl/picoHWMon/internal/systemd"
+)
+
+// installService implements the install-service subcommand: it writes a
+// hardened systemd service for this binary, and a socket unit if asked to
+func installService(args []string) error {
+	flags := flag.NewFlagSet("install-service", flag.ExitOnError)
+	configPath := flags.String("config", "", "Configuration file the service loads")
+	dir := flags.String("dir", "/etc/systemd/system", "Directory the units are written to")
+	listen := flags.String("listen", "", "Also write a socket unit listening on this port or address (e.g. 8080 or 127.0.0.1:8080)")
+	watchdog := flags.Duration("watchdog", 30*time.Second, "Restart the service when its control loops stall for this long; 0 turns the watchdog off")
+	printOnly := flags.Bool("print", false, "Print the units instead of writing them")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s install-service [flags]\n\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("systemd services are only supported on Linux")
+	}
+	if *watchdog < 0 {
+		return fmt.Errorf("the watchdog timeout must not be negative")
+	}
+
+	binary, err := os.Executable()
+	if err == nil {
+		binary, err = filepath.EvalSymlinks(binary)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to locate the picoHWMon binary: %w", err)
+	}
+	if err := visibleToService(binary); err != nil {
+		return err
+	}
+	execStart := []string{binary}
+	var writable []string
+	if *configPath != "" {
+		path, err := filepath.Abs(*configPath)
+		if err != nil {
+			return err
+		}
+		if err := visibleToService(path); err != nil {
+			return err
+		}
+		cfg, err := config.Load(path)
+		if err != nil {
+			return err
+		}
+		if writable, err = writablePaths(cfg); err != nil {
+			return err
+		}
+		execStart = append(execStart, "--config", path)
+	}
+
+	unit := systemd.Unit{ExecStart: execStart, Listen: *listen, Watchdog: *watchdog, ReadWritePaths: writable}
+	files := []struct{ name, content string }{
+		{systemd.UnitName + ".service", unit.Service()},
+	}
+	if socket := unit.Socket(); socket != "" {
+		files = append(files, struct{ name, content string }{systemd.UnitName + ".socket", socket})
+	}
+
+	if *printOnly {
+		for _, file := range files {
+			fmt.Printf("# %s\n%s\n", filepath.Join(*dir, file.name), file.content)
+		}
+		return nil
+	}
+	// ReadWritePaths that don't exist keep the service from starting
+	for _, path := range writable {
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		path := filepath.Join(*dir, file.name)
+		if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+
+	enable := files[len(files)-1].name
+	fmt.Printf("\nStart it with:\n  systemctl daemon-reload\n  systemctl enable --now %s\n", enable)
+	return nil
+}
+
+// writablePaths returns the directories outside the state directory that the
+// configuration has the service write to
+func writablePaths(cfg *config.Config) ([]string, error) {
+	type setting struct{ name, dir string }
+	var settings []setting
+	if cfg.Overclock.ProfilesDir != "" {
+		// rules.json is kept next to the profiles directory
+		settings = append(settings, setting{"overclock.profiles_dir", filepath.Dir(filepath.Clean(cfg.Overclock.ProfilesDir))})
+	}
+	if cfg.Server.AuditDir != "" {
+		settings = append(settings, setting{"server.audit_dir", cfg.Server.AuditDir})
+	}
+	if cfg.Energy.StateFile != "" {
+		settings = append(settings, setting{"energy.state_file", filepath.Dir(cfg.Energy.StateFile)})
+	}
+	if tls := cfg.Server.TLS; tls.Enabled {
+		// A self-signed certificate is generated where the files are missing
+		if tls.CertFile != "" {
+			settings = append(settings, setting{"server.tls.cert_file", filepath.Dir(tls.CertFile)})
+		}
+		if tls.KeyFile != "" {
+			settings = append(settings, setting{"server.tls.key_file", filepath.Dir(tls.KeyFile)})
+		}
+	}
+
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, s := range settings {
+		if !filepath.IsAbs(s.dir) {
+			return nil, fmt.Errorf("%s: %s must be an absolute path for the service", s.name, s.dir)
+		}
+		dir := filepath.Clean(s.dir)
+		if err := visibleToService(dir); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.name, err)
+		}
+		if seen[dir] || dir == systemd.StateDirectory || strings.HasPrefix(dir, systemd.StateDirectory+"/") {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// visibleToService rejects paths that the unit's sandboxing hides
+func visibleToService(path string) error {
+	for prefix, setting := range map[string]string{
+		"/home/":     "ProtectHome",
+		"/root/":     "ProtectHome",
+		"/run/user/": "ProtectHome",
+		"/tmp/":      "PrivateTmp",
+		"/var/tmp/":  "PrivateTmp",
+	} {
+		if strings.HasPrefix(path, prefix) {
+			return fmt.Errorf("%s is hidden from the service by %s; move it to e.g. /usr/local/bin or /etc/picohwmon first", path, setting)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,559 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/config"
+	"github.com/CristiGvl/picoHWMon/internal/metrics"
+)
+
+// Alert states
+const (
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+const (
+	// maxHistory is the number of resolved alerts kept
+	maxHistory = 200
+	// defaultNotifyTimeout bounds a single notification delivery
+	defaultNotifyTimeout = 10 * time.Second
+)
+
+// ErrNotifierNotFound is returned when a notifier name does not exist
+var ErrNotifierNotFound = errors.New("notifier not found")
+
+// Alert is one rule matching one series
+type Alert struct {
+	ID           string            `json:"id"`
+	Rule         string            `json:"rule"`
+	Severity     string            `json:"severity"`
+	State        string            `json:"state"`
+	Metric       string            `json:"metric"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Value        *float64          `json:"value,omitempty"`
+	Summary      string            `json:"summary"`
+	Description  string            `json:"description,omitempty"`
+	ActiveSince  time.Time         `json:"active_since"`
+	FiredAt      *time.Time        `json:"fired_at,omitempty"`
+	ResolvedAt   *time.Time        `json:"resolved_at,omitempty"`
+	LastNotified *time.Time        `json:"last_notified,omitempty"`
+}
+
+// Notification is what notifiers deliver
+type Notification struct {
+	Status string    `json:"status"`
+	Host   string    `json:"host"`
+	Time   time.Time `json:"time"`
+	Alert  *Alert    `json:"alert"`
+	Test   bool      `json:"test,omitempty"`
+}
+
+// Notifier delivers notifications to one destination
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// NotifierStatus reports the outcome of the last deliveries of a notifier
+type NotifierStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	MinSeverity string     `json:"min_severity,omitempty"`
+	LastSent    *time.Time `json:"last_sent,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// notifierEntry pairs a configured notifier with its delivery status
+type notifierEntry struct {
+	config   config.Notifier
+	notifier Notifier
+	status   NotifierStatus
+}
+
+// point is one observation kept for rate rules
+type point struct {
+	time  time.Time
+	value float64
+}
+
+// Engine evaluates alert rules against metric snapshots and sends notifications
+type Engine struct {
+	mu        sync.Mutex
+	rules     []config.AlertRule
+	notifiers map[string]*notifierEntry
+	order     []string
+	active    map[string]*Alert
+	history   []*Alert
+	points    map[string][]point
+	host      string
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewEngine creates an alerting engine with no rules
+func NewEngine() *Engine {
+	host, _ := os.Hostname()
+	return &Engine{
+		notifiers: make(map[string]*notifierEntry),
+		active:    make(map[string]*Alert),
+		points:    make(map[string][]point),
+		host:      host,
+	}
+}
+
+// NotifierError is a notifier that could not be set up
+type NotifierError struct {
+	Name string
+	Err  error
+}
+
+// Error describes the notifier and why it failed
+func (e *NotifierError) Error() string {
+	return fmt.Sprintf("notifier %s: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *NotifierError) Unwrap() error {
+	return e.Err
+}
+
+// Configure replaces the rules and notifiers. Alerts of rules that still
+// exist keep their state; delivery status is kept for unchanged notifiers.
+// The rules always apply; notifiers that can't be set up are left out and
+// returned.
+func (e *Engine) Configure(cfg config.Alerting) []*NotifierError {
+	notifiers := make(map[string]*notifierEntry)
+	var order []string
+	var failed []*NotifierError
+	for _, nc := range cfg.Notifiers {
+		notifier, err := newNotifier(nc)
+		if err != nil {
+			failed = append(failed, &NotifierError{Name: nc.Name, Err: err})
+			continue
+		}
+		notifiers[nc.Name] = &notifierEntry{
+			config:   nc,
+			notifier: notifier,
+			status:   NotifierStatus{Name: nc.Name, Type: nc.Type, MinSeverity: nc.MinSeverity},
+		}
+		order = append(order, nc.Name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for name, entry := range notifiers {
+		if old, ok := e.notifiers[name]; ok && old.config.Type == entry.config.Type {
+			entry.status.LastSent = old.status.LastSent
+			entry.status.LastError = old.status.LastError
+			entry.status.LastErrorAt = old.status.LastErrorAt
+		}
+	}
+
+	rules := make(map[string]config.AlertRule)
+	for _, rule := range cfg.Rules {
+		rules[rule.Name] = rule
+	}
+	for key, alert := range e.active {
+		rule, ok := rules[alert.Rule]
+		if !ok || rule.Metric != alert.Metric {
+			delete(e.active, key)
+			continue
+		}
+		alert.Severity = rule.Severity
+		alert.Description = rule.Description
+	}
+	for key := range e.points {
+		name, _, _ := strings.Cut(key, "/")
+		if rule, ok := rules[name]; !ok || rule.Type != config.AlertRate {
+			delete(e.points, key)
+		}
+	}
+
+	e.rules = cfg.Rules
+	e.notifiers = notifiers
+	e.order = order
+	return failed
+}
+
+// Start evaluates every snapshot received on snapshots until the channel is
+// closed or Stop is called
+func (e *Engine) Start(snapshots <-chan *metrics.Snapshot) {
+	e.stop = make(chan struct{})
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case snapshot, ok := <-snapshots:
+				if !ok {
+					return
+				}
+				e.Evaluate(snapshot)
+			}
+		}
+	}()
+}
+
+// Stop stops evaluating and waits for pending notifications
+func (e *Engine) Stop() {
+	if e.stop != nil {
+		close(e.stop)
+	}
+	e.wg.Wait()
+}
+
+// Evaluate updates the alert states from a snapshot
+func (e *Engine) Evaluate(snapshot *metrics.Snapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := snapshot.Time
+	for i := range e.rules {
+		rule := &e.rules[i]
+
+		var matches []*metrics.Sample
+		for _, sample := range snapshot.Samples {
+			if sample.Metric == rule.Metric && labelsMatch(rule.Labels, sample.Labels) {
+				matches = append(matches, sample)
+			}
+		}
+
+		seen := make(map[string]bool)
+		switch rule.Type {
+		case config.AlertAbsent:
+			key := rule.Name
+			seen[key] = true
+			summary := fmt.Sprintf("no data for %s", selector(rule.Metric, rule.Labels))
+			if err, failed := snapshot.Errors[metrics.CollectorOf(rule.Metric)]; failed {
+				summary += ": " + err
+			}
+			e.update(rule, key, len(matches) == 0, nil, rule.Labels, summary, now)
+
+		case config.AlertThreshold:
+			for _, sample := range matches {
+				key := rule.Name + "/" + sample.Key()
+				seen[key] = true
+				value := sample.Value
+				summary := fmt.Sprintf("%s is %.4g (%s %g)", sample.Key(), value, rule.Operator, rule.Threshold)
+				e.update(rule, key, compare(value, rule.Operator, rule.Threshold), &value, sample.Labels, summary, now)
+			}
+
+		case config.AlertRate:
+			for _, sample := range matches {
+				key := rule.Name + "/" + sample.Key()
+				seen[key] = true
+				rate, ok := e.rate(key, rule.Window.Std(), now, sample.Value)
+				if !ok {
+					// Not enough history yet; keep the current state
+					continue
+				}
+				summary := fmt.Sprintf("%s changes by %.4g per minute over %s (%s %g)",
+					sample.Key(), rate, rule.Window.Std(), rule.Operator, rule.Threshold)
+				e.update(rule, key, compare(rate, rule.Operator, rule.Threshold), &rate, sample.Labels, summary, now)
+			}
+		}
+
+		// Series that are gone resolve, unless their collector failed this round
+		if _, failed := snapshot.Errors[metrics.CollectorOf(rule.Metric)]; failed {
+			continue
+		}
+		for key, alert := range e.active {
+			if alert.Rule == rule.Name && !seen[key] {
+				e.update(rule, key, false, nil, nil, "", now)
+			}
+		}
+		for key := range e.points {
+			if strings.HasPrefix(key, rule.Name+"/") && !seen[key] {
+				delete(e.points, key)
+			}
+		}
+	}
+}
+
+// update moves one alert through its states; the caller holds e.mu
+func (e *Engine) update(rule *config.AlertRule, key string, active bool, value *float64, labels map[string]string, summary string, now time.Time) {
+	alert := e.active[key]
+
+	if !active {
+		if alert == nil {
+			return
+		}
+		delete(e.active, key)
+		if alert.State != StateFiring {
+			// Pending alerts that never fired are dropped silently
+			return
+		}
+		alert.State = StateResolved
+		alert.ResolvedAt = &now
+		slog.Info("Alert resolved", "alert", alert.ID, "summary", alert.Summary)
+		e.history = append(e.history, alert)
+		if len(e.history) > maxHistory {
+			e.history = e.history[len(e.history)-maxHistory:]
+		}
+		e.notify(rule, alert, now)
+		return
+	}
+
+	if alert == nil {
+		alert = &Alert{
+			ID:          key,
+			Rule:        rule.Name,
+			Severity:    rule.Severity,
+			State:       StatePending,
+			Metric:      rule.Metric,
+			Labels:      labels,
+			Description: rule.Description,
+			ActiveSince: now,
+		}
+		e.active[key] = alert
+	}
+	alert.Value = value
+	alert.Summary = summary
+
+	switch alert.State {
+	case StatePending:
+		if now.Sub(alert.ActiveSince) >= rule.For.Std() {
+			alert.State = StateFiring
+			alert.FiredAt = &now
+			slog.Warn("Alert firing", "alert", alert.ID, "severity", alert.Severity, "summary", alert.Summary)
+			e.notify(rule, alert, now)
+		}
+	case StateFiring:
+		repeat := rule.RepeatInterval.Std()
+		if repeat > 0 && alert.LastNotified != nil && now.Sub(*alert.LastNotified) >= repeat {
+			e.notify(rule, alert, now)
+		}
+	}
+}
+
+// rate records a value and returns the change per minute over at least
+// window; the caller holds e.mu
+func (e *Engine) rate(key string, window time.Duration, now time.Time, value float64) (float64, bool) {
+	points := append(e.points[key], point{time: now, value: value})
+	// Keep the newest point that is at least window old as the anchor
+	for len(points) > 1 && !points[1].time.After(now.Add(-window)) {
+		points = points[1:]
+	}
+	e.points[key] = points
+
+	anchor := points[0]
+	elapsed := now.Sub(anchor.time)
+	if elapsed < window || elapsed <= 0 {
+		return 0, false
+	}
+	return (value - anchor.value) / elapsed.Minutes(), true
+}
+
+// notify sends an alert to the notifiers selected by its rule; the caller holds e.mu
+func (e *Engine) notify(rule *config.AlertRule, alert *Alert, now time.Time) {
+	alert.LastNotified = &now
+
+	notification := &Notification{
+		Status: alert.State,
+		Host:   e.host,
+		Time:   now,
+		Alert:  alert.clone(),
+	}
+
+	for _, name := range e.order {
+		entry := e.notifiers[name]
+		if len(rule.Notifiers) > 0 && !contains(rule.Notifiers, name) {
+			continue
+		}
+		if config.SeverityRank(alert.Severity) < config.SeverityRank(entry.config.MinSeverity) {
+			continue
+		}
+
+		e.wg.Add(1)
+		go func(entry *notifierEntry) {
+			defer e.wg.Done()
+			err := e.deliver(context.Background(), entry, notification)
+			if err != nil {
+				slog.Error("Alert notifier failed", "notifier", entry.config.Name, "error", err)
+			}
+		}(entry)
+	}
+}
+
+// deliver sends one notification and records the outcome
+func (e *Engine) deliver(ctx context.Context, entry *notifierEntry, notification *Notification) error {
+	timeout := entry.config.Timeout.Std()
+	if timeout <= 0 {
+		timeout = defaultNotifyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := entry.notifier.Notify(ctx, notification)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	if err != nil {
+		entry.status.LastError = err.Error()
+		entry.status.LastErrorAt = &now
+	} else {
+		entry.status.LastSent = &now
+	}
+	return err
+}
+
+// TestNotifier sends a test notification through the named notifier
+func (e *Engine) TestNotifier(ctx context.Context, name string) error {
+	e.mu.Lock()
+	entry, ok := e.notifiers[name]
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotifierNotFound, name)
+	}
+
+	now := time.Now()
+	value := 0.0
+	notification := &Notification{
+		Status: StateFiring,
+		Host:   e.host,
+		Time:   now,
+		Test:   true,
+		Alert: &Alert{
+			ID:          "test",
+			Rule:        "test",
+			Severity:    config.SeverityInfo,
+			State:       StateFiring,
+			Metric:      "test",
+			Value:       &value,
+			Summary:     "picoHWMon test notification",
+			ActiveSince: now,
+			FiredAt:     &now,
+		},
+	}
+	return e.deliver(ctx, entry, notification)
+}
+
+// Alerts returns the pending and firing alerts, firing and most severe first
+func (e *Engine) Alerts() []*Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]*Alert, 0, len(e.active))
+	for _, alert := range e.active {
+		alerts = append(alerts, alert.clone())
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if a.State != b.State {
+			return a.State == StateFiring
+		}
+		if ra, rb := config.SeverityRank(a.Severity), config.SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		return a.ID < b.ID
+	})
+	return alerts
+}
+
+// History returns resolved alerts, newest first
+func (e *Engine) History(limit int) []*Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := []*Alert{}
+	for i := len(e.history) - 1; i >= 0; i-- {
+		alerts = append(alerts, e.history[i].clone())
+		if limit > 0 && len(alerts) >= limit {
+			break
+		}
+	}
+	return alerts
+}
+
+// Rules returns the configured alert rules
+func (e *Engine) Rules() []config.AlertRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := make([]config.AlertRule, len(e.rules))
+	copy(rules, e.rules)
+	return rules
+}
+
+// Notifiers returns the delivery status of every notifier
+func (e *Engine) Notifiers() []NotifierStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	statuses := make([]NotifierStatus, 0, len(e.order))
+	for _, name := range e.order {
+		statuses = append(statuses, e.notifiers[name].status)
+	}
+	return statuses
+}
+
+// clone returns a copy that is safe to hand out while the engine keeps updating the original
+func (a *Alert) clone() *Alert {
+	c := *a
+	if a.Value != nil {
+		value := *a.Value
+		c.Value = &value
+	}
+	return &c
+}
+
+// compare applies a rule operator
+func compare(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	default:
+		return false
+	}
+}
+
+// labelsMatch reports whether every selector label has the same value in labels
+func labelsMatch(selector, labels map[string]string) bool {
+	for name, value := range selector {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// selector formats a metric name with label selectors
+func selector(metric string, labels map[string]string) string {
+	sample := &metrics.Sample{Metric: metric, Labels: labels}
+	return sample.Key()
+}
+
+// contains reports whether list contains value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/config"
+)
+
+// defaultSMTPPort is the submission port used when none is configured
+const defaultSMTPPort = 587
+
+// newNotifier creates the notifier for a validated configuration
+func newNotifier(nc config.Notifier) (Notifier, error) {
+	switch nc.Type {
+	case config.NotifierWebhook:
+		return &webhookNotifier{url: nc.URL, headers: nc.Headers}, nil
+	case config.NotifierPico:
+		return &picoNotifier{url: nc.URL}, nil
+	case config.NotifierSMTP:
+		return &smtpNotifier{config: nc.SMTP}, nil
+	case config.NotifierCommand:
+		return &commandNotifier{command: nc.Command}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", nc.Type)
+	}
+}
+
+// webhookNotifier posts the notification as JSON
+type webhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+// Notify posts the notification
+func (n *webhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.url, n.headers, body)
+}
+
+// picoNotifier posts a compact alert to the display board, which has little
+// memory for parsing
+type picoNotifier struct {
+	url string
+}
+
+// picoAlert is the payload understood by the board's /api/alert route
+type picoAlert struct {
+	Status   string   `json:"status"`
+	Severity string   `json:"severity"`
+	Rule     string   `json:"rule"`
+	Summary  string   `json:"summary"`
+	Value    *float64 `json:"value,omitempty"`
+}
+
+// Notify posts the compact alert
+func (n *picoNotifier) Notify(ctx context.Context, notification *Notification) error {
+	alert := notification.Alert
+	summary := alert.Summary
+	if len(summary) > 120 {
+		summary = summary[:117] + "..."
+	}
+	body, err := json.Marshal(&picoAlert{
+		Status:   notification.Status,
+		Severity: alert.Severity,
+		Rule:     alert.Rule,
+		Summary:  summary,
+		Value:    alert.Value,
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.url, nil, body)
+}
+
+// postJSON sends a JSON body and treats non-2xx responses as errors
+func postJSON(ctx context.Context, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "picoHWMon")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier sends an e-mail, upgrading to TLS when the server offers STARTTLS
+type smtpNotifier struct {
+	config config.SMTP
+}
+
+// Notify sends the e-mail
+func (n *smtpNotifier) Notify(ctx context.Context, notification *Notification) error {
+	port := n.config.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	address := net.JoinHostPort(n.config.Host, strconv.Itoa(port))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.config.Host}); err != nil {
+			return err
+		}
+	}
+	if n.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.config.From); err != nil {
+		return err
+	}
+	for _, to := range n.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(notification)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message formats the e-mail headers and body
+func (n *smtpNotifier) message(notification *Notification) []byte {
+	alert := notification.Alert
+	subject := fmt.Sprintf("[%s] %s %s on %s", strings.ToUpper(notification.Status), alert.Severity, alert.Rule, notification.Host)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&b, "%s\r\n\r\n", alert.Summary)
+	if alert.Description != "" {
+		fmt.Fprintf(&b, "%s\r\n\r\n", alert.Description)
+	}
+	fmt.Fprintf(&b, "Rule:     %s\r\nSeverity: %s\r\nState:    %s\r\nHost:     %s\r\n", alert.Rule, alert.Severity, notification.Status, notification.Host)
+	fmt.Fprintf(&b, "Since:    %s\r\n", alert.ActiveSince.Format(time.RFC3339))
+	if alert.ResolvedAt != nil {
+		fmt.Fprintf(&b, "Resolved: %s\r\n", alert.ResolvedAt.Format(time.RFC3339))
+	}
+	return []byte(b.String())
+}
+
+// commandNotifier runs a local program with the notification as JSON on
+// stdin and the main fields in PICOHWMON_ALERT_* environment variables
+type commandNotifier struct {
+	command []string
+}
+
+// Notify runs the command
+func (n *commandNotifier) Notify(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	alert := notification.Alert
+	value := ""
+	if alert.Value != nil {
+		value = strconv.FormatFloat(*alert.Value, 'g', -1, 64)
+	}
+
+	cmd := exec.CommandContext(ctx, n.command[0], n.command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"PICOHWMON_ALERT_STATUS="+notification.Status,
+		"PICOHWMON_ALERT_RULE="+alert.Rule,
+		"PICOHWMON_ALERT_SEVERITY="+alert.Severity,
+		"PICOHWMON_ALERT_SUMMARY="+alert.Summary,
+		"PICOHWMON_ALERT_VALUE="+value,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		message := strings.TrimSpace(string(output))
+		if len(message) > 200 {
+			message = message[:200]
+		}
+		if message != "" {
+			return fmt.Errorf("%v: %s", err, message)
+		}
+		return err
+	}
+	return nil
+}
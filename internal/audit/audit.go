@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result values recorded for control actions
+const (
+	ResultSuccess = "success"
+	ResultPartial = "partial"
+	ResultFailure = "failure"
+	ResultDenied  = "denied"
+)
+
+const (
+	// DefaultMaxSize is the size at which the active log file is rotated
+	DefaultMaxSize = 10 * 1024 * 1024
+	// DefaultMaxFiles is the number of rotated files kept besides the active one
+	DefaultMaxFiles = 10
+
+	activeFile    = "audit.jsonl"
+	rotatedPrefix = "audit-"
+)
+
+// Entry represents one audited control action
+type Entry struct {
+	Time       time.Time       `json:"time"`
+	ClientAddr string          `json:"client_addr"`
+	Identity   string          `json:"identity"`
+	AuthMethod string          `json:"auth_method,omitempty"`
+	Method     string          `json:"method"`
+	Endpoint   string          `json:"endpoint"`
+	Target     string          `json:"target,omitempty"`
+	Previous   json.RawMessage `json:"previous,omitempty"`
+	New        json.RawMessage `json:"new,omitempty"`
+	Result     string          `json:"result"`
+	Status     int             `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Warnings   []string        `json:"warnings,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	// Suppressed counts the denied requests from the same client that
+	// were left out since the previous entry
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// Filter selects entries in Query. Zero fields match everything.
+type Filter struct {
+	Since    time.Time
+	Until    time.Time
+	Identity string
+	// Endpoint matches entries whose endpoint starts with this prefix
+	Endpoint string
+	Target   string
+	Result   string
+	Limit    int
+}
+
+// Log is an append-only JSONL audit log that rotates by size
+type Log struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// Open opens or creates the audit log in dir
+func Open(dir string, maxSize int64, maxFiles int) (*Log, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	l := &Log{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.openActive(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record appends an entry to the log
+func (l *Log) Record(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// Query returns matching entries, newest first
+func (l *Log) Query(filter Filter) ([]*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := l.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filepath.Join(l.dir, activeFile))
+
+	entries := []*Entry{}
+	// Walk newest file first so the limit can stop early
+	for i := len(files) - 1; i >= 0; i-- {
+		matches, err := readEntries(files[i], filter)
+		if err != nil {
+			return nil, err
+		}
+		for j := len(matches) - 1; j >= 0; j-- {
+			entries = append(entries, matches[j])
+			if filter.Limit > 0 && len(entries) >= filter.Limit {
+				return entries, nil
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// Close closes the active log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// openActive opens the active log file for appending
+func (l *Log) openActive() error {
+	file, err := os.OpenFile(filepath.Join(l.dir, activeFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// rotate renames the active file with a timestamp and prunes old files; the caller holds l.mu
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+
+	rotated := filepath.Join(l.dir, rotatedPrefix+time.Now().UTC().Format("20060102T150405.000000000")+".jsonl")
+	if err := os.Rename(filepath.Join(l.dir, activeFile), rotated); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	files, err := l.rotatedFiles()
+	if err == nil && len(files) > l.maxFiles {
+		for _, old := range files[:len(files)-l.maxFiles] {
+			os.Remove(old)
+		}
+	}
+
+	return l.openActive()
+}
+
+// rotatedFiles returns rotated log files, oldest first
+func (l *Log) rotatedFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(l.dir, rotatedPrefix+"*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readEntries reads the entries of one file that match the filter, in file order
+func readEntries(path string, filter Filter) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // Skip a torn last line after a crash
+		}
+		if filter.matches(&entry) {
+			entries = append(entries, &entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+// matches reports whether an entry passes the filter
+func (f Filter) matches(entry *Entry) bool {
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Time.After(f.Until) {
+		return false
+	}
+	if f.Identity != "" && entry.Identity != f.Identity {
+		return false
+	}
+	if f.Endpoint != "" && !strings.HasPrefix(entry.Endpoint, f.Endpoint) {
+		return false
+	}
+	if f.Target != "" && entry.Target != f.Target {
+		return false
+	}
+	if f.Result != "" && entry.Result != f.Result {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role represents what an API client is allowed to do
+type Role string
+
+const (
+	// RoleRead allows reading telemetry, settings and logs
+	RoleRead Role = "read"
+	// RoleAdmin additionally allows fan, overclock, profile and rule changes
+	RoleAdmin Role = "admin"
+	// RoleSensor only allows pushing external sensor readings
+	RoleSensor Role = "sensor"
+)
+
+// MaxClockSkew is how far an HMAC request timestamp may deviate from the server clock
+const MaxClockSkew = 5 * time.Minute
+
+const (
+	// minNonceLength and maxNonceLength bound the nonce of a signed request
+	minNonceLength = 16
+	maxNonceLength = 128
+	// maxNonces bounds the nonces remembered within the clock skew window
+	maxNonces = 100000
+)
+
+// Authentication methods reported in Identity.Method
+const (
+	MethodBearer     = "bearer"
+	MethodHMAC       = "hmac"
+	MethodClientCert = "client-cert"
+	MethodAnonymous  = "anonymous"
+)
+
+// ErrUnauthorized is returned when credentials are missing or invalid
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Allows reports whether the role grants the required role
+func (r Role) Allows(required Role) bool {
+	switch r {
+	case RoleAdmin:
+		return true
+	case RoleRead, RoleSensor:
+		return required == r
+	default:
+		return false
+	}
+}
+
+// Identity describes an authenticated API client
+type Identity struct {
+	Name   string `json:"name"`
+	Role   Role   `json:"role"`
+	Method string `json:"method"`
+	// Sources restricts the external sensor sources a client may push to
+	Sources []string `json:"sources,omitempty"`
+}
+
+// AllowsSource reports whether the client may push readings for source
+func (i *Identity) AllowsSource(source string) bool {
+	if len(i.Sources) == 0 {
+		return true
+	}
+	for _, allowed := range i.Sources {
+		if allowed == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Token represents a configured API credential. Bearer clients present the
+// token itself, which may be stored as plaintext or as its SHA-256 hex digest.
+// HMAC clients sign requests with Secret and identify themselves by Name.
+// Mutual TLS clients are matched by the common name of their certificate.
+// Sensor tokens may be limited to the external sensor sources they push.
+type Token struct {
+	Name         string   `json:"name"`
+	Role         Role     `json:"role"`
+	Token        string   `json:"token,omitempty"`
+	TokenSHA256  string   `json:"token_sha256,omitempty"`
+	Secret       string   `json:"hmac_secret,omitempty"`
+	ClientCertCN string   `json:"client_cert_cn,omitempty"`
+	Sources      []string `json:"sources,omitempty"`
+}
+
+// identity returns the identity of a client authenticated with the token
+func (t *Token) identity(method string) *Identity {
+	return &Identity{Name: t.Name, Role: t.Role, Method: method, Sources: t.Sources}
+}
+
+// TokenFile is the on-disk format of the tokens file
+type TokenFile struct {
+	Tokens []*Token `json:"tokens"`
+}
+
+// Store holds the configured API tokens
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	tokens []*Token
+
+	// nonces maps the token name and nonce of accepted signed requests to
+	// when their timestamp leaves the clock skew window
+	noncesMu sync.Mutex
+	nonces   map[string]time.Time
+}
+
+// LoadStore reads tokens from path. An empty path yields a store with no
+// tokens, in which case authentication is not configured.
+func LoadStore(path string) (*Store, error) {
+	store := &Store{path: path}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Reload re-reads the tokens file
+func (s *Store) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read tokens file: %w", err)
+	}
+
+	var file TokenFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse tokens file %s: %w", s.path, err)
+	}
+	if err := validateTokens(file.Tokens); err != nil {
+		return fmt.Errorf("invalid tokens file %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	s.tokens = file.Tokens
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Enabled reports whether any tokens are configured
+func (s *Store) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.tokens) > 0
+}
+
+// AuthenticateBearer returns the identity of a bearer token
+func (s *Store) AuthenticateBearer(presented string) (*Identity, error) {
+	if presented == "" {
+		return nil, ErrUnauthorized
+	}
+	digest := sha256.Sum256([]byte(presented))
+	presentedHash := hex.EncodeToString(digest[:])
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Compare against every token so timing doesn't reveal which one matched
+	var match *Token
+	for _, token := range s.tokens {
+		ok := false
+		if token.TokenSHA256 != "" {
+			ok = subtle.ConstantTimeCompare([]byte(strings.ToLower(token.TokenSHA256)), []byte(presentedHash)) == 1
+		} else if token.Token != "" {
+			ok = subtle.ConstantTimeCompare([]byte(token.Token), []byte(presented)) == 1
+		}
+		if ok && match == nil {
+			match = token
+		}
+	}
+
+	if match == nil {
+		return nil, ErrUnauthorized
+	}
+	return match.identity(MethodBearer), nil
+}
+
+// AuthenticateHMAC verifies a signed request. The signature is the hex
+// HMAC-SHA256 of the string produced by SigningString. A nonce is only
+// accepted once while its timestamp is within the clock skew, so a captured
+// request can't be replayed.
+func (s *Store) AuthenticateHMAC(name, timestamp, nonce, signature, method, uri string, body []byte, now time.Time) (*Identity, error) {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp", ErrUnauthorized)
+	}
+	skew := now.Sub(time.Unix(seconds, 0))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return nil, fmt.Errorf("%w: timestamp outside the allowed %s clock skew", ErrUnauthorized, MaxClockSkew)
+	}
+	if len(nonce) < minNonceLength || len(nonce) > maxNonceLength || strings.ContainsAny(nonce, "\r\n") {
+		return nil, fmt.Errorf("%w: the nonce must be %d to %d characters on one line", ErrUnauthorized, minNonceLength, maxNonceLength)
+	}
+
+	presented, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature encoding", ErrUnauthorized)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.Name != name || token.Secret == "" {
+			continue
+		}
+		mac := hmac.New(sha256.New, []byte(token.Secret))
+		mac.Write([]byte(SigningString(method, uri, timestamp, nonce, body)))
+		if !hmac.Equal(mac.Sum(nil), presented) {
+			break
+		}
+		if err := s.useNonce(name, nonce, time.Unix(seconds, 0).Add(MaxClockSkew), now); err != nil {
+			return nil, err
+		}
+		return token.identity(MethodHMAC), nil
+	}
+
+	return nil, ErrUnauthorized
+}
+
+// useNonce remembers the nonce of a signed request until expires, and fails
+// if it was seen before
+func (s *Store) useNonce(name, nonce string, expires, now time.Time) error {
+	s.noncesMu.Lock()
+	defer s.noncesMu.Unlock()
+
+	if s.nonces == nil {
+		s.nonces = make(map[string]time.Time)
+	}
+	key := name + "\n" + nonce
+	if seen, ok := s.nonces[key]; ok && now.Before(seen) {
+		return fmt.Errorf("%w: the nonce was already used", ErrUnauthorized)
+	}
+	if len(s.nonces) >= maxNonces {
+		for k, seen := range s.nonces {
+			if !now.Before(seen) {
+				delete(s.nonces, k)
+			}
+		}
+		if len(s.nonces) >= maxNonces {
+			return fmt.Errorf("%w: too many signed requests", ErrUnauthorized)
+		}
+	}
+	s.nonces[key] = expires
+	return nil
+}
+
+// AuthenticateClientCert returns the identity mapped to a verified client
+// certificate common name
+func (s *Store) AuthenticateClientCert(commonName string) (*Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.ClientCertCN != "" && token.ClientCertCN == commonName {
+			return token.identity(MethodClientCert), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: client certificate %q is not mapped to a role", ErrUnauthorized, commonName)
+}
+
+// SigningString builds the string HMAC clients sign:
+// METHOD \n request URI (path and query) \n unix timestamp \n nonce \n hex SHA-256 of the body
+func SigningString(method, uri, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{strings.ToUpper(method), uri, timestamp, nonce, hex.EncodeToString(bodyHash[:])}, "\n")
+}
+
+// Sign returns the hex HMAC-SHA256 signature for a request
+func Sign(secret, method, uri, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(SigningString(method, uri, timestamp, nonce, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateTokens checks names, roles and that every token has a credential
+func validateTokens(tokens []*Token) error {
+	names := make(map[string]bool)
+	for i, token := range tokens {
+		if token == nil {
+			return fmt.Errorf("token %d is null", i)
+		}
+		if token.Name == "" {
+			return fmt.Errorf("token %d has no name", i)
+		}
+		if names[token.Name] {
+			return fmt.Errorf("duplicate token name %q", token.Name)
+		}
+		names[token.Name] = true
+
+		if token.Role != RoleRead && token.Role != RoleAdmin && token.Role != RoleSensor {
+			return fmt.Errorf("token %q: role must be %q, %q or %q", token.Name, RoleRead, RoleAdmin, RoleSensor)
+		}
+		if len(token.Sources) > 0 && token.Role != RoleSensor {
+			return fmt.Errorf("token %q: sources only apply to the %q role", token.Name, RoleSensor)
+		}
+		if token.Token == "" && token.TokenSHA256 == "" && token.Secret == "" && token.ClientCertCN == "" {
+			return fmt.Errorf("token %q: needs token, token_sha256, hmac_secret or client_cert_cn", token.Name)
+		}
+		if token.Token != "" && len(token.Token) < 16 {
+			return fmt.Errorf("token %q: tokens must be at least 16 characters", token.Name)
+		}
+		if token.TokenSHA256 != "" {
+			if decoded, err := hex.DecodeString(token.TokenSHA256); err != nil || len(decoded) != sha256.Size {
+				return fmt.Errorf("token %q: token_sha256 must be a hex SHA-256 digest", token.Name)
+			}
+		}
+		if token.Secret != "" && len(token.Secret) < 16 {
+			return fmt.Errorf("token %q: HMAC secrets must be at least 16 characters", token.Name)
+		}
+	}
+	return nil
+}
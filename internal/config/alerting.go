@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+)
+
+// Alert rule types
+const (
+	AlertThreshold = "threshold"
+	AlertRate      = "rate"
+	AlertAbsent    = "absent"
+)
+
+// Alert severities, in increasing order
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Notifier types
+const (
+	NotifierWebhook = "webhook"
+	NotifierSMTP    = "smtp"
+	NotifierCommand = "command"
+	NotifierPico    = "pico"
+)
+
+var (
+	alertNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	metricPattern    = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	alertOperators   = map[string]bool{">": true, ">=": true, "<": true, "<=": true, "==": true, "!=": true}
+	severityRanks    = map[string]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+)
+
+// SeverityRank orders severities; unknown severities rank lowest
+func SeverityRank(severity string) int {
+	return severityRanks[severity]
+}
+
+// Alerting configures alert rules and where notifications go
+type Alerting struct {
+	Rules     []AlertRule `yaml:"rules" json:"rules"`
+	Notifiers []Notifier  `yaml:"notifiers" json:"notifiers"`
+}
+
+// AlertRule raises an alert for every series of Metric (narrowed by Labels)
+// whose condition has held for For.
+//
+// Threshold rules compare the latest value with Threshold. Rate rules compare
+// the change per minute over Window. Absent rules fire when no matching
+// series has been collected.
+type AlertRule struct {
+	Name        string            `yaml:"name" json:"name"`
+	Type        string            `yaml:"type" json:"type"`
+	Metric      string            `yaml:"metric" json:"metric"`
+	Labels      map[string]string `yaml:"labels" json:"labels,omitempty"`
+	Operator    string            `yaml:"operator" json:"operator,omitempty"`
+	Threshold   float64           `yaml:"threshold" json:"threshold"`
+	Window      Duration          `yaml:"window" json:"window,omitempty"`
+	For         Duration          `yaml:"for" json:"for"`
+	Severity    string            `yaml:"severity" json:"severity"`
+	Description string            `yaml:"description" json:"description,omitempty"`
+	// Notifiers restricts notifications to the named notifiers; empty means all
+	Notifiers []string `yaml:"notifiers" json:"notifiers,omitempty"`
+	// RepeatInterval re-sends firing notifications; zero sends them once
+	RepeatInterval Duration `yaml:"repeat_interval" json:"repeat_interval,omitempty"`
+}
+
+// Notifier delivers alert notifications
+type Notifier struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"`
+	// MinSeverity skips alerts below this severity
+	MinSeverity string `yaml:"min_severity" json:"min_severity,omitempty"`
+
+	// URL is the webhook endpoint, or the alert endpoint of the Pico board
+	URL     string            `yaml:"url" json:"url,omitempty"`
+	Headers map[string]string `yaml:"headers" json:"-"`
+
+	SMTP SMTP `yaml:"smtp" json:"smtp"`
+
+	// Command is run with the notification as JSON on stdin
+	Command []string `yaml:"command" json:"command,omitempty"`
+
+	Timeout Duration `yaml:"timeout" json:"timeout,omitempty"`
+}
+
+// SMTP configures an e-mail notifier
+type SMTP struct {
+	Host string `yaml:"host" json:"host,omitempty"`
+	// Port defaults to 587; STARTTLS is used when the server offers it
+	Port     int      `yaml:"port" json:"port,omitempty"`
+	Username string   `yaml:"username" json:"username,omitempty"`
+	Password string   `yaml:"password" json:"-"`
+	From     string   `yaml:"from" json:"from,omitempty"`
+	To       []string `yaml:"to" json:"to,omitempty"`
+}
+
+// validate reports the problems of the alerting section
+func (a *Alerting) validate(check func(ok bool, format string, args ...interface{})) {
+	notifiers := make(map[string]bool)
+	for i, n := range a.Notifiers {
+		field := fmt.Sprintf("alerting.notifiers[%d]", i)
+		check(alertNamePattern.MatchString(n.Name), "%s.name: %q must be up to 64 letters, digits, '_' or '-'", field, n.Name)
+		check(!notifiers[n.Name], "%s.name: notifier %q is defined twice", field, n.Name)
+		notifiers[n.Name] = true
+
+		_, knownSeverity := severityRanks[n.MinSeverity]
+		check(n.MinSeverity == "" || knownSeverity, "%s.min_severity: must be info, warning or critical", field)
+		check(n.Timeout >= 0, "%s.timeout: cannot be negative", field)
+
+		switch n.Type {
+		case NotifierWebhook, NotifierPico:
+			u, err := url.Parse(n.URL)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+				"%s.url: must be an http:// or https:// URL", field)
+		case NotifierSMTP:
+			check(n.SMTP.Host != "", "%s.smtp.host: is required", field)
+			check(n.SMTP.Port >= 0 && n.SMTP.Port <= 65535, "%s.smtp.port: must be between 1 and 65535 (0 = 587)", field)
+			_, err := mail.ParseAddress(n.SMTP.From)
+			check(err == nil, "%s.smtp.from: %q is not an e-mail address", field, n.SMTP.From)
+			check(len(n.SMTP.To) > 0, "%s.smtp.to: needs at least one recipient", field)
+			for _, to := range n.SMTP.To {
+				_, err := mail.ParseAddress(to)
+				check(err == nil, "%s.smtp.to: %q is not an e-mail address", field, to)
+			}
+		case NotifierCommand:
+			check(len(n.Command) > 0 && n.Command[0] != "", "%s.command: needs the program to run", field)
+		default:
+			check(false, "%s.type: must be webhook, smtp, command or pico", field)
+		}
+	}
+
+	rules := make(map[string]bool)
+	for i, r := range a.Rules {
+		field := fmt.Sprintf("alerting.rules[%d]", i)
+		check(alertNamePattern.MatchString(r.Name), "%s.name: %q must be up to 64 letters, digits, '_' or '-'", field, r.Name)
+		check(!rules[r.Name], "%s.name: rule %q is defined twice", field, r.Name)
+		rules[r.Name] = true
+
+		check(metricPattern.MatchString(r.Metric), "%s.metric: %q is not a metric name", field, r.Metric)
+		check(r.For >= 0, "%s.for: cannot be negative", field)
+		check(r.RepeatInterval >= 0, "%s.repeat_interval: cannot be negative", field)
+		_, ok := severityRanks[r.Severity]
+		check(ok, "%s.severity: must be info, warning or critical", field)
+
+		switch r.Type {
+		case AlertThreshold:
+			check(alertOperators[r.Operator], "%s.operator: must be one of >, >=, <, <=, ==, !=", field)
+		case AlertRate:
+			check(alertOperators[r.Operator], "%s.operator: must be one of >, >=, <, <=, ==, !=", field)
+			check(r.Window.Std() > 0, "%s.window: rate rules need a window, e.g. 1m", field)
+		case AlertAbsent:
+			check(r.Operator == "", "%s.operator: absent rules don't take an operator", field)
+		default:
+			check(false, "%s.type: must be threshold, rate or absent", field)
+		}
+
+		for _, name := range r.Notifiers {
+			check(notifiers[name], "%s.notifiers: unknown notifier %q", field, name)
+		}
+	}
+}
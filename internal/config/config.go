@@ -0,0 +1,533 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/hwmon"
+	"gopkg.in/yaml.v3"
+)
+
+// voltageInput matches the voltage channels of hwmon chips
+var voltageInput = regexp.MustCompile(`^in\d+$`)
+
+// Duration is a time.Duration written as a Go duration string ("10s", "2m")
+// in both the config file and the API
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q (use e.g. 500ms, 10s, 2m)", node.Line, value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalYAML writes the duration as a string
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Std returns the duration as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// Config is the picoHWMon configuration file
+type Config struct {
+	Server       Server       `yaml:"server" json:"server"`
+	Collectors   Collectors   `yaml:"collectors" json:"collectors"`
+	Fans         Fans         `yaml:"fans" json:"fans"`
+	Safety       Safety       `yaml:"safety" json:"safety"`
+	Overclock    Overclock    `yaml:"overclock" json:"overclock"`
+	Alerting     Alerting     `yaml:"alerting" json:"alerting"`
+	Energy       Energy       `yaml:"energy" json:"energy"`
+	Integrations Integrations `yaml:"integrations" json:"integrations"`
+}
+
+// Server configures the HTTP API
+type Server struct {
+	Bind           string   `yaml:"bind" json:"bind"`
+	Port           int      `yaml:"port" json:"port"`
+	RequestTimeout Duration `yaml:"request_timeout" json:"request_timeout"`
+	TokensFile     string   `yaml:"tokens_file" json:"tokens_file"`
+	CORSOrigins    []string `yaml:"cors_origins" json:"cors_origins"`
+	// Hosts lists the host names clients may reach the API by, besides
+	// localhost and IP addresses
+	Hosts    []string `yaml:"hosts" json:"hosts"`
+	AuditDir string   `yaml:"audit_dir" json:"audit_dir"`
+	TLS      TLS      `yaml:"tls" json:"tls"`
+}
+
+// TLS configures HTTPS and mutual TLS
+type TLS struct {
+	Enabled           bool   `yaml:"enabled" json:"enabled"`
+	CertFile          string `yaml:"cert_file" json:"cert_file"`
+	KeyFile           string `yaml:"key_file" json:"key_file"`
+	ClientCAFile      string `yaml:"client_ca_file" json:"client_ca_file"`
+	RequireClientCert bool   `yaml:"require_client_cert" json:"require_client_cert"`
+}
+
+// Collectors configures metric collection. Disabled collectors are neither
+// sampled nor served by their endpoint.
+type Collectors struct {
+	// Interval is how often metrics are sampled for alerting and integrations
+	Interval Duration `yaml:"interval" json:"interval"`
+
+	CPU    bool `yaml:"cpu" json:"cpu"`
+	GPU    bool `yaml:"gpu" json:"gpu"`
+	Memory bool `yaml:"memory" json:"memory"`
+	Disk   bool `yaml:"disk" json:"disk"`
+	Temps  bool `yaml:"temps" json:"temps"`
+	// Fans covers fan speed sampling only; fan control is always available
+	Fans    bool `yaml:"fans" json:"fans"`
+	Network bool `yaml:"network" json:"network"`
+	// Power covers RAPL, hwmon power sensors and batteries; energy metering is configured separately
+	Power bool `yaml:"power" json:"power"`
+	// Voltages covers the voltage rails of hwmon chips
+	Voltages bool `yaml:"voltages" json:"voltages"`
+	// Processes covers per-process CPU, memory, disk I/O and GPU use
+	Processes bool `yaml:"processes" json:"processes"`
+	// TopProcesses is how many process names the sampler reports per resource (0 = only the count)
+	TopProcesses int `yaml:"top_processes" json:"top_processes"`
+	// Cgroups covers the cgroup v2 limits and usage of the container picoHWMon
+	// runs in and the per-cgroup breakdown
+	Cgroups bool `yaml:"cgroups" json:"cgroups"`
+
+	// Filesystems selects the filesystems reported by the disk collector
+	Filesystems Filesystems `yaml:"filesystems" json:"filesystems"`
+
+	// Interfaces selects the network interfaces that are reported
+	Interfaces Interfaces `yaml:"interfaces" json:"interfaces"`
+
+	// LMSensors applies an lm-sensors configuration to temps, fans and voltages
+	LMSensors LMSensors `yaml:"lm_sensors" json:"lm_sensors"`
+
+	// VoltageRails labels, scales, limits or hides voltage rails
+	VoltageRails []VoltageRail `yaml:"voltage_rails" json:"voltage_rails,omitempty"`
+
+	// External lists temperature sources outside this machine, e.g. the Pico thermistors
+	External []ExternalSource `yaml:"external" json:"external"`
+}
+
+// Filesystems holds include and exclude rules for filesystems. Empty include
+// lists match everything and any exclude match drops a filesystem. Patterns
+// use shell glob syntax; a mountpoint pattern also matches what is mounted
+// below a matching directory. Filesystems without a block device (tmpfs,
+// overlay) are only reported when include_types names them.
+type Filesystems struct {
+	IncludeTypes       []string `yaml:"include_types" json:"include_types,omitempty"`
+	ExcludeTypes       []string `yaml:"exclude_types" json:"exclude_types,omitempty"`
+	IncludeMountpoints []string `yaml:"include_mountpoints" json:"include_mountpoints,omitempty"`
+	ExcludeMountpoints []string `yaml:"exclude_mountpoints" json:"exclude_mountpoints,omitempty"`
+	IncludeDevices     []string `yaml:"include_devices" json:"include_devices,omitempty"`
+	ExcludeDevices     []string `yaml:"exclude_devices" json:"exclude_devices,omitempty"`
+}
+
+// Interfaces filters network interfaces. Patterns use shell glob syntax.
+type Interfaces struct {
+	// IncludeVirtual also reports loopback, bridge, veth and tunnel interfaces
+	IncludeVirtual bool `yaml:"include_virtual" json:"include_virtual"`
+	// Include lists the only interfaces reported, virtual or not, when not empty
+	Include []string `yaml:"include" json:"include,omitempty"`
+	Exclude []string `yaml:"exclude" json:"exclude,omitempty"`
+}
+
+// LMSensors reads the label, ignore, compute and set statements of
+// sensors.conf so that names and values match the output of `sensors`
+type LMSensors struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Files are read in order; empty reads /etc/sensors3.conf and /etc/sensors.d like libsensors
+	Files []string `yaml:"files" json:"files,omitempty"`
+}
+
+// VoltageRail adjusts one input of the chips matching an lm-sensors chip
+// pattern, like the label, compute and set statements of sensors.conf
+type VoltageRail struct {
+	// Chip is a chip name or pattern as printed by `sensors`, e.g. nct6775-*
+	Chip string `yaml:"chip" json:"chip"`
+	// Input is the chip's channel, e.g. in1
+	Input string `yaml:"input" json:"input"`
+	Label string `yaml:"label" json:"label,omitempty"`
+	// Compute converts the reading in volts, e.g. "@*(1+120/56)" for a divider
+	Compute string `yaml:"compute" json:"compute,omitempty"`
+	// Min and Max are limits in computed volts; they replace the chip's limits
+	Min    *float64 `yaml:"min" json:"min,omitempty"`
+	Max    *float64 `yaml:"max" json:"max,omitempty"`
+	Ignore bool     `yaml:"ignore" json:"ignore,omitempty"`
+}
+
+// ExternalSource is a device whose temperature readings are polled over HTTP
+// or pushed to /api/sensors/external/<name>
+type ExternalSource struct {
+	Name string `yaml:"name" json:"name"`
+	// URL is polled for readings; empty means the source only pushes
+	URL      string   `yaml:"url" json:"url,omitempty"`
+	Interval Duration `yaml:"interval" json:"interval,omitempty"`
+	Timeout  Duration `yaml:"timeout" json:"timeout,omitempty"`
+	// StaleAfter hides readings that were not refreshed in time (0 = three intervals)
+	StaleAfter Duration `yaml:"stale_after" json:"stale_after,omitempty"`
+	// SensorsKey, NameKey and ValueKey locate readings in the JSON document;
+	// the defaults match the Pico: {"sensors": [{"name": ..., "temperature_celsius": ...}]}
+	SensorsKey string `yaml:"sensors_key" json:"sensors_key,omitempty"`
+	NameKey    string `yaml:"name_key" json:"name_key,omitempty"`
+	ValueKey   string `yaml:"value_key" json:"value_key,omitempty"`
+}
+
+// Energy configures the energy meter, which integrates the power drawn by
+// the machine into kWh and cost
+type Energy struct {
+	Enabled     bool     `yaml:"enabled" json:"enabled"`
+	Interval    Duration `yaml:"interval" json:"interval"`
+	PricePerKWh float64  `yaml:"price_per_kwh" json:"price_per_kwh"`
+	Currency    string   `yaml:"currency" json:"currency"`
+	// StateFile keeps the totals across restarts (default <config dir>/energy.json)
+	StateFile string `yaml:"state_file" json:"state_file"`
+}
+
+// Fans configures fan control
+type Fans struct {
+	// CurveInterval is how often fans in curve mode are re-evaluated
+	CurveInterval Duration `yaml:"curve_interval" json:"curve_interval"`
+	// Defaults are applied to the listed fans on startup
+	Defaults []FanDefault `yaml:"defaults" json:"defaults"`
+}
+
+// FanDefault is the startup setting of one fan
+type FanDefault struct {
+	FanID      int              `yaml:"fan_id" json:"fan_id"`
+	Mode       fan.FanMode      `yaml:"mode" json:"mode"`
+	FixedSpeed int              `yaml:"fixed_speed_percent" json:"fixed_speed_percent,omitempty"`
+	Curve      []fan.CurvePoint `yaml:"curve" json:"curve,omitempty"`
+	// Sensor is the curve input, e.g. external/pico/T1; empty uses the CPU temperature
+	Sensor string `yaml:"sensor" json:"sensor,omitempty"`
+}
+
+// Settings converts the default to fan settings
+func (d FanDefault) Settings() *fan.Settings {
+	return &fan.Settings{Mode: d.Mode, FixedSpeed: d.FixedSpeed, Curve: d.Curve, Sensor: d.Sensor}
+}
+
+// Safety configures limits that apply on top of what the hardware reports
+type Safety struct {
+	// MaxCoreClockOffset and MaxMemoryClockOffset cap the absolute offset in MHz (0 = no cap)
+	MaxCoreClockOffset   int     `yaml:"max_core_clock_offset_mhz" json:"max_core_clock_offset_mhz"`
+	MaxMemoryClockOffset int     `yaml:"max_memory_clock_offset_mhz" json:"max_memory_clock_offset_mhz"`
+	MaxPowerLimit        int     `yaml:"max_power_limit_percent" json:"max_power_limit_percent"`
+	MaxTempLimit         int     `yaml:"max_temp_limit_celsius" json:"max_temp_limit_celsius"`
+	MaxVoltageOffset     float64 `yaml:"max_voltage_offset_mv" json:"max_voltage_offset_mv"`
+	// MinFixedFanSpeed is the lowest fixed fan speed accepted (0 = no floor)
+	MinFixedFanSpeed int `yaml:"min_fixed_fan_speed_percent" json:"min_fixed_fan_speed_percent"`
+
+	Watchdog Watchdog `yaml:"watchdog" json:"watchdog"`
+	Governor Governor `yaml:"governor" json:"governor"`
+}
+
+// Watchdog configures the rollback watchdog of pending overclock transactions
+type Watchdog struct {
+	PollInterval   Duration `yaml:"poll_interval" json:"poll_interval"`
+	TempSpikeDelta float64  `yaml:"temp_spike_delta_celsius" json:"temp_spike_delta_celsius"`
+	TempCeiling    float64  `yaml:"temp_ceiling_celsius" json:"temp_ceiling_celsius"`
+}
+
+// Governor configures the thermal protection governor, which escalates from
+// full fan speed to a stock overclock, a lower power limit and finally a
+// command while a CPU or GPU sensor is within MarginCelsius of its critical value
+type Governor struct {
+	Enabled                bool     `yaml:"enabled" json:"enabled"`
+	Interval               Duration `yaml:"interval" json:"interval"`
+	MarginCelsius          float64  `yaml:"margin_celsius" json:"margin_celsius"`
+	DefaultCriticalCelsius float64  `yaml:"default_critical_celsius" json:"default_critical_celsius"`
+	StepDelay              Duration `yaml:"step_delay" json:"step_delay"`
+	PowerLimitPercent      int      `yaml:"power_limit_percent" json:"power_limit_percent"`
+	Command                []string `yaml:"command" json:"command,omitempty"`
+	CommandTimeout         Duration `yaml:"command_timeout" json:"command_timeout"`
+	RecoveryMarginCelsius  float64  `yaml:"recovery_margin_celsius" json:"recovery_margin_celsius"`
+	RecoveryDelay          Duration `yaml:"recovery_delay" json:"recovery_delay"`
+}
+
+// Overclock configures overclock profile storage
+type Overclock struct {
+	// ProfilesDir overrides the default profiles directory
+	ProfilesDir string `yaml:"profiles_dir" json:"profiles_dir"`
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Bind:           "127.0.0.1",
+			Port:           8080,
+			RequestTimeout: Duration(10 * time.Second),
+		},
+		Collectors: Collectors{
+			Interval:     Duration(5 * time.Second),
+			CPU:          true,
+			GPU:          true,
+			Memory:       true,
+			Disk:         true,
+			Temps:        true,
+			Fans:         true,
+			Network:      true,
+			Power:        true,
+			Voltages:     true,
+			Processes:    true,
+			TopProcesses: 5,
+			Cgroups:      true,
+			LMSensors: LMSensors{
+				Enabled: true,
+			},
+			Filesystems: Filesystems{
+				// Snap packages and container layers
+				ExcludeTypes:       []string{"squashfs"},
+				ExcludeMountpoints: []string{"/snap", "/var/snap", "/var/lib/docker", "/var/lib/containers", "/var/lib/kubelet"},
+			},
+		},
+		Fans: Fans{
+			CurveInterval: Duration(2 * time.Second),
+		},
+		Safety: Safety{
+			Watchdog: Watchdog{
+				PollInterval:   Duration(2 * time.Second),
+				TempSpikeDelta: 15,
+				TempCeiling:    90,
+			},
+			Governor: Governor{
+				Enabled:                true,
+				Interval:               Duration(2 * time.Second),
+				MarginCelsius:          5,
+				DefaultCriticalCelsius: 95,
+				StepDelay:              Duration(10 * time.Second),
+				PowerLimitPercent:      70,
+				CommandTimeout:         Duration(5 * time.Minute),
+				RecoveryMarginCelsius:  10,
+				RecoveryDelay:          Duration(time.Minute),
+			},
+		},
+		Energy: Energy{
+			Enabled:  true,
+			Interval: Duration(10 * time.Second),
+		},
+		Alerting: Alerting{
+			// Physical disk degradation; replaced by the rules of a config file
+			Rules: []AlertRule{
+				{
+					Name: "disk-degraded", Type: AlertThreshold, Metric: "disk_health_state",
+					Operator: "==", Threshold: 1, Severity: SeverityWarning,
+					Description: "SMART or NVMe health reports wear or errors",
+				},
+				{
+					Name: "disk-failing", Type: AlertThreshold, Metric: "disk_health_state",
+					Operator: "==", Threshold: 2, Severity: SeverityCritical,
+					Description: "the drive reports that it is failing",
+				},
+			},
+		},
+		Integrations: Integrations{
+			MQTT: MQTT{
+				Broker:   "tcp://localhost:1883",
+				Interval: Duration(10 * time.Second),
+				HomeAssistant: HomeAssistant{
+					Enabled:         true,
+					DiscoveryPrefix: "homeassistant",
+				},
+			},
+			Display: Display{
+				Listen:              "127.0.0.1:9123",
+				Interval:            Duration(time.Second),
+				DeviceTimeout:       Duration(30 * time.Second),
+				MaxDevices:          8,
+				MaxPacketsPerSecond: 50,
+			},
+		},
+	}
+}
+
+// Load reads a YAML configuration file on top of the defaults and validates it.
+// Unknown keys are rejected so typos don't go unnoticed.
+func Load(path string) (*Config, error) {
+	config := Default()
+	if path == "" {
+		return config, config.Validate()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Validate checks the configuration and reports every problem found
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(net.ParseIP(c.Server.Bind) != nil, "server.bind: %q is not an IP address", c.Server.Bind)
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port: must be between 1 and 65535")
+	check(c.Server.RequestTimeout.Std() >= time.Second, "server.request_timeout: must be at least 1s")
+	for _, origin := range c.Server.CORSOrigins {
+		check(strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"server.cors_origins: %q must start with http:// or https://", origin)
+	}
+	for _, host := range c.Server.Hosts {
+		check(host != "" && !strings.ContainsAny(host, ":/ "), "server.hosts: %q is not a host name", host)
+	}
+	check(!c.Server.TLS.RequireClientCert || c.Server.TLS.ClientCAFile != "",
+		"server.tls.require_client_cert: needs server.tls.client_ca_file")
+
+	check(c.Collectors.Interval.Std() >= time.Second, "collectors.interval: must be at least 1s")
+	fs := c.Collectors.Filesystems
+	for _, rule := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"filesystems.include_types", fs.IncludeTypes}, {"filesystems.exclude_types", fs.ExcludeTypes},
+		{"filesystems.include_mountpoints", fs.IncludeMountpoints}, {"filesystems.exclude_mountpoints", fs.ExcludeMountpoints},
+		{"filesystems.include_devices", fs.IncludeDevices}, {"filesystems.exclude_devices", fs.ExcludeDevices},
+		{"interfaces.include", c.Collectors.Interfaces.Include}, {"interfaces.exclude", c.Collectors.Interfaces.Exclude},
+	} {
+		for _, pattern := range rule.patterns {
+			_, err := filepath.Match(pattern, "")
+			check(pattern != "" && err == nil, "collectors.%s: %q is not a valid pattern", rule.name, pattern)
+		}
+	}
+	check(c.Collectors.TopProcesses >= 0 && c.Collectors.TopProcesses <= 50, "collectors.top_processes: must be between 0 and 50")
+	for _, file := range c.Collectors.LMSensors.Files {
+		check(file != "", "collectors.lm_sensors.files: cannot contain an empty path")
+	}
+	for i, rail := range c.Collectors.VoltageRails {
+		field := fmt.Sprintf("collectors.voltage_rails[%d]", i)
+		_, err := filepath.Match(rail.Chip, "")
+		check(rail.Chip != "" && err == nil, "%s.chip: %q is not a valid chip pattern", field, rail.Chip)
+		check(voltageInput.MatchString(rail.Input), "%s.input: %q is not a voltage input such as in1", field, rail.Input)
+		if rail.Compute != "" {
+			_, err := hwmon.ParseExpr(rail.Compute)
+			check(err == nil, "%s.compute: %v", field, err)
+		}
+		check(rail.Min == nil || rail.Max == nil || *rail.Min < *rail.Max, "%s.min: must be below max", field)
+	}
+	seenSources := make(map[string]bool)
+	for i, source := range c.Collectors.External {
+		field := fmt.Sprintf("collectors.external[%d]", i)
+		check(source.Name != "" && !strings.ContainsAny(source.Name, "/ "), "%s.name: is required and cannot contain / or spaces", field)
+		check(!seenSources[source.Name], "%s.name: %q is used twice", field, source.Name)
+		seenSources[source.Name] = true
+		check(source.URL == "" || strings.HasPrefix(source.URL, "http://") || strings.HasPrefix(source.URL, "https://"),
+			"%s.url: %q must start with http:// or https://", field, source.URL)
+		check(source.Interval == 0 || source.Interval.Std() >= time.Second, "%s.interval: must be at least 1s", field)
+		check(source.Timeout >= 0, "%s.timeout: cannot be negative", field)
+		check(source.StaleAfter >= 0, "%s.stale_after: cannot be negative", field)
+	}
+
+	check(c.Energy.Interval.Std() >= time.Second, "energy.interval: must be at least 1s")
+	check(c.Energy.PricePerKWh >= 0, "energy.price_per_kwh: cannot be negative")
+
+	check(c.Fans.CurveInterval.Std() >= 500*time.Millisecond, "fans.curve_interval: must be at least 500ms")
+
+	seenFans := make(map[int]bool)
+	for i, d := range c.Fans.Defaults {
+		check(!seenFans[d.FanID], "fans.defaults[%d]: fan %d is listed twice", i, d.FanID)
+		seenFans[d.FanID] = true
+		switch d.Mode {
+		case fan.ModeAuto:
+		case fan.ModeFixed:
+			check(d.FixedSpeed >= 0 && d.FixedSpeed <= 100, "fans.defaults[%d].fixed_speed_percent: must be between 0 and 100", i)
+			check(d.FixedSpeed >= c.Safety.MinFixedFanSpeed, "fans.defaults[%d].fixed_speed_percent: below safety.min_fixed_fan_speed_percent", i)
+		case fan.ModeCurve:
+			check(len(d.Curve) >= 2, "fans.defaults[%d].curve: needs at least two points", i)
+			check(d.Sensor == "" || strings.Count(d.Sensor, "/") >= 1, "fans.defaults[%d].sensor: %q is not <category>/<sensor>[/<label>]", i, d.Sensor)
+		default:
+			problems = append(problems, fmt.Sprintf("fans.defaults[%d].mode: must be auto, fixed or curve", i))
+		}
+	}
+
+	s := c.Safety
+	check(s.MaxCoreClockOffset >= 0, "safety.max_core_clock_offset_mhz: cannot be negative")
+	check(s.MaxMemoryClockOffset >= 0, "safety.max_memory_clock_offset_mhz: cannot be negative")
+	check(s.MaxPowerLimit >= 0, "safety.max_power_limit_percent: cannot be negative")
+	check(s.MaxTempLimit >= 0, "safety.max_temp_limit_celsius: cannot be negative")
+	check(s.MaxVoltageOffset >= 0, "safety.max_voltage_offset_mv: cannot be negative")
+	check(s.MinFixedFanSpeed >= 0 && s.MinFixedFanSpeed <= 100, "safety.min_fixed_fan_speed_percent: must be between 0 and 100")
+	check(s.Watchdog.PollInterval.Std() >= 500*time.Millisecond, "safety.watchdog.poll_interval: must be at least 500ms")
+	check(s.Watchdog.TempSpikeDelta > 0, "safety.watchdog.temp_spike_delta_celsius: must be positive")
+	check(s.Watchdog.TempCeiling > 0 && s.Watchdog.TempCeiling <= 110, "safety.watchdog.temp_ceiling_celsius: must be between 0 and 110")
+
+	gov := s.Governor
+	check(gov.Interval.Std() >= 500*time.Millisecond, "safety.governor.interval: must be at least 500ms")
+	check(gov.MarginCelsius >= 0 && gov.MarginCelsius <= 30, "safety.governor.margin_celsius: must be between 0 and 30")
+	check(gov.DefaultCriticalCelsius >= 50 && gov.DefaultCriticalCelsius <= 120, "safety.governor.default_critical_celsius: must be between 50 and 120")
+	check(gov.StepDelay >= gov.Interval, "safety.governor.step_delay: must be at least safety.governor.interval")
+	check(gov.PowerLimitPercent >= 10 && gov.PowerLimitPercent <= 100, "safety.governor.power_limit_percent: must be between 10 and 100")
+	check(gov.CommandTimeout.Std() >= time.Second, "safety.governor.command_timeout: must be at least 1s")
+	check(gov.RecoveryMarginCelsius > gov.MarginCelsius, "safety.governor.recovery_margin_celsius: must be larger than margin_celsius")
+	check(gov.RecoveryDelay >= 0, "safety.governor.recovery_delay: cannot be negative")
+
+	c.Alerting.validate(check)
+	c.Integrations.validate(check)
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// RestartRequired lists the settings that differ between two configurations
+// but only take effect after a restart
+func RestartRequired(old, new *Config) []string {
+	var fields []string
+	if old.Server.Bind != new.Server.Bind {
+		fields = append(fields, "server.bind")
+	}
+	if old.Server.Port != new.Server.Port {
+		fields = append(fields, "server.port")
+	}
+	if old.Server.TokensFile != new.Server.TokensFile {
+		fields = append(fields, "server.tokens_file")
+	}
+	if old.Server.AuditDir != new.Server.AuditDir {
+		fields = append(fields, "server.audit_dir")
+	}
+	if old.Server.TLS != new.Server.TLS {
+		fields = append(fields, "server.tls")
+	}
+	if old.Overclock.ProfilesDir != new.Overclock.ProfilesDir {
+		fields = append(fields, "overclock.profiles_dir")
+	}
+	return fields
+}
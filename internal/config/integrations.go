@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Display schemas understood by the Pico push protocol
+const (
+	DisplaySchemaCompact = "compact"
+	DisplaySchemaCBOR    = "cbor"
+)
+
+// Integrations configures connections to other systems
+type Integrations struct {
+	MQTT    MQTT    `yaml:"mqtt" json:"mqtt"`
+	Display Display `yaml:"display" json:"display"`
+}
+
+// MQTT configures publishing metrics to an MQTT broker
+type MQTT struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Broker is the broker URL: tcp://, ssl:// or ws://
+	Broker   string `yaml:"broker" json:"broker"`
+	ClientID string `yaml:"client_id" json:"client_id,omitempty"`
+	Username string `yaml:"username" json:"username,omitempty"`
+	Password string `yaml:"password" json:"-"`
+	// TopicPrefix is the root of the topic tree; empty defaults to picohwmon/<hostname>
+	TopicPrefix string   `yaml:"topic_prefix" json:"topic_prefix,omitempty"`
+	Interval    Duration `yaml:"interval" json:"interval"`
+	QoS         byte     `yaml:"qos" json:"qos"`
+	Retain      bool     `yaml:"retain" json:"retain"`
+	// AllowControl subscribes to command topics for fans and overclock profiles
+	AllowControl bool `yaml:"allow_control" json:"allow_control"`
+
+	HomeAssistant HomeAssistant `yaml:"home_assistant" json:"home_assistant"`
+}
+
+// HomeAssistant configures Home Assistant MQTT discovery
+type HomeAssistant struct {
+	Enabled         bool   `yaml:"enabled" json:"enabled"`
+	DiscoveryPrefix string `yaml:"discovery_prefix" json:"discovery_prefix"`
+}
+
+// Display configures pushing snapshots to display devices such as the Pico
+type Display struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Listen is the UDP address devices register on
+	Listen string `yaml:"listen" json:"listen"`
+	// AllowedSources lists the addresses or CIDR ranges devices may register
+	// from; empty allows any
+	AllowedSources []string `yaml:"allowed_sources" json:"allowed_sources,omitempty"`
+	// Key is a shared secret devices prove they know when answering the
+	// registration challenge
+	Key      string   `yaml:"key" json:"-"`
+	Interval Duration `yaml:"interval" json:"interval"`
+	// DeviceTimeout drops registered devices that stop sending keepalives
+	DeviceTimeout Duration `yaml:"device_timeout" json:"device_timeout"`
+	MaxDevices    int      `yaml:"max_devices" json:"max_devices"`
+	// MaxPacketsPerSecond caps the packets sent to all devices together
+	MaxPacketsPerSecond int             `yaml:"max_packets_per_second" json:"max_packets_per_second"`
+	Devices             []DisplayDevice `yaml:"devices" json:"devices"`
+	Serial              []DisplaySerial `yaml:"serial" json:"serial"`
+}
+
+// DisplayDevice is a UDP device that is pushed to without registering
+type DisplayDevice struct {
+	Name    string `yaml:"name" json:"name"`
+	Address string `yaml:"address" json:"address"`
+	Schema  string `yaml:"schema" json:"schema"`
+}
+
+// DisplaySerial is a USB-serial port a device may be attached to
+type DisplaySerial struct {
+	Name string `yaml:"name" json:"name"`
+	Port string `yaml:"port" json:"port"`
+}
+
+// validate reports the problems of the integrations section
+func (i *Integrations) validate(check func(ok bool, format string, args ...interface{})) {
+	if i.MQTT.Enabled {
+		i.MQTT.validate(check)
+	}
+	if i.Display.Enabled {
+		i.Display.validate(check)
+	}
+}
+
+// ParseSource parses an IP address or CIDR range into a network
+func ParseSource(source string) (*net.IPNet, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(source)
+	return network, err
+}
+
+// validate reports the problems of an enabled MQTT section
+func (m *MQTT) validate(check func(ok bool, format string, args ...interface{})) {
+	u, err := url.Parse(m.Broker)
+	check(err == nil && u.Host != "" && (u.Scheme == "tcp" || u.Scheme == "ssl" || u.Scheme == "tls" || u.Scheme == "ws" || u.Scheme == "wss"),
+		"integrations.mqtt.broker: %q must be a tcp://, ssl:// or ws:// URL", m.Broker)
+	check(m.Interval.Std() >= time.Second, "integrations.mqtt.interval: must be at least 1s")
+	check(m.QoS <= 2, "integrations.mqtt.qos: must be 0, 1 or 2")
+	check(!strings.ContainsAny(m.TopicPrefix, "#+") && !strings.HasPrefix(m.TopicPrefix, "/") && !strings.HasSuffix(m.TopicPrefix, "/"),
+		"integrations.mqtt.topic_prefix: cannot contain wildcards or start or end with /")
+	if m.HomeAssistant.Enabled {
+		check(m.HomeAssistant.DiscoveryPrefix != "" && !strings.ContainsAny(m.HomeAssistant.DiscoveryPrefix, "#+/"),
+			"integrations.mqtt.home_assistant.discovery_prefix: %q is not a single topic level", m.HomeAssistant.DiscoveryPrefix)
+	}
+}
+
+// validate reports the problems of an enabled display section
+func (d *Display) validate(check func(ok bool, format string, args ...interface{})) {
+	_, _, err := net.SplitHostPort(d.Listen)
+	check(d.Listen == "" || err == nil, "integrations.display.listen: %q is not a host:port address", d.Listen)
+	for i, source := range d.AllowedSources {
+		_, err := ParseSource(source)
+		check(err == nil, "integrations.display.allowed_sources[%d]: %q is not an IP address or CIDR range", i, source)
+	}
+	check(d.Interval.Std() >= 100*time.Millisecond, "integrations.display.interval: must be at least 100ms")
+	check(d.DeviceTimeout.Std() >= time.Second, "integrations.display.device_timeout: must be at least 1s")
+	check(d.MaxDevices >= 1, "integrations.display.max_devices: must be at least 1")
+	check(d.MaxPacketsPerSecond >= 1, "integrations.display.max_packets_per_second: must be at least 1")
+
+	names := make(map[string]bool)
+	for i, device := range d.Devices {
+		field := fmt.Sprintf("integrations.display.devices[%d]", i)
+		check(device.Name != "", "%s.name: is required", field)
+		check(!names[device.Name], "%s.name: %q is used twice", field, device.Name)
+		names[device.Name] = true
+		_, _, err := net.SplitHostPort(device.Address)
+		check(err == nil, "%s.address: %q is not a host:port address", field, device.Address)
+		check(device.Schema == DisplaySchemaCompact || device.Schema == DisplaySchemaCBOR,
+			"%s.schema: must be %q or %q", field, DisplaySchemaCompact, DisplaySchemaCBOR)
+	}
+	for i, serial := range d.Serial {
+		field := fmt.Sprintf("integrations.display.serial[%d]", i)
+		check(serial.Name != "", "%s.name: is required", field)
+		check(!names[serial.Name], "%s.name: %q is used twice", field, serial.Name)
+		names[serial.Name] = true
+		check(serial.Port != "", "%s.port: is required", field)
+	}
+}
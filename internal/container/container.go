@@ -0,0 +1,263 @@
+package container
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minInterval is the shortest window rates are computed over; reads
+	// closer together return the previous result
+	minInterval = time.Second
+	// firstWindow is how long the first read waits for a second sample
+	firstWindow = 250 * time.Millisecond
+)
+
+// Sort orders of a breakdown
+const (
+	SortPath   = "path"
+	SortCPU    = "cpu"
+	SortMemory = "memory"
+	SortIO     = "io"
+)
+
+// Environment describes where picoHWMon runs
+type Environment struct {
+	InContainer bool `json:"in_container"`
+	// Runtime is e.g. docker, podman, kubernetes or lxc, when it can be told
+	Runtime string `json:"runtime,omitempty"`
+	ID      string `json:"id,omitempty"`
+	// CgroupVersion is 1 or 2; 0 when no cgroup filesystem is mounted
+	CgroupVersion int `json:"cgroup_version"`
+	// Cgroup is the cgroup picoHWMon runs in, as its /proc/self/cgroup shows it
+	Cgroup string `json:"cgroup,omitempty"`
+	// HostProc and HostSys are the procfs and sysfs the readers use; they
+	// differ from /proc and /sys when HOST_PROC or HOST_SYS point at host mounts
+	HostProc string `json:"host_proc"`
+	HostSys  string `json:"host_sys"`
+	// Warnings name what the readers cannot see from inside the container
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Stats are the limits and usage of a cgroup v2 group. Limits are the
+// effective ones: the tightest of the group and its ancestors.
+type Stats struct {
+	Path        string `json:"path"`
+	ContainerID string `json:"container_id,omitempty"`
+	CPU         CPU    `json:"cpu"`
+	Memory      Memory `json:"memory"`
+	IO          IO     `json:"io"`
+	Pids        Pids   `json:"pids"`
+	// Window is the length of the sampling window in seconds
+	Window float64 `json:"window_seconds"`
+
+	counters counters
+}
+
+// CPU is the cpu controller of a group
+type CPU struct {
+	// LimitCores is the cpu.max quota in cores; nil when unlimited
+	LimitCores *float64 `json:"limit_cores,omitempty"`
+	// Percent is relative to one core, so two busy cores read 200
+	Percent float64 `json:"percent"`
+	// LimitPercent is the use relative to the quota
+	LimitPercent *float64 `json:"limit_percent,omitempty"`
+	// Throttling is cumulative since the group was created
+	ThrottledPeriods uint64  `json:"throttled_periods"`
+	ThrottledSeconds float64 `json:"throttled_seconds"`
+}
+
+// Memory is the memory controller of a group
+type Memory struct {
+	UsedBytes  uint64  `json:"used_bytes"`
+	LimitBytes *uint64 `json:"limit_bytes,omitempty"`
+	// Percent is the use relative to the limit
+	Percent        *float64 `json:"percent,omitempty"`
+	SwapBytes      uint64   `json:"swap_bytes"`
+	SwapLimitBytes *uint64  `json:"swap_limit_bytes,omitempty"`
+}
+
+// IO is the io controller of a group, summed over devices
+type IO struct {
+	ReadBytesPerSec  float64 `json:"read_bytes_per_second"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_second"`
+}
+
+// Pids is the pids controller of a group
+type Pids struct {
+	Current uint64  `json:"current"`
+	Limit   *uint64 `json:"limit,omitempty"`
+}
+
+// counters are the cumulative values rates are computed from
+type counters struct {
+	cpuMicroseconds uint64
+	readBytes       uint64
+	writeBytes      uint64
+}
+
+// Breakdown is the result of a query
+type Breakdown struct {
+	Cgroups []*Stats `json:"cgroups"`
+	// Window is the length of the sampling window in seconds
+	Window float64 `json:"window_seconds"`
+}
+
+// Query selects and orders the groups of a breakdown
+type Query struct {
+	// Sort is path (ascending), cpu, memory or io (descending)
+	Sort string
+	// Depth is how far below the root groups are reported; 0 reports all
+	// that were read
+	Depth int
+	// Containers reports only groups that belong to a container
+	Containers bool
+}
+
+// ValidSort reports whether s is a sort order
+func ValidSort(s string) bool {
+	switch s {
+	case SortPath, SortCPU, SortMemory, SortIO:
+		return true
+	}
+	return false
+}
+
+// depth returns how far below the root a cgroup path is
+func depth(path string) int {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return 0
+	}
+	return strings.Count(path, "/") + 1
+}
+
+// key returns the value a group is sorted by
+func key(s *Stats, order string) float64 {
+	switch order {
+	case SortMemory:
+		return float64(s.Memory.UsedBytes)
+	case SortIO:
+		return s.IO.ReadBytesPerSec + s.IO.WriteBytesPerSec
+	default:
+		return s.CPU.Percent
+	}
+}
+
+// Select filters and sorts the groups of a breakdown
+func Select(groups []*Stats, query Query) []*Stats {
+	selected := []*Stats{}
+	for _, g := range groups {
+		if query.Depth > 0 && depth(g.Path) > query.Depth {
+			continue
+		}
+		if query.Containers && g.ContainerID == "" {
+			continue
+		}
+		selected = append(selected, g)
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if query.Sort != SortPath && query.Sort != "" {
+			a, b := key(selected[i], query.Sort), key(selected[j], query.Sort)
+			if a != b {
+				return a > b
+			}
+		}
+		return selected[i].Path < selected[j].Path
+	})
+	return selected
+}
+
+// Reader interface for container and cgroup monitoring
+type Reader interface {
+	// Environment returns the environment detected when the reader was created
+	Environment() *Environment
+	// GetOwn returns the stats of the cgroup picoHWMon runs in
+	GetOwn(ctx context.Context) (*Stats, error)
+	// GetCgroups returns the groups near the top of the hierarchy and every
+	// container group, with the window their rates cover
+	GetCgroups(ctx context.Context) ([]*Stats, float64, error)
+}
+
+// NewReader creates a new container reader for the current platform
+func NewReader() Reader {
+	return newPlatformReader()
+}
+
+// tracker turns the cumulative counters of a platform read into rates
+type tracker struct {
+	read func(ctx context.Context) ([]*Stats, error)
+
+	mu       sync.Mutex
+	previous map[string]counters
+	taken    time.Time
+	window   float64
+	latest   []*Stats
+}
+
+// get returns the groups with their rates since the previous call. The
+// first call waits briefly for a second sample.
+func (t *tracker) get(ctx context.Context) ([]*Stats, float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.previous == nil {
+		groups, err := t.read(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.previous, t.taken = byPath(groups), time.Now()
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(firstWindow):
+		}
+	} else if t.latest != nil && time.Since(t.taken) < minInterval {
+		return t.latest, t.window, nil
+	}
+
+	groups, err := t.read(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	seconds := now.Sub(t.taken).Seconds()
+
+	rate := func(before, after uint64) float64 {
+		if after < before {
+			return 0
+		}
+		return float64(after-before) / seconds
+	}
+	for _, g := range groups {
+		previous, ok := t.previous[g.Path]
+		if !ok {
+			// Created during the window: its whole lifetime counts
+			previous = counters{}
+		}
+		c := g.counters
+		g.CPU.Percent = rate(previous.cpuMicroseconds, c.cpuMicroseconds) / 1e6 * 100
+		if g.CPU.LimitCores != nil {
+			percent := g.CPU.Percent / *g.CPU.LimitCores
+			g.CPU.LimitPercent = &percent
+		}
+		g.IO.ReadBytesPerSec = rate(previous.readBytes, c.readBytes)
+		g.IO.WriteBytesPerSec = rate(previous.writeBytes, c.writeBytes)
+		g.Window = seconds
+	}
+
+	t.previous, t.taken, t.window, t.latest = byPath(groups), now, seconds, groups
+	return groups, seconds, nil
+}
+
+// byPath indexes the counters of groups by path
+func byPath(groups []*Stats) map[string]counters {
+	indexed := make(map[string]counters, len(groups))
+	for _, g := range groups {
+		indexed[g.Path] = g.counters
+	}
+	return indexed
+}
@@ -0,0 +1,381 @@
+//go:build linux
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// cgroup2Magic is the filesystem type of a cgroup v2 mount, see statfs(2)
+const cgroup2Magic = 0x63677270
+
+// maxDepth is how far below the root a breakdown reads groups that don't
+// belong to a container
+const maxDepth = 3
+
+// cgroupDir is where picoHWMon's own cgroup hierarchy is mounted. Unlike
+// the breakdown it is never taken from HOST_SYS: /proc/self/cgroup is
+// relative to this mount.
+var cgroupDir = "/sys/fs/cgroup"
+
+var (
+	// containerID matches the 64 hex digit container IDs that Docker,
+	// containerd, CRI-O and Podman put in cgroup paths
+	containerID = regexp.MustCompile(`[0-9a-f]{64}`)
+	// mountedID finds the container ID in the paths of the hostname and
+	// resolv.conf files Docker and Podman bind mount into a container
+	mountedID = regexp.MustCompile(`containers/([0-9a-f]{64})/`)
+)
+
+var errNoCgroup2 = errors.New("cgroup limits need cgroup v2")
+
+// LinuxReader implements container detection and cgroup v2 monitoring for Linux
+type LinuxReader struct {
+	env *Environment
+	own tracker
+	all tracker
+}
+
+// newPlatformReader creates a new Linux container reader
+func newPlatformReader() Reader {
+	r := &LinuxReader{env: detect()}
+	r.own.read = r.readOwn
+	r.all.read = r.readAll
+	return r
+}
+
+// Environment returns the environment detected when the reader was created
+func (r *LinuxReader) Environment() *Environment {
+	return r.env
+}
+
+// GetOwn returns the stats of the cgroup picoHWMon runs in
+func (r *LinuxReader) GetOwn(ctx context.Context) (*Stats, error) {
+	if r.env.CgroupVersion != 2 {
+		return nil, errNoCgroup2
+	}
+	groups, _, err := r.own.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return groups[0], nil
+}
+
+// GetCgroups returns the groups of the host hierarchy, or of the
+// container's own when HOST_SYS is not set
+func (r *LinuxReader) GetCgroups(ctx context.Context) ([]*Stats, float64, error) {
+	return r.all.get(ctx)
+}
+
+// readOwn reads the group picoHWMon runs in
+func (r *LinuxReader) readOwn(ctx context.Context) ([]*Stats, error) {
+	return []*Stats{readGroup(cgroupDir, r.env.Cgroup)}, nil
+}
+
+// readAll reads the groups up to maxDepth below the root and every
+// container group, but not the groups inside a container
+func (r *LinuxReader) readAll(ctx context.Context) ([]*Stats, error) {
+	root := platform.HostSys("fs", "cgroup")
+	if cgroupVersion(root) != 2 {
+		return nil, fmt.Errorf("%s: %w", root, errNoCgroup2)
+	}
+	groups := []*Stats{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // removed while walking
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = "/" + strings.TrimPrefix(filepath.ToSlash(rel), ".")
+		isContainer := containerID.MatchString(entry.Name())
+		if depth(rel) <= maxDepth || isContainer {
+			groups = append(groups, readGroup(root, rel))
+		}
+		if isContainer {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// readGroup reads the controllers of the group at path below root. Files
+// of disabled controllers are missing and read as zero or unlimited.
+func readGroup(root, path string) *Stats {
+	dir := filepath.Join(root, path)
+	g := &Stats{Path: path, ContainerID: containerID.FindString(path)}
+
+	stat := readKeyed(dir, "cpu.stat")
+	g.counters.cpuMicroseconds = stat["usage_usec"]
+	g.CPU.ThrottledPeriods = stat["nr_throttled"]
+	g.CPU.ThrottledSeconds = float64(stat["throttled_usec"]) / 1e6
+	g.Memory.UsedBytes, _ = readUint(dir, "memory.current")
+	g.Memory.SwapBytes, _ = readUint(dir, "memory.swap.current")
+	g.Pids.Current, _ = readUint(dir, "pids.current")
+	g.counters.readBytes, g.counters.writeBytes = readIOStat(dir)
+
+	// The effective limits are the tightest on the way up to the root
+	for {
+		if cores, ok := readCPUMax(dir); ok && (g.CPU.LimitCores == nil || cores < *g.CPU.LimitCores) {
+			g.CPU.LimitCores = &cores
+		}
+		tighten(&g.Memory.LimitBytes, dir, "memory.max")
+		tighten(&g.Memory.SwapLimitBytes, dir, "memory.swap.max")
+		tighten(&g.Pids.Limit, dir, "pids.max")
+		if dir == root || len(dir) < len(root) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	if limit := g.Memory.LimitBytes; limit != nil && *limit > 0 {
+		percent := float64(g.Memory.UsedBytes) / float64(*limit) * 100
+		g.Memory.Percent = &percent
+	}
+	return g
+}
+
+// tighten lowers limit to the one in a file when that is lower
+func tighten(limit **uint64, dir, name string) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return // "max"
+	}
+	if *limit == nil || v < **limit {
+		*limit = &v
+	}
+}
+
+// readCPUMax returns the quota of cpu.max ("$MAX $PERIOD") in cores
+func readCPUMax(dir string) (float64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.max"))
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return 0, false
+	}
+	quota, err1 := strconv.ParseUint(fields[0], 10, 64)
+	period, err2 := strconv.ParseUint(fields[1], 10, 64)
+	if err1 != nil || err2 != nil || period == 0 {
+		return 0, false
+	}
+	return float64(quota) / float64(period), true
+}
+
+// readIOStat sums the bytes read and written over the devices of io.stat
+func readIOStat(dir string) (read, written uint64) {
+	data, err := os.ReadFile(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, field := range strings.Fields(line) {
+			name, value, _ := strings.Cut(field, "=")
+			v, _ := strconv.ParseUint(value, 10, 64)
+			switch name {
+			case "rbytes":
+				read += v
+			case "wbytes":
+				written += v
+			}
+		}
+	}
+	return read, written
+}
+
+// readKeyed reads a flat keyed file such as cpu.stat
+func readKeyed(dir, name string) map[string]uint64 {
+	values := make(map[string]uint64)
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return values
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, ok := strings.Cut(line, " "); ok {
+			values[key], _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return values
+}
+
+// readUint reads a file holding a single number
+func readUint(dir, name string) (uint64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return v, err == nil
+}
+
+// cgroupVersion tells cgroup v2 from the v1 (or hybrid) layout by the type
+// of the filesystem mounted at dir
+func cgroupVersion(dir string) int {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0
+	}
+	if fs.Type == cgroup2Magic {
+		return 2
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cpu")); err == nil {
+		return 1
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory")); err == nil {
+		return 1
+	}
+	return 0
+}
+
+// detect works out whether picoHWMon runs in a container. Its own /proc is
+// read, not the host's that HOST_PROC may point at.
+func detect() *Environment {
+	env := &Environment{
+		HostProc:      platform.HostProc(),
+		HostSys:       platform.HostSys(),
+		CgroupVersion: cgroupVersion(cgroupDir),
+		Cgroup:        ownCgroup(),
+	}
+
+	switch {
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		env.Runtime = "kubernetes"
+	case exists("/run/.containerenv"):
+		env.Runtime = "podman"
+	case exists("/.dockerenv"):
+		env.Runtime = "docker"
+	default:
+		env.Runtime = initContainerVariable()
+	}
+	if env.ID = containerID.FindString(env.Cgroup); env.ID == "" {
+		env.ID = mountedContainerID()
+	}
+	if env.Runtime == "" && env.ID != "" {
+		env.Runtime = runtimeOf(env.Cgroup)
+	}
+	env.InContainer = env.Runtime != ""
+	if !env.InContainer {
+		return env
+	}
+
+	if os.Getenv("HOST_PROC") == "" {
+		env.Warnings = append(env.Warnings, "HOST_PROC is not set: processes are the container's own unless it runs with --pid host")
+	}
+	if os.Getenv("HOST_SYS") == "" {
+		env.Warnings = append(env.Warnings, "HOST_SYS is not set: network interfaces and the cgroup breakdown are the container's own unless it runs with --network host and --cgroupns host")
+	}
+	if !exists(platform.HostSys("class", "hwmon")) {
+		env.Warnings = append(env.Warnings, "no hwmon chips are visible: temperatures, fans and voltages need the host's /sys")
+	}
+	switch env.CgroupVersion {
+	case 0:
+		env.Warnings = append(env.Warnings, "no cgroup filesystem is mounted: container limits are not reported")
+	case 1:
+		env.Warnings = append(env.Warnings, "the host uses cgroup v1: container limits are only reported on cgroup v2")
+	}
+	return env
+}
+
+// ownCgroup returns the cgroup v2 path of this process, or on cgroup v1 the
+// first hierarchy that places it below the root. Inside a cgroup namespace
+// the v2 path is "/".
+func ownCgroup() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	var v1, v2 string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			v2 = parts[2]
+		} else if v1 == "" && parts[2] != "/" {
+			v1 = parts[2]
+		}
+	}
+	// On a hybrid layout the unified hierarchy is usually unused
+	if v2 == "" || (v2 == "/" && v1 != "") {
+		return v1
+	}
+	return v2
+}
+
+// initContainerVariable returns the container variable that LXC, Podman and
+// systemd-nspawn set for the init process; it needs root to read
+func initContainerVariable() string {
+	data, err := os.ReadFile("/proc/1/environ")
+	if err != nil {
+		return ""
+	}
+	for _, variable := range bytes.Split(data, []byte{0}) {
+		if value, ok := bytes.CutPrefix(variable, []byte("container=")); ok {
+			return string(value)
+		}
+	}
+	return ""
+}
+
+// mountedContainerID finds the container ID in the mount table when the
+// cgroup path doesn't show it, as inside a cgroup namespace
+func mountedContainerID() string {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	if match := mountedID.FindSubmatch(data); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+// runtimeOf guesses the container runtime from a cgroup path
+func runtimeOf(cgroup string) string {
+	switch {
+	case strings.Contains(cgroup, "kubepods"):
+		return "kubernetes"
+	case strings.Contains(cgroup, "libpod"):
+		return "podman"
+	case strings.Contains(cgroup, "docker"):
+		return "docker"
+	case strings.Contains(cgroup, "containerd"):
+		return "containerd"
+	}
+	return "container"
+}
+
+// exists reports whether a path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+
+package container
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnsupportedReader is a fallback for platforms without cgroups
+type UnsupportedReader struct{}
+
+// newPlatformReader creates a fallback container reader for unsupported platforms
+func newPlatformReader() Reader {
+	return &UnsupportedReader{}
+}
+
+// Environment reports that picoHWMon does not run in a Linux container
+func (r *UnsupportedReader) Environment() *Environment {
+	return &Environment{}
+}
+
+// GetOwn returns an error for unsupported platforms
+func (r *UnsupportedReader) GetOwn(ctx context.Context) (*Stats, error) {
+	return nil, fmt.Errorf("cgroup monitoring not supported on this platform")
+}
+
+// GetCgroups returns an error for unsupported platforms
+func (r *UnsupportedReader) GetCgroups(ctx context.Context) ([]*Stats, float64, error) {
+	return nil, 0, fmt.Errorf("cgroup monitoring not supported on this platform")
+}
@@ -21,3 +21,24 @@ type Reader interface {
 func NewReader() Reader {
 	return newPlatformReader()
 }
+
+// Policy represents CPU frequency scaling policy.
+// Empty or zero fields are left unchanged when applied.
+type Policy struct {
+	Governor                    string `json:"governor,omitempty"`
+	EnergyPerformancePreference string `json:"energy_performance_preference,omitempty"`
+	MinFrequency                int    `json:"min_frequency_mhz,omitempty"`
+	MaxFrequency                int    `json:"max_frequency_mhz,omitempty"`
+	Boost                       *bool  `json:"boost,omitempty"`
+}
+
+// PolicyController interface for CPU frequency policy control
+type PolicyController interface {
+	GetPolicy(ctx context.Context) (*Policy, error)
+	SetPolicy(ctx context.Context, policy *Policy) error
+}
+
+// NewPolicyController creates a new CPU policy controller for the current platform
+func NewPolicyController() PolicyController {
+	return newPlatformPolicyController()
+}
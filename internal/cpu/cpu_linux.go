@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
 )
 
 // LinuxReader implements CPU monitoring for Linux
@@ -79,7 +81,7 @@ func (r *LinuxReader) GetUsage(ctx context.Context) (float64, error) {
 
 // getPhysicalCoreCount reads /proc/cpuinfo to get accurate physical core count
 func (r *LinuxReader) getPhysicalCoreCount() int {
-	content, err := os.ReadFile("/proc/cpuinfo")
+	content, err := os.ReadFile(platform.HostProc("cpuinfo"))
 	if err != nil {
 		return 0
 	}
@@ -24,3 +24,21 @@ func (r *UnsupportedReader) GetInfo(ctx context.Context) (*Info, error) {
 func (r *UnsupportedReader) GetUsage(ctx context.Context) (float64, error) {
 	return 0, fmt.Errorf("CPU monitoring not supported on this platform")
 }
+
+// UnsupportedPolicyController is a fallback for unsupported platforms
+type UnsupportedPolicyController struct{}
+
+// newPlatformPolicyController creates a fallback CPU policy controller for unsupported platforms
+func newPlatformPolicyController() PolicyController {
+	return &UnsupportedPolicyController{}
+}
+
+// GetPolicy returns an error for unsupported platforms
+func (c *UnsupportedPolicyController) GetPolicy(ctx context.Context) (*Policy, error) {
+	return nil, fmt.Errorf("CPU policy control not supported on this platform")
+}
+
+// SetPolicy returns an error for unsupported platforms
+func (c *UnsupportedPolicyController) SetPolicy(ctx context.Context, policy *Policy) error {
+	return fmt.Errorf("CPU policy control not supported on this platform")
+}
@@ -4,6 +4,7 @@ package cpu
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -57,3 +58,22 @@ func (r *WindowsReader) GetUsage(ctx context.Context) (float64, error) {
 
 	return percentages[0], nil
 }
+
+// WindowsPolicyController reports that CPU policy control is unavailable on Windows,
+// where frequency policy is owned by the active power plan
+type WindowsPolicyController struct{}
+
+// newPlatformPolicyController creates a new Windows CPU policy controller
+func newPlatformPolicyController() PolicyController {
+	return &WindowsPolicyController{}
+}
+
+// GetPolicy returns an error on Windows
+func (c *WindowsPolicyController) GetPolicy(ctx context.Context) (*Policy, error) {
+	return nil, fmt.Errorf("CPU policy control not supported on Windows - use power plans instead")
+}
+
+// SetPolicy returns an error on Windows
+func (c *WindowsPolicyController) SetPolicy(ctx context.Context, policy *Policy) error {
+	return fmt.Errorf("CPU policy control not supported on Windows - use power plans instead")
+}
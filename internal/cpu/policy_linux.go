@@ -0,0 +1,169 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// LinuxPolicyController implements CPU frequency policy control through cpufreq sysfs
+type LinuxPolicyController struct {
+	cpufreqPath string
+}
+
+// newPlatformPolicyController creates a new Linux CPU policy controller
+func newPlatformPolicyController() PolicyController {
+	return &LinuxPolicyController{
+		cpufreqPath: platform.HostSys("devices", "system", "cpu", "cpufreq"),
+	}
+}
+
+// policyDirs returns the cpufreq policy directories (one per frequency domain)
+func (c *LinuxPolicyController) policyDirs() ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(c.cpufreqPath, "policy*"))
+	if err != nil || len(dirs) == 0 {
+		return nil, fmt.Errorf("cpufreq not available (no %s/policy* directories)", c.cpufreqPath)
+	}
+	return dirs, nil
+}
+
+// GetPolicy returns the policy of the first frequency domain
+func (c *LinuxPolicyController) GetPolicy(ctx context.Context) (*Policy, error) {
+	dirs, err := c.policyDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{
+		Governor:                    readSysfsString(filepath.Join(dirs[0], "scaling_governor")),
+		EnergyPerformancePreference: readSysfsString(filepath.Join(dirs[0], "energy_performance_preference")),
+	}
+
+	// Frequencies are reported in kHz
+	if khz, err := strconv.Atoi(readSysfsString(filepath.Join(dirs[0], "scaling_min_freq"))); err == nil {
+		policy.MinFrequency = khz / 1000
+	}
+	if khz, err := strconv.Atoi(readSysfsString(filepath.Join(dirs[0], "scaling_max_freq"))); err == nil {
+		policy.MaxFrequency = khz / 1000
+	}
+
+	if boost, ok := c.readBoost(); ok {
+		policy.Boost = &boost
+	}
+
+	return policy, nil
+}
+
+// SetPolicy applies the policy to every frequency domain
+func (c *LinuxPolicyController) SetPolicy(ctx context.Context, policy *Policy) error {
+	if policy == nil {
+		return fmt.Errorf("policy cannot be nil")
+	}
+
+	dirs, err := c.policyDirs()
+	if err != nil {
+		return err
+	}
+
+	if policy.Governor != "" {
+		available := strings.Fields(readSysfsString(filepath.Join(dirs[0], "scaling_available_governors")))
+		if len(available) > 0 && !containsString(available, policy.Governor) {
+			return fmt.Errorf("governor %q not available (available: %s)", policy.Governor, strings.Join(available, ", "))
+		}
+	}
+
+	for _, dir := range dirs {
+		// Raise the maximum before the minimum so the pair never becomes inverted
+		writes := []struct {
+			file  string
+			value string
+			set   bool
+		}{
+			{"scaling_governor", policy.Governor, policy.Governor != ""},
+			{"scaling_max_freq", strconv.Itoa(policy.MaxFrequency * 1000), policy.MaxFrequency > 0},
+			{"scaling_min_freq", strconv.Itoa(policy.MinFrequency * 1000), policy.MinFrequency > 0},
+			{"energy_performance_preference", policy.EnergyPerformancePreference, policy.EnergyPerformancePreference != ""},
+		}
+		for _, w := range writes {
+			if !w.set {
+				continue
+			}
+			if err := os.WriteFile(filepath.Join(dir, w.file), []byte(w.value), 0644); err != nil {
+				return fmt.Errorf("failed to set %s (may need root): %w", w.file, err)
+			}
+		}
+	}
+
+	if policy.Boost != nil {
+		if err := c.writeBoost(*policy.Boost); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readBoost reads the turbo/boost state from the generic or intel_pstate knob
+func (c *LinuxPolicyController) readBoost() (bool, bool) {
+	if value := readSysfsString(filepath.Join(c.cpufreqPath, "boost")); value != "" {
+		return value == "1", true
+	}
+	if value := readSysfsString(filepath.Join(filepath.Dir(c.cpufreqPath), "intel_pstate", "no_turbo")); value != "" {
+		return value == "0", true
+	}
+	return false, false
+}
+
+// writeBoost sets the turbo/boost state through the generic or intel_pstate knob
+func (c *LinuxPolicyController) writeBoost(enabled bool) error {
+	boostPath := filepath.Join(c.cpufreqPath, "boost")
+	if _, err := os.Stat(boostPath); err == nil {
+		value := "0"
+		if enabled {
+			value = "1"
+		}
+		if err := os.WriteFile(boostPath, []byte(value), 0644); err != nil {
+			return fmt.Errorf("failed to set boost (may need root): %w", err)
+		}
+		return nil
+	}
+
+	noTurboPath := filepath.Join(filepath.Dir(c.cpufreqPath), "intel_pstate", "no_turbo")
+	if _, err := os.Stat(noTurboPath); err == nil {
+		value := "1"
+		if enabled {
+			value = "0"
+		}
+		if err := os.WriteFile(noTurboPath, []byte(value), 0644); err != nil {
+			return fmt.Errorf("failed to set turbo (may need root): %w", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("CPU boost control not available")
+}
+
+// readSysfsString reads a sysfs attribute, returning "" if it cannot be read
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+//go:build linux
+
+package disk
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// virtualDevices are block devices without a physical disk behind them
+var virtualDevices = []string{"loop", "ram", "zram", "dm-", "md", "sr", "fd", "nbd"}
+
+// nvmeNamespace matches NVMe namespace names and captures the controller
+var nvmeNamespace = regexp.MustCompile(`^(nvme\d+)n\d+$`)
+
+// LinuxDeviceReader lists disks from /sys/block and reads their health with
+// smartctl, or with the NVMe admin ioctl when smartctl is not installed
+type LinuxDeviceReader struct {
+	cache healthCache
+}
+
+// newPlatformDeviceReader creates a new Linux physical disk reader
+func newPlatformDeviceReader() DeviceReader {
+	return &LinuxDeviceReader{}
+}
+
+// GetDevices returns the physical disks
+func (r *LinuxDeviceReader) GetDevices(ctx context.Context) ([]*Device, error) {
+	entries, err := os.ReadDir(platform.HostSys("block"))
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []*Device{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if isVirtual(name) {
+			continue
+		}
+		sysPath := platform.HostSys("block", name)
+
+		device := &Device{
+			Name:       name,
+			Path:       "/dev/" + name,
+			Rotational: readSysfs(sysPath, "queue/rotational") == "1",
+			Transport:  transportOf(name, sysPath),
+		}
+		if sectors, err := strconv.ParseUint(readSysfs(sysPath, "size"), 10, 64); err == nil {
+			// size is always in 512-byte sectors
+			device.Capacity = sectors * 512 / (1024 * 1024)
+		}
+		if device.Capacity == 0 {
+			continue // empty card readers and ejected media
+		}
+		device.Model = readSysfs(sysPath, "device/model")
+		device.Serial = firstNonEmpty(readSysfs(sysPath, "device/serial"), unitSerial(sysPath))
+		device.Firmware = firstNonEmpty(readSysfs(sysPath, "device/firmware_rev"), readSysfs(sysPath, "device/rev"))
+
+		device.Health = r.cache.get(device.Path, func(ctx context.Context) (*Health, error) {
+			return r.readHealth(ctx, device)
+		})
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// readHealth queries smartctl, falling back to the NVMe ioctl
+func (r *LinuxDeviceReader) readHealth(ctx context.Context, device *Device) (*Health, error) {
+	output, err := querySmartctl(ctx, device.Path)
+	if err == nil {
+		return output.health(), nil
+	}
+
+	if match := nvmeNamespace.FindStringSubmatch(device.Name); match != nil && !errors.Is(err, errStandby) {
+		if health, nvmeErr := readNVMeHealth("/dev/" + match[1]); nvmeErr == nil {
+			return health, nil
+		}
+	}
+	return nil, err
+}
+
+// nvmeAdminCommand mirrors struct nvme_admin_cmd from linux/nvme_ioctl.h
+type nvmeAdminCommand struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+const (
+	// nvmeIoctlAdminCmd is _IOWR('N', 0x41, struct nvme_admin_cmd)
+	nvmeIoctlAdminCmd = 0xC0484E41
+	// nvmeGetLogPage is the admin opcode for Get Log Page
+	nvmeGetLogPage = 0x02
+	// nvmeSmartLog is the SMART / Health Information log identifier
+	nvmeSmartLog     = 0x02
+	nvmeSmartLogSize = 512
+)
+
+// readNVMeHealth reads the SMART / Health Information log page from an NVMe
+// controller character device. It needs CAP_SYS_ADMIN.
+func readNVMeHealth(controller string) (*Health, error) {
+	file, err := os.OpenFile(controller, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	log := make([]byte, nvmeSmartLogSize)
+	cmd := nvmeAdminCommand{
+		opcode:  nvmeGetLogPage,
+		nsid:    0xFFFFFFFF,
+		addr:    uint64(uintptr(unsafe.Pointer(&log[0]))),
+		dataLen: nvmeSmartLogSize,
+		// Number of dwords minus one in the upper half, log identifier in the lower
+		cdw10: (nvmeSmartLogSize/4-1)<<16 | nvmeSmartLog,
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd)))
+	runtime.KeepAlive(log)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	le := binary.LittleEndian
+	h := &Health{Source: "nvme-ioctl"}
+	if kelvin := le.Uint16(log[1:3]); kelvin > 0 {
+		temperature := float64(kelvin) - 273.15
+		h.Temperature = &temperature
+	}
+	h.CriticalWarning = uint64Ptr(uint64(log[0]))
+	h.AvailableSpare = uint64Ptr(uint64(log[3]))
+	h.SpareThreshold = uint64Ptr(uint64(log[4]))
+	h.PercentageUsed = uint64Ptr(uint64(log[5]))
+	// 128-bit counters; the upper half is zero for any real drive
+	h.PowerOnHours = uint64Ptr(le.Uint64(log[128:136]))
+	h.MediaErrors = uint64Ptr(le.Uint64(log[160:168]))
+	h.evaluate()
+	return h, nil
+}
+
+// isVirtual reports whether a block device name belongs to a virtual device
+func isVirtual(name string) bool {
+	for _, prefix := range virtualDevices {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// transportOf guesses how a disk is attached from its name and sysfs path
+func transportOf(name, sysPath string) string {
+	target, _ := filepath.EvalSymlinks(sysPath)
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		return "nvme"
+	case strings.Contains(target, "/usb"):
+		return "usb"
+	case strings.HasPrefix(name, "vd") || strings.Contains(target, "/virtio"):
+		return "virtio"
+	case strings.HasPrefix(name, "mmcblk"):
+		return "mmc"
+	case strings.Contains(target, "/ata"):
+		return "sata"
+	case strings.HasPrefix(name, "sd"):
+		return "scsi"
+	default:
+		return ""
+	}
+}
+
+// unitSerial reads the serial number of a SCSI or SATA disk from its Unit
+// Serial Number VPD page: a 4-byte header followed by the ASCII serial
+func unitSerial(sysPath string) string {
+	data, err := os.ReadFile(filepath.Join(sysPath, "device/vpd_pg80"))
+	if err != nil || len(data) < 4 {
+		return ""
+	}
+	return strings.TrimSpace(string(data[4:]))
+}
+
+// readSysfs returns the trimmed contents of a sysfs attribute, or ""
+func readSysfs(dir, attribute string) string {
+	data, err := os.ReadFile(filepath.Join(dir, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package disk
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnsupportedDeviceReader is a fallback for unsupported platforms
+type UnsupportedDeviceReader struct{}
+
+// newPlatformDeviceReader creates a fallback physical disk reader for unsupported platforms
+func newPlatformDeviceReader() DeviceReader {
+	return &UnsupportedDeviceReader{}
+}
+
+// GetDevices returns an error for unsupported platforms
+func (r *UnsupportedDeviceReader) GetDevices(ctx context.Context) ([]*Device, error) {
+	return nil, fmt.Errorf("physical disk monitoring not supported on this platform")
+}
@@ -0,0 +1,81 @@
+//go:build windows
+
+package disk
+
+import (
+	"context"
+	"strings"
+
+	"github.com/StackExchange/wmi"
+)
+
+// MSFT_PhysicalDisk is the Storage Management API view of a disk
+type MSFT_PhysicalDisk struct {
+	DeviceId        string
+	FriendlyName    string
+	SerialNumber    string
+	FirmwareVersion string
+	Size            uint64
+	MediaType       uint16
+	BusType         uint16
+}
+
+// Values of MSFT_PhysicalDisk.MediaType and BusType
+const (
+	mediaTypeHDD = 3
+	busTypeUSB   = 7
+	busTypeSATA  = 11
+	busTypeNVMe  = 17
+)
+
+// WindowsDeviceReader lists disks through WMI and reads their health with smartctl
+type WindowsDeviceReader struct {
+	cache healthCache
+}
+
+// newPlatformDeviceReader creates a new Windows physical disk reader
+func newPlatformDeviceReader() DeviceReader {
+	return &WindowsDeviceReader{}
+}
+
+// GetDevices returns the physical disks
+func (r *WindowsDeviceReader) GetDevices(ctx context.Context) ([]*Device, error) {
+	var disks []MSFT_PhysicalDisk
+	err := wmi.QueryNamespace("SELECT DeviceId, FriendlyName, SerialNumber, FirmwareVersion, Size, MediaType, BusType FROM MSFT_PhysicalDisk",
+		&disks, `root\Microsoft\Windows\Storage`)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []*Device{}
+	for _, d := range disks {
+		device := &Device{
+			Name:       "PhysicalDrive" + d.DeviceId,
+			Path:       `\\.\PhysicalDrive` + d.DeviceId,
+			Model:      strings.TrimSpace(d.FriendlyName),
+			Serial:     strings.TrimSpace(d.SerialNumber),
+			Firmware:   strings.TrimSpace(d.FirmwareVersion),
+			Capacity:   d.Size / (1024 * 1024),
+			Rotational: d.MediaType == mediaTypeHDD,
+		}
+		switch d.BusType {
+		case busTypeNVMe:
+			device.Transport = "nvme"
+		case busTypeSATA:
+			device.Transport = "sata"
+		case busTypeUSB:
+			device.Transport = "usb"
+		}
+
+		// smartctl names PhysicalDriveN /dev/pdN
+		device.Health = r.cache.get(device.Path, func(ctx context.Context) (*Health, error) {
+			output, err := querySmartctl(ctx, "/dev/pd"+d.DeviceId)
+			if err != nil {
+				return nil, err
+			}
+			return output.health(), nil
+		})
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
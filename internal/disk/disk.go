@@ -11,14 +11,93 @@ type Info struct {
 	Used       uint64  `json:"used_mb"`
 	Available  uint64  `json:"available_mb"`
 	Usage      float64 `json:"usage_percent"`
+	// Inode counts; filesystems without inodes (FAT, NTFS) report none
+	InodesTotal uint64   `json:"inodes_total,omitempty"`
+	InodesUsed  uint64   `json:"inodes_used,omitempty"`
+	InodesFree  uint64   `json:"inodes_free,omitempty"`
+	InodesUsage float64  `json:"inodes_usage_percent,omitempty"`
+	ReadOnly    bool     `json:"read_only"`
+	Options     []string `json:"mount_options,omitempty"`
+	// Pseudo marks filesystems without a backing device (tmpfs, overlay, proc);
+	// they are only reported when their type is included explicitly
+	Pseudo bool `json:"pseudo,omitempty"`
+	// Parent is the block device in /api/disk/io that holds the filesystem
+	Parent string `json:"parent_device,omitempty"`
 }
 
 // Reader interface for disk monitoring
 type Reader interface {
 	GetInfo(ctx context.Context) ([]*Info, error)
+	// Configure replaces the filter that selects the reported filesystems
+	Configure(filter Filter)
 }
 
 // NewReader creates a new disk reader for the current platform
 func NewReader() Reader {
 	return newPlatformReader()
 }
+
+// Health states of a physical disk
+const (
+	HealthOK      = "ok"
+	HealthWarning = "warning"
+	HealthFailing = "failing"
+	HealthUnknown = "unknown"
+)
+
+// Device is a physical disk, as opposed to the filesystems in Info
+type Device struct {
+	Name       string  `json:"name"`
+	Path       string  `json:"path"`
+	Model      string  `json:"model,omitempty"`
+	Serial     string  `json:"serial,omitempty"`
+	Firmware   string  `json:"firmware,omitempty"`
+	Transport  string  `json:"transport,omitempty"`
+	Capacity   uint64  `json:"capacity_mb"`
+	Rotational bool    `json:"rotational"`
+	Health     *Health `json:"health"`
+}
+
+// Health summarises SMART or NVMe health information. Counters the drive does
+// not report are omitted.
+type Health struct {
+	State string `json:"state"`
+	// Source is smartctl or nvme-ioctl
+	Source   string   `json:"source,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+	// Passed is the drive's overall self-assessment
+	Passed             *bool    `json:"passed,omitempty"`
+	Temperature        *float64 `json:"temperature_celsius,omitempty"`
+	PowerOnHours       *uint64  `json:"power_on_hours,omitempty"`
+	PercentageUsed     *uint64  `json:"percentage_used,omitempty"`
+	AvailableSpare     *uint64  `json:"available_spare_percent,omitempty"`
+	SpareThreshold     *uint64  `json:"available_spare_threshold_percent,omitempty"`
+	CriticalWarning    *uint64  `json:"critical_warning,omitempty"`
+	MediaErrors        *uint64  `json:"media_errors,omitempty"`
+	ReallocatedSectors *uint64  `json:"reallocated_sectors,omitempty"`
+	PendingSectors     *uint64  `json:"pending_sectors,omitempty"`
+	Uncorrectable      *uint64  `json:"uncorrectable_sectors,omitempty"`
+	// Attributes is the ATA SMART attribute table
+	Attributes []*Attribute `json:"attributes,omitempty"`
+}
+
+// Attribute is an ATA SMART attribute
+type Attribute struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Value      int    `json:"value"`
+	Worst      int    `json:"worst"`
+	Threshold  int    `json:"threshold"`
+	Raw        uint64 `json:"raw"`
+	WhenFailed string `json:"when_failed,omitempty"`
+}
+
+// DeviceReader lists physical disks with their health
+type DeviceReader interface {
+	GetDevices(ctx context.Context) ([]*Device, error)
+}
+
+// NewDeviceReader creates a physical disk reader for the current platform
+func NewDeviceReader() DeviceReader {
+	return newPlatformDeviceReader()
+}
@@ -3,13 +3,20 @@
 package disk
 
 import (
+	"bufio"
 	"context"
+	"os"
+	"strings"
 
 	"github.com/shirou/gopsutil/v3/disk"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
 )
 
 // LinuxReader implements disk monitoring for Linux
-type LinuxReader struct{}
+type LinuxReader struct {
+	filtering
+}
 
 // newPlatformReader creates a new Linux disk reader
 func newPlatformReader() Reader {
@@ -18,30 +25,36 @@ func newPlatformReader() Reader {
 
 // GetInfo returns disk information
 func (r *LinuxReader) GetInfo(ctx context.Context) ([]*Info, error) {
-	partitions, err := disk.PartitionsWithContext(ctx, false)
+	// All mounts are listed so that filters can include pseudo filesystems,
+	// e.g. the overlay root of a container
+	partitions, err := disk.PartitionsWithContext(ctx, true)
 	if err != nil {
 		return nil, err
 	}
 
-	var disks []*Info
-	for _, partition := range partitions {
-		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
-		if err != nil {
-			continue // Skip partitions we can't read
-		}
+	devFilesystems := deviceFilesystems()
+	pseudo := func(fstype string) bool {
+		return devFilesystems != nil && !devFilesystems[fstype]
+	}
+	return readPartitions(ctx, partitions, r.current(), pseudo, parentDevice), nil
+}
 
-		info := &Info{
-			Device:     partition.Device,
-			Mountpoint: partition.Mountpoint,
-			Filesystem: partition.Fstype,
-			Total:      usage.Total / (1024 * 1024), // Convert to MB
-			Used:       usage.Used / (1024 * 1024),  // Convert to MB
-			Available:  usage.Free / (1024 * 1024),  // Convert to MB
-			Usage:      usage.UsedPercent,
+// deviceFilesystems returns the filesystem types that need a block device,
+// i.e. those not marked nodev in /proc/filesystems, or nil when unknown
+func deviceFilesystems() map[string]bool {
+	file, err := os.Open(platform.HostProc("filesystems"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	types := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 1 {
+			types[fields[0]] = true
 		}
-
-		disks = append(disks, info)
 	}
-
-	return disks, nil
+	return types
 }
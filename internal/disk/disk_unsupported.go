@@ -8,7 +8,9 @@ import (
 )
 
 // UnsupportedReader is a fallback for unsupported platforms
-type UnsupportedReader struct{}
+type UnsupportedReader struct {
+	filtering
+}
 
 // newPlatformReader creates a fallback disk reader for unsupported platforms
 func newPlatformReader() Reader {
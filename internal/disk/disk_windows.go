@@ -9,7 +9,9 @@ import (
 )
 
 // WindowsReader implements disk monitoring for Windows
-type WindowsReader struct{}
+type WindowsReader struct {
+	filtering
+}
 
 // newPlatformReader creates a new Windows disk reader
 func newPlatformReader() Reader {
@@ -23,25 +25,8 @@ func (r *WindowsReader) GetInfo(ctx context.Context) ([]*Info, error) {
 		return nil, err
 	}
 
-	var disks []*Info
-	for _, partition := range partitions {
-		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
-		if err != nil {
-			continue // Skip partitions we can't read
-		}
-
-		info := &Info{
-			Device:     partition.Device,
-			Mountpoint: partition.Mountpoint,
-			Filesystem: partition.Fstype,
-			Total:      usage.Total / (1024 * 1024), // Convert to MB
-			Used:       usage.Used / (1024 * 1024),  // Convert to MB
-			Available:  usage.Free / (1024 * 1024),  // Convert to MB
-			Usage:      usage.UsedPercent,
-		}
-
-		disks = append(disks, info)
-	}
-
-	return disks, nil
+	// Every volume has a drive letter, which also names its I/O counters
+	pseudo := func(string) bool { return false }
+	parent := func(device string) string { return device }
+	return readPartitions(ctx, partitions, r.current(), pseudo, parent), nil
 }
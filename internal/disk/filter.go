@@ -0,0 +1,106 @@
+package disk
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// Filter selects the filesystems that are reported. Empty include lists
+// match everything; any exclude match drops a filesystem. Patterns use
+// filepath.Match syntax, and a mountpoint pattern that matches a parent
+// directory also matches everything below it.
+type Filter struct {
+	IncludeTypes       []string
+	ExcludeTypes       []string
+	IncludeMountpoints []string
+	ExcludeMountpoints []string
+	IncludeDevices     []string
+	ExcludeDevices     []string
+}
+
+// filtering holds the filter of a platform reader
+type filtering struct {
+	mu     sync.RWMutex
+	filter Filter
+}
+
+// Configure replaces the filter
+func (f *filtering) Configure(filter Filter) {
+	f.mu.Lock()
+	f.filter = filter
+	f.mu.Unlock()
+}
+
+// current returns the filter in use
+func (f *filtering) current() Filter {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.filter
+}
+
+// dedupe keeps one entry per device, so bind mounts and btrfs subvolumes are
+// reported once under their shortest mountpoint, which is usually the original
+func dedupe(disks []*Info) []*Info {
+	kept := []*Info{}
+	byDevice := make(map[string]int)
+	for _, d := range disks {
+		key := d.Device
+		if d.Pseudo {
+			// Pseudo devices are names like "tmpfs" shared by unrelated
+			// mounts; only the same mount listed twice is a duplicate
+			key += " " + d.Mountpoint
+		}
+		if i, ok := byDevice[key]; ok {
+			if len(d.Mountpoint) < len(kept[i].Mountpoint) {
+				kept[i] = d
+			}
+			continue
+		}
+		byDevice[key] = len(kept)
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// matches reports whether a filesystem passes the filter
+func (f Filter) matches(d *Info) bool {
+	if d.Pseudo && !matchAny(f.IncludeTypes, d.Filesystem, false) {
+		return false
+	}
+	checks := []struct {
+		include, exclude []string
+		value            string
+		parents          bool
+	}{
+		{f.IncludeTypes, f.ExcludeTypes, d.Filesystem, false},
+		{f.IncludeMountpoints, f.ExcludeMountpoints, d.Mountpoint, true},
+		{f.IncludeDevices, f.ExcludeDevices, d.Device, false},
+	}
+	for _, c := range checks {
+		if len(c.include) > 0 && !matchAny(c.include, c.value, c.parents) {
+			return false
+		}
+		if matchAny(c.exclude, c.value, c.parents) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether value, or with parents one of its parent
+// directories, matches one of the patterns
+func matchAny(patterns []string, value string, parents bool) bool {
+	for _, pattern := range patterns {
+		for candidate := value; ; {
+			if ok, _ := filepath.Match(pattern, candidate); ok {
+				return true
+			}
+			parent := filepath.Dir(candidate)
+			if !parents || parent == candidate {
+				break
+			}
+			candidate = parent
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package disk
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// minIOInterval is the shortest window rates are computed over; reads
+	// closer together return the previous result
+	minIOInterval = time.Second
+	// firstIOWindow is how long the first read waits for a second sample
+	firstIOWindow = 250 * time.Millisecond
+)
+
+// IOStats is the I/O activity of a block device over the last sampling window
+type IOStats struct {
+	Device string `json:"device"`
+	// Partitions lists the partitions of the device; disk.Info entries link to it through Parent
+	Partitions       []string `json:"partitions,omitempty"`
+	ReadBytesPerSec  float64  `json:"read_bytes_per_second"`
+	WriteBytesPerSec float64  `json:"write_bytes_per_second"`
+	ReadIOPS         float64  `json:"read_iops"`
+	WriteIOPS        float64  `json:"write_iops"`
+	// ReadLatency and WriteLatency are the average time per completed request
+	ReadLatency  float64 `json:"read_latency_ms"`
+	WriteLatency float64 `json:"write_latency_ms"`
+	// QueueDepth is the average number of requests in flight over the window
+	QueueDepth float64 `json:"queue_depth"`
+	InFlight   uint64  `json:"in_flight"`
+	// Utilization is the share of the window the device was busy
+	Utilization float64 `json:"utilization_percent"`
+	// Window is the length of the sampling window in seconds
+	Window float64 `json:"window_seconds"`
+}
+
+// IOReader reports block device I/O rates. Rates are deltas between
+// consecutive reads, so the metrics sampler sets the window.
+type IOReader interface {
+	GetIO(ctx context.Context) ([]*IOStats, error)
+}
+
+// NewIOReader creates an I/O reader for the current platform
+func NewIOReader() IOReader {
+	return newPlatformIOReader()
+}
+
+// ioCounters are the cumulative counters of one device
+type ioCounters struct {
+	reads, writes         uint64
+	readBytes, writeBytes uint64
+	// Milliseconds spent on reads, writes, with any I/O in flight, and
+	// weighted by the number of requests in flight
+	readTime, writeTime, ioTime, weightedTime uint64
+	inFlight                                  uint64
+	partitions                                []string
+}
+
+// ioTracker turns cumulative counters into rates
+type ioTracker struct {
+	read func() (map[string]*ioCounters, error)
+
+	mu       sync.Mutex
+	previous map[string]*ioCounters
+	taken    time.Time
+	latest   []*IOStats
+}
+
+// GetIO returns the rates since the previous call. The first call waits
+// briefly for a second sample.
+func (t *ioTracker) GetIO(ctx context.Context) ([]*IOStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.previous == nil {
+		counters, err := t.read()
+		if err != nil {
+			return nil, err
+		}
+		t.previous, t.taken = counters, time.Now()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(firstIOWindow):
+		}
+	} else if t.latest != nil && time.Since(t.taken) < minIOInterval {
+		return t.latest, nil
+	}
+
+	counters, err := t.read()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	elapsed := now.Sub(t.taken)
+
+	stats := []*IOStats{}
+	for name, current := range counters {
+		previous, ok := t.previous[name]
+		if !ok {
+			continue // appeared during the window
+		}
+		stats = append(stats, ioRates(name, previous, current, elapsed))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Device < stats[j].Device })
+
+	t.previous, t.taken, t.latest = counters, now, stats
+	return stats, nil
+}
+
+// ioRates computes the rates of one device over elapsed
+func ioRates(name string, previous, current *ioCounters, elapsed time.Duration) *IOStats {
+	seconds := elapsed.Seconds()
+	ms := seconds * 1000
+	// Counters that went backwards wrapped or were reset; the window reads as idle
+	delta := func(before, after uint64) float64 {
+		if after < before {
+			return 0
+		}
+		return float64(after - before)
+	}
+	latency := func(time, requests float64) float64 {
+		if requests == 0 {
+			return 0
+		}
+		return time / requests
+	}
+
+	reads := delta(previous.reads, current.reads)
+	writes := delta(previous.writes, current.writes)
+	return &IOStats{
+		Device:           name,
+		Partitions:       current.partitions,
+		ReadBytesPerSec:  delta(previous.readBytes, current.readBytes) / seconds,
+		WriteBytesPerSec: delta(previous.writeBytes, current.writeBytes) / seconds,
+		ReadIOPS:         reads / seconds,
+		WriteIOPS:        writes / seconds,
+		ReadLatency:      latency(delta(previous.readTime, current.readTime), reads),
+		WriteLatency:     latency(delta(previous.writeTime, current.writeTime), writes),
+		QueueDepth:       delta(previous.weightedTime, current.weightedTime) / ms,
+		InFlight:         current.inFlight,
+		Utilization:      math.Min(100, delta(previous.ioTime, current.ioTime)/ms*100),
+		Window:           seconds,
+	}
+}
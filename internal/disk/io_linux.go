@@ -0,0 +1,129 @@
+//go:build linux
+
+package disk
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// ioSkipped are block devices whose I/O is not reported
+var ioSkipped = []string{"loop", "ram", "zram", "sr", "fd"}
+
+// LinuxIOReader computes I/O rates from /proc/diskstats
+type LinuxIOReader struct {
+	ioTracker
+}
+
+// newPlatformIOReader creates a new Linux I/O reader
+func newPlatformIOReader() IOReader {
+	return &LinuxIOReader{ioTracker{read: readDiskstats}}
+}
+
+// readDiskstats reads the counters of whole block devices. Partitions are
+// not reported on their own but listed under their parent.
+func readDiskstats() (map[string]*ioCounters, error) {
+	file, err := os.Open(platform.HostProc("diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counters := make(map[string]*ioCounters)
+	partitions := make(map[string][]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// major minor name, then at least the 11 fields of kernels before 4.18
+		if len(fields) < 14 {
+			continue
+		}
+		name := fields[2]
+		if skipIO(name) {
+			continue
+		}
+		if parent := partitionParent(name); parent != "" {
+			partitions[parent] = append(partitions[parent], name)
+			continue
+		}
+
+		values := make([]uint64, 11)
+		for i := range values {
+			if values[i], err = strconv.ParseUint(fields[3+i], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid /proc/diskstats line for %s: %w", name, err)
+			}
+		}
+		// Sectors are always 512 bytes in /proc/diskstats
+		counters[name] = &ioCounters{
+			reads:        values[0],
+			readBytes:    values[2] * 512,
+			readTime:     values[3],
+			writes:       values[4],
+			writeBytes:   values[6] * 512,
+			writeTime:    values[7],
+			inFlight:     values[8],
+			ioTime:       values[9],
+			weightedTime: values[10],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for parent, names := range partitions {
+		if c, ok := counters[parent]; ok {
+			c.partitions = names
+		}
+	}
+	return counters, nil
+}
+
+// skipIO reports whether a block device is left out of I/O statistics
+func skipIO(name string) bool {
+	for _, prefix := range ioSkipped {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// partitionParent returns the device a partition belongs to, or "" when
+// name is not a partition
+func partitionParent(name string) string {
+	sysPath := platform.HostSys("class", "block", name)
+	if _, err := os.Stat(filepath.Join(sysPath, "partition")); err != nil {
+		return ""
+	}
+	// /sys/class/block/sda1 links into .../block/sda/sda1
+	target, err := filepath.EvalSymlinks(sysPath)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(filepath.Dir(target))
+}
+
+// parentDevice returns the block device that holds a filesystem's device,
+// e.g. nvme0n1 for /dev/nvme0n1p2 or dm-0 for /dev/mapper/root
+func parentDevice(device string) string {
+	if !strings.HasPrefix(device, "/dev/") {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	name := filepath.Base(device)
+	if parent := partitionParent(name); parent != "" {
+		return parent
+	}
+	if _, err := os.Stat(platform.HostSys("class", "block", name)); err != nil {
+		return ""
+	}
+	return name
+}
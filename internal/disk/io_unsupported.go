@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package disk
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnsupportedIOReader is a fallback for unsupported platforms
+type UnsupportedIOReader struct{}
+
+// newPlatformIOReader creates a fallback I/O reader for unsupported platforms
+func newPlatformIOReader() IOReader {
+	return &UnsupportedIOReader{}
+}
+
+// GetIO returns an error for unsupported platforms
+func (r *UnsupportedIOReader) GetIO(ctx context.Context) ([]*IOStats, error) {
+	return nil, fmt.Errorf("disk I/O monitoring not supported on this platform")
+}
@@ -0,0 +1,50 @@
+//go:build linux || windows
+
+package disk
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// readPartitions builds Info for the partitions that pass filter, dropping
+// bind mount duplicates. Usage is read after filtering so that excluded
+// network mounts cannot stall the reader.
+func readPartitions(ctx context.Context, partitions []disk.PartitionStat, filter Filter, pseudo func(fstype string) bool, parent func(device string) string) []*Info {
+	var disks []*Info
+	for _, partition := range partitions {
+		info := &Info{
+			Device:     partition.Device,
+			Mountpoint: partition.Mountpoint,
+			Filesystem: partition.Fstype,
+			Options:    partition.Opts,
+			Pseudo:     pseudo(partition.Fstype),
+		}
+		if !filter.matches(info) {
+			continue
+		}
+
+		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+		if err != nil {
+			continue // Skip partitions we can't read
+		}
+		info.Total = usage.Total / (1024 * 1024) // Convert to MB
+		info.Used = usage.Used / (1024 * 1024)
+		info.Available = usage.Free / (1024 * 1024)
+		info.Usage = usage.UsedPercent
+		info.InodesTotal = usage.InodesTotal
+		info.InodesUsed = usage.InodesUsed
+		info.InodesFree = usage.InodesFree
+		info.InodesUsage = usage.InodesUsedPercent
+		for _, option := range partition.Opts {
+			if option == "ro" {
+				info.ReadOnly = true
+			}
+		}
+		info.Parent = parent(partition.Device)
+
+		disks = append(disks, info)
+	}
+	return dedupe(disks)
+}
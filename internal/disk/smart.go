@@ -0,0 +1,274 @@
+package disk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// healthCacheTTL is how long health results are reused; SMART data changes
+	// slowly and querying it can take a second per drive
+	healthCacheTTL = 5 * time.Minute
+	// healthRetry is how soon a failed health query is repeated
+	healthRetry = time.Minute
+	// healthTimeout bounds a single health query
+	healthTimeout = 30 * time.Second
+)
+
+// errHealthPending is reported until the first health query of a drive finishes
+var errHealthPending = errors.New("health is still being read")
+
+// smartctlOutput is the part of `smartctl --json -a` that is used
+type smartctlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	ModelName       string `json:"model_name"`
+	SerialNumber    string `json:"serial_number"`
+	FirmwareVersion string `json:"firmware_version"`
+	SmartStatus     *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime *struct {
+		Hours uint64 `json:"hours"`
+	} `json:"power_on_time"`
+	NVMeLog *struct {
+		CriticalWarning         uint64 `json:"critical_warning"`
+		AvailableSpare          uint64 `json:"available_spare"`
+		AvailableSpareThreshold uint64 `json:"available_spare_threshold"`
+		PercentageUsed          uint64 `json:"percentage_used"`
+		MediaErrors             uint64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+	ATAAttributes *struct {
+		Table []struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			Value      int    `json:"value"`
+			Worst      int    `json:"worst"`
+			Thresh     int    `json:"thresh"`
+			WhenFailed string `json:"when_failed"`
+			Raw        struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+// errStandby is returned when smartctl skipped a drive to avoid spinning it up
+var errStandby = errors.New("drive is in standby")
+
+// querySmartctl reads the health of a device with smartctl. Drives in standby
+// are not woken up.
+func querySmartctl(ctx context.Context, path string) (*smartctlOutput, error) {
+	if _, err := exec.LookPath("smartctl"); err != nil {
+		return nil, errors.New("smartctl not found (install smartmontools)")
+	}
+
+	// smartctl encodes informational bits in its exit status; the JSON is what matters
+	output, err := exec.CommandContext(ctx, "smartctl", "--json", "-a", "-n", "standby", path).Output()
+	if len(output) == 0 {
+		if err != nil {
+			return nil, fmt.Errorf("smartctl failed: %w", err)
+		}
+		return nil, errors.New("smartctl returned nothing")
+	}
+
+	var result smartctlOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse smartctl output: %w", err)
+	}
+	// Bits 0 and 1: the command line was invalid or the device could not be opened
+	if result.Smartctl.ExitStatus&0x03 != 0 {
+		for _, message := range result.Smartctl.Messages {
+			if message.Severity == "error" {
+				if strings.Contains(message.String, "STANDBY") {
+					return nil, errStandby
+				}
+				return nil, errors.New(message.String)
+			}
+		}
+		return nil, fmt.Errorf("smartctl exited with status %d", result.Smartctl.ExitStatus)
+	}
+	return &result, nil
+}
+
+// health converts smartctl output to a health summary
+func (o *smartctlOutput) health() *Health {
+	h := &Health{Source: "smartctl"}
+	if o.SmartStatus != nil {
+		passed := o.SmartStatus.Passed
+		h.Passed = &passed
+	}
+	if o.Temperature != nil && o.Temperature.Current > 0 {
+		temperature := o.Temperature.Current
+		h.Temperature = &temperature
+	}
+	if o.PowerOnTime != nil {
+		hours := o.PowerOnTime.Hours
+		h.PowerOnHours = &hours
+	}
+	if log := o.NVMeLog; log != nil {
+		h.CriticalWarning = uint64Ptr(log.CriticalWarning)
+		h.AvailableSpare = uint64Ptr(log.AvailableSpare)
+		h.SpareThreshold = uint64Ptr(log.AvailableSpareThreshold)
+		h.PercentageUsed = uint64Ptr(log.PercentageUsed)
+		h.MediaErrors = uint64Ptr(log.MediaErrors)
+	}
+	if o.ATAAttributes != nil {
+		for _, a := range o.ATAAttributes.Table {
+			h.Attributes = append(h.Attributes, &Attribute{
+				ID:         a.ID,
+				Name:       a.Name,
+				Value:      a.Value,
+				Worst:      a.Worst,
+				Threshold:  a.Thresh,
+				Raw:        a.Raw.Value,
+				WhenFailed: a.WhenFailed,
+			})
+			switch a.ID {
+			case 5:
+				h.ReallocatedSectors = uint64Ptr(a.Raw.Value)
+			case 197:
+				h.PendingSectors = uint64Ptr(a.Raw.Value)
+			case 198:
+				h.Uncorrectable = uint64Ptr(a.Raw.Value)
+			case 9:
+				if h.PowerOnHours == nil {
+					h.PowerOnHours = uint64Ptr(a.Raw.Value)
+				}
+			}
+		}
+	}
+	h.evaluate()
+	return h
+}
+
+// evaluate sets State and Problems from the collected values
+func (h *Health) evaluate() {
+	failing := func(format string, args ...interface{}) {
+		h.State = HealthFailing
+		h.Problems = append(h.Problems, fmt.Sprintf(format, args...))
+	}
+	warning := func(format string, args ...interface{}) {
+		if h.State != HealthFailing {
+			h.State = HealthWarning
+		}
+		h.Problems = append(h.Problems, fmt.Sprintf(format, args...))
+	}
+	h.State = HealthOK
+
+	if h.Passed != nil && !*h.Passed {
+		failing("the drive's SMART self-assessment failed")
+	}
+	if h.CriticalWarning != nil && *h.CriticalWarning != 0 {
+		failing("NVMe critical warning 0x%02x", *h.CriticalWarning)
+	}
+	if h.AvailableSpare != nil && h.SpareThreshold != nil && *h.AvailableSpare < *h.SpareThreshold {
+		failing("available spare %d%% is below the %d%% threshold", *h.AvailableSpare, *h.SpareThreshold)
+	}
+	for _, a := range h.Attributes {
+		switch a.WhenFailed {
+		case "now", "FAILING_NOW":
+			failing("attribute %d %s is below its threshold", a.ID, a.Name)
+		case "past", "In_the_past":
+			warning("attribute %d %s was below its threshold in the past", a.ID, a.Name)
+		}
+	}
+	if h.PercentageUsed != nil && *h.PercentageUsed >= 90 {
+		warning("%d%% of the rated endurance is used", *h.PercentageUsed)
+	}
+	if h.MediaErrors != nil && *h.MediaErrors > 0 {
+		warning("%d media errors", *h.MediaErrors)
+	}
+	if h.ReallocatedSectors != nil && *h.ReallocatedSectors > 0 {
+		warning("%d reallocated sectors", *h.ReallocatedSectors)
+	}
+	if h.PendingSectors != nil && *h.PendingSectors > 0 {
+		warning("%d sectors pending reallocation", *h.PendingSectors)
+	}
+	if h.Uncorrectable != nil && *h.Uncorrectable > 0 {
+		warning("%d uncorrectable sectors", *h.Uncorrectable)
+	}
+}
+
+// unknownHealth reports why health could not be read
+func unknownHealth(err error) *Health {
+	return &Health{State: HealthUnknown, Problems: []string{err.Error()}}
+}
+
+// healthCache remembers health results per device path
+type healthCache struct {
+	mu         sync.Mutex
+	entries    map[string]healthEntry
+	refreshing map[string]bool
+}
+
+type healthEntry struct {
+	health  *Health
+	fetched time.Time
+	failed  bool
+}
+
+// get returns the cached health of path without waiting for the drive. When
+// the entry is missing or too old, fetch refreshes it in the background, so
+// a slow or failing drive never holds up the sampler.
+func (c *healthCache) get(path string, fetch func(ctx context.Context) (*Health, error)) *Health {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]healthEntry)
+		c.refreshing = make(map[string]bool)
+	}
+	entry, ok := c.entries[path]
+	ttl := healthCacheTTL
+	if entry.failed {
+		ttl = healthRetry
+	}
+	if (!ok || time.Since(entry.fetched) >= ttl) && !c.refreshing[path] {
+		c.refreshing[path] = true
+		go c.refresh(path, fetch)
+	}
+
+	if !ok {
+		return unknownHealth(errHealthPending)
+	}
+	return entry.health
+}
+
+// refresh queries the health of path. A drive in standby keeps its last
+// known health.
+func (c *healthCache) refresh(path string, fetch func(ctx context.Context) (*Health, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+	health, err := fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.refreshing, path)
+	entry, ok := c.entries[path]
+	if errors.Is(err, errStandby) && ok {
+		health = entry.health
+	} else if err != nil {
+		health = unknownHealth(err)
+	}
+	c.entries[path] = healthEntry{health: health, fetched: time.Now(), failed: err != nil}
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
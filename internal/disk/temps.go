@@ -0,0 +1,44 @@
+package disk
+
+import (
+	"context"
+
+	"github.com/CristiGvl/picoHWMon/internal/temps"
+)
+
+// WrapTemps returns a reader that reports drive temperatures from SMART when
+// base found no drive sensors, which is the case for most SATA disks without
+// the drivetemp module and for all disks on Windows
+func WrapTemps(base temps.Reader, devices DeviceReader) temps.Reader {
+	return &tempsReader{base: base, devices: devices}
+}
+
+// tempsReader fills temps.Info.Drives from device health
+type tempsReader struct {
+	base    temps.Reader
+	devices DeviceReader
+}
+
+// GetInfo reads the base sensors and adds drive temperatures when missing
+func (r *tempsReader) GetInfo(ctx context.Context) (*temps.Info, error) {
+	info, err := r.base.GetInfo(ctx)
+	if err != nil || len(info.Drives) > 0 {
+		return info, err
+	}
+
+	devices, devErr := r.devices.GetDevices(ctx)
+	if devErr != nil {
+		return info, nil
+	}
+	for _, d := range devices {
+		if d.Health == nil || d.Health.Temperature == nil {
+			continue
+		}
+		label := d.Model
+		if label == "" {
+			label = d.Name
+		}
+		info.Drives = append(info.Drives, &temps.Sensor{Name: d.Name, Label: label, Temperature: *d.Health.Temperature})
+	}
+	return info, nil
+}
@@ -0,0 +1,79 @@
+package display
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// cborWriter encodes the subset of CBOR (RFC 8949) used by snapshots:
+// unsigned integers, text strings, float64, arrays and maps
+type cborWriter struct {
+	buf []byte
+}
+
+// head writes a major type with its argument
+func (w *cborWriter) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, major|26), uint32(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, major|27), n)
+	}
+}
+
+// uint writes an unsigned integer
+func (w *cborWriter) uint(n uint64) {
+	w.head(0, n)
+}
+
+// text writes a UTF-8 string
+func (w *cborWriter) text(s string) {
+	w.head(3, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// array starts an array of n items
+func (w *cborWriter) array(n int) {
+	w.head(4, uint64(n))
+}
+
+// mapHeader starts a map of n pairs
+func (w *cborWriter) mapHeader(n int) {
+	w.head(5, uint64(n))
+}
+
+// float writes a float as the shortest of float32 or float64 that is exact;
+// NaN is written as null
+func (w *cborWriter) float(f float64) {
+	switch {
+	case math.IsNaN(f):
+		w.buf = append(w.buf, 0xf6)
+	case float64(float32(f)) == f:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xfa), math.Float32bits(float32(f)))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, 0xfb), math.Float64bits(f))
+	}
+}
+
+// floatMap writes a map of text keys to floats, sorted by key
+func (w *cborWriter) floatMap(values map[string]float64) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.mapHeader(len(keys))
+	for _, key := range keys {
+		w.text(key)
+		w.float(values[key])
+	}
+}
@@ -0,0 +1,911 @@
+package display
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/alerting"
+	"github.com/CristiGvl/picoHWMon/internal/config"
+	"github.com/CristiGvl/picoHWMon/internal/metrics"
+)
+
+const (
+	// tick is how often devices are checked for a due snapshot
+	tick = 100 * time.Millisecond
+	// serialRetry is how long to wait before reopening a missing serial port
+	serialRetry = 5 * time.Second
+	// minDevicePayload is the smallest snapshot size a device may ask for
+	minDevicePayload = 64
+	// helloRate and helloBurst limit hellos per source address
+	helloRate  = 1.0
+	helloBurst = 3.0
+	// nonceSize is the length of registration challenges
+	nonceSize = 16
+	// challengeTimeout is how long a device has to answer a challenge
+	challengeTimeout = 10 * time.Second
+	// maxPending bounds the challenges awaiting an answer
+	maxPending = 64
+)
+
+// Transports a device can be reached over
+const (
+	TransportUDP    = "udp"
+	TransportSerial = "serial"
+)
+
+// DeviceStatus describes a registered or configured display device
+type DeviceStatus struct {
+	Name       string     `json:"name"`
+	Transport  string     `json:"transport"`
+	Address    string     `json:"address"`
+	Static     bool       `json:"static"`
+	Schema     string     `json:"schema,omitempty"`
+	Interval   string     `json:"interval,omitempty"`
+	MaxPayload int        `json:"max_payload,omitempty"`
+	Registered *time.Time `json:"registered,omitempty"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+	LastSent   *time.Time `json:"last_sent,omitempty"`
+	Sent       uint64     `json:"sent"`
+	Dropped    uint64     `json:"dropped"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Status describes the pusher
+type Status struct {
+	Enabled bool            `json:"enabled"`
+	Listen  string          `json:"listen,omitempty"`
+	Devices []*DeviceStatus `json:"devices"`
+}
+
+// device is a display that receives snapshots
+type device struct {
+	name       string
+	transport  string
+	address    string
+	static     bool
+	schema     byte
+	interval   time.Duration
+	maxPayload int
+	registered time.Time
+	lastSeen   time.Time
+	lastSent   time.Time
+	lastTime   time.Time // time of the last snapshot sent
+	sent       uint64
+	dropped    uint64
+	lastError  string
+	send       func([]byte) error
+}
+
+// challenge is a UDP hello waiting for the device to answer its nonce
+type challenge struct {
+	hello   *hello
+	nonce   []byte
+	expires time.Time
+}
+
+// bucket is a token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token if one is available
+func (b *bucket) take(rate, burst float64, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Pusher pushes compact snapshots to display devices over UDP and USB-serial.
+// Devices register with a hello, are offered the richest schema both sides
+// understand and are dropped when they stop sending keepalives. UDP devices
+// must first answer a challenge, so that nothing is pushed to an address
+// that did not ask for it.
+type Pusher struct {
+	sampler *metrics.Sampler
+	alerts  *alerting.Engine
+
+	mu      sync.Mutex
+	config  config.Display
+	running bool
+	devices map[string]*device
+	pending map[string]*challenge
+	hellos  map[string]*bucket
+	sources []*net.IPNet
+	budget  bucket
+	seq     uint16
+	conn    *net.UDPConn
+	serials map[io.Closer]bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPusher creates a disabled pusher
+func NewPusher(sampler *metrics.Sampler, alerts *alerting.Engine) *Pusher {
+	return &Pusher{
+		sampler: sampler,
+		alerts:  alerts,
+		devices: make(map[string]*device),
+		pending: make(map[string]*challenge),
+		hellos:  make(map[string]*bucket),
+	}
+}
+
+// Configure applies a new configuration. Any change restarts the transports;
+// registered devices are told to register again.
+func (p *Pusher) Configure(cfg config.Display) error {
+	p.mu.Lock()
+	unchanged := p.running && reflect.DeepEqual(p.config, cfg)
+	p.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	p.Stop()
+	if !cfg.Enabled {
+		p.mu.Lock()
+		p.config = cfg
+		p.mu.Unlock()
+		return nil
+	}
+	return p.start(cfg)
+}
+
+// Stop says goodbye to every device and closes the transports
+func (p *Pusher) Stop() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	for key, d := range p.devices {
+		d.send(encodeFrame(FrameBye, p.nextSeq(), nil))
+		delete(p.devices, key)
+	}
+	close(p.stop)
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	for serial := range p.serials {
+		serial.Close()
+	}
+	p.serials = nil
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// GetStatus returns the pusher state and its devices
+func (p *Pusher) GetStatus() *Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := &Status{Enabled: p.config.Enabled, Devices: []*DeviceStatus{}}
+	if p.conn != nil {
+		status.Listen = p.conn.LocalAddr().String()
+	}
+	for _, d := range p.devices {
+		ds := &DeviceStatus{
+			Name:      d.name,
+			Transport: d.transport,
+			Address:   d.address,
+			Static:    d.static,
+			Schema:    schemaName(d.schema),
+			Interval:  d.interval.String(),
+			Sent:      d.sent,
+			Dropped:   d.dropped,
+			LastError: d.lastError,
+		}
+		if d.schema != 0 {
+			ds.MaxPayload = d.maxPayload
+		}
+		ds.Registered = timePtr(d.registered)
+		ds.LastSeen = timePtr(d.lastSeen)
+		ds.LastSent = timePtr(d.lastSent)
+		status.Devices = append(status.Devices, ds)
+	}
+	sort.Slice(status.Devices, func(i, j int) bool { return status.Devices[i].Name < status.Devices[j].Name })
+	return status
+}
+
+// start opens the transports and begins pushing
+func (p *Pusher) start(cfg config.Display) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.config = cfg
+	p.stop = make(chan struct{})
+	p.pending = make(map[string]*challenge)
+	p.hellos = make(map[string]*bucket)
+	p.sources = nil
+	for _, source := range cfg.AllowedSources {
+		network, err := config.ParseSource(source)
+		if err != nil {
+			return fmt.Errorf("display allowed source: %w", err)
+		}
+		p.sources = append(p.sources, network)
+	}
+	p.serials = make(map[io.Closer]bool)
+	p.budget = bucket{}
+
+	if cfg.Listen != "" {
+		addr, err := net.ResolveUDPAddr("udp", cfg.Listen)
+		if err != nil {
+			return fmt.Errorf("display listen address: %w", err)
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return fmt.Errorf("display listen address: %w", err)
+		}
+		p.conn = conn
+		p.wg.Add(1)
+		go p.readUDP(conn)
+	}
+
+	for _, static := range cfg.Devices {
+		schema := SchemaCompact
+		if static.Schema == config.DisplaySchemaCBOR {
+			schema = SchemaCBOR
+		}
+		d := &device{
+			name:       static.Name,
+			transport:  TransportUDP,
+			address:    static.Address,
+			static:     true,
+			schema:     schema,
+			interval:   cfg.Interval.Std(),
+			maxPayload: maxPayload,
+		}
+		d.send = staticSender(p.conn, d.address)
+		p.devices["static:"+static.Name] = d
+	}
+
+	for _, serial := range cfg.Serial {
+		p.wg.Add(1)
+		go p.runSerial(serial, p.stop)
+	}
+
+	p.running = true
+	p.wg.Add(1)
+	go p.run(p.stop)
+	return nil
+}
+
+// staticSender sends to a configured address from the listening socket, if
+// it can reach the address. The address is resolved on every send so that
+// host name changes are followed.
+func staticSender(listener *net.UDPConn, address string) func([]byte) error {
+	return func(data []byte) error {
+		addr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return err
+		}
+		if listener != nil && (!listener.LocalAddr().(*net.UDPAddr).IP.IsLoopback() || addr.IP.IsLoopback()) {
+			_, err = listener.WriteToUDP(data, addr)
+			return err
+		}
+		conn, err := net.DialUDP("udp", nil, addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = conn.Write(data)
+		return err
+	}
+}
+
+// readUDP handles datagrams until the socket is closed
+func (p *Pusher) readUDP(conn *net.UDPConn) {
+	defer p.wg.Done()
+
+	buf := make([]byte, headerSize+maxPayload+trailerSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("Display: UDP read failed", "error", err)
+			}
+			return
+		}
+		f, _, err := decodeFrame(buf[:n])
+		send := func(data []byte) error {
+			_, err := conn.WriteToUDP(data, addr)
+			return err
+		}
+		if f == nil && err == nil {
+			continue
+		}
+		p.handle(f, err, addr, send)
+	}
+}
+
+// runSerial keeps a serial port open and handles its frames until stopped
+func (p *Pusher) runSerial(cfg config.DisplaySerial, stop chan struct{}) {
+	defer p.wg.Done()
+
+	logged := false
+	for {
+		port, err := openSerial(cfg.Port)
+		if err != nil {
+			if !logged {
+				slog.Warn("Display: cannot open serial port", "display", cfg.Name, "port", cfg.Port, "error", err)
+				logged = true
+			}
+		} else {
+			logged = false
+			p.mu.Lock()
+			select {
+			case <-stop:
+				p.mu.Unlock()
+				port.Close()
+				return
+			default:
+			}
+			p.serials[port] = true
+			p.mu.Unlock()
+
+			p.readSerial(cfg, port)
+			port.Close()
+
+			p.mu.Lock()
+			delete(p.serials, port)
+			p.mu.Unlock()
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(serialRetry):
+		}
+	}
+}
+
+// readSerial handles frames from an open port until it fails
+func (p *Pusher) readSerial(cfg config.DisplaySerial, port io.ReadWriteCloser) {
+	var writeMu sync.Mutex
+	send := func(data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, err := port.Write(data)
+		return err
+	}
+	key := "serial:" + cfg.Port
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.devices, key)
+		p.mu.Unlock()
+	}()
+
+	var pending []byte
+	buf := make([]byte, 512)
+	for {
+		n, err := port.Read(buf)
+		if err != nil {
+			return
+		}
+		pending = append(pending, buf[:n]...)
+
+		for len(pending) > 0 {
+			f, consumed, err := decodeFrame(pending)
+			if consumed == 0 {
+				break
+			}
+			pending = pending[consumed:]
+			if f == nil {
+				// Noise, e.g. console output; resynchronise on the next magic
+				continue
+			}
+			p.handleNamed(f, err, TransportSerial, key, cfg.Port, cfg.Name, send)
+		}
+		if len(pending) > headerSize+maxPayload+trailerSize {
+			pending = nil
+		}
+	}
+}
+
+// handle processes a frame from a UDP source. Sources outside the allowed
+// list are ignored without an answer.
+func (p *Pusher) handle(f *frame, err error, addr *net.UDPAddr, send func([]byte) error) {
+	if f == nil {
+		return
+	}
+	address, host := addr.String(), addr.IP.String()
+
+	p.mu.Lock()
+	if !p.allowedSource(addr.IP) {
+		p.mu.Unlock()
+		return
+	}
+	limiter, ok := p.hellos[host]
+	if !ok {
+		limiter = &bucket{}
+		p.hellos[host] = limiter
+	}
+	_, registered := p.devices[address]
+	allowed := registered || f.kind != FrameHello || limiter.take(helloRate, helloBurst, time.Now())
+	p.mu.Unlock()
+
+	if !allowed {
+		return
+	}
+	p.handleNamed(f, err, TransportUDP, address, address, "", send)
+}
+
+// allowedSource reports whether devices may register from ip; callers hold mu
+func (p *Pusher) allowedSource(ip net.IP) bool {
+	if len(p.sources) == 0 {
+		return true
+	}
+	for _, network := range p.sources {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleNamed processes a frame of the device registered under key
+func (p *Pusher) handleNamed(f *frame, err error, transport, key, address, name string, send func([]byte) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if errors.Is(err, errVersion) {
+		send(encodeFrame(FrameError, p.nextSeq(), encodeError(ErrorUnsupportedVersion,
+			fmt.Sprintf("protocol version %d is not supported", f.version))))
+		return
+	}
+	now := time.Now()
+
+	switch f.kind {
+	case FrameHello:
+		h, err := parseHello(f.payload)
+		if err != nil {
+			send(encodeFrame(FrameError, p.nextSeq(), encodeError(ErrorMalformed, err.Error())))
+			return
+		}
+		if refusal := p.refuse(key, h); refusal != nil {
+			send(encodeFrame(FrameError, p.nextSeq(), refusal))
+			return
+		}
+		if transport != TransportUDP {
+			p.register(h, transport, key, address, name, send, now)
+			return
+		}
+		if _, ok := p.pending[key]; !ok && len(p.pending) >= maxPending {
+			return
+		}
+		nonce := make([]byte, nonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return
+		}
+		p.pending[key] = &challenge{hello: h, nonce: nonce, expires: now.Add(challengeTimeout)}
+		send(encodeFrame(FrameChallenge, p.nextSeq(), nonce))
+
+	case FrameAnswer:
+		c, ok := p.pending[key]
+		if !ok || now.After(c.expires) {
+			return
+		}
+		delete(p.pending, key)
+		if !hmac.Equal(f.payload, challengeAnswer(p.config.Key, c.nonce)) {
+			slog.Warn("Display answered the registration challenge with the wrong key", "address", address)
+			send(encodeFrame(FrameError, p.nextSeq(), encodeError(ErrorUnauthorized, "wrong challenge answer")))
+			return
+		}
+		if refusal := p.refuse(key, c.hello); refusal != nil {
+			send(encodeFrame(FrameError, p.nextSeq(), refusal))
+			return
+		}
+		p.register(c.hello, transport, key, address, name, send, now)
+
+	case FramePing:
+		if d, ok := p.devices[key]; ok && !d.static {
+			d.lastSeen = now
+			return
+		}
+		// Unknown device, e.g. after a restart: ask it to register again
+		send(encodeFrame(FrameBye, p.nextSeq(), nil))
+
+	case FrameBye:
+		if d, ok := p.devices[key]; ok && !d.static {
+			slog.Info("Display unregistered", "display", d.name)
+			delete(p.devices, key)
+		}
+	}
+}
+
+// refuse returns the error payload for a hello that cannot be accepted, or
+// nil; callers hold mu
+func (p *Pusher) refuse(key string, h *hello) []byte {
+	if _, existing := p.devices[key]; !existing && p.registeredCount() >= p.config.MaxDevices {
+		return encodeError(ErrorRegistryFull, "too many display devices")
+	}
+	if negotiate(h.schemas) == 0 {
+		return encodeError(ErrorNoCommonSchema, "supported schemas are 1 (compact) and 2 (cbor)")
+	}
+	return nil
+}
+
+// register adds or updates the device under key and welcomes it; callers hold mu
+func (p *Pusher) register(h *hello, transport, key, address, name string, send func([]byte) error, now time.Time) {
+	schema := negotiate(h.schemas)
+	d, existing := p.devices[key]
+	if !existing {
+		d = &device{transport: transport, address: address, registered: now}
+		p.devices[key] = d
+		slog.Info("Display registered", "display", firstNonEmpty(name, h.name, address), "transport", transport, "schema", schemaName(schema))
+	}
+	d.name = firstNonEmpty(name, h.name, address)
+	d.schema = schema
+	d.interval = max(p.config.Interval.Std(), time.Duration(h.minInterval)*time.Millisecond)
+	d.maxPayload = maxPayload
+	if h.maxPayload != 0 {
+		d.maxPayload = max(minDevicePayload, min(int(h.maxPayload), maxPayload))
+	}
+	d.lastSeen = now
+	d.lastTime = time.Time{}
+	d.send = send
+	send(encodeFrame(FrameWelcome, p.nextSeq(), encodeWelcome(schema,
+		uint16(min(d.interval.Milliseconds(), 0xFFFF)),
+		uint16(min(int64(p.config.DeviceTimeout.Std()/time.Second), 0xFFFF)))))
+}
+
+// run pushes snapshots to due devices until stopped
+func (p *Pusher) run(stop chan struct{}) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.push(time.Now())
+		}
+	}
+}
+
+// push sends the latest snapshot to every device that is due. A snapshot that
+// was already sent is only repeated as a keepalive, at half the device timeout.
+func (p *Pusher) push(now time.Time) {
+	snapshot := p.sampler.Latest()
+
+	p.mu.Lock()
+	cfg := p.config
+	for host, limiter := range p.hellos {
+		if now.Sub(limiter.last) > time.Minute {
+			delete(p.hellos, host)
+		}
+	}
+	for key, c := range p.pending {
+		if now.After(c.expires) {
+			delete(p.pending, key)
+		}
+	}
+	var due []*device
+	for key, d := range p.devices {
+		if !d.static && now.Sub(d.lastSeen) > cfg.DeviceTimeout.Std() {
+			slog.Info("Display timed out", "display", d.name)
+			delete(p.devices, key)
+			continue
+		}
+		if snapshot == nil || now.Sub(d.lastSent) < d.interval {
+			continue
+		}
+		if snapshot.Time.Equal(d.lastTime) && now.Sub(d.lastSent) < cfg.DeviceTimeout.Std()/2 {
+			continue
+		}
+		due = append(due, d)
+	}
+	p.mu.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	var firing []*alerting.Alert
+	if p.alerts != nil {
+		for _, alert := range p.alerts.Alerts() {
+			if alert.State == alerting.StateFiring {
+				firing = append(firing, alert)
+			}
+		}
+	}
+
+	bodies := make(map[string][]byte)
+	for _, d := range due {
+		cacheKey := fmt.Sprintf("%d/%d", d.schema, d.maxPayload)
+		body, ok := bodies[cacheKey]
+		if !ok {
+			body = encodeSnapshot(d.schema, d.maxPayload, snapshot, firing)
+			bodies[cacheKey] = body
+		}
+
+		p.mu.Lock()
+		if !p.budget.take(float64(cfg.MaxPacketsPerSecond), float64(cfg.MaxPacketsPerSecond), now) {
+			d.dropped++
+			p.mu.Unlock()
+			continue
+		}
+		data := encodeFrame(FrameSnapshot, p.nextSeq(), body)
+		send := d.send
+		p.mu.Unlock()
+
+		err := send(data)
+
+		p.mu.Lock()
+		d.lastSent = now
+		d.lastTime = snapshot.Time
+		if err != nil {
+			d.dropped++
+			d.lastError = err.Error()
+		} else {
+			d.sent++
+			d.lastError = ""
+		}
+		p.mu.Unlock()
+	}
+}
+
+// registeredCount counts the devices that registered themselves
+func (p *Pusher) registeredCount() int {
+	count := 0
+	for _, d := range p.devices {
+		if !d.static {
+			count++
+		}
+	}
+	return count
+}
+
+// nextSeq returns the next frame sequence number; callers hold mu
+func (p *Pusher) nextSeq() uint16 {
+	p.seq++
+	return p.seq
+}
+
+// encodeSnapshot builds a snapshot payload: the schema byte followed by its body
+func encodeSnapshot(schema byte, limit int, snapshot *metrics.Snapshot, firing []*alerting.Alert) []byte {
+	if schema == SchemaCBOR {
+		return append([]byte{SchemaCBOR}, encodeCBORSnapshot(limit-1, snapshot, firing)...)
+	}
+	return append([]byte{SchemaCompact}, encodeCompact(compactFrom(snapshot, firing))...)
+}
+
+// compactFrom picks the compact schema fields out of a snapshot
+func compactFrom(snapshot *metrics.Snapshot, firing []*alerting.Alert) *compactValues {
+	v := &compactValues{
+		time:         uint32(snapshot.Time.Unix()),
+		cpuUsage:     math.NaN(),
+		cpuFrequency: math.NaN(),
+		cpuTemp:      math.NaN(),
+		gpuUsage:     math.NaN(),
+		gpuTemp:      math.NaN(),
+		gpuPower:     math.NaN(),
+		memoryUsage:  math.NaN(),
+		diskUsage:    math.NaN(),
+		firingAlerts: len(firing),
+	}
+	maxOf := func(current, value float64) float64 {
+		if math.IsNaN(current) || value > current {
+			return value
+		}
+		return current
+	}
+
+	fans := make(map[int]float64)
+	for _, s := range snapshot.Samples {
+		switch s.Metric {
+		case "cpu_usage_percent":
+			v.cpuUsage = s.Value
+		case "cpu_frequency_mhz":
+			v.cpuFrequency = s.Value
+		case "temperature_celsius":
+			if s.Labels["category"] == "cpu" {
+				v.cpuTemp = maxOf(v.cpuTemp, s.Value)
+			}
+		case "gpu_usage_percent":
+			if s.Labels["device"] == "0" {
+				v.gpuUsage = s.Value
+			}
+		case "gpu_temperature_celsius":
+			if s.Labels["device"] == "0" {
+				v.gpuTemp = s.Value
+			}
+		case "gpu_power_usage_watts":
+			if s.Labels["device"] == "0" {
+				v.gpuPower = s.Value
+			}
+		case "memory_usage_percent":
+			v.memoryUsage = s.Value
+		case "disk_usage_percent":
+			v.diskUsage = maxOf(v.diskUsage, s.Value)
+		case "fan_rpm":
+			if id, err := strconv.Atoi(s.Labels["fan"]); err == nil {
+				fans[id] = s.Value
+			}
+		}
+	}
+
+	ids := make([]int, 0, len(fans))
+	for id := range fans {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		v.fanRPM = append(v.fanRPM, fans[id])
+	}
+
+	for _, alert := range firing {
+		if rank := byte(config.SeverityRank(alert.Severity) + 1); rank > v.worstSeverity {
+			v.worstSeverity = rank
+		}
+	}
+	return v
+}
+
+// displayMetrics are the metrics a display shows first, in the order they are
+// kept when a CBOR snapshot has to be trimmed
+var displayMetrics = []string{
+	"cpu_usage_percent",
+	"cpu_frequency_mhz",
+	"temperature_celsius",
+	"gpu_usage_percent",
+	"gpu_temperature_celsius",
+	"gpu_power_usage_watts",
+	"memory_usage_percent",
+	"disk_usage_percent",
+	"fan_rpm",
+	"voltage_volts",
+}
+
+// maxCBORAlerts bounds the alerts listed in a CBOR snapshot
+const maxCBORAlerts = 8
+
+// encodeCBORSnapshot builds the CBOR schema body:
+//
+//	{"t": unix time, "s": {"<metric>[/<label values>]": value}, "a": [[rule, severity]]}
+//
+// When the body would exceed limit bytes, series are dropped starting with
+// the least relevant to a display, then the least severe alerts.
+func encodeCBORSnapshot(limit int, snapshot *metrics.Snapshot, firing []*alerting.Alert) []byte {
+	series := make(map[string]float64, len(snapshot.Samples))
+	ranks := make(map[string]int, len(snapshot.Samples))
+	for _, s := range snapshot.Samples {
+		key := seriesKey(s)
+		series[key] = s.Value
+		ranks[key] = seriesRank(s)
+	}
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ranks[keys[i]] != ranks[keys[j]] {
+			return ranks[keys[i]] < ranks[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	alerts := append([]*alerting.Alert(nil), firing...)
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return config.SeverityRank(alerts[i].Severity) > config.SeverityRank(alerts[j].Severity)
+	})
+	if len(alerts) > maxCBORAlerts {
+		alerts = alerts[:maxCBORAlerts]
+	}
+
+	for {
+		w := &cborWriter{}
+		w.mapHeader(3)
+		w.text("t")
+		w.uint(uint64(snapshot.Time.Unix()))
+		w.text("a")
+		w.array(len(alerts))
+		for _, alert := range alerts {
+			w.array(2)
+			w.text(alert.Rule)
+			w.text(alert.Severity)
+		}
+		w.text("s")
+		values := make(map[string]float64, len(keys))
+		for _, key := range keys {
+			values[key] = series[key]
+		}
+		w.floatMap(values)
+
+		switch {
+		case len(w.buf) <= limit || len(keys) == 0 && len(alerts) == 0:
+			return w.buf
+		case len(keys) > 0:
+			keys = keys[:len(keys)*9/10]
+		default:
+			alerts = alerts[:len(alerts)-1]
+		}
+	}
+}
+
+// seriesRank orders series by relevance to a display: the display metrics in
+// their order, then other metrics, then the per-process series
+func seriesRank(sample *metrics.Sample) int {
+	for i, metric := range displayMetrics {
+		if sample.Metric == metric {
+			return i
+		}
+	}
+	if _, ok := sample.Labels["process"]; ok {
+		return len(displayMetrics) + 1
+	}
+	return len(displayMetrics)
+}
+
+// seriesKey names a series by its metric and label values, ordered by label name
+func seriesKey(sample *metrics.Sample) string {
+	names := make([]string, 0, len(sample.Labels))
+	for name := range sample.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{sample.Metric}
+	for _, name := range names {
+		parts = append(parts, sample.Labels[name])
+	}
+	return strings.Join(parts, "/")
+}
+
+// schemaName returns the configuration name of a schema
+func schemaName(schema byte) string {
+	switch schema {
+	case SchemaCompact:
+		return config.DisplaySchemaCompact
+	case SchemaCBOR:
+		return config.DisplaySchemaCBOR
+	default:
+		return ""
+	}
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// timePtr returns nil for the zero time
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
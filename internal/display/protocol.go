@@ -0,0 +1,280 @@
+package display
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Frame layout, big endian:
+//
+//	'P' 'H' version type seq:u16 length:u16 payload[length] crc:u16
+//
+// The CRC-16/CCITT-FALSE covers everything from version to the end of the
+// payload. The magic and CRC let the serial reader resynchronise after noise;
+// UDP frames carry exactly one frame per datagram.
+const (
+	// ProtocolVersion is the only frame version this host speaks
+	ProtocolVersion = 1
+
+	headerSize  = 8
+	trailerSize = 2
+	// maxPayload bounds frames in both directions
+	maxPayload = 1024
+)
+
+var magic = [2]byte{'P', 'H'}
+
+// Frame types
+const (
+	// FrameHello registers a device: name, supported schemas and limits
+	FrameHello byte = 0x01
+	// FrameWelcome answers a hello with the negotiated schema and interval
+	FrameWelcome byte = 0x02
+	// FrameSnapshot carries metrics in the negotiated schema
+	FrameSnapshot byte = 0x03
+	// FramePing keeps a registration alive
+	FramePing byte = 0x04
+	// FrameBye ends a registration; sent by either side
+	FrameBye byte = 0x05
+	// FrameError rejects a hello or an unsupported frame
+	FrameError byte = 0x06
+	// FrameChallenge answers a UDP hello with a nonce the device must return
+	FrameChallenge byte = 0x07
+	// FrameAnswer returns the challenge nonce, or its HMAC-SHA256 under the
+	// shared key when one is configured
+	FrameAnswer byte = 0x08
+)
+
+// Snapshot schemas, listed in hellos by preference of the host: higher is richer
+const (
+	// SchemaCompact is a fixed binary layout readable with struct.unpack
+	SchemaCompact byte = 1
+	// SchemaCBOR is a CBOR map with every sampled series
+	SchemaCBOR byte = 2
+)
+
+// Error codes carried by FrameError
+const (
+	ErrorUnsupportedVersion byte = 1
+	ErrorNoCommonSchema     byte = 2
+	ErrorRegistryFull       byte = 3
+	ErrorMalformed          byte = 4
+	ErrorUnauthorized       byte = 5
+)
+
+// errVersion is returned for well-formed frames of another protocol version
+var errVersion = errors.New("unsupported protocol version")
+
+// frame is a decoded frame
+type frame struct {
+	version byte
+	kind    byte
+	seq     uint16
+	payload []byte
+}
+
+// encodeFrame builds a version 1 frame
+func encodeFrame(kind byte, seq uint16, payload []byte) []byte {
+	buf := make([]byte, headerSize+len(payload)+trailerSize)
+	buf[0], buf[1] = magic[0], magic[1]
+	buf[2] = ProtocolVersion
+	buf[3] = kind
+	binary.BigEndian.PutUint16(buf[4:], seq)
+	binary.BigEndian.PutUint16(buf[6:], uint16(len(payload)))
+	copy(buf[headerSize:], payload)
+	binary.BigEndian.PutUint16(buf[headerSize+len(payload):], crc16(buf[2:headerSize+len(payload)]))
+	return buf
+}
+
+// decodeFrame parses one frame at the start of data and returns it with the
+// number of bytes consumed. It returns 0 consumed when data holds only part of
+// a frame.
+func decodeFrame(data []byte) (*frame, int, error) {
+	if len(data) < headerSize {
+		return nil, 0, nil
+	}
+	if data[0] != magic[0] || data[1] != magic[1] {
+		return nil, 1, errors.New("bad magic")
+	}
+	length := int(binary.BigEndian.Uint16(data[6:]))
+	if length > maxPayload {
+		return nil, 1, fmt.Errorf("payload of %d bytes is too large", length)
+	}
+	total := headerSize + length + trailerSize
+	if len(data) < total {
+		return nil, 0, nil
+	}
+	if crc16(data[2:headerSize+length]) != binary.BigEndian.Uint16(data[headerSize+length:]) {
+		return nil, 1, errors.New("checksum mismatch")
+	}
+
+	f := &frame{
+		version: data[2],
+		kind:    data[3],
+		seq:     binary.BigEndian.Uint16(data[4:]),
+		payload: append([]byte(nil), data[headerSize:headerSize+length]...),
+	}
+	if f.version != ProtocolVersion {
+		return f, total, errVersion
+	}
+	return f, total, nil
+}
+
+// crc16 computes CRC-16/CCITT-FALSE (poly 0x1021, init 0xFFFF)
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// hello is the registration request of a device:
+//
+//	nameLen:u8 name schemaCount:u8 schemas[schemaCount] minIntervalMs:u16 maxPayload:u16
+type hello struct {
+	name        string
+	schemas     []byte
+	minInterval uint16
+	maxPayload  uint16
+}
+
+// parseHello decodes a hello payload
+func parseHello(payload []byte) (*hello, error) {
+	malformed := errors.New("malformed hello")
+	if len(payload) < 1 {
+		return nil, malformed
+	}
+	n := int(payload[0])
+	if len(payload) < 1+n+1 {
+		return nil, malformed
+	}
+	h := &hello{name: string(payload[1 : 1+n])}
+	rest := payload[1+n:]
+	count := int(rest[0])
+	if len(rest) < 1+count+4 {
+		return nil, malformed
+	}
+	h.schemas = append([]byte(nil), rest[1:1+count]...)
+	h.minInterval = binary.BigEndian.Uint16(rest[1+count:])
+	h.maxPayload = binary.BigEndian.Uint16(rest[3+count:])
+	return h, nil
+}
+
+// encodeWelcome builds a welcome payload:
+//
+//	schema:u8 intervalMs:u16 timeoutS:u16
+func encodeWelcome(schema byte, intervalMs, timeoutS uint16) []byte {
+	buf := []byte{schema}
+	buf = binary.BigEndian.AppendUint16(buf, intervalMs)
+	return binary.BigEndian.AppendUint16(buf, timeoutS)
+}
+
+// encodeError builds an error payload:
+//
+//	code:u8 supportedVersion:u8 message
+func encodeError(code byte, message string) []byte {
+	return append([]byte{code, ProtocolVersion}, message...)
+}
+
+// challengeAnswer returns the answer expected for a challenge nonce
+func challengeAnswer(key string, nonce []byte) []byte {
+	if key == "" {
+		return nonce
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
+
+// negotiate picks the richest schema both sides support, or 0
+func negotiate(offered []byte) byte {
+	var best byte
+	for _, schema := range offered {
+		if (schema == SchemaCompact || schema == SchemaCBOR) && schema > best {
+			best = schema
+		}
+	}
+	return best
+}
+
+// Sentinels for values that were not sampled in the compact schema
+const (
+	missingUnsigned = math.MaxUint16
+	missingSigned   = math.MaxInt16
+)
+
+// compactValues are the fields of the compact schema
+type compactValues struct {
+	time          uint32
+	cpuUsage      float64
+	cpuFrequency  float64
+	cpuTemp       float64
+	gpuUsage      float64
+	gpuTemp       float64
+	gpuPower      float64
+	memoryUsage   float64
+	diskUsage     float64
+	firingAlerts  int
+	worstSeverity byte
+	fanRPM        []float64
+}
+
+// maxCompactFans bounds the fan list of the compact schema
+const maxCompactFans = 8
+
+// encodeCompact builds a compact snapshot body. Percentages, temperatures and
+// watts are sent in tenths; NaN becomes the missing sentinel.
+//
+//	time:u32 cpuUsage:u16 cpuMHz:u16 cpuTemp:i16 gpuUsage:u16 gpuTemp:i16
+//	gpuPower:u16 memUsage:u16 diskUsage:u16 alerts:u8 severity:u8
+//	fanCount:u8 fanRPM:u16[fanCount]
+func encodeCompact(v *compactValues) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, v.time)
+	unsigned := func(value, scale float64) {
+		if math.IsNaN(value) {
+			buf = binary.BigEndian.AppendUint16(buf, missingUnsigned)
+			return
+		}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(math.Max(0, math.Min(math.Round(value*scale), missingUnsigned-1))))
+	}
+	signed := func(value, scale float64) {
+		if math.IsNaN(value) {
+			buf = binary.BigEndian.AppendUint16(buf, uint16(missingSigned))
+			return
+		}
+		scaled := math.Max(math.MinInt16, math.Min(math.Round(value*scale), missingSigned-1))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(int16(scaled)))
+	}
+
+	unsigned(v.cpuUsage, 10)
+	unsigned(v.cpuFrequency, 1)
+	signed(v.cpuTemp, 10)
+	unsigned(v.gpuUsage, 10)
+	signed(v.gpuTemp, 10)
+	unsigned(v.gpuPower, 10)
+	unsigned(v.memoryUsage, 10)
+	unsigned(v.diskUsage, 10)
+	buf = append(buf, byte(min(v.firingAlerts, 255)), v.worstSeverity)
+
+	fans := v.fanRPM
+	if len(fans) > maxCompactFans {
+		fans = fans[:maxCompactFans]
+	}
+	buf = append(buf, byte(len(fans)))
+	for _, rpm := range fans {
+		unsigned(rpm, 1)
+	}
+	return buf
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package display
+
+import (
+	"errors"
+	"io"
+)
+
+// openSerial is not implemented on this platform
+func openSerial(port string) (io.ReadWriteCloser, error) {
+	return nil, errors.New("serial displays are not supported on this platform")
+}
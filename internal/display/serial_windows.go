@@ -0,0 +1,57 @@
+//go:build windows
+
+package display
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// openSerial opens a COM port at 115200 8N1. Reads return after at most a
+// second so that closing the port is noticed.
+func openSerial(port string) (io.ReadWriteCloser, error) {
+	path := port
+	if !strings.HasPrefix(path, `\\.\`) {
+		path = `\\.\` + path
+	}
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var dcb windows.DCB
+	dcb.DCBlength = uint32(unsafe.Sizeof(dcb))
+	if err := windows.GetCommState(handle, &dcb); err != nil {
+		windows.CloseHandle(handle)
+		return nil, err
+	}
+	dcb.BaudRate = 115200
+	dcb.ByteSize = 8
+	dcb.Parity = 0   // no parity
+	dcb.StopBits = 0 // one stop bit
+	// fBinary and DTR_CONTROL_ENABLE; CircuitPython waits for DTR before sending
+	dcb.Flags = 0x01 | 0x10
+	if err := windows.SetCommState(handle, &dcb); err != nil {
+		windows.CloseHandle(handle)
+		return nil, err
+	}
+
+	timeouts := windows.CommTimeouts{
+		ReadIntervalTimeout:        0xFFFFFFFF,
+		ReadTotalTimeoutMultiplier: 0xFFFFFFFF,
+		ReadTotalTimeoutConstant:   1000,
+	}
+	if err := windows.SetCommTimeouts(handle, &timeouts); err != nil {
+		windows.CloseHandle(handle)
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), port), nil
+}
@@ -1,6 +1,9 @@
 package fan
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // FanMode represents different fan control modes
 type FanMode string
@@ -13,8 +16,8 @@ const (
 
 // CurvePoint represents a point in a fan curve
 type CurvePoint struct {
-	Temperature int `json:"temperature_celsius"`
-	FanSpeed    int `json:"fan_speed_percent"`
+	Temperature int `json:"temperature_celsius" yaml:"temperature_celsius"`
+	FanSpeed    int `json:"fan_speed_percent" yaml:"fan_speed_percent"`
 }
 
 // Settings represents fan control settings
@@ -22,14 +25,37 @@ type Settings struct {
 	Mode       FanMode      `json:"mode"`
 	FixedSpeed int          `json:"fixed_speed_percent,omitempty"`
 	Curve      []CurvePoint `json:"curve,omitempty"`
+	// Sensor is the curve input as <category>/<sensor>[/<label>], e.g.
+	// external/pico/T1; empty uses the CPU temperature
+	Sensor string `json:"sensor,omitempty"`
 }
 
-// Info represents fan information
+// SensorLookup returns the current temperature of a named sensor
+type SensorLookup func(ctx context.Context, name string) (float64, error)
+
+// Info represents fan information. A fan's ID is its index in GetFans.
 type Info struct {
 	Name   string `json:"name"`
 	RPM    int    `json:"rpm"`
 	Speed  int    `json:"speed_percent"`
 	MaxRPM int    `json:"max_rpm"`
+	// Controllable is false for fans that can only be monitored
+	Controllable bool `json:"controllable"`
+}
+
+// Config holds runtime tunables of a fan controller
+type Config struct {
+	// CurveInterval is how often fans in curve mode are re-evaluated
+	CurveInterval time.Duration
+	// MinFixedSpeed rejects fixed speeds below this percentage (0 = no floor)
+	MinFixedSpeed int
+	// Sensors resolves the named curve inputs; nil allows only the CPU temperature
+	Sensors SensorLookup
+}
+
+// DefaultConfig returns the default fan controller tunables
+func DefaultConfig() Config {
+	return Config{CurveInterval: 2 * time.Second}
 }
 
 // Controller interface for fan control
@@ -37,6 +63,11 @@ type Controller interface {
 	GetFans(ctx context.Context) ([]*Info, error)
 	GetSettings(ctx context.Context, fanID int) (*Settings, error)
 	SetSettings(ctx context.Context, fanID int, settings *Settings) error
+	// Configure replaces the controller tunables; running curves pick up the new interval
+	Configure(config Config)
+	// StalledCurves returns the fans in curve mode whose curve has not been
+	// evaluated for longer than maxAge
+	StalledCurves(maxAge time.Duration) []int
 }
 
 // NewController creates a new fan controller for the current platform
@@ -6,35 +6,49 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/hwmon"
+	"github.com/CristiGvl/picoHWMon/internal/platform"
 )
 
 // LinuxController implements fan control for Linux
 type LinuxController struct {
-	pwmPaths    []string
-	fanPaths    []string
-	tempPaths   []string
+	pwmPaths  []string
+	tempPaths []string
+
+	curvesMu    sync.Mutex
 	curveStates map[int]*curveState
+
+	configMu sync.RWMutex
+	config   Config
 }
 
 // curveState holds the state for a fan running in curve mode
 type curveState struct {
 	curve       []CurvePoint
+	sensor      string
 	lastTemp    int
 	isActive    bool
 	stopChannel chan bool
+	// lastRun is when the curve was last evaluated, in Unix nanoseconds
+	lastRun atomic.Int64
 }
 
 // newPlatformController creates a new Linux fan controller
 func newPlatformController() Controller {
 	controller := &LinuxController{
 		curveStates: make(map[int]*curveState),
+		config:      DefaultConfig(),
 	}
 	controller.discoverFans()
 	return controller
@@ -43,7 +57,7 @@ func newPlatformController() Controller {
 // discoverFans finds available PWM and fan monitoring paths
 func (c *LinuxController) discoverFans() {
 	// Look for PWM control files
-	pwmGlob := "/sys/class/hwmon/hwmon*/pwm*"
+	pwmGlob := platform.HostSys("class", "hwmon", "hwmon*", "pwm*")
 	if matches, err := filepath.Glob(pwmGlob); err == nil {
 		for _, match := range matches {
 			// Skip pwm files that are not the main control files
@@ -73,117 +87,81 @@ func (c *LinuxController) discoverFans() {
 		}
 	}
 
-	// Look for fan input files
-	fanGlob := "/sys/class/hwmon/hwmon*/fan*_input"
-	if matches, err := filepath.Glob(fanGlob); err == nil {
-		c.fanPaths = matches
-	}
-
 	// Look for temperature sensor files
-	tempGlob := "/sys/class/hwmon/hwmon*/temp*_input"
+	tempGlob := platform.HostSys("class", "hwmon", "hwmon*", "temp*_input")
 	if matches, err := filepath.Glob(tempGlob); err == nil {
 		c.tempPaths = matches
 	}
 }
 
-// GetFans returns fan information
+// GetFans returns fan information. The fan at index i is driven by the i-th
+// PWM output, which is the ID GetSettings and SetSettings take; fan inputs
+// without a PWM output follow and can only be monitored.
 func (c *LinuxController) GetFans(ctx context.Context) ([]*Info, error) {
-	var fans []*Info
-
-	// First, try to use lm-sensors to get fan info
-	cmd := exec.CommandContext(ctx, "sensors")
-	output, err := cmd.Output()
-	sensorsAvailable := err == nil
-
-	if sensorsAvailable {
-		lines := strings.Split(string(output), "\n")
-		fanIndex := 0
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if strings.Contains(line, "fan") && strings.Contains(line, "RPM") {
-				parts := strings.Fields(line)
-				if len(parts) >= 2 {
-					rpmStr := parts[1]
-					if rpm, err := strconv.Atoi(rpmStr); err == nil {
-						fan := &Info{
-							Name:   parts[0],
-							RPM:    rpm,
-							Speed:  0, // Will be populated below
-							MaxRPM: 0, // Will be estimated below
-						}
+	chipList := hwmon.Chips()
+	chips := make(map[string]*hwmon.Chip, len(chipList))
+	for _, chip := range chipList {
+		chips[chip.Dir] = chip
+	}
 
-						// Try to get PWM speed percentage for this fan
-						if fanIndex < len(c.pwmPaths) {
-							if speed := c.getPWMSpeedPercent(c.pwmPaths[fanIndex]); speed >= 0 {
-								fan.Speed = speed
-							}
-							// Estimate max RPM based on current RPM and speed percentage
-							if rpm > 0 && fan.Speed > 0 {
-								estimatedMaxRPM := (rpm * 100) / fan.Speed
-								fan.MaxRPM = estimatedMaxRPM
-							}
-						}
+	fans := make([]*Info, 0, len(c.pwmPaths))
+	driven := make(map[string]bool)
+	for i, pwmPath := range c.pwmPaths {
+		pwmName := filepath.Base(pwmPath)
+		fan := &Info{Name: fmt.Sprintf("%s (ID:%d)", pwmName, i), Controllable: true}
+		if speed := c.getPWMSpeedPercent(pwmPath); speed >= 0 {
+			fan.Speed = speed
+		}
 
-						fans = append(fans, fan)
-						fanIndex++
-					}
-				}
+		// The fan input with the same number usually reports the fan the PWM drives
+		channel := "fan" + strings.TrimPrefix(pwmName, "pwm")
+		if chip, ok := chips[filepath.Dir(pwmPath)]; ok {
+			driven[filepath.Join(chip.Dir, channel)] = true
+			if name, rpm, ok := readFanInput(chip, channel); ok {
+				fan.Name, fan.RPM = name, rpm
 			}
 		}
+		// Estimate max RPM based on current RPM and speed percentage
+		if fan.RPM > 0 && fan.Speed > 0 {
+			fan.MaxRPM = (fan.RPM * 100) / fan.Speed
+		}
+		fans = append(fans, fan)
 	}
 
-	// If no fans found via sensors or sensors not available,
-	// create entries based on available PWM controls and try to read RPM directly
-	if len(fans) == 0 {
-		for i, pwmPath := range c.pwmPaths {
-			// Extract PWM number from path (e.g., /sys/class/hwmon/hwmon2/pwm1 -> "pwm1")
-			pwmName := filepath.Base(pwmPath)
-			speed := c.getPWMSpeedPercent(pwmPath)
-
-			// Try to read RPM directly from fan input files
-			rpm := c.getFanRPMDirect(i)
-
-			fan := &Info{
-				Name:   fmt.Sprintf("%s (ID:%d)", pwmName, i),
-				RPM:    rpm,
-				Speed:  speed,
-				MaxRPM: 0, // Cannot estimate without both RPM and speed
+	for _, chip := range chipList {
+		for _, channel := range chip.Channels("fan") {
+			if driven[filepath.Join(chip.Dir, channel)] {
+				continue
 			}
-
-			// Estimate max RPM if we have both values
-			if rpm > 0 && speed > 0 {
-				estimatedMaxRPM := (rpm * 100) / speed
-				fan.MaxRPM = estimatedMaxRPM
+			if name, rpm, ok := readFanInput(chip, channel); ok {
+				fans = append(fans, &Info{Name: name, RPM: rpm})
 			}
-
-			fans = append(fans, fan)
 		}
 	}
 
 	return fans, nil
 }
 
-// getFanRPMDirect attempts to read RPM directly from fan input files
-func (c *LinuxController) getFanRPMDirect(fanIndex int) int {
-	// Try to find corresponding fan input file
-	for _, fanPath := range c.fanPaths {
-		// Extract fan number from path (e.g., fan1_input -> 1)
-		baseName := filepath.Base(fanPath)
-		if strings.HasPrefix(baseName, fmt.Sprintf("fan%d_", fanIndex+1)) {
-			data, err := ioutil.ReadFile(fanPath)
-			if err != nil {
-				continue
-			}
-
-			rpm, err := strconv.Atoi(strings.TrimSpace(string(data)))
-			if err != nil {
-				continue
-			}
+// readFanInput reads a fan input with the lm-sensors configuration applied,
+// so that names match what `sensors` prints. Ignored inputs report false.
+func readFanInput(chip *hwmon.Chip, channel string) (string, int, bool) {
+	feature := chip.Feature(channel)
+	rpm, ok := chip.ReadInt(channel + "_input")
+	if !ok || feature.Ignore {
+		return "", 0, false
+	}
+	if feature.Compute != nil {
+		rpm = int64(feature.Compute.Eval(float64(rpm)))
+	}
 
-			return rpm
-		}
+	name := feature.Label
+	if name == "" {
+		name = chip.Read(channel + "_label")
+	}
+	if name == "" {
+		name = channel
 	}
-	return 0
+	return name, int(rpm), true
 }
 
 // getPWMSpeedPercent reads the current PWM value and converts to percentage
@@ -210,10 +188,14 @@ func (c *LinuxController) GetSettings(ctx context.Context, fanID int) (*Settings
 	}
 
 	// Check if fan is in curve mode
-	if state, exists := c.curveStates[fanID]; exists && state.isActive {
+	c.curvesMu.Lock()
+	state, exists := c.curveStates[fanID]
+	c.curvesMu.Unlock()
+	if exists && state.isActive {
 		return &Settings{
-			Mode:  ModeCurve,
-			Curve: state.curve,
+			Mode:   ModeCurve,
+			Curve:  state.curve,
+			Sensor: state.sensor,
 		}, nil
 	}
 
@@ -268,6 +250,12 @@ func (c *LinuxController) SetSettings(ctx context.Context, fanID int, settings *
 		return fmt.Errorf("insufficient permissions for fan control: %w", err)
 	}
 
+	if settings.Mode == ModeFixed {
+		if floor := c.getConfig().MinFixedSpeed; settings.FixedSpeed < floor {
+			return fmt.Errorf("fixed fan speed %d%% is below the configured minimum of %d%%", settings.FixedSpeed, floor)
+		}
+	}
+
 	switch settings.Mode {
 	case ModeFixed:
 		// Set to manual mode
@@ -319,6 +307,9 @@ func (c *LinuxController) SetSettings(ctx context.Context, fanID int, settings *
 				return fmt.Errorf("fan speed must be between 0 and 100%%")
 			}
 		}
+		if settings.Sensor != "" && c.getConfig().Sensors == nil {
+			return fmt.Errorf("sensor %s is not available as a curve input", settings.Sensor)
+		}
 
 		// Set to manual mode first
 		if err := ioutil.WriteFile(enablePath, []byte("1"), 0644); err != nil {
@@ -326,7 +317,7 @@ func (c *LinuxController) SetSettings(ctx context.Context, fanID int, settings *
 		}
 
 		// Start curve control
-		c.startFanCurve(fanID, sortedCurve)
+		c.startFanCurve(fanID, sortedCurve, settings.Sensor)
 
 	default:
 		return fmt.Errorf("unsupported fan mode: %s", settings.Mode)
@@ -352,36 +343,99 @@ func (c *LinuxController) checkWritePermissions(paths ...string) error {
 }
 
 // startFanCurve starts a goroutine to control the fan based on temperature curve
-func (c *LinuxController) startFanCurve(fanID int, curve []CurvePoint) {
+func (c *LinuxController) startFanCurve(fanID int, curve []CurvePoint, sensor string) {
 	state := &curveState{
 		curve:       curve,
+		sensor:      sensor,
 		isActive:    true,
 		stopChannel: make(chan bool),
 	}
+	state.lastRun.Store(time.Now().UnixNano())
+	c.curvesMu.Lock()
 	c.curveStates[fanID] = state
+	c.curvesMu.Unlock()
 
 	go func() {
-		ticker := time.NewTicker(2 * time.Second) // Update every 2 seconds
-		defer ticker.Stop()
-
 		for {
 			select {
 			case <-state.stopChannel:
 				return
-			case <-ticker.C:
-				temp := c.getCurrentTemperature()
-				if temp > 0 {
+			case <-time.After(c.getConfig().CurveInterval):
+				if sensor != "" {
+					c.applySensorCurve(fanID, state)
+				} else if temp := c.getCurrentTemperature(); temp > 0 {
 					fanSpeed := c.interpolateFanSpeed(curve, temp)
 					c.setPWMSpeed(fanID, fanSpeed)
 					state.lastTemp = temp
 				}
+				state.lastRun.Store(time.Now().UnixNano())
 			}
 		}
 	}()
 }
 
+// StalledCurves returns the fans in curve mode whose curve has not been
+// evaluated for longer than maxAge
+func (c *LinuxController) StalledCurves(maxAge time.Duration) []int {
+	c.curvesMu.Lock()
+	defer c.curvesMu.Unlock()
+
+	stalled := []int{}
+	for fanID, state := range c.curveStates {
+		if time.Since(time.Unix(0, state.lastRun.Load())) > maxAge {
+			stalled = append(stalled, fanID)
+		}
+	}
+	sort.Ints(stalled)
+	return stalled
+}
+
+// applySensorCurve drives a fan from a named sensor. A sensor that cannot be
+// read, e.g. a stale external source, runs the fan at the curve's highest speed.
+func (c *LinuxController) applySensorCurve(fanID int, state *curveState) {
+	lookup := c.getConfig().Sensors
+	if lookup == nil {
+		c.setPWMSpeed(fanID, state.curve[len(state.curve)-1].FanSpeed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.getConfig().CurveInterval)
+	defer cancel()
+
+	temp, err := lookup(ctx, state.sensor)
+	if err != nil {
+		c.setPWMSpeed(fanID, state.curve[len(state.curve)-1].FanSpeed)
+		return
+	}
+	state.lastTemp = int(math.Round(temp))
+	c.setPWMSpeed(fanID, c.interpolateFanSpeed(state.curve, state.lastTemp))
+}
+
+// Configure replaces the controller tunables
+func (c *LinuxController) Configure(config Config) {
+	if config.CurveInterval <= 0 {
+		config.CurveInterval = DefaultConfig().CurveInterval
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	c.config = config
+}
+
+// getConfig returns the current controller tunables
+func (c *LinuxController) getConfig() Config {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+
+	return c.config
+}
+
 // stopFanCurve stops the curve control for a fan
 func (c *LinuxController) stopFanCurve(fanID int) {
+	c.curvesMu.Lock()
+	defer c.curvesMu.Unlock()
+
 	if state, exists := c.curveStates[fanID]; exists && state.isActive {
 		state.isActive = false
 		close(state.stopChannel)
@@ -393,9 +447,9 @@ func (c *LinuxController) stopFanCurve(fanID int) {
 func (c *LinuxController) getCurrentTemperature() int {
 	// Try to get CPU temperature from common paths
 	tempPaths := []string{
-		"/sys/class/thermal/thermal_zone0/temp",
-		"/sys/class/hwmon/hwmon0/temp1_input",
-		"/sys/class/hwmon/hwmon1/temp1_input",
+		platform.HostSys("class", "thermal", "thermal_zone0", "temp"),
+		platform.HostSys("class", "hwmon", "hwmon0", "temp1_input"),
+		platform.HostSys("class", "hwmon", "hwmon1", "temp1_input"),
 	}
 
 	// Also try discovered temperature paths
@@ -5,6 +5,7 @@ package fan
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // UnsupportedController is a fallback for unsupported platforms
@@ -29,3 +30,11 @@ func (c *UnsupportedController) GetSettings(ctx context.Context, fanID int) (*Se
 func (c *UnsupportedController) SetSettings(ctx context.Context, fanID int, settings *Settings) error {
 	return fmt.Errorf("fan control not supported on this platform")
 }
+
+// Configure does nothing on unsupported platforms
+func (c *UnsupportedController) Configure(config Config) {}
+
+// StalledCurves returns nothing on unsupported platforms
+func (c *UnsupportedController) StalledCurves(maxAge time.Duration) []int {
+	return nil
+}
@@ -5,6 +5,8 @@ package fan
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/StackExchange/wmi"
 )
@@ -12,6 +14,9 @@ import (
 // WindowsController implements fan control for Windows
 type WindowsController struct {
 	fans []WindowsFanInfo
+
+	configMu sync.RWMutex
+	config   Config
 }
 
 // WindowsFanInfo represents a Windows fan device
@@ -67,7 +72,7 @@ type Win32_OperatingSystem struct {
 
 // newPlatformController creates a new Windows fan controller
 func newPlatformController() Controller {
-	controller := &WindowsController{}
+	controller := &WindowsController{config: DefaultConfig()}
 	controller.discoverFans()
 	return controller
 }
@@ -186,10 +191,11 @@ func (c *WindowsController) GetFans(ctx context.Context) ([]*Info, error) {
 
 	for _, fanInfo := range c.fans {
 		info := &Info{
-			Name:   fanInfo.Name,
-			RPM:    fanInfo.RPM,
-			Speed:  fanInfo.Speed,
-			MaxRPM: fanInfo.MaxRPM,
+			Name:         fanInfo.Name,
+			RPM:          fanInfo.RPM,
+			Speed:        fanInfo.Speed,
+			MaxRPM:       fanInfo.MaxRPM,
+			Controllable: fanInfo.Controllable,
 		}
 		fans = append(fans, info)
 	}
@@ -229,6 +235,12 @@ func (c *WindowsController) SetSettings(ctx context.Context, fanID int, settings
 
 	switch settings.Mode {
 	case ModeFixed:
+		c.configMu.RLock()
+		floor := c.config.MinFixedSpeed
+		c.configMu.RUnlock()
+		if settings.FixedSpeed < floor {
+			return fmt.Errorf("fixed fan speed %d%% is below the configured minimum of %d%%", settings.FixedSpeed, floor)
+		}
 		return c.setFixedSpeed(fanID, settings.FixedSpeed)
 	case ModeAuto:
 		return c.setAutoMode(fanID)
@@ -239,6 +251,19 @@ func (c *WindowsController) SetSettings(ctx context.Context, fanID int, settings
 	}
 }
 
+// Configure replaces the controller tunables; curves are not supported on Windows
+func (c *WindowsController) Configure(config Config) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	c.config = config
+}
+
+// StalledCurves returns nothing; curves are not supported on Windows
+func (c *WindowsController) StalledCurves(maxAge time.Duration) []int {
+	return nil
+}
+
 // setFixedSpeed sets a fixed fan speed
 func (c *WindowsController) setFixedSpeed(fanID int, speedPercent int) error {
 	if speedPercent < 0 || speedPercent > 100 {
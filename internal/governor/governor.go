@@ -0,0 +1,715 @@
+package governor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+	"github.com/CristiGvl/picoHWMon/internal/temps"
+)
+
+// Level is how far the governor has escalated
+type Level int
+
+// Escalation levels, in the order they are applied
+const (
+	LevelNormal Level = iota
+	LevelFansMax
+	LevelOverclockReset
+	LevelPowerLimited
+	LevelCommand
+)
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelNormal:
+		return "normal"
+	case LevelFansMax:
+		return "fans_max"
+	case LevelOverclockReset:
+		return "overclock_reset"
+	case LevelPowerLimited:
+		return "power_limited"
+	case LevelCommand:
+		return "command"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText writes the level name
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+const (
+	// maxEvents is the number of governor events kept
+	maxEvents = 100
+	// stockPowerLimit is restored on recovery for GPUs whose power limit
+	// could not be read when the governor engaged
+	stockPowerLimit = 100
+)
+
+// Config holds the governor tunables
+type Config struct {
+	Enabled bool
+	// Interval is how often sensors are checked
+	Interval time.Duration
+	// Margin engages the governor when a sensor is within this many degrees of its critical value
+	Margin float64
+	// DefaultCritical is used for sensors that report no critical value
+	DefaultCritical float64
+	// StepDelay is how long a step gets to bring temperatures down before the next one
+	StepDelay time.Duration
+	// PowerLimit is the GPU power limit in percent applied by the power step
+	PowerLimit int
+	// Command is run by the last step; without it the governor stops at the power step
+	Command []string
+	// CommandTimeout is how long Command may run before it is killed
+	CommandTimeout time.Duration
+	// RecoveryMargin and RecoveryDelay control when the governor stands down:
+	// every sensor must stay this far below critical for this long
+	RecoveryMargin float64
+	RecoveryDelay  time.Duration
+}
+
+// DefaultConfig returns the default governor tunables
+func DefaultConfig() Config {
+	return Config{
+		Enabled:         true,
+		Interval:        2 * time.Second,
+		Margin:          5,
+		DefaultCritical: 95,
+		StepDelay:       10 * time.Second,
+		PowerLimit:      70,
+		CommandTimeout:  5 * time.Minute,
+		RecoveryMargin:  10,
+		RecoveryDelay:   time.Minute,
+	}
+}
+
+// Reading is the sensor closest to its critical value
+type Reading struct {
+	Category    string  `json:"category"`
+	Name        string  `json:"name"`
+	Label       string  `json:"label,omitempty"`
+	Temperature float64 `json:"temperature_celsius"`
+	Critical    float64 `json:"critical_celsius"`
+}
+
+// Event records an escalation or recovery step
+type Event struct {
+	Time    time.Time `json:"time"`
+	Level   Level     `json:"level"`
+	Action  string    `json:"action"`
+	Reason  string    `json:"reason"`
+	Errors  []string  `json:"errors,omitempty"`
+	Reading *Reading  `json:"reading,omitempty"`
+}
+
+// Status describes the state of the governor
+type Status struct {
+	Enabled     bool       `json:"enabled"`
+	Level       Level      `json:"level"`
+	Engaged     bool       `json:"engaged"`
+	Since       *time.Time `json:"since,omitempty"`
+	LastStepAt  *time.Time `json:"last_step_at,omitempty"`
+	Hottest     *Reading   `json:"hottest,omitempty"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastEvent   *Event     `json:"last_event,omitempty"`
+}
+
+// Governor protects the hardware from overheating by escalating through
+// progressively stronger measures, regardless of user fan and overclock settings
+type Governor struct {
+	tempsReader         temps.Reader
+	gpuReader           gpu.Reader
+	fanController       fan.Controller
+	overclockController overclock.Controller
+	auditLog            *audit.Log
+
+	// mu is held while the governor acts on the hardware
+	mu          sync.Mutex
+	config      Config
+	level       Level
+	since       time.Time
+	lastStep    time.Time
+	coolSince   time.Time
+	hottest     *Reading
+	lastChecked time.Time
+	lastError   string
+	// commandRunning is set while the emergency command runs in the background
+	commandRunning bool
+
+	// status and events are published separately so health checks don't
+	// wait for hardware calls
+	status   atomic.Pointer[Status]
+	eventsMu sync.Mutex
+	events   []*Event
+
+	// Settings from before the governor engaged, restored on recovery
+	savedFans        map[int]*fan.Settings
+	savedGPUFans     map[int]int
+	savedPowerLimits map[int]int
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// New creates a governor that reads sensors through tempsReader, acts
+// through the fan and overclock controllers and records its actions in
+// auditLog, which may be nil
+func New(tempsReader temps.Reader, gpuReader gpu.Reader, fanController fan.Controller, overclockController overclock.Controller, auditLog *audit.Log) *Governor {
+	return &Governor{
+		tempsReader:         tempsReader,
+		gpuReader:           gpuReader,
+		fanController:       fanController,
+		overclockController: overclockController,
+		auditLog:            auditLog,
+		config:              DefaultConfig(),
+	}
+}
+
+// Configure replaces the governor tunables. Disabling an engaged governor
+// restores the saved fan settings.
+func (g *Governor) Configure(config Config) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.config = config
+	if !config.Enabled && g.level > LevelNormal {
+		g.recover("governor disabled")
+	}
+	g.publish()
+}
+
+// Start begins monitoring in the background
+func (g *Governor) Start() {
+	g.stop = make(chan struct{})
+	g.stopped = make(chan struct{})
+	go g.run()
+}
+
+// Stop stops monitoring. Measures in effect stay in place.
+func (g *Governor) Stop() {
+	if g.stop == nil {
+		return
+	}
+	close(g.stop)
+	<-g.stopped
+}
+
+// GetStatus returns the current governor state
+func (g *Governor) GetStatus() *Status {
+	if status := g.status.Load(); status != nil {
+		return status
+	}
+	return &Status{Enabled: DefaultConfig().Enabled}
+}
+
+// publish updates the status returned by GetStatus; the caller holds g.mu
+func (g *Governor) publish() {
+	status := &Status{
+		Enabled:   g.config.Enabled,
+		Level:     g.level,
+		Engaged:   g.level > LevelNormal,
+		Hottest:   g.hottest,
+		LastError: g.lastError,
+	}
+	if status.Engaged {
+		since, lastStep := g.since, g.lastStep
+		status.Since = &since
+		status.LastStepAt = &lastStep
+	}
+	if !g.lastChecked.IsZero() {
+		lastChecked := g.lastChecked
+		status.LastChecked = &lastChecked
+	}
+
+	g.eventsMu.Lock()
+	if len(g.events) > 0 {
+		status.LastEvent = g.events[len(g.events)-1]
+	}
+	g.eventsMu.Unlock()
+
+	g.status.Store(status)
+}
+
+// GetEvents returns the governor events, newest first
+func (g *Governor) GetEvents() []*Event {
+	g.eventsMu.Lock()
+	defer g.eventsMu.Unlock()
+
+	events := make([]*Event, 0, len(g.events))
+	for i := len(g.events) - 1; i >= 0; i-- {
+		events = append(events, g.events[i])
+	}
+	return events
+}
+
+// run checks the sensors until stopped
+func (g *Governor) run() {
+	defer close(g.stopped)
+
+	for {
+		g.mu.Lock()
+		interval := g.config.Interval
+		enabled := g.config.Enabled
+		g.mu.Unlock()
+
+		if enabled {
+			g.check()
+		}
+
+		select {
+		case <-g.stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// check reads the sensors once and escalates or recovers
+func (g *Governor) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := g.tempsReader.GetInfo(ctx)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	defer g.publish()
+
+	now := time.Now()
+	g.lastChecked = now
+	if err != nil {
+		g.lastError = err.Error()
+		return
+	}
+	g.lastError = ""
+
+	hottest, headroom := g.hottestSensor(info)
+	g.hottest = hottest
+	if hottest == nil {
+		return
+	}
+
+	if headroom <= g.config.Margin {
+		g.coolSince = time.Time{}
+		switch {
+		case g.level == LevelNormal:
+			g.since = now
+			// Profiles, rules and API clients must not re-apply an overclock
+			// or power limit underneath the governor
+			g.overclockController.Block(fmt.Sprintf("thermal protection is engaged (%s at %.1f°C)", hottest.Name, hottest.Temperature))
+			g.escalate(ctx, hottest, now)
+		case now.Sub(g.lastStep) >= g.config.StepDelay && g.level < g.maxLevel():
+			g.escalate(ctx, hottest, now)
+		default:
+			// Keep the fans at full speed even if something else changed them
+			g.reassertFans(ctx, hottest)
+		}
+		return
+	}
+
+	if g.level == LevelNormal {
+		return
+	}
+	if headroom < g.config.RecoveryMargin {
+		g.coolSince = time.Time{}
+		g.reassertFans(ctx, hottest)
+		return
+	}
+	if g.coolSince.IsZero() {
+		g.coolSince = now
+	}
+	if now.Sub(g.coolSince) >= g.config.RecoveryDelay {
+		g.recover(fmt.Sprintf("%s cooled to %.1f°C (critical %.1f°C)", hottest.Name, hottest.Temperature, hottest.Critical))
+	}
+}
+
+// hottestSensor returns the CPU or GPU sensor with the least headroom to its
+// critical value; the caller holds g.mu
+func (g *Governor) hottestSensor(info *temps.Info) (*Reading, float64) {
+	var hottest *Reading
+	var headroom float64
+	for category, sensors := range map[string][]*temps.Sensor{"cpu": info.CPU, "gpu": info.GPU} {
+		for _, sensor := range sensors {
+			if sensor.Temperature <= 0 {
+				continue
+			}
+			critical := sensor.Critical
+			if critical <= 0 {
+				critical = g.config.DefaultCritical
+			}
+			if h := critical - sensor.Temperature; hottest == nil || h < headroom {
+				hottest = &Reading{
+					Category:    category,
+					Name:        sensor.Name,
+					Label:       sensor.Label,
+					Temperature: sensor.Temperature,
+					Critical:    critical,
+				}
+				headroom = h
+			}
+		}
+	}
+	return hottest, headroom
+}
+
+// maxLevel is the last step available with the current configuration; the caller holds g.mu
+func (g *Governor) maxLevel() Level {
+	if len(g.config.Command) > 0 {
+		return LevelCommand
+	}
+	return LevelPowerLimited
+}
+
+// escalate applies the next step; the caller holds g.mu
+func (g *Governor) escalate(ctx context.Context, reading *Reading, now time.Time) {
+	g.level++
+	g.lastStep = now
+	reason := fmt.Sprintf("%s at %.1f°C (critical %.1f°C)", reading.Name, reading.Temperature, reading.Critical)
+
+	var action string
+	var errs []string
+	switch g.level {
+	case LevelFansMax:
+		action = "ramped all fans to 100%"
+		g.saveSettings(ctx)
+		_, errs = g.maxFans(ctx)
+	case LevelOverclockReset:
+		action = "reset GPU overclock to stock clocks"
+		errs = g.resetOverclock(ctx)
+	case LevelPowerLimited:
+		action = fmt.Sprintf("lowered GPU power limit to %d%%", g.config.PowerLimit)
+		errs = g.setPowerLimit(ctx, g.config.PowerLimit)
+	case LevelCommand:
+		action = "started emergency command " + strings.Join(g.config.Command, " ")
+		if !g.startCommand(reading) {
+			action = "emergency command is still running from an earlier step"
+		}
+	}
+	if (g.level == LevelOverclockReset || g.level == LevelPowerLimited) && len(g.gpuDevices(ctx)) == 0 {
+		action += " (no GPUs found)"
+	}
+
+	g.record(&Event{Time: now, Level: g.level, Action: action, Reason: reason, Errors: errs, Reading: reading})
+}
+
+// recover restores the saved fan settings and GPU power limits and lifts the
+// overclock block; the caller holds g.mu
+func (g *Governor) recover(reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var errs []string
+	action := "restored fan settings"
+	for fanID, settings := range g.savedFans {
+		if err := g.fanController.SetSettings(ctx, fanID, settings); err != nil {
+			errs = append(errs, fmt.Sprintf("fan %d: %v", fanID, err))
+		}
+	}
+	for deviceID, speed := range g.savedGPUFans {
+		if err := g.updateGPU(ctx, deviceID, func(s *overclock.Settings) { s.FanSpeed = speed }); err != nil {
+			errs = append(errs, fmt.Sprintf("GPU %d fan: %v", deviceID, err))
+		}
+	}
+	if g.level >= LevelPowerLimited {
+		action += " and GPU power limits"
+		errs = append(errs, g.restorePowerLimits(ctx)...)
+	}
+	if g.level >= LevelOverclockReset {
+		action += "; GPU overclock stays at stock until settings or a profile are applied again"
+	}
+
+	g.level = LevelNormal
+	g.savedFans = nil
+	g.savedGPUFans = nil
+	g.savedPowerLimits = nil
+	g.coolSince = time.Time{}
+	g.overclockController.Block("")
+	g.record(&Event{Time: time.Now(), Level: LevelNormal, Action: action, Reason: reason, Errors: errs, Reading: g.hottest})
+}
+
+// saveSettings remembers the fan settings and GPU power limits to restore on
+// recovery; the caller holds g.mu
+func (g *Governor) saveSettings(ctx context.Context) {
+	g.savedFans = make(map[int]*fan.Settings)
+	g.savedGPUFans = make(map[int]int)
+	g.savedPowerLimits = make(map[int]int)
+
+	if fans, err := g.fanController.GetFans(ctx); err == nil {
+		for fanID, info := range fans {
+			if !info.Controllable {
+				continue
+			}
+			if settings, err := g.fanController.GetSettings(ctx, fanID); err == nil {
+				g.savedFans[fanID] = settings
+			}
+		}
+	}
+	for _, deviceID := range g.gpuDevices(ctx) {
+		if settings, err := g.overclockController.GetSettings(ctx, deviceID); err == nil {
+			g.savedGPUFans[deviceID] = settings.FanSpeed
+			if settings.PowerLimit > 0 {
+				g.savedPowerLimits[deviceID] = settings.PowerLimit
+			}
+		}
+	}
+}
+
+// reassertFans puts fans that something else changed back to full speed and
+// audits it; the caller holds g.mu
+func (g *Governor) reassertFans(ctx context.Context, reading *Reading) {
+	changed, errs := g.maxFans(ctx)
+	if changed == 0 && len(errs) == 0 {
+		return
+	}
+	g.audit(&Event{
+		Time:    time.Now(),
+		Level:   g.level,
+		Action:  fmt.Sprintf("ramped %d fans back to 100%%", changed),
+		Reason:  "fan settings changed while the governor is engaged",
+		Errors:  errs,
+		Reading: reading,
+	})
+}
+
+// maxFans sets every system and GPU fan to full speed and returns how many
+// it changed; the caller holds g.mu
+func (g *Governor) maxFans(ctx context.Context) (int, []string) {
+	changed := 0
+	var errs []string
+	full := &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: 100}
+	fans, err := g.fanController.GetFans(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("fans: %v", err))
+	}
+	for fanID, info := range fans {
+		if !info.Controllable {
+			continue
+		}
+		if current, err := g.fanController.GetSettings(ctx, fanID); err == nil && current.Mode == fan.ModeFixed && current.FixedSpeed == 100 {
+			continue
+		}
+		changed++
+		if err := g.fanController.SetSettings(ctx, fanID, full); err != nil {
+			errs = append(errs, fmt.Sprintf("fan %d: %v", fanID, err))
+		}
+	}
+	for _, deviceID := range g.gpuDevices(ctx) {
+		if current, err := g.overclockController.GetSettings(ctx, deviceID); err == nil && current.FanSpeed == 100 {
+			continue
+		}
+		changed++
+		if err := g.updateGPU(ctx, deviceID, func(s *overclock.Settings) { s.FanSpeed = 100 }); err != nil {
+			errs = append(errs, fmt.Sprintf("GPU %d fan: %v", deviceID, err))
+		}
+	}
+	return changed, errs
+}
+
+// resetOverclock rolls back pending overclock transactions and sets every GPU
+// to stock clocks and voltage; the caller holds g.mu
+func (g *Governor) resetOverclock(ctx context.Context) []string {
+	var errs []string
+	if transactions, err := g.overclockController.GetTransactions(ctx); err == nil {
+		for _, tx := range transactions {
+			if tx.State != overclock.TransactionPending {
+				continue
+			}
+			if _, err := g.overclockController.RollbackTransaction(ctx, tx.ID); err != nil {
+				errs = append(errs, fmt.Sprintf("transaction %s: %v", tx.ID, err))
+			}
+		}
+	}
+
+	for _, deviceID := range g.gpuDevices(ctx) {
+		err := g.updateGPU(ctx, deviceID, func(s *overclock.Settings) {
+			s.CoreClockOffset = 0
+			s.MemoryClockOffset = 0
+			s.VoltageOffset = 0
+			s.FanSpeed = 100
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("GPU %d: %v", deviceID, err))
+		}
+	}
+	return errs
+}
+
+// setPowerLimit applies a power limit to every GPU, clamped to the range the
+// GPU supports; the caller holds g.mu
+func (g *Governor) setPowerLimit(ctx context.Context, percent int) []string {
+	var errs []string
+	for _, deviceID := range g.gpuDevices(ctx) {
+		percent := percent
+		if caps, err := g.gpuReader.GetCapabilities(ctx, deviceID); err == nil && caps.PowerLimit != nil {
+			percent = int(math.Max(caps.PowerLimit.Min, math.Min(caps.PowerLimit.Max, float64(percent))))
+		}
+		if err := g.updateGPU(ctx, deviceID, func(s *overclock.Settings) { s.PowerLimit = percent }); err != nil {
+			errs = append(errs, fmt.Sprintf("GPU %d: %v", deviceID, err))
+		}
+	}
+	return errs
+}
+
+// restorePowerLimits restores the power limits saved when the governor
+// engaged; the caller holds g.mu
+func (g *Governor) restorePowerLimits(ctx context.Context) []string {
+	var errs []string
+	for _, deviceID := range g.gpuDevices(ctx) {
+		percent, ok := g.savedPowerLimits[deviceID]
+		if !ok {
+			percent = stockPowerLimit
+		}
+		if err := g.updateGPU(ctx, deviceID, func(s *overclock.Settings) { s.PowerLimit = percent }); err != nil {
+			errs = append(errs, fmt.Sprintf("GPU %d: %v", deviceID, err))
+		}
+	}
+	return errs
+}
+
+// updateGPU changes some settings of a GPU and keeps the others, bypassing
+// the overclock block the governor holds
+func (g *Governor) updateGPU(ctx context.Context, deviceID int, change func(*overclock.Settings)) error {
+	settings, err := g.overclockController.GetSettings(ctx, deviceID)
+	if err != nil {
+		settings = &overclock.Settings{}
+	}
+	settings.DeviceID = deviceID
+	change(settings)
+
+	result, err := g.overclockController.ForceSettings(ctx, settings)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("%s", strings.Join(result.Errors, "; "))
+	}
+	return nil
+}
+
+// gpuDevices returns the IDs of the installed GPUs
+func (g *Governor) gpuDevices(ctx context.Context) []int {
+	gpus, err := g.gpuReader.GetInfo(ctx)
+	if err != nil {
+		return nil
+	}
+	ids := make([]int, len(gpus))
+	for i := range gpus {
+		ids[i] = i
+	}
+	return ids
+}
+
+// startCommand runs the configured emergency command in the background with
+// the hottest sensor in PICOHWMON_GOVERNOR_* environment variables, so it is
+// neither bounded by the check's timeout nor holds up the next check. A
+// failure, including being killed after CommandTimeout, is recorded as an
+// event of its own. It reports false if the command is still running; the
+// caller holds g.mu.
+func (g *Governor) startCommand(reading *Reading) bool {
+	if g.commandRunning {
+		return false
+	}
+	g.commandRunning = true
+	command, timeout, level := g.config.Command, g.config.CommandTimeout, g.level
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Env = append(cmd.Environ(),
+			"PICOHWMON_GOVERNOR_SENSOR="+reading.Name,
+			fmt.Sprintf("PICOHWMON_GOVERNOR_TEMPERATURE=%.1f", reading.Temperature),
+			fmt.Sprintf("PICOHWMON_GOVERNOR_CRITICAL=%.1f", reading.Critical),
+		)
+		output, err := cmd.CombinedOutput()
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.commandRunning = false
+		if err == nil {
+			return
+		}
+		action := "emergency command failed"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			action = fmt.Sprintf("emergency command killed after %s", timeout)
+		}
+		message := err.Error()
+		if output := strings.TrimSpace(string(output)); output != "" {
+			message += ": " + output
+		}
+		g.record(&Event{Time: time.Now(), Level: level, Action: action, Reason: strings.Join(command, " "),
+			Errors: []string{message}, Reading: reading})
+		g.publish()
+	}()
+	return true
+}
+
+// record logs and audits an event and keeps it; the caller holds g.mu
+func (g *Governor) record(event *Event) {
+	g.audit(event)
+
+	g.eventsMu.Lock()
+	defer g.eventsMu.Unlock()
+
+	g.events = append(g.events, event)
+	if len(g.events) > maxEvents {
+		g.events = g.events[len(g.events)-maxEvents:]
+	}
+}
+
+// audit logs an event and writes it to the audit log
+func (g *Governor) audit(event *Event) {
+	attrs := []any{"level", event.Level.String(), "action", event.Action, "reason", event.Reason}
+	switch {
+	case len(event.Errors) > 0:
+		slog.Error("Thermal governor", append(attrs, "errors", strings.Join(event.Errors, "; "))...)
+	case event.Level > LevelNormal:
+		slog.Warn("Thermal governor", attrs...)
+	default:
+		slog.Info("Thermal governor", attrs...)
+	}
+
+	if g.auditLog == nil {
+		return
+	}
+	entry := &audit.Entry{
+		Time:       event.Time,
+		ClientAddr: "local",
+		Identity:   "governor",
+		AuthMethod: "internal",
+		Method:     "GOVERNOR",
+		Endpoint:   "governor/" + event.Level.String(),
+		Result:     audit.ResultSuccess,
+	}
+	entry.Details, _ = json.Marshal(event)
+	if len(event.Errors) > 0 {
+		entry.Result = audit.ResultPartial
+		entry.Error = strings.Join(event.Errors, "; ")
+	}
+	if err := g.auditLog.Record(entry); err != nil {
+		slog.Error("Failed to record governor action in the audit log", "error", err)
+	}
+}
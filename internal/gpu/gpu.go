@@ -1,6 +1,10 @@
 package gpu
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"math"
+)
 
 // Vendor represents GPU vendor
 type Vendor string
@@ -25,25 +29,136 @@ type Info struct {
 	ClockMemory int     `json:"clock_memory_mhz"`
 }
 
-// OverclockSettings represents GPU overclocking settings
+// OverclockSettings represents GPU overclocking settings.
+// Clock and voltage offsets are always applied (0 restores stock values),
+// while a zero PowerLimit or TempLimit leaves the current value untouched
+// and a zero FanSpeed hands the fan back to automatic control.
 type OverclockSettings struct {
-	CoreClockOffset   int `json:"core_clock_offset_mhz"`
-	MemoryClockOffset int `json:"memory_clock_offset_mhz"`
-	PowerLimit        int `json:"power_limit_percent"`
-	FanSpeed          int `json:"fan_speed_percent"`
+	CoreClockOffset   int     `json:"core_clock_offset_mhz"`
+	MemoryClockOffset int     `json:"memory_clock_offset_mhz"`
+	PowerLimit        int     `json:"power_limit_percent"`
+	TempLimit         int     `json:"temp_limit_celsius"`
+	FanSpeed          int     `json:"fan_speed_percent"`
+	VoltageOffset     float64 `json:"voltage_offset_mv"`
+}
+
+// FieldStatus represents the outcome of applying a single overclock field
+type FieldStatus string
+
+const (
+	FieldApplied     FieldStatus = "applied"
+	FieldFailed      FieldStatus = "failed"
+	FieldSkipped     FieldStatus = "skipped"
+	FieldUnsupported FieldStatus = "unsupported"
+)
+
+// Overclock field names used in FieldResult
+const (
+	FieldCoreClockOffset   = "core_clock_offset_mhz"
+	FieldMemoryClockOffset = "memory_clock_offset_mhz"
+	FieldPowerLimit        = "power_limit_percent"
+	FieldTempLimit         = "temp_limit_celsius"
+	FieldFanSpeed          = "fan_speed_percent"
+	FieldVoltageOffset     = "voltage_offset_mv"
+)
+
+// FieldResult represents the result of applying one overclock field
+type FieldResult struct {
+	Field   string      `json:"field"`
+	Value   float64     `json:"value"`
+	Status  FieldStatus `json:"status"`
+	Message string      `json:"message,omitempty"`
 }
 
 // OverclockResult represents the result of an overclocking operation
 type OverclockResult struct {
-	Success  bool     `json:"success"`
-	Applied  []string `json:"applied"`
-	Warnings []string `json:"warnings"`
-	Errors   []string `json:"errors"`
+	DeviceID int            `json:"device_id"`
+	Success  bool           `json:"success"`
+	Fields   []*FieldResult `json:"fields"`
+	Applied  []string       `json:"applied"`
+	Warnings []string       `json:"warnings"`
+	Errors   []string       `json:"errors"`
+}
+
+// newOverclockResult creates an empty, successful result for a device
+func newOverclockResult(deviceID int) *OverclockResult {
+	return &OverclockResult{
+		DeviceID: deviceID,
+		Success:  true,
+		Fields:   []*FieldResult{},
+		Applied:  []string{},
+		Warnings: []string{},
+		Errors:   []string{},
+	}
+}
+
+// applied records a field that was written to the hardware
+func (r *OverclockResult) applied(field string, value float64, summary string) {
+	r.Fields = append(r.Fields, &FieldResult{Field: field, Value: value, Status: FieldApplied})
+	r.Applied = append(r.Applied, summary)
+}
+
+// failed records a field that could not be written and marks the result as failed
+func (r *OverclockResult) failed(field string, value float64, err error) {
+	msg := fmt.Sprintf("failed to set %s: %v", field, err)
+	r.Fields = append(r.Fields, &FieldResult{Field: field, Value: value, Status: FieldFailed, Message: err.Error()})
+	r.Errors = append(r.Errors, msg)
+	r.Success = false
+}
+
+// unsupported records a field the backend cannot change as a warning
+func (r *OverclockResult) unsupported(field string, value float64, reason string) {
+	r.Fields = append(r.Fields, &FieldResult{Field: field, Value: value, Status: FieldUnsupported, Message: reason})
+	r.Warnings = append(r.Warnings, fmt.Sprintf("%s: %s", field, reason))
+}
+
+// skipped records a field that was left untouched
+func (r *OverclockResult) skipped(field string, reason string) {
+	r.Fields = append(r.Fields, &FieldResult{Field: field, Status: FieldSkipped, Message: reason})
+}
+
+// Range represents an inclusive range of allowed values
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Contains reports whether value lies within the range
+func (r *Range) Contains(value float64) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+// Capabilities describes the overclock ranges a device reports.
+// A nil range means the field cannot be adjusted or the backend cannot
+// report its limits.
+type Capabilities struct {
+	DeviceID          int     `json:"device_id"`
+	Vendor            Vendor  `json:"vendor"`
+	CoreClockOffset   *Range  `json:"core_clock_offset_mhz"`
+	MemoryClockOffset *Range  `json:"memory_clock_offset_mhz"`
+	PowerLimit        *Range  `json:"power_limit_percent"`
+	DefaultPowerLimit float64 `json:"default_power_limit_watts,omitempty"`
+	TempLimit         *Range  `json:"temp_limit_celsius"`
+	FanSpeed          *Range  `json:"fan_speed_percent"`
+	VoltageOffset     *Range  `json:"voltage_offset_mv"`
+}
+
+// percentRange converts an absolute min/max into whole percentages of def,
+// rounding inwards so the bounds are always achievable
+func percentRange(min, max, def float64) *Range {
+	if def <= 0 || max < min {
+		return nil
+	}
+	return &Range{
+		Min: math.Ceil(min / def * 100),
+		Max: math.Floor(max / def * 100),
+	}
 }
 
 // Reader interface for GPU monitoring
 type Reader interface {
 	GetInfo(ctx context.Context) ([]*Info, error)
+	GetCapabilities(ctx context.Context, deviceID int) (*Capabilities, error)
 	GetOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error)
 	SetOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) (*OverclockResult, error)
 }
@@ -6,19 +6,30 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
 )
 
 // LinuxReader implements GPU monitoring for Linux
-type LinuxReader struct{}
+type LinuxReader struct {
+	mu sync.Mutex
+	// odBaselines holds the stock overdrive table of each AMD card so clock
+	// offsets can be expressed relative to the stock levels
+	odBaselines map[string]*odTable
+}
 
 // newPlatformReader creates a new Linux GPU reader
 func newPlatformReader() Reader {
-	return &LinuxReader{}
+	return &LinuxReader{
+		odBaselines: make(map[string]*odTable),
+	}
 }
 
 // NvidiaSMIOutput represents nvidia-smi XML output structure
@@ -35,7 +46,12 @@ type NvidiaSMIOutput struct {
 		} `xml:"utilization"`
 		Temperature struct {
 			Current string `xml:"gpu_temp"`
+			Target  string `xml:"gpu_target_temperature"`
 		} `xml:"temperature"`
+		SupportedTargetTemp struct {
+			Min string `xml:"gpu_target_temp_min"`
+			Max string `xml:"gpu_target_temp_max"`
+		} `xml:"supported_gpu_target_temp"`
 		PowerReadings struct {
 			PowerDraw string `xml:"power_draw"`
 		} `xml:"power_readings"`
@@ -172,7 +188,7 @@ func (r *LinuxReader) getAMDGPUsFromSysfs(ctx context.Context) ([]*Info, error)
 	var gpus []*Info
 
 	// Look for AMD GPUs in /sys/class/drm/
-	drmPath := "/sys/class/drm"
+	drmPath := platform.HostSys("class", "drm")
 	entries, err := os.ReadDir(drmPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read DRM directory: %w", err)
@@ -328,380 +344,607 @@ func findAMDHwmonPath(cardPath string) string {
 	return ""
 }
 
+// resolveDevice maps a device ID (an index into GetInfo) to its vendor and
+// to the device's index among GPUs of that vendor
+func (r *LinuxReader) resolveDevice(ctx context.Context, deviceID int) (Vendor, int, error) {
+	gpus, err := r.GetInfo(ctx)
+	if err != nil || deviceID < 0 || deviceID >= len(gpus) {
+		return Unknown, 0, fmt.Errorf("GPU device %d not found", deviceID)
+	}
+
+	vendor := gpus[deviceID].Vendor
+	vendorIndex := 0
+	for i := 0; i < deviceID; i++ {
+		if gpus[i].Vendor == vendor {
+			vendorIndex++
+		}
+	}
+
+	return vendor, vendorIndex, nil
+}
+
 // GetOverclockSettings returns current overclock settings
 func (r *LinuxReader) GetOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error) {
-	// First check what type of GPU this is
-	gpus, err := r.GetInfo(ctx)
-	if err != nil || deviceID >= len(gpus) {
-		return nil, fmt.Errorf("GPU device %d not found", deviceID)
+	vendor, index, err := r.resolveDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
 	}
 
-	gpu := gpus[deviceID]
+	switch vendor {
+	case NVIDIA:
+		return r.getNvidiaOverclockSettings(ctx, index)
+	case AMD:
+		return r.getAMDOverclockSettings(ctx, index)
+	default:
+		return nil, fmt.Errorf("overclocking not supported for %s GPUs", vendor)
+	}
+}
+
+// SetOverclockSettings applies overclock settings with detailed results
+func (r *LinuxReader) SetOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) (*OverclockResult, error) {
+	vendor, index, err := r.resolveDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
 
-	switch gpu.Vendor {
+	var result *OverclockResult
+	switch vendor {
 	case NVIDIA:
-		return r.getNvidiaOverclockSettings(ctx, deviceID)
+		result, err = r.setNvidiaOverclockSettings(ctx, index, settings)
 	case AMD:
-		return r.getAMDOverclockSettings(ctx, deviceID)
+		result, err = r.setAMDOverclockSettings(ctx, index, settings)
 	default:
-		return nil, fmt.Errorf("overclocking not supported for %s GPUs", gpu.Vendor)
+		return nil, fmt.Errorf("overclocking not supported for %s GPUs", vendor)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.DeviceID = deviceID
+	return result, nil
 }
 
-// getNvidiaOverclockSettings gets NVIDIA GPU overclock settings
-func (r *LinuxReader) getNvidiaOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error) {
-	// Check if nvidia-settings is available
-	cmd := exec.CommandContext(ctx, "nvidia-settings", "-q", fmt.Sprintf("[gpu:%d]/GPUGraphicsClockOffset[3]", deviceID))
-	output, err := cmd.Output()
+// GetCapabilities returns the overclock ranges supported by a device
+func (r *LinuxReader) GetCapabilities(ctx context.Context, deviceID int) (*Capabilities, error) {
+	vendor, index, err := r.resolveDevice(ctx, deviceID)
 	if err != nil {
-		return nil, fmt.Errorf("nvidia-settings not available or GPU not found: %w", err)
+		return nil, err
 	}
 
-	settings := &OverclockSettings{}
+	var caps *Capabilities
+	switch vendor {
+	case NVIDIA:
+		caps, err = r.getNvidiaCapabilities(ctx, index)
+	case AMD:
+		caps, err = r.getAMDCapabilities(ctx, index)
+	default:
+		return nil, fmt.Errorf("overclocking not supported for %s GPUs", vendor)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// Parse graphics clock offset
-	if strings.Contains(string(output), "GPUGraphicsClockOffset") {
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "GPUGraphicsClockOffset") && strings.Contains(line, ":") {
-				parts := strings.Split(line, ":")
-				if len(parts) > 1 {
-					offsetStr := strings.TrimSpace(parts[len(parts)-1])
-					if offset, err := strconv.Atoi(offsetStr); err == nil {
-						settings.CoreClockOffset = offset
-					}
-				}
-			}
-		}
+	caps.DeviceID = deviceID
+	caps.Vendor = vendor
+	return caps, nil
+}
+
+// getNvidiaCapabilities queries nvidia-smi and nvidia-settings for the allowed ranges
+func (r *LinuxReader) getNvidiaCapabilities(ctx context.Context, index int) (*Capabilities, error) {
+	caps := &Capabilities{}
+
+	// Power limit range relative to the default limit
+	if limits, err := r.getNvidiaPowerLimits(ctx, index); err == nil {
+		caps.PowerLimit = percentRange(limits.Min, limits.Max, limits.Default)
+		caps.DefaultPowerLimit = limits.Default
 	}
 
-	// Get memory clock offset
-	cmd = exec.CommandContext(ctx, "nvidia-settings", "-q", fmt.Sprintf("[gpu:%d]/GPUMemoryTransferRateOffset[3]", deviceID))
-	if output, err := cmd.Output(); err == nil {
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "GPUMemoryTransferRateOffset") && strings.Contains(line, ":") {
-				parts := strings.Split(line, ":")
-				if len(parts) > 1 {
-					offsetStr := strings.TrimSpace(parts[len(parts)-1])
-					if offset, err := strconv.Atoi(offsetStr); err == nil {
-						settings.MemoryClockOffset = offset
-					}
-				}
-			}
-		}
+	// Clock offset and fan ranges as reported by nvidia-settings
+	caps.CoreClockOffset = queryNvidiaSettingsRange(ctx, fmt.Sprintf("[gpu:%d]/GPUGraphicsClockOffset[3]", index))
+	caps.MemoryClockOffset = queryNvidiaSettingsRange(ctx, fmt.Sprintf("[gpu:%d]/GPUMemoryTransferRateOffset[3]", index))
+	caps.FanSpeed = queryNvidiaSettingsRange(ctx, fmt.Sprintf("[fan:%d]/GPUTargetFanSpeed", index))
+
+	// Target temperature range from the XML report
+	cmd := exec.CommandContext(ctx, "nvidia-smi", "-q", "-x", "-i", strconv.Itoa(index))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi not available: %w", err)
 	}
 
-	// Get power limit (from nvidia-smi)
-	cmd = exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=power.limit", "--format=csv,noheader,nounits", "-i", fmt.Sprintf("%d", deviceID))
-	if output, err := cmd.Output(); err == nil {
-		powerStr := strings.TrimSpace(string(output))
-		if power, err := strconv.ParseFloat(powerStr, 64); err == nil {
-			settings.PowerLimit = int(power)
+	var smiOutput NvidiaSMIOutput
+	if err := xml.Unmarshal(output, &smiOutput); err != nil {
+		return nil, fmt.Errorf("failed to parse nvidia-smi output: %w", err)
+	}
+	if len(smiOutput.GPUs) > 0 {
+		supported := smiOutput.GPUs[0].SupportedTargetTemp
+		min, errMin := strconv.ParseFloat(strings.TrimSuffix(supported.Min, " C"), 64)
+		max, errMax := strconv.ParseFloat(strings.TrimSuffix(supported.Max, " C"), 64)
+		if errMin == nil && errMax == nil && max >= min {
+			caps.TempLimit = &Range{Min: min, Max: max}
 		}
 	}
 
-	return settings, nil
+	return caps, nil
 }
 
-// getAMDOverclockSettings gets AMD GPU overclock settings
-func (r *LinuxReader) getAMDOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error) {
-	// Find the card path for this device
-	cardPath := r.findAMDCardPath(deviceID)
+// getAMDCapabilities derives the allowed ranges from OD_RANGE and the hwmon power cap limits
+func (r *LinuxReader) getAMDCapabilities(ctx context.Context, index int) (*Capabilities, error) {
+	cardPath := r.findAMDCardPath(index)
 	if cardPath == "" {
-		return nil, fmt.Errorf("AMD GPU card%d not found", deviceID)
+		return nil, fmt.Errorf("AMD GPU card%d not found", index)
 	}
 
-	settings := &OverclockSettings{}
+	caps := &Capabilities{}
 
-	// Get current GPU clock
-	if sclkPath := filepath.Join(cardPath, "pp_dpm_sclk"); fileExists(sclkPath) {
-		if sclkData, err := os.ReadFile(sclkPath); err == nil {
-			lines := strings.Split(string(sclkData), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "*") {
-					parts := strings.Fields(line)
-					if len(parts) >= 2 {
-						freqStr := strings.TrimSuffix(parts[1], "Mhz")
-						if _, err := strconv.Atoi(freqStr); err == nil {
-							// AMD doesn't use offsets like NVIDIA, so we report current clock
-							settings.CoreClockOffset = 0 // Will be base clock
-						}
-					}
-					break
-				}
-			}
+	if table, err := readODTable(cardPath); err == nil {
+		baseline := r.amdBaseline(cardPath, table)
+
+		// Offsets are relative to the stock top level, so shift the absolute range
+		if rng, ok := table.Ranges["SCLK_OFFSET"]; ok {
+			caps.CoreClockOffset = &Range{Min: float64(rng.Min), Max: float64(rng.Max)}
+		} else if rng, ok := table.Ranges["SCLK"]; ok && baseline.topSclk() > 0 {
+			caps.CoreClockOffset = &Range{Min: float64(rng.Min - baseline.topSclk()), Max: float64(rng.Max - baseline.topSclk())}
+		}
+		if rng, ok := table.Ranges["MCLK"]; ok && baseline.topMclk() > 0 {
+			caps.MemoryClockOffset = &Range{Min: float64(rng.Min - baseline.topMclk()), Max: float64(rng.Max - baseline.topMclk())}
+		}
+		if rng, ok := table.Ranges["VDDGFX_OFFSET"]; ok {
+			caps.VoltageOffset = &Range{Min: float64(rng.Min), Max: float64(rng.Max)}
 		}
 	}
 
-	// Get current memory clock
-	if mclkPath := filepath.Join(cardPath, "pp_dpm_mclk"); fileExists(mclkPath) {
-		if mclkData, err := os.ReadFile(mclkPath); err == nil {
-			lines := strings.Split(string(mclkData), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "*") {
-					settings.MemoryClockOffset = 0 // Will be base clock
-					break
-				}
-			}
+	if hwmonPath := findAMDHwmonPath(cardPath); hwmonPath != "" {
+		capMin, errMin := readHwmonInt(hwmonPath, "power1_cap_min")
+		capMax, errMax := readHwmonInt(hwmonPath, "power1_cap_max")
+		capDefault, errDefault := readHwmonInt(hwmonPath, "power1_cap_default")
+		if errMin == nil && errMax == nil && errDefault == nil {
+			caps.PowerLimit = percentRange(float64(capMin), float64(capMax), float64(capDefault))
+			caps.DefaultPowerLimit = float64(capDefault) / 1000000
 		}
-	}
 
-	// Get power limit (percentage)
-	if powerCapPath := filepath.Join(cardPath, "power_dpm_force_performance_level"); fileExists(powerCapPath) {
-		settings.PowerLimit = 100 // Default to 100% if we can't read specific value
+		if fileExists(filepath.Join(hwmonPath, "pwm1")) && fileExists(filepath.Join(hwmonPath, "pwm1_enable")) {
+			caps.FanSpeed = &Range{Min: 0, Max: 100}
+		}
 	}
 
-	return settings, nil
+	return caps, nil
 }
 
-// findAMDCardPath finds the DRM card path for the given device ID
-func (r *LinuxReader) findAMDCardPath(deviceID int) string {
-	drmPath := "/sys/class/drm"
-	entries, err := os.ReadDir(drmPath)
+// queryNvidiaSettingsRange reads the valid range of an nvidia-settings attribute,
+// e.g. "Valid values for 'GPUGraphicsClockOffset' are in the range -200 - 1200 (inclusive)."
+func queryNvidiaSettingsRange(ctx context.Context, attribute string) *Range {
+	output, err := exec.CommandContext(ctx, "nvidia-settings", "-q", attribute).Output()
 	if err != nil {
-		return ""
+		return nil
 	}
 
-	currentID := 0
-	for _, entry := range entries {
-		if !strings.HasPrefix(entry.Name(), "card") || strings.Contains(entry.Name(), "-") {
+	for _, line := range strings.Split(string(output), "\n") {
+		i := strings.Index(line, "in the range")
+		if i < 0 {
 			continue
 		}
+		var min, max float64
+		if _, err := fmt.Sscanf(line[i:], "in the range %g - %g", &min, &max); err == nil && max >= min {
+			return &Range{Min: min, Max: max}
+		}
+	}
+	return nil
+}
 
-		cardPath := filepath.Join(drmPath, entry.Name(), "device")
-		vendorPath := filepath.Join(cardPath, "vendor")
+// queryNvidiaSettings reads a single integer attribute through nvidia-settings
+func queryNvidiaSettings(ctx context.Context, attribute string) (int, error) {
+	cmd := exec.CommandContext(ctx, "nvidia-settings", "-t", "-q", attribute)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("nvidia-settings not available or GPU not found: %w", err)
+	}
 
-		if vendorData, err := os.ReadFile(vendorPath); err == nil {
-			vendor := strings.TrimSpace(string(vendorData))
-			if vendor == "0x1002" { // AMD vendor ID
-				if currentID == deviceID {
-					return cardPath
-				}
-				currentID++
-			}
+	value, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected nvidia-settings output for %s: %q", attribute, strings.TrimSpace(string(output)))
+	}
+	return value, nil
+}
+
+// runNvidiaCommand runs an NVIDIA tool and folds its output into the error
+func runNvidiaCommand(ctx context.Context, name string, args ...string) error {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if strings.Contains(msg, "Insufficient Permissions") ||
+			strings.Contains(msg, "permission denied") ||
+			strings.Contains(msg, "Operation not permitted") {
+			return fmt.Errorf("requires root privileges: %s", msg)
 		}
+		if msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
 	}
-	return ""
+	return nil
 }
 
-// SetOverclockSettings applies overclock settings with detailed results
-func (r *LinuxReader) SetOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) (*OverclockResult, error) {
-	// First check what type of GPU this is
-	gpus, err := r.GetInfo(ctx)
-	if err != nil || deviceID >= len(gpus) {
-		return nil, fmt.Errorf("GPU device %d not found", deviceID)
+// nvidiaPowerLimits holds the current and default power limits of an NVIDIA GPU in watts
+type nvidiaPowerLimits struct {
+	Current float64
+	Default float64
+	Min     float64
+	Max     float64
+}
+
+// getNvidiaPowerLimits queries the power limits of an NVIDIA GPU
+func (r *LinuxReader) getNvidiaPowerLimits(ctx context.Context, index int) (*nvidiaPowerLimits, error) {
+	cmd := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-gpu=power.limit,power.default_limit,power.min_limit,power.max_limit",
+		"--format=csv,noheader,nounits", "-i", strconv.Itoa(index))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query power limits: %w", err)
 	}
 
-	gpu := gpus[deviceID]
+	fields := strings.Split(strings.TrimSpace(string(output)), ",")
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("unexpected nvidia-smi output format")
+	}
 
-	switch gpu.Vendor {
-	case NVIDIA:
-		return r.setNvidiaOverclockSettings(ctx, deviceID, settings)
-	case AMD:
-		return r.setAMDOverclockSettings(ctx, deviceID, settings)
-	default:
-		return nil, fmt.Errorf("overclocking not supported for %s GPUs", gpu.Vendor)
+	values := make([]float64, 4)
+	for i := range values {
+		value, err := strconv.ParseFloat(strings.TrimSpace(fields[i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("power limit management not supported by this GPU")
+		}
+		values[i] = value
 	}
+
+	return &nvidiaPowerLimits{Current: values[0], Default: values[1], Min: values[2], Max: values[3]}, nil
 }
 
-// setNvidiaOverclockSettings applies NVIDIA GPU overclock settings
-func (r *LinuxReader) setNvidiaOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) (*OverclockResult, error) {
-	result := &OverclockResult{
-		Success:  true,
-		Applied:  []string{},
-		Warnings: []string{},
-		Errors:   []string{},
+// getNvidiaOverclockSettings gets NVIDIA GPU overclock settings
+func (r *LinuxReader) getNvidiaOverclockSettings(ctx context.Context, index int) (*OverclockSettings, error) {
+	settings := &OverclockSettings{}
+
+	// Clock offsets require nvidia-settings and a running X server
+	if offset, err := queryNvidiaSettings(ctx, fmt.Sprintf("[gpu:%d]/GPUGraphicsClockOffset[3]", index)); err == nil {
+		settings.CoreClockOffset = offset
+	}
+	if offset, err := queryNvidiaSettings(ctx, fmt.Sprintf("[gpu:%d]/GPUMemoryTransferRateOffset[3]", index)); err == nil {
+		settings.MemoryClockOffset = offset
+	}
+
+	// Power limit as a percentage of the default limit
+	if limits, err := r.getNvidiaPowerLimits(ctx, index); err == nil && limits.Default > 0 {
+		settings.PowerLimit = int(math.Round(limits.Current / limits.Default * 100))
 	}
 
+	// Target temperature and fan speed from the XML report
+	cmd := exec.CommandContext(ctx, "nvidia-smi", "-q", "-x", "-i", strconv.Itoa(index))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi not available: %w", err)
+	}
+
+	var smiOutput NvidiaSMIOutput
+	if err := xml.Unmarshal(output, &smiOutput); err != nil {
+		return nil, fmt.Errorf("failed to parse nvidia-smi output: %w", err)
+	}
+	if len(smiOutput.GPUs) > 0 {
+		if target, err := strconv.Atoi(strings.TrimSuffix(smiOutput.GPUs[0].Temperature.Target, " C")); err == nil {
+			settings.TempLimit = target
+		}
+	}
+
+	// Fan speed is only reported while under manual control
+	if state, err := queryNvidiaSettings(ctx, fmt.Sprintf("[gpu:%d]/GPUFanControlState", index)); err == nil && state == 1 {
+		if speed, err := queryNvidiaSettings(ctx, fmt.Sprintf("[fan:%d]/GPUTargetFanSpeed", index)); err == nil {
+			settings.FanSpeed = speed
+		}
+	}
+
+	return settings, nil
+}
+
+// setNvidiaOverclockSettings applies NVIDIA GPU overclock settings
+func (r *LinuxReader) setNvidiaOverclockSettings(ctx context.Context, index int, settings *OverclockSettings) (*OverclockResult, error) {
 	// Validate device exists
-	cmd := exec.CommandContext(ctx, "nvidia-smi", "-i", fmt.Sprintf("%d", deviceID))
-	if _, err := cmd.Output(); err != nil {
-		return nil, fmt.Errorf("GPU device %d not found", deviceID)
+	if err := exec.CommandContext(ctx, "nvidia-smi", "-i", strconv.Itoa(index), "-L").Run(); err != nil {
+		return nil, fmt.Errorf("NVIDIA GPU %d not found", index)
 	}
 
+	result := newOverclockResult(index)
+
 	// Apply graphics clock offset
-	if settings.CoreClockOffset != 0 {
-		cmd := exec.CommandContext(ctx, "nvidia-settings",
-			"-a", fmt.Sprintf("[gpu:%d]/GPUGraphicsClockOffset[3]=%d", deviceID, settings.CoreClockOffset))
-		if err := cmd.Run(); err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("failed to set core clock offset: %v", err))
-			result.Success = false
-		} else {
-			result.Applied = append(result.Applied, fmt.Sprintf("core clock offset: %+d MHz", settings.CoreClockOffset))
-		}
+	if err := runNvidiaCommand(ctx, "nvidia-settings",
+		"-a", fmt.Sprintf("[gpu:%d]/GPUGraphicsClockOffset[3]=%d", index, settings.CoreClockOffset)); err != nil {
+		result.failed(FieldCoreClockOffset, float64(settings.CoreClockOffset), err)
+	} else {
+		result.applied(FieldCoreClockOffset, float64(settings.CoreClockOffset),
+			fmt.Sprintf("core clock offset: %+d MHz", settings.CoreClockOffset))
 	}
 
 	// Apply memory clock offset
-	if settings.MemoryClockOffset != 0 {
-		cmd := exec.CommandContext(ctx, "nvidia-settings",
-			"-a", fmt.Sprintf("[gpu:%d]/GPUMemoryTransferRateOffset[3]=%d", deviceID, settings.MemoryClockOffset))
-		if err := cmd.Run(); err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("failed to set memory clock offset: %v", err))
-			result.Success = false
-		} else {
-			result.Applied = append(result.Applied, fmt.Sprintf("memory clock offset: %+d MHz", settings.MemoryClockOffset))
-		}
+	if err := runNvidiaCommand(ctx, "nvidia-settings",
+		"-a", fmt.Sprintf("[gpu:%d]/GPUMemoryTransferRateOffset[3]=%d", index, settings.MemoryClockOffset)); err != nil {
+		result.failed(FieldMemoryClockOffset, float64(settings.MemoryClockOffset), err)
+	} else {
+		result.applied(FieldMemoryClockOffset, float64(settings.MemoryClockOffset),
+			fmt.Sprintf("memory clock offset: %+d MHz", settings.MemoryClockOffset))
 	}
 
-	// Apply power limit (if supported)
+	// Apply power limit, converting the percentage to watts of the default limit
 	if settings.PowerLimit > 0 {
-		cmd := exec.CommandContext(ctx, "nvidia-smi", "-i", fmt.Sprintf("%d", deviceID),
-			"-pl", fmt.Sprintf("%d", settings.PowerLimit))
-		if err := cmd.Run(); err != nil {
-			// Check if it's a permission error
-			if strings.Contains(err.Error(), "Insufficient permissions") ||
-				strings.Contains(err.Error(), "permission denied") ||
-				strings.Contains(err.Error(), "Operation not permitted") {
-				result.Warnings = append(result.Warnings,
-					fmt.Sprintf("power limit setting requires root privileges (requested: %d%%)", settings.PowerLimit))
+		limits, err := r.getNvidiaPowerLimits(ctx, index)
+		if err != nil {
+			result.failed(FieldPowerLimit, float64(settings.PowerLimit), err)
+		} else {
+			watts := limits.Default * float64(settings.PowerLimit) / 100
+			if err := runNvidiaCommand(ctx, "nvidia-smi", "-i", strconv.Itoa(index),
+				"-pl", strconv.FormatFloat(watts, 'f', 2, 64)); err != nil {
+				result.failed(FieldPowerLimit, float64(settings.PowerLimit), err)
 			} else {
-				result.Warnings = append(result.Warnings,
-					fmt.Sprintf("failed to set power limit: %v", err))
+				result.applied(FieldPowerLimit, float64(settings.PowerLimit),
+					fmt.Sprintf("power limit: %d%% (%.0f W)", settings.PowerLimit, watts))
 			}
+		}
+	} else {
+		result.skipped(FieldPowerLimit, "not requested")
+	}
+
+	// Apply GPU target temperature
+	if settings.TempLimit > 0 {
+		if err := runNvidiaCommand(ctx, "nvidia-smi", "-i", strconv.Itoa(index),
+			"-gtt", strconv.Itoa(settings.TempLimit)); err != nil {
+			result.failed(FieldTempLimit, float64(settings.TempLimit), err)
 		} else {
-			result.Applied = append(result.Applied, fmt.Sprintf("power limit: %d%%", settings.PowerLimit))
+			result.applied(FieldTempLimit, float64(settings.TempLimit),
+				fmt.Sprintf("temperature limit: %d°C", settings.TempLimit))
 		}
+	} else {
+		result.skipped(FieldTempLimit, "not requested")
 	}
 
-	// Apply fan speed if specified
+	// Apply fan speed, or hand the fan back to the driver
 	if settings.FanSpeed > 0 {
-		cmd := exec.CommandContext(ctx, "nvidia-settings",
-			"-a", fmt.Sprintf("[gpu:%d]/GPUFanControlState=1", deviceID),
-			"-a", fmt.Sprintf("[fan:%d]/GPUTargetFanSpeed=%d", deviceID, settings.FanSpeed))
-		if err := cmd.Run(); err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("failed to set fan speed: %v", err))
-			result.Success = false
+		if err := runNvidiaCommand(ctx, "nvidia-settings",
+			"-a", fmt.Sprintf("[gpu:%d]/GPUFanControlState=1", index),
+			"-a", fmt.Sprintf("[fan:%d]/GPUTargetFanSpeed=%d", index, settings.FanSpeed)); err != nil {
+			result.failed(FieldFanSpeed, float64(settings.FanSpeed), err)
 		} else {
-			result.Applied = append(result.Applied, fmt.Sprintf("fan speed: %d%%", settings.FanSpeed))
+			result.applied(FieldFanSpeed, float64(settings.FanSpeed), fmt.Sprintf("fan speed: %d%%", settings.FanSpeed))
+		}
+	} else {
+		if err := runNvidiaCommand(ctx, "nvidia-settings",
+			"-a", fmt.Sprintf("[gpu:%d]/GPUFanControlState=0", index)); err != nil {
+			result.failed(FieldFanSpeed, 0, err)
+		} else {
+			result.applied(FieldFanSpeed, 0, "fan speed: auto")
 		}
 	}
 
-	// If we have warnings but no errors, consider it a partial success
-	if len(result.Errors) == 0 && len(result.Warnings) > 0 {
-		result.Success = true
+	// Voltage offsets are not exposed by the Linux driver
+	if settings.VoltageOffset != 0 {
+		result.unsupported(FieldVoltageOffset, settings.VoltageOffset, "voltage offsets are not supported by the NVIDIA Linux driver")
 	}
 
 	return result, nil
 }
 
-// setAMDOverclockSettings applies AMD GPU overclock settings
-func (r *LinuxReader) setAMDOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) (*OverclockResult, error) {
-	result := &OverclockResult{
-		Success:  true,
-		Applied:  []string{},
-		Warnings: []string{},
-		Errors:   []string{},
+// amdBaseline returns the stock overdrive table for a card. The first table
+// seen may already be overclocked (e.g. after a restart), so the stock table
+// is read by resetting overdrive and the current levels are written back.
+// If the reset is refused the current table is used and not remembered.
+func (r *LinuxReader) amdBaseline(cardPath string, current *odTable) *odTable {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if baseline, exists := r.odBaselines[cardPath]; exists {
+		return baseline
 	}
+	stock, err := readStockODTable(cardPath, current)
+	if err != nil {
+		return current
+	}
+	r.odBaselines[cardPath] = stock
+	return stock
+}
+
+// readHwmonInt reads an integer hwmon attribute
+func readHwmonInt(hwmonPath, name string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(hwmonPath, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// getAMDOverclockSettings gets AMD GPU overclock settings
+func (r *LinuxReader) getAMDOverclockSettings(ctx context.Context, index int) (*OverclockSettings, error) {
+	cardPath := r.findAMDCardPath(index)
+	if cardPath == "" {
+		return nil, fmt.Errorf("AMD GPU card%d not found", index)
+	}
+
+	settings := &OverclockSettings{}
+
+	// Clock and voltage offsets from the overdrive table
+	if table, err := readODTable(cardPath); err == nil {
+		baseline := r.amdBaseline(cardPath, table)
+		if table.SclkOffset != nil {
+			settings.CoreClockOffset = *table.SclkOffset
+		} else if baseline.topSclk() > 0 {
+			settings.CoreClockOffset = table.topSclk() - baseline.topSclk()
+		}
+		if baseline.topMclk() > 0 {
+			settings.MemoryClockOffset = table.topMclk() - baseline.topMclk()
+		}
+		if table.VoltageOffset != nil {
+			settings.VoltageOffset = float64(*table.VoltageOffset)
+		}
+	}
+
+	if hwmonPath := findAMDHwmonPath(cardPath); hwmonPath != "" {
+		// Power limit as a percentage of the default cap
+		capValue, errCap := readHwmonInt(hwmonPath, "power1_cap")
+		capDefault, errDefault := readHwmonInt(hwmonPath, "power1_cap_default")
+		if errCap == nil && errDefault == nil && capDefault > 0 {
+			settings.PowerLimit = int(math.Round(float64(capValue) / float64(capDefault) * 100))
+		}
 
-	// Find the card path for this device
-	cardPath := r.findAMDCardPath(deviceID)
+		// Fan speed is only reported while under manual control
+		if enable, err := readHwmonInt(hwmonPath, "pwm1_enable"); err == nil && enable == 1 {
+			if pwm, err := readHwmonInt(hwmonPath, "pwm1"); err == nil {
+				settings.FanSpeed = int(pwm * 100 / 255)
+			}
+		}
+	}
+
+	return settings, nil
+}
+
+// setAMDOverclockSettings applies AMD GPU overclock settings
+func (r *LinuxReader) setAMDOverclockSettings(ctx context.Context, index int, settings *OverclockSettings) (*OverclockResult, error) {
+	cardPath := r.findAMDCardPath(index)
 	if cardPath == "" {
-		return nil, fmt.Errorf("AMD GPU card%d not found", deviceID)
+		return nil, fmt.Errorf("AMD GPU card%d not found", index)
 	}
 
-	// AMD overclocking requires manual performance level
-	perfLevelPath := filepath.Join(cardPath, "power_dpm_force_performance_level")
-	if fileExists(perfLevelPath) {
-		// Set to manual mode for overclocking
+	result := newOverclockResult(index)
+
+	// Clock and voltage offsets go through the overdrive table
+	table, err := readODTable(cardPath)
+	if err != nil {
+		result.failed(FieldCoreClockOffset, float64(settings.CoreClockOffset), err)
+		result.failed(FieldMemoryClockOffset, float64(settings.MemoryClockOffset), err)
+		if settings.VoltageOffset != 0 {
+			result.failed(FieldVoltageOffset, settings.VoltageOffset, err)
+		}
+	} else {
+		// Overdrive writes require manual performance level
+		perfLevelPath := filepath.Join(cardPath, "power_dpm_force_performance_level")
 		if err := os.WriteFile(perfLevelPath, []byte("manual"), 0644); err != nil {
 			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to set manual performance level (may need root): %v", err))
+		}
+
+		baseline := r.amdBaseline(cardPath, table)
+
+		// Core clock
+		var sclkCommand string
+		switch {
+		case table.SclkOffset != nil:
+			sclkCommand = fmt.Sprintf("s %d", settings.CoreClockOffset)
+		case len(baseline.SclkLevels) > 0:
+			sclkCommand = fmt.Sprintf("s %d %d", len(baseline.SclkLevels)-1, baseline.topSclk()+settings.CoreClockOffset)
+		}
+		if sclkCommand == "" {
+			result.unsupported(FieldCoreClockOffset, float64(settings.CoreClockOffset), "core clock overdrive not exposed by this card")
+		} else if err := writeODCommands(cardPath, sclkCommand); err != nil {
+			result.failed(FieldCoreClockOffset, float64(settings.CoreClockOffset), err)
 		} else {
-			result.Applied = append(result.Applied, "enabled manual performance mode")
+			result.applied(FieldCoreClockOffset, float64(settings.CoreClockOffset),
+				fmt.Sprintf("core clock offset: %+d MHz", settings.CoreClockOffset))
 		}
-	}
 
-	// Apply GPU clock settings
-	if settings.CoreClockOffset != 0 {
-		sclkPath := filepath.Join(cardPath, "pp_dpm_sclk")
-		if fileExists(sclkPath) {
-			// For AMD, we'll try to set the highest performance state
-			// This is a simplified approach - real overclocking would need more complex logic
-			if sclkData, err := os.ReadFile(sclkPath); err == nil {
-				lines := strings.Split(string(sclkData), "\n")
-				var maxState string
-				for _, line := range lines {
-					if strings.TrimSpace(line) != "" && !strings.Contains(line, "*") {
-						parts := strings.Fields(line)
-						if len(parts) >= 1 {
-							maxState = parts[0]
-						}
-					}
-				}
-				if maxState != "" {
-					sclkMaskPath := filepath.Join(cardPath, "pp_dpm_sclk")
-					if err := os.WriteFile(sclkMaskPath, []byte(maxState), 0644); err != nil {
-						result.Warnings = append(result.Warnings, fmt.Sprintf("failed to set GPU clock state (may need root): %v", err))
-					} else {
-						result.Applied = append(result.Applied, fmt.Sprintf("set GPU to performance state %s", maxState))
-					}
-				}
+		// Memory clock
+		if len(baseline.MclkLevels) == 0 {
+			result.unsupported(FieldMemoryClockOffset, float64(settings.MemoryClockOffset), "memory clock overdrive not exposed by this card")
+		} else if err := writeODCommands(cardPath,
+			fmt.Sprintf("m %d %d", len(baseline.MclkLevels)-1, baseline.topMclk()+settings.MemoryClockOffset)); err != nil {
+			result.failed(FieldMemoryClockOffset, float64(settings.MemoryClockOffset), err)
+		} else {
+			result.applied(FieldMemoryClockOffset, float64(settings.MemoryClockOffset),
+				fmt.Sprintf("memory clock offset: %+d MHz", settings.MemoryClockOffset))
+		}
+
+		// Voltage offset
+		if table.VoltageOffset == nil {
+			if settings.VoltageOffset != 0 {
+				result.unsupported(FieldVoltageOffset, settings.VoltageOffset, "voltage offset not exposed by this card")
 			}
+		} else if err := writeODCommands(cardPath, fmt.Sprintf("vo %.0f", settings.VoltageOffset)); err != nil {
+			result.failed(FieldVoltageOffset, settings.VoltageOffset, err)
+		} else {
+			result.applied(FieldVoltageOffset, settings.VoltageOffset,
+				fmt.Sprintf("voltage offset: %+.0f mV", settings.VoltageOffset))
 		}
 	}
 
-	// Apply memory clock settings
-	if settings.MemoryClockOffset != 0 {
-		mclkPath := filepath.Join(cardPath, "pp_dpm_mclk")
-		if fileExists(mclkPath) {
-			// Similar approach for memory clock
-			if mclkData, err := os.ReadFile(mclkPath); err == nil {
-				lines := strings.Split(string(mclkData), "\n")
-				var maxState string
-				for _, line := range lines {
-					if strings.TrimSpace(line) != "" && !strings.Contains(line, "*") {
-						parts := strings.Fields(line)
-						if len(parts) >= 1 {
-							maxState = parts[0]
-						}
-					}
-				}
-				if maxState != "" {
-					mclkMaskPath := filepath.Join(cardPath, "pp_dpm_mclk")
-					if err := os.WriteFile(mclkMaskPath, []byte(maxState), 0644); err != nil {
-						result.Warnings = append(result.Warnings, fmt.Sprintf("failed to set memory clock state (may need root): %v", err))
-					} else {
-						result.Applied = append(result.Applied, fmt.Sprintf("set memory to performance state %s", maxState))
-					}
-				}
+	hwmonPath := findAMDHwmonPath(cardPath)
+
+	// Power limit via the hwmon power cap
+	if settings.PowerLimit > 0 {
+		capDefault, err := readHwmonInt(hwmonPath, "power1_cap_default")
+		if err != nil {
+			result.unsupported(FieldPowerLimit, float64(settings.PowerLimit), "power cap not exposed by this card")
+		} else {
+			capValue := capDefault * int64(settings.PowerLimit) / 100
+			if err := os.WriteFile(filepath.Join(hwmonPath, "power1_cap"), []byte(strconv.FormatInt(capValue, 10)), 0644); err != nil {
+				result.failed(FieldPowerLimit, float64(settings.PowerLimit), err)
+			} else {
+				result.applied(FieldPowerLimit, float64(settings.PowerLimit),
+					fmt.Sprintf("power limit: %d%% (%d W)", settings.PowerLimit, capValue/1000000))
 			}
 		}
+	} else {
+		result.skipped(FieldPowerLimit, "not requested")
 	}
 
-	// Power limit adjustment
-	if settings.PowerLimit > 0 && settings.PowerLimit != 100 {
-		result.Warnings = append(result.Warnings, "AMD power limit adjustment requires additional tools like 'amdgpu-pro' drivers")
+	// amdgpu does not allow changing the thermal limit
+	if settings.TempLimit > 0 {
+		result.unsupported(FieldTempLimit, float64(settings.TempLimit), "temperature limit is fixed by amdgpu firmware")
+	} else {
+		result.skipped(FieldTempLimit, "not requested")
 	}
 
-	// Fan speed control for AMD
-	if settings.FanSpeed > 0 {
-		// Look for hwmon fan controls
-		hwmonPath := findAMDHwmonPath(cardPath)
-		if hwmonPath != "" {
-			pwm1Path := filepath.Join(hwmonPath, "pwm1")
-			pwm1EnablePath := filepath.Join(hwmonPath, "pwm1_enable")
-
-			if fileExists(pwm1Path) && fileExists(pwm1EnablePath) {
-				// Set manual fan control
-				if err := os.WriteFile(pwm1EnablePath, []byte("1"), 0644); err != nil {
-					result.Warnings = append(result.Warnings, fmt.Sprintf("failed to enable manual fan control: %v", err))
-				} else {
-					// Convert percentage to PWM value (0-255)
-					pwmVal := (settings.FanSpeed * 255) / 100
-					if err := os.WriteFile(pwm1Path, []byte(fmt.Sprintf("%d", pwmVal)), 0644); err != nil {
-						result.Warnings = append(result.Warnings, fmt.Sprintf("failed to set fan speed: %v", err))
-					} else {
-						result.Applied = append(result.Applied, fmt.Sprintf("fan speed: %d%%", settings.FanSpeed))
-					}
-				}
-			}
+	// Fan speed via hwmon PWM, or hand the fan back to the driver
+	pwm1Path := filepath.Join(hwmonPath, "pwm1")
+	pwm1EnablePath := filepath.Join(hwmonPath, "pwm1_enable")
+	if hwmonPath == "" || !fileExists(pwm1Path) || !fileExists(pwm1EnablePath) {
+		if settings.FanSpeed > 0 {
+			result.unsupported(FieldFanSpeed, float64(settings.FanSpeed), "fan PWM control not exposed by this card")
+		}
+	} else if settings.FanSpeed > 0 {
+		pwmVal := (settings.FanSpeed * 255) / 100
+		if err := os.WriteFile(pwm1EnablePath, []byte("1"), 0644); err != nil {
+			result.failed(FieldFanSpeed, float64(settings.FanSpeed), err)
+		} else if err := os.WriteFile(pwm1Path, []byte(strconv.Itoa(pwmVal)), 0644); err != nil {
+			result.failed(FieldFanSpeed, float64(settings.FanSpeed), err)
+		} else {
+			result.applied(FieldFanSpeed, float64(settings.FanSpeed), fmt.Sprintf("fan speed: %d%%", settings.FanSpeed))
 		}
+	} else if err := os.WriteFile(pwm1EnablePath, []byte("2"), 0644); err != nil {
+		result.failed(FieldFanSpeed, 0, err)
+	} else {
+		result.applied(FieldFanSpeed, 0, "fan speed: auto")
 	}
 
-	// Check if any operations succeeded
-	if len(result.Applied) == 0 && len(result.Warnings) > 0 {
-		result.Warnings = append(result.Warnings, "AMD GPU overclocking may require root privileges or additional driver setup")
+	return result, nil
+}
+
+// findAMDCardPath finds the DRM card path for the given AMD device index
+func (r *LinuxReader) findAMDCardPath(index int) string {
+	drmPath := platform.HostSys("class", "drm")
+	entries, err := os.ReadDir(drmPath)
+	if err != nil {
+		return ""
 	}
 
-	return result, nil
+	currentID := 0
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "card") || strings.Contains(entry.Name(), "-") {
+			continue
+		}
+
+		cardPath := filepath.Join(drmPath, entry.Name(), "device")
+		vendorPath := filepath.Join(cardPath, "vendor")
+
+		if vendorData, err := os.ReadFile(vendorPath); err == nil {
+			vendor := strings.TrimSpace(string(vendorData))
+			if vendor == "0x1002" { // AMD vendor ID
+				if currentID == index {
+					return cardPath
+				}
+				currentID++
+			}
+		}
+	}
+	return ""
 }
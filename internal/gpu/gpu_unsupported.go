@@ -20,6 +20,11 @@ func (r *UnsupportedReader) GetInfo(ctx context.Context) ([]*Info, error) {
 	return nil, fmt.Errorf("GPU monitoring not supported on this platform")
 }
 
+// GetCapabilities returns an error for unsupported platforms
+func (r *UnsupportedReader) GetCapabilities(ctx context.Context, deviceID int) (*Capabilities, error) {
+	return nil, fmt.Errorf("GPU overclocking not supported on this platform")
+}
+
 // GetOverclockSettings returns an error for unsupported platforms
 func (r *UnsupportedReader) GetOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error) {
 	return nil, fmt.Errorf("GPU overclocking not supported on this platform")
@@ -5,24 +5,21 @@ package gpu
 import (
 	"context"
 	"fmt"
+	"math"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 
-	"github.com/CristiGvl/picoHWMon/internal/overclock"
 	"github.com/StackExchange/wmi"
 )
 
 // WindowsReader implements GPU monitoring for Windows
-type WindowsReader struct {
-	overclockController overclock.Controller
-}
+type WindowsReader struct{}
 
 // newPlatformReader creates a new Windows GPU reader
 func newPlatformReader() Reader {
-	return &WindowsReader{
-		overclockController: overclock.NewController(),
-	}
+	return &WindowsReader{}
 }
 
 // Win32_VideoController represents WMI video controller
@@ -174,74 +171,268 @@ func (r *WindowsReader) getWMIGPUs(ctx context.Context) ([]*Info, error) {
 
 // GetOverclockSettings returns current overclock settings
 func (r *WindowsReader) GetOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error) {
-	settings, err := r.overclockController.GetSettings(ctx, deviceID)
+	vendor, err := r.detectGPUVendor(ctx, deviceID)
 	if err != nil {
-		return &OverclockSettings{}, err
+		return nil, fmt.Errorf("failed to detect GPU vendor: %w", err)
 	}
 
-	return &OverclockSettings{
-		CoreClockOffset:   settings.CoreClockOffset,
-		MemoryClockOffset: settings.MemoryClockOffset,
-		PowerLimit:        settings.PowerLimit,
-		FanSpeed:          settings.FanSpeed,
-	}, nil
+	switch vendor {
+	case "nvidia":
+		return r.getNvidiaOverclockSettings(ctx, deviceID)
+	case "amd":
+		return r.getAMDOverclockSettings(ctx, deviceID)
+	default:
+		return nil, fmt.Errorf("unsupported GPU vendor: %s", vendor)
+	}
 }
 
 // SetOverclockSettings applies overclock settings
 func (r *WindowsReader) SetOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) (*OverclockResult, error) {
-	overclockSettings := &overclock.Settings{
-		DeviceID:          deviceID,
-		CoreClockOffset:   settings.CoreClockOffset,
-		MemoryClockOffset: settings.MemoryClockOffset,
-		PowerLimit:        settings.PowerLimit,
-		TempLimit:         83, // Default temp limit
-		FanSpeed:          settings.FanSpeed,
-		VoltageOffset:     0, // Default voltage offset
+	vendor, err := r.detectGPUVendor(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect GPU vendor: %w", err)
 	}
 
-	err := r.overclockController.SetSettings(ctx, overclockSettings)
+	switch vendor {
+	case "nvidia":
+		return r.setNvidiaOverclockSettings(ctx, deviceID, settings), nil
+	case "amd":
+		return r.setAMDOverclockSettings(ctx, deviceID, settings), nil
+	default:
+		return nil, fmt.Errorf("unsupported GPU vendor: %s", vendor)
+	}
+}
+
+// GetCapabilities returns the overclock ranges supported by a device.
+// Only the NVIDIA power limit range can be queried on Windows; OverdriveNTool
+// does not report the limits of AMD cards.
+func (r *WindowsReader) GetCapabilities(ctx context.Context, deviceID int) (*Capabilities, error) {
+	vendor, err := r.detectGPUVendor(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect GPU vendor: %w", err)
+	}
+
+	caps := &Capabilities{DeviceID: deviceID}
+	switch vendor {
+	case "nvidia":
+		caps.Vendor = NVIDIA
+		cmd := exec.CommandContext(ctx, "nvidia-smi",
+			"--query-gpu=power.min_limit,power.max_limit,power.default_limit",
+			"--format=csv,noheader,nounits",
+			fmt.Sprintf("--id=%d", deviceID))
+		if output, err := cmd.Output(); err == nil {
+			fields := strings.Split(strings.TrimSpace(string(output)), ", ")
+			if len(fields) >= 3 {
+				min, errMin := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+				max, errMax := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+				def, errDef := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+				if errMin == nil && errMax == nil && errDef == nil {
+					caps.PowerLimit = percentRange(min, max, def)
+					caps.DefaultPowerLimit = def
+				}
+			}
+		}
+	case "amd":
+		caps.Vendor = AMD
+	default:
+		return nil, fmt.Errorf("unsupported GPU vendor: %s", vendor)
+	}
+
+	return caps, nil
+}
+
+// getNvidiaOverclockSettings gets current NVIDIA GPU settings using nvidia-smi
+func (r *WindowsReader) getNvidiaOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error) {
+	cmd := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-gpu=power.limit,power.default_limit",
+		"--format=csv,noheader,nounits",
+		fmt.Sprintf("--id=%d", deviceID))
+
+	output, err := cmd.Output()
 	if err != nil {
-		return &OverclockResult{
-			Success:  false,
-			Applied:  []string{},
-			Warnings: []string{},
-			Errors:   []string{err.Error()},
-		}, nil
+		return nil, fmt.Errorf("failed to query NVIDIA GPU settings: %w", err)
 	}
 
-	// Build list of applied settings
-	var applied []string
-	var warnings []string
+	fields := strings.Split(strings.TrimSpace(string(output)), ", ")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("unexpected nvidia-smi output format")
+	}
+
+	// Clock offsets are managed by third-party tools and cannot be read back here
+	settings := &OverclockSettings{}
+
+	// Power limit as a percentage of the default limit
+	powerLimit, errLimit := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	powerDefault, errDefault := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if errLimit == nil && errDefault == nil && powerDefault > 0 {
+		settings.PowerLimit = int(math.Round(powerLimit / powerDefault * 100))
+	}
+
+	return settings, nil
+}
+
+// setNvidiaOverclockSettings sets NVIDIA GPU settings using nvidia-smi.
+// nvidia-smi only supports power limit changes on Windows; clocks, voltage and
+// fan speed require MSI Afterburner, EVGA Precision or similar tools.
+func (r *WindowsReader) setNvidiaOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) *OverclockResult {
+	result := newOverclockResult(deviceID)
+	const toolsHint = "requires additional tools (MSI Afterburner, EVGA Precision, etc.)"
 
 	if settings.CoreClockOffset != 0 {
-		applied = append(applied, fmt.Sprintf("Core clock offset: %+d MHz", settings.CoreClockOffset))
+		result.unsupported(FieldCoreClockOffset, float64(settings.CoreClockOffset), toolsHint)
 	}
 	if settings.MemoryClockOffset != 0 {
-		applied = append(applied, fmt.Sprintf("Memory clock offset: %+d MHz", settings.MemoryClockOffset))
+		result.unsupported(FieldMemoryClockOffset, float64(settings.MemoryClockOffset), toolsHint)
+	}
+
+	if settings.PowerLimit > 0 {
+		cmd := exec.CommandContext(ctx, "nvidia-smi",
+			"--query-gpu=power.default_limit", "--format=csv,noheader,nounits",
+			fmt.Sprintf("--id=%d", deviceID))
+		output, err := cmd.Output()
+		powerDefault, parseErr := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+		switch {
+		case err != nil:
+			result.failed(FieldPowerLimit, float64(settings.PowerLimit), err)
+		case parseErr != nil:
+			result.unsupported(FieldPowerLimit, float64(settings.PowerLimit), "power limit management not supported by this GPU")
+		default:
+			watts := powerDefault * float64(settings.PowerLimit) / 100
+			cmd := exec.CommandContext(ctx, "nvidia-smi",
+				"-i", fmt.Sprintf("%d", deviceID),
+				"-pl", strconv.FormatFloat(watts, 'f', 2, 64))
+			if err := cmd.Run(); err != nil {
+				result.failed(FieldPowerLimit, float64(settings.PowerLimit), err)
+			} else {
+				result.applied(FieldPowerLimit, float64(settings.PowerLimit),
+					fmt.Sprintf("Power limit: %d%% (%.0f W)", settings.PowerLimit, watts))
+			}
+		}
+	} else {
+		result.skipped(FieldPowerLimit, "not requested")
+	}
+
+	if settings.TempLimit > 0 {
+		result.unsupported(FieldTempLimit, float64(settings.TempLimit), toolsHint)
+	}
+	if settings.FanSpeed > 0 {
+		result.unsupported(FieldFanSpeed, float64(settings.FanSpeed), toolsHint)
+	}
+	if settings.VoltageOffset != 0 {
+		result.unsupported(FieldVoltageOffset, settings.VoltageOffset, toolsHint)
+	}
+
+	return result
+}
+
+// getAMDOverclockSettings gets current AMD GPU settings using OverdriveNTool
+func (r *WindowsReader) getAMDOverclockSettings(ctx context.Context, deviceID int) (*OverclockSettings, error) {
+	cmd := exec.CommandContext(ctx, "OverdriveNTool.exe", "-r", fmt.Sprintf("%d", deviceID))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("OverdriveNTool not available or failed: %w", err)
+	}
+
+	settings := &OverclockSettings{}
+
+	// OverdriveNTool reports absolute clocks; offsets cannot be derived without
+	// the stock clocks, so only limits and fan speed are read back
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines {
+		parts := strings.Fields(strings.TrimSpace(line))
+		if len(parts) < 2 {
+			continue
+		}
+
+		value, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case strings.Contains(parts[0], "Power_Limit"):
+			settings.PowerLimit = value
+		case strings.Contains(parts[0], "Temp_Limit"):
+			settings.TempLimit = value
+		case strings.Contains(parts[0], "Fan_Min"):
+			settings.FanSpeed = value
+		}
+	}
+
+	return settings, nil
+}
+
+// setAMDOverclockSettings sets AMD GPU settings using OverdriveNTool
+func (r *WindowsReader) setAMDOverclockSettings(ctx context.Context, deviceID int, settings *OverclockSettings) *OverclockResult {
+	result := newOverclockResult(deviceID)
+
+	type entry struct {
+		field   string
+		value   float64
+		lines   []string
+		summary string
+	}
+
+	entries := []entry{
+		{FieldCoreClockOffset, float64(settings.CoreClockOffset),
+			[]string{fmt.Sprintf("GPU_P7: %d", settings.CoreClockOffset)},
+			fmt.Sprintf("Core clock offset: %+d MHz", settings.CoreClockOffset)},
+		{FieldMemoryClockOffset, float64(settings.MemoryClockOffset),
+			[]string{fmt.Sprintf("Mem_P3: %d", settings.MemoryClockOffset)},
+			fmt.Sprintf("Memory clock offset: %+d MHz", settings.MemoryClockOffset)},
+		{FieldVoltageOffset, settings.VoltageOffset,
+			[]string{fmt.Sprintf("Voltage_GPU: %.0f", settings.VoltageOffset)},
+			fmt.Sprintf("Voltage offset: %+.0f mV", settings.VoltageOffset)},
 	}
 	if settings.PowerLimit > 0 {
-		applied = append(applied, fmt.Sprintf("Power limit: %d%%", settings.PowerLimit))
+		entries = append(entries, entry{FieldPowerLimit, float64(settings.PowerLimit),
+			[]string{fmt.Sprintf("Power_Limit: %d", settings.PowerLimit)},
+			fmt.Sprintf("Power limit: %d%%", settings.PowerLimit)})
+	} else {
+		result.skipped(FieldPowerLimit, "not requested")
+	}
+	if settings.TempLimit > 0 {
+		entries = append(entries, entry{FieldTempLimit, float64(settings.TempLimit),
+			[]string{fmt.Sprintf("Temp_Limit: %d", settings.TempLimit)},
+			fmt.Sprintf("Temperature limit: %d°C", settings.TempLimit)})
+	} else {
+		result.skipped(FieldTempLimit, "not requested")
 	}
-	// Temperature limit is handled internally with default value
 	if settings.FanSpeed > 0 {
-		applied = append(applied, fmt.Sprintf("Fan speed: %d%%", settings.FanSpeed))
+		entries = append(entries, entry{FieldFanSpeed, float64(settings.FanSpeed),
+			[]string{fmt.Sprintf("Fan_Min: %d", settings.FanSpeed), fmt.Sprintf("Fan_Max: %d", settings.FanSpeed)},
+			fmt.Sprintf("Fan speed: %d%%", settings.FanSpeed)})
+	}
+
+	var configLines []string
+	for _, e := range entries {
+		configLines = append(configLines, e.lines...)
+	}
+
+	// Write a temporary config file for OverdriveNTool
+	configFile, err := os.CreateTemp("", fmt.Sprintf("picohwmon_odnt_%d_*.txt", deviceID))
+	if err == nil {
+		_, err = configFile.WriteString(strings.Join(configLines, "\n"))
+		configFile.Close()
+		defer os.Remove(configFile.Name())
+	}
+	if err == nil {
+		cmd := exec.CommandContext(ctx, "OverdriveNTool.exe",
+			"-p", fmt.Sprintf("%d", deviceID),
+			"-f", configFile.Name())
+		err = cmd.Run()
 	}
-	// Voltage offset is handled internally with default value
 
-	// Add warnings for NVIDIA limitations
-	vendor, _ := r.detectGPUVendor(ctx, deviceID)
-	if vendor == "nvidia" {
-		if settings.CoreClockOffset != 0 || settings.MemoryClockOffset != 0 || settings.FanSpeed > 0 {
-			warnings = append(warnings, "NVIDIA clock/voltage/fan control requires additional tools (MSI Afterburner, EVGA Precision, etc.)")
+	// OverdriveNTool applies the whole file at once, so every field shares its outcome
+	for _, e := range entries {
+		if err != nil {
+			result.failed(e.field, e.value, fmt.Errorf("OverdriveNTool: %w", err))
+		} else {
+			result.applied(e.field, e.value, e.summary)
 		}
 	}
 
-	return &OverclockResult{
-		Success:  true,
-		Applied:  applied,
-		Warnings: warnings,
-		Errors:   []string{},
-	}, nil
+	return result
 }
 
 // detectGPUVendor detects the vendor of the specified GPU
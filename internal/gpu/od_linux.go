@@ -0,0 +1,171 @@
+//go:build linux
+
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// odRange represents a min/max pair from the OD_RANGE section
+type odRange struct {
+	Min int
+	Max int
+}
+
+// odTable represents the parsed contents of amdgpu's pp_od_clk_voltage file.
+// Pre-RDNA3 cards expose absolute clock levels (OD_SCLK/OD_MCLK), while RDNA3
+// cards expose a single core clock offset (OD_SCLK_OFFSET).
+type odTable struct {
+	SclkLevels     []int
+	MclkLevels     []int
+	SclkOffset     *int
+	VoltageOffset  *int
+	Ranges         map[string]odRange
+	hasSclkSection bool
+}
+
+// topSclk returns the highest core clock level, or 0 if none are exposed
+func (t *odTable) topSclk() int {
+	if len(t.SclkLevels) == 0 {
+		return 0
+	}
+	return t.SclkLevels[len(t.SclkLevels)-1]
+}
+
+// topMclk returns the highest memory clock level, or 0 if none are exposed
+func (t *odTable) topMclk() int {
+	if len(t.MclkLevels) == 0 {
+		return 0
+	}
+	return t.MclkLevels[len(t.MclkLevels)-1]
+}
+
+// readODTable reads and parses pp_od_clk_voltage for the given card path
+func readODTable(cardPath string) (*odTable, error) {
+	data, err := os.ReadFile(filepath.Join(cardPath, "pp_od_clk_voltage"))
+	if err != nil {
+		return nil, fmt.Errorf("overdrive not available (is amdgpu.ppfeaturemask set?): %w", err)
+	}
+	return parseODTable(string(data)), nil
+}
+
+// readStockODTable resets overdrive to the driver defaults, reads the stock
+// table and restores the levels of current that differ from it
+func readStockODTable(cardPath string, current *odTable) (*odTable, error) {
+	if err := writeODCommands(cardPath, "r"); err != nil {
+		return nil, err
+	}
+	stock, err := readODTable(cardPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var restore []string
+	for i, freq := range current.SclkLevels {
+		if i < len(stock.SclkLevels) && stock.SclkLevels[i] != freq {
+			restore = append(restore, fmt.Sprintf("s %d %d", i, freq))
+		}
+	}
+	for i, freq := range current.MclkLevels {
+		if i < len(stock.MclkLevels) && stock.MclkLevels[i] != freq {
+			restore = append(restore, fmt.Sprintf("m %d %d", i, freq))
+		}
+	}
+	if current.SclkOffset != nil && stock.SclkOffset != nil && *current.SclkOffset != *stock.SclkOffset {
+		restore = append(restore, fmt.Sprintf("s %d", *current.SclkOffset))
+	}
+	if current.VoltageOffset != nil && stock.VoltageOffset != nil && *current.VoltageOffset != *stock.VoltageOffset {
+		restore = append(restore, fmt.Sprintf("vo %d", *current.VoltageOffset))
+	}
+	if len(restore) > 0 {
+		if err := writeODCommands(cardPath, restore...); err != nil {
+			return nil, fmt.Errorf("failed to restore the overdrive table after reading the stock levels: %w", err)
+		}
+	}
+	return stock, nil
+}
+
+// parseODTable parses the text format of pp_od_clk_voltage
+func parseODTable(content string) *odTable {
+	table := &odTable{Ranges: make(map[string]odRange)}
+
+	section := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "OD_") && strings.HasSuffix(line, ":") {
+			section = strings.TrimSuffix(line, ":")
+			if section == "OD_SCLK" {
+				table.hasSclkSection = true
+			}
+			continue
+		}
+
+		switch section {
+		case "OD_SCLK", "OD_MCLK":
+			// Format: "1: 2615Mhz" (optionally followed by a voltage)
+			parts := strings.Fields(line)
+			if len(parts) < 2 || !strings.HasSuffix(parts[0], ":") {
+				continue
+			}
+			if freq, ok := parseODValue(parts[1]); ok {
+				if section == "OD_SCLK" {
+					table.SclkLevels = append(table.SclkLevels, freq)
+				} else {
+					table.MclkLevels = append(table.MclkLevels, freq)
+				}
+			}
+		case "OD_SCLK_OFFSET":
+			if offset, ok := parseODValue(line); ok {
+				table.SclkOffset = &offset
+			}
+		case "OD_VDDGFX_OFFSET":
+			if offset, ok := parseODValue(line); ok {
+				table.VoltageOffset = &offset
+			}
+		case "OD_RANGE":
+			// Format: "SCLK:     500Mhz       3150Mhz"
+			parts := strings.Fields(line)
+			if len(parts) < 3 {
+				continue
+			}
+			min, okMin := parseODValue(parts[1])
+			max, okMax := parseODValue(parts[2])
+			if okMin && okMax {
+				table.Ranges[strings.TrimSuffix(parts[0], ":")] = odRange{Min: min, Max: max}
+			}
+		}
+	}
+
+	return table
+}
+
+// parseODValue parses values like "2615Mhz", "1250MHz" or "-50mV"
+func parseODValue(value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, "mhz")
+	value = strings.TrimSuffix(value, "mv")
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// writeODCommands writes a sequence of commands to pp_od_clk_voltage and commits them
+func writeODCommands(cardPath string, commands ...string) error {
+	odPath := filepath.Join(cardPath, "pp_od_clk_voltage")
+	for _, command := range append(commands, "c") {
+		if err := os.WriteFile(odPath, []byte(command), 0644); err != nil {
+			return fmt.Errorf("failed to write %q to %s: %w", command, odPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package hwmon
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SystemConfigFiles returns the files libsensors reads: sensors3.conf, or
+// the older sensors.conf, followed by sensors.d in alphabetical order
+func SystemConfigFiles() []string {
+	var files []string
+	for _, name := range []string{"/etc/sensors3.conf", "/etc/sensors.conf"} {
+		if _, err := os.Stat(name); err == nil {
+			files = append(files, name)
+			break
+		}
+	}
+	entries, _ := os.ReadDir("/etc/sensors.d")
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, filepath.Join("/etc/sensors.d", entry.Name()))
+		}
+	}
+	return files
+}
+
+// Config is an lm-sensors configuration: chip statements followed by the
+// label, compute, set and ignore statements that apply to those chips
+type Config struct {
+	blocks []*chipBlock
+}
+
+// chipBlock holds the statements following one chip statement
+type chipBlock struct {
+	patterns []string
+	labels   map[string]string
+	computes map[string]*Expr
+	ignores  map[string]bool
+	sets     map[string]float64
+}
+
+// Feature is what the configuration says about one channel of a chip
+type Feature struct {
+	// Label replaces the channel's name, e.g. "CPU Fan" for fan2
+	Label string
+	// Compute converts readings and limits, e.g. @*(1+120/56)
+	Compute *Expr
+	Ignore  bool
+	// Limits are the values of set statements by attribute suffix, e.g.
+	// "min" for set in0_min
+	Limits map[string]float64
+}
+
+// LoadConfig parses configuration files in order, later statements winning
+// over earlier ones as in libsensors. Missing files are skipped. Statements
+// that cannot be parsed are left out and reported in the error; the
+// returned configuration holds the rest.
+func LoadConfig(files []string) (*Config, error) {
+	config := &Config{}
+	var problems []error
+	for _, name := range files {
+		f, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		problems = append(problems, config.parse(f, name)...)
+		f.Close()
+	}
+	return config, errors.Join(problems...)
+}
+
+// parse adds the statements of one file
+func (c *Config) parse(r io.Reader, name string) []error {
+	var problems []error
+	fail := func(line int, format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("%s:%d: %s", name, line, fmt.Sprintf(format, args...)))
+	}
+
+	var block *chipBlock
+	scanner := bufio.NewScanner(r)
+	var statement string
+	number, start := 0, 0
+	for scanner.Scan() {
+		number++
+		line := scanner.Text()
+		if statement == "" {
+			start = number
+		}
+		if strings.HasSuffix(line, "\\") {
+			statement += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		statement += line
+
+		words, rest, err := splitStatement(statement)
+		statement = ""
+		if err != nil {
+			fail(start, "%v", err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		keyword, args := words[0], words[1:]
+		if keyword == "chip" {
+			if len(args) == 0 {
+				fail(start, "chip needs at least one chip name")
+				continue
+			}
+			block = &chipBlock{patterns: args}
+			c.blocks = append(c.blocks, block)
+			continue
+		}
+		if keyword == "bus" {
+			continue // bus numbers are taken from sysfs
+		}
+		switch keyword {
+		case "label", "ignore", "compute", "set":
+		default:
+			fail(start, "unknown statement %q", keyword)
+			continue
+		}
+		if block == nil {
+			fail(start, "%s before the first chip statement", keyword)
+			continue
+		}
+		if len(args) == 0 {
+			fail(start, "%s needs a feature name", keyword)
+			continue
+		}
+		feature := args[0]
+
+		switch keyword {
+		case "label":
+			if len(args) < 2 {
+				fail(start, "label %s needs a label", feature)
+				continue
+			}
+			if block.labels == nil {
+				block.labels = make(map[string]string)
+			}
+			block.labels[feature] = strings.Join(args[1:], " ")
+		case "ignore":
+			if block.ignores == nil {
+				block.ignores = make(map[string]bool)
+			}
+			block.ignores[feature] = true
+		case "compute":
+			// The second expression converts back for set; only the first is used
+			from, _, _ := strings.Cut(rest(1), ",")
+			expr, err := ParseExpr(strings.TrimSpace(from))
+			if err != nil {
+				fail(start, "compute %s: %v", feature, err)
+				continue
+			}
+			if block.computes == nil {
+				block.computes = make(map[string]*Expr)
+			}
+			block.computes[feature] = expr
+		case "set":
+			expr, err := ParseExpr(strings.TrimSpace(rest(1)))
+			if err != nil {
+				fail(start, "set %s: %v", feature, err)
+				continue
+			}
+			if block.sets == nil {
+				block.sets = make(map[string]float64)
+			}
+			block.sets[feature] = expr.Eval(0)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		problems = append(problems, fmt.Errorf("%s: %w", name, err))
+	}
+	return problems
+}
+
+// splitStatement drops the comment of a statement and splits it into words;
+// quoted strings are one word. rest(n) returns the text after the first n
+// words after the keyword, for expressions that contain spaces.
+func splitStatement(statement string) ([]string, func(n int) string, error) {
+	var words []string
+	var ends []int
+	i := 0
+	for i < len(statement) {
+		switch c := statement[i]; {
+		case c == '#':
+			statement = statement[:i]
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			var word strings.Builder
+			i++
+			for ; i < len(statement) && statement[i] != '"'; i++ {
+				if statement[i] == '\\' && i+1 < len(statement) {
+					i++
+				}
+				word.WriteByte(statement[i])
+			}
+			if i == len(statement) {
+				return nil, nil, errors.New("unterminated string")
+			}
+			i++
+			words = append(words, word.String())
+			ends = append(ends, i)
+		default:
+			end := i
+			for end < len(statement) && strings.IndexByte(" \t#\"", statement[end]) < 0 {
+				end++
+			}
+			words = append(words, statement[i:end])
+			ends = append(ends, end)
+			i = end
+		}
+	}
+	rest := func(n int) string {
+		if n >= len(ends) {
+			return ""
+		}
+		return statement[ends[n]:]
+	}
+	return words, rest, nil
+}
+
+// Feature returns the statements for a channel of a chip, e.g. in1 of
+// nct6775-isa-0290; later chip blocks win
+func (c *Config) Feature(chip, channel string) Feature {
+	var f Feature
+	if c == nil {
+		return f
+	}
+	for _, block := range c.blocks {
+		if !block.matches(chip) {
+			continue
+		}
+		if label, ok := block.labels[channel]; ok {
+			f.Label = label
+		}
+		if expr, ok := block.computes[channel]; ok {
+			f.Compute = expr
+		}
+		if block.ignores[channel] {
+			f.Ignore = true
+		}
+		for attribute, value := range block.sets {
+			if suffix, ok := strings.CutPrefix(attribute, channel+"_"); ok {
+				if f.Limits == nil {
+					f.Limits = make(map[string]float64)
+				}
+				f.Limits[suffix] = value
+			}
+		}
+	}
+	return f
+}
+
+// matches reports whether the block applies to a chip
+func (b *chipBlock) matches(chip string) bool {
+	for _, pattern := range b.patterns {
+		if MatchChip(pattern, chip) {
+			return true
+		}
+	}
+	return false
+}
+
+// The configuration applied to every chip, like libsensors' global state
+var (
+	configMu sync.RWMutex
+	current  *Config
+)
+
+// SetConfig replaces the lm-sensors configuration used by Chip.Feature
+func SetConfig(config *Config) {
+	configMu.Lock()
+	current = config
+	configMu.Unlock()
+}
+
+// Feature returns what the lm-sensors configuration says about a channel
+func (c *Chip) Feature(channel string) Feature {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return current.Feature(c.Name, channel)
+}
@@ -0,0 +1,171 @@
+package hwmon
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Expr is an lm-sensors compute expression such as "@*(1+120/56)", where @
+// is the raw reading. Besides + - * / and parentheses it knows the prefix
+// operators ^ (e to the power) and ` (natural logarithm).
+type Expr struct {
+	source string
+	root   node
+}
+
+type node interface {
+	eval(at float64) float64
+}
+
+type (
+	number float64
+	raw    struct{}
+	unary  struct {
+		op      byte
+		operand node
+	}
+	binary struct {
+		op          byte
+		left, right node
+	}
+)
+
+func (n number) eval(float64) float64 { return float64(n) }
+
+func (raw) eval(at float64) float64 { return at }
+
+func (u unary) eval(at float64) float64 {
+	v := u.operand.eval(at)
+	switch u.op {
+	case '-':
+		return -v
+	case '^':
+		return math.Exp(v)
+	default: // '`'
+		return math.Log(v)
+	}
+}
+
+func (b binary) eval(at float64) float64 {
+	l, r := b.left.eval(at), b.right.eval(at)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default: // '/'
+		return l / r
+	}
+}
+
+// ParseExpr parses a compute expression
+func ParseExpr(source string) (*Expr, error) {
+	p := &exprParser{input: source}
+	root, err := p.sum()
+	if err == nil && p.peek() != 0 {
+		err = fmt.Errorf("unexpected %q", p.input[p.pos:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// Eval computes the expression for a raw reading
+func (e *Expr) Eval(at float64) float64 {
+	return e.root.eval(at)
+}
+
+// String returns the expression as written
+func (e *Expr) String() string {
+	return e.source
+}
+
+// exprParser is a recursive descent parser over the expression grammar of
+// libsensors' conf-parse.y
+type exprParser struct {
+	input string
+	pos   int
+}
+
+// peek skips whitespace and returns the next character, or 0 at the end
+func (p *exprParser) peek() byte {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos == len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// sum parses terms joined by + and -
+func (p *exprParser) sum() (node, error) {
+	left, err := p.product()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := p.input[p.pos]
+		p.pos++
+		var right node
+		if right, err = p.product(); err == nil {
+			left = binary{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// product parses factors joined by * and /
+func (p *exprParser) product() (node, error) {
+	left, err := p.factor()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := p.input[p.pos]
+		p.pos++
+		var right node
+		if right, err = p.factor(); err == nil {
+			left = binary{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// factor parses a number, @, a parenthesised expression or a prefix operator
+func (p *exprParser) factor() (node, error) {
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end")
+	case c == '@':
+		p.pos++
+		return raw{}, nil
+	case c == '-' || c == '^' || c == '`':
+		p.pos++
+		operand, err := p.factor()
+		return unary{op: c, operand: operand}, err
+	case c == '(':
+		p.pos++
+		inner, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		end := p.pos
+		for end < len(p.input) && strings.IndexByte("0123456789.eE", p.input[end]) >= 0 {
+			end++
+		}
+		v, err := strconv.ParseFloat(p.input[p.pos:end], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", p.input[p.pos:end])
+		}
+		p.pos = end
+		return number(v), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q", p.input[p.pos:])
+	}
+}
@@ -0,0 +1,134 @@
+// Package hwmon reads Linux hwmon chips under the names lm-sensors gives them
+package hwmon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// sysfsDir is where the kernel lists hwmon devices, a variable so it can
+// point at a copy of sysfs
+var sysfsDir = platform.HostSys("class", "hwmon")
+
+// channelPattern matches the input attribute of a channel, e.g. in1_input
+var channelPattern = regexp.MustCompile(`^([a-z]+)(\d+)_input$`)
+
+// Chip is a hwmon device
+type Chip struct {
+	// Name is the lm-sensors chip name <prefix>-<bus>-<address>, e.g.
+	// nct6775-isa-0290, as printed by `sensors`
+	Name string
+	// Prefix is the driver name from the name attribute, e.g. nct6775
+	Prefix string
+	// Dir is the hwmon directory, e.g. /sys/class/hwmon/hwmon2
+	Dir string
+}
+
+// Chips returns the hwmon chips ordered by name
+func Chips() []*Chip {
+	dirs, _ := filepath.Glob(filepath.Join(sysfsDir, "hwmon*"))
+	chips := []*Chip{}
+	for _, dir := range dirs {
+		prefix := readAttribute(dir, "name")
+		if prefix == "" {
+			continue
+		}
+		chips = append(chips, &Chip{Name: chipName(prefix, dir), Prefix: prefix, Dir: dir})
+	}
+	sort.Slice(chips, func(i, j int) bool { return chips[i].Name < chips[j].Name })
+	return chips
+}
+
+// chipName builds the lm-sensors name of a chip from the bus its device
+// sits on, following libsensors' sysfs.c
+func chipName(prefix, dir string) string {
+	device, err := filepath.EvalSymlinks(filepath.Join(dir, "device"))
+	if err != nil {
+		return prefix + "-virtual-0"
+	}
+	subsystem, _ := filepath.EvalSymlinks(filepath.Join(device, "subsystem"))
+	id := filepath.Base(device)
+
+	var bus, domain, slot, function, address int
+	switch filepath.Base(subsystem) {
+	case "i2c":
+		if _, err := fmt.Sscanf(id, "%d-%x", &bus, &address); err == nil {
+			return fmt.Sprintf("%s-i2c-%d-%02x", prefix, bus, address)
+		}
+	case "pci":
+		if _, err := fmt.Sscanf(id, "%x:%x:%x.%x", &domain, &bus, &slot, &function); err == nil {
+			return fmt.Sprintf("%s-pci-%04x", prefix, domain<<16+bus<<8+slot<<3+function)
+		}
+	case "platform", "of_platform":
+		// Super I/O chips: nct6775.656 is at ISA address 0x290
+		if i := strings.LastIndex(id, "."); i >= 0 {
+			address, _ = strconv.Atoi(id[i+1:])
+		}
+		return fmt.Sprintf("%s-isa-%04x", prefix, address)
+	case "spi":
+		if _, err := fmt.Sscanf(id, "spi%d.%d", &bus, &address); err == nil {
+			return fmt.Sprintf("%s-spi-%d-%x", prefix, bus, address)
+		}
+	case "hid":
+		var vendor, product int
+		if _, err := fmt.Sscanf(id, "%x:%x:%x.%x", &bus, &vendor, &product, &address); err == nil {
+			return fmt.Sprintf("%s-hid-%d-%x", prefix, bus, address)
+		}
+	case "acpi":
+		return prefix + "-acpi-0"
+	}
+	return prefix + "-virtual-0"
+}
+
+// Channels returns the channels of one type that have an input, e.g. in0 and
+// in1 for typ "in", in numeric order
+func (c *Chip) Channels(typ string) []string {
+	entries, _ := os.ReadDir(c.Dir)
+	var numbers []int
+	for _, entry := range entries {
+		if m := channelPattern.FindStringSubmatch(entry.Name()); m != nil && m[1] == typ {
+			n, _ := strconv.Atoi(m[2])
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	channels := make([]string, len(numbers))
+	for i, n := range numbers {
+		channels[i] = typ + strconv.Itoa(n)
+	}
+	return channels
+}
+
+// Read returns the trimmed value of an attribute such as in1_label, or ""
+func (c *Chip) Read(attribute string) string {
+	return readAttribute(c.Dir, attribute)
+}
+
+// ReadInt returns the value of a numeric attribute and whether it exists
+func (c *Chip) ReadInt(attribute string) (int64, bool) {
+	v, err := strconv.ParseInt(c.Read(attribute), 10, 64)
+	return v, err == nil
+}
+
+// MatchChip reports whether a chip name matches an lm-sensors chip pattern
+// such as nct6775-* or it8792-isa-0a60
+func MatchChip(pattern, name string) bool {
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
+// readAttribute returns the trimmed contents of a sysfs attribute, or ""
+func readAttribute(dir, attribute string) string {
+	data, err := os.ReadFile(filepath.Join(dir, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
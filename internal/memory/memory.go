@@ -19,3 +19,105 @@ type Reader interface {
 func NewReader() Reader {
 	return newPlatformReader()
 }
+
+// Detail is the full memory breakdown. All sizes are in bytes; groups the
+// platform does not provide are omitted.
+type Detail struct {
+	Total     uint64  `json:"total_bytes"`
+	Used      uint64  `json:"used_bytes"`
+	Available uint64  `json:"available_bytes"`
+	Free      uint64  `json:"free_bytes"`
+	Usage     float64 `json:"usage_percent"`
+	Swap      *Swap   `json:"swap"`
+
+	Kernel    *Kernel       `json:"kernel,omitempty"`
+	HugePages *HugePages    `json:"hugepages,omitempty"`
+	Zram      []*ZramDevice `json:"zram,omitempty"`
+	Zswap     *Zswap        `json:"zswap,omitempty"`
+	Pressure  *Pressure     `json:"pressure,omitempty"`
+}
+
+// Swap is swap space usage; the page file on Windows
+type Swap struct {
+	Total uint64  `json:"total_bytes"`
+	Used  uint64  `json:"used_bytes"`
+	Free  uint64  `json:"free_bytes"`
+	Usage float64 `json:"usage_percent"`
+	// Cached is swapped-out memory that is also in RAM again
+	Cached uint64 `json:"cached_bytes"`
+}
+
+// Kernel is the page cache and kernel memory breakdown from /proc/meminfo
+type Kernel struct {
+	Buffers      uint64 `json:"buffers_bytes"`
+	Cached       uint64 `json:"cached_bytes"`
+	Shared       uint64 `json:"shared_bytes"`
+	Dirty        uint64 `json:"dirty_bytes"`
+	Writeback    uint64 `json:"writeback_bytes"`
+	Slab         uint64 `json:"slab_bytes"`
+	SReclaimable uint64 `json:"slab_reclaimable_bytes"`
+	SUnreclaim   uint64 `json:"slab_unreclaimable_bytes"`
+	PageTables   uint64 `json:"page_tables_bytes"`
+	Mapped       uint64 `json:"mapped_bytes"`
+	Committed    uint64 `json:"committed_bytes"`
+	CommitLimit  uint64 `json:"commit_limit_bytes"`
+}
+
+// HugePages describes the static huge page pool and transparent huge pages
+type HugePages struct {
+	Total    uint64 `json:"total"`
+	Free     uint64 `json:"free"`
+	Reserved uint64 `json:"reserved"`
+	Surplus  uint64 `json:"surplus"`
+	PageSize uint64 `json:"page_size_bytes"`
+	// Anonymous is memory backed by transparent huge pages
+	Anonymous uint64 `json:"anonymous_bytes"`
+}
+
+// ZramDevice is a compressed RAM block device, usually used as swap
+type ZramDevice struct {
+	Name       string `json:"name"`
+	DiskSize   uint64 `json:"disk_size_bytes"`
+	Original   uint64 `json:"original_bytes"`
+	Compressed uint64 `json:"compressed_bytes"`
+	// MemoryUsed includes allocator overhead on top of Compressed
+	MemoryUsed       uint64  `json:"memory_used_bytes"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	Algorithm        string  `json:"algorithm,omitempty"`
+}
+
+// Zswap is the compressed swap cache
+type Zswap struct {
+	Enabled bool `json:"enabled"`
+	// Pool is the memory used by the compressed pool, Stored the size of
+	// the pages it holds before compression
+	Pool   uint64 `json:"pool_bytes"`
+	Stored uint64 `json:"stored_bytes"`
+}
+
+// Pressure is pressure stall information from /proc/pressure/memory
+type Pressure struct {
+	// Some is time at least one task was stalled on memory, Full time all
+	// non-idle tasks were
+	Some PressureStats `json:"some"`
+	Full PressureStats `json:"full"`
+}
+
+// PressureStats are the stalled-time percentages over 10s, 60s and 300s and
+// the cumulative stalled time
+type PressureStats struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total_us"`
+}
+
+// DetailReader reads the full memory breakdown
+type DetailReader interface {
+	GetDetail(ctx context.Context) (*Detail, error)
+}
+
+// NewDetailReader creates a detailed memory reader for the current platform
+func NewDetailReader() DetailReader {
+	return newPlatformDetailReader()
+}
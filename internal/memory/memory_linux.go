@@ -3,9 +3,17 @@
 package memory
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/shirou/gopsutil/v3/mem"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
 )
 
 // LinuxReader implements memory monitoring for Linux
@@ -32,3 +40,173 @@ func (r *LinuxReader) GetInfo(ctx context.Context) (*Info, error) {
 
 	return info, nil
 }
+
+// newPlatformDetailReader creates a new Linux detailed memory reader
+func newPlatformDetailReader() DetailReader {
+	return &LinuxReader{}
+}
+
+// GetDetail returns the full memory breakdown
+func (r *LinuxReader) GetDetail(ctx context.Context) (*Detail, error) {
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	swap, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &Detail{
+		Total:     vm.Total,
+		Used:      vm.Used,
+		Available: vm.Available,
+		Free:      vm.Free,
+		Usage:     vm.UsedPercent,
+		Swap: &Swap{
+			Total:  swap.Total,
+			Used:   swap.Used,
+			Free:   swap.Free,
+			Usage:  swap.UsedPercent,
+			Cached: vm.SwapCached,
+		},
+		Kernel: &Kernel{
+			Buffers:      vm.Buffers,
+			Cached:       vm.Cached,
+			Shared:       vm.Shared,
+			Dirty:        vm.Dirty,
+			Writeback:    vm.WriteBack,
+			Slab:         vm.Slab,
+			SReclaimable: vm.Sreclaimable,
+			SUnreclaim:   vm.Sunreclaim,
+			PageTables:   vm.PageTables,
+			Mapped:       vm.Mapped,
+			Committed:    vm.CommittedAS,
+			CommitLimit:  vm.CommitLimit,
+		},
+		HugePages: &HugePages{
+			Total:     vm.HugePagesTotal,
+			Free:      vm.HugePagesFree,
+			Reserved:  vm.HugePagesRsvd,
+			Surplus:   vm.HugePagesSurp,
+			PageSize:  vm.HugePageSize,
+			Anonymous: vm.AnonHugePages,
+		},
+		Zram:     readZram(),
+		Zswap:    readZswap(),
+		Pressure: readPressure(),
+	}
+	return detail, nil
+}
+
+// readZram reads the zram devices that are set up
+func readZram() []*ZramDevice {
+	paths, _ := filepath.Glob(platform.HostSys("block", "zram*"))
+	devices := []*ZramDevice{}
+	for _, path := range paths {
+		size, _ := strconv.ParseUint(readSysfs(path, "disksize"), 10, 64)
+		if size == 0 {
+			continue // not initialised
+		}
+		device := &ZramDevice{Name: filepath.Base(path), DiskSize: size}
+
+		// orig_data_size compr_data_size mem_used_total mem_limit mem_used_max ...
+		fields := strings.Fields(readSysfs(path, "mm_stat"))
+		if len(fields) >= 3 {
+			device.Original, _ = strconv.ParseUint(fields[0], 10, 64)
+			device.Compressed, _ = strconv.ParseUint(fields[1], 10, 64)
+			device.MemoryUsed, _ = strconv.ParseUint(fields[2], 10, 64)
+		}
+		if device.Compressed > 0 {
+			device.CompressionRatio = float64(device.Original) / float64(device.Compressed)
+		}
+		// The active algorithm is the bracketed one, e.g. "lzo [zstd] lz4"
+		for _, algorithm := range strings.Fields(readSysfs(path, "comp_algorithm")) {
+			if strings.HasPrefix(algorithm, "[") {
+				device.Algorithm = strings.Trim(algorithm, "[]")
+			}
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// readZswap reads the zswap state, or nil when the kernel has no zswap
+func readZswap() *Zswap {
+	enabled := readSysfs(platform.HostSys("module", "zswap", "parameters"), "enabled")
+	if enabled == "" {
+		return nil
+	}
+	zswap := &Zswap{Enabled: enabled == "Y"}
+	// Kernels since 5.19 account zswap in /proc/meminfo
+	values := readMeminfo("Zswap", "Zswapped")
+	zswap.Pool, zswap.Stored = values["Zswap"], values["Zswapped"]
+	return zswap
+}
+
+// readMeminfo returns the requested /proc/meminfo fields in bytes
+func readMeminfo(keys ...string) map[string]uint64 {
+	values := make(map[string]uint64, len(keys))
+	file, err := os.Open(platform.HostProc("meminfo"))
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		for _, wanted := range keys {
+			if key == wanted {
+				fields := strings.Fields(value)
+				if len(fields) > 0 {
+					kb, _ := strconv.ParseUint(fields[0], 10, 64)
+					values[key] = kb * 1024
+				}
+			}
+		}
+	}
+	return values
+}
+
+// readPressure reads memory pressure stall information, or nil when the
+// kernel was built without PSI
+func readPressure() *Pressure {
+	data, err := os.ReadFile(platform.HostProc("pressure", "memory"))
+	if err != nil {
+		return nil
+	}
+
+	pressure := &Pressure{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			continue
+		}
+		var stats *PressureStats
+		switch fields[0] {
+		case "some":
+			stats = &pressure.Some
+		case "full":
+			stats = &pressure.Full
+		default:
+			continue
+		}
+		fmt.Sscanf(strings.Join(fields[1:], " "), "avg10=%f avg60=%f avg300=%f total=%d",
+			&stats.Avg10, &stats.Avg60, &stats.Avg300, &stats.Total)
+	}
+	return pressure
+}
+
+// readSysfs returns the trimmed contents of a sysfs attribute, or ""
+func readSysfs(dir, attribute string) string {
+	data, err := os.ReadFile(filepath.Join(dir, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
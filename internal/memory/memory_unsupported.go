@@ -19,3 +19,13 @@ func newPlatformReader() Reader {
 func (r *UnsupportedReader) GetInfo(ctx context.Context) (*Info, error) {
 	return nil, fmt.Errorf("memory monitoring not supported on this platform")
 }
+
+// newPlatformDetailReader creates a fallback detailed memory reader for unsupported platforms
+func newPlatformDetailReader() DetailReader {
+	return &UnsupportedReader{}
+}
+
+// GetDetail returns an error for unsupported platforms
+func (r *UnsupportedReader) GetDetail(ctx context.Context) (*Detail, error) {
+	return nil, fmt.Errorf("memory monitoring not supported on this platform")
+}
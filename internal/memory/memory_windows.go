@@ -32,3 +32,35 @@ func (r *WindowsReader) GetInfo(ctx context.Context) (*Info, error) {
 
 	return info, nil
 }
+
+// newPlatformDetailReader creates a new Windows detailed memory reader
+func newPlatformDetailReader() DetailReader {
+	return &WindowsReader{}
+}
+
+// GetDetail returns the memory breakdown Windows provides: physical memory
+// and the page file
+func (r *WindowsReader) GetDetail(ctx context.Context) (*Detail, error) {
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	swap, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Detail{
+		Total:     vm.Total,
+		Used:      vm.Used,
+		Available: vm.Available,
+		Free:      vm.Free,
+		Usage:     vm.UsedPercent,
+		Swap: &Swap{
+			Total: swap.Total,
+			Used:  swap.Used,
+			Free:  swap.Free,
+			Usage: swap.UsedPercent,
+		},
+	}, nil
+}
@@ -0,0 +1,574 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/container"
+	"github.com/CristiGvl/picoHWMon/internal/cpu"
+	"github.com/CristiGvl/picoHWMon/internal/disk"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/memory"
+	"github.com/CristiGvl/picoHWMon/internal/network"
+	"github.com/CristiGvl/picoHWMon/internal/power"
+	"github.com/CristiGvl/picoHWMon/internal/process"
+	"github.com/CristiGvl/picoHWMon/internal/temps"
+	"github.com/CristiGvl/picoHWMon/internal/voltage"
+)
+
+// DefaultInterval is how often the sampler collects metrics when no interval is configured
+const DefaultInterval = 5 * time.Second
+
+// Collector names used in Config and Snapshot.Errors
+const (
+	CollectorCPU     = "cpu"
+	CollectorGPU     = "gpu"
+	CollectorMemory  = "memory"
+	CollectorDisk    = "disk"
+	CollectorTemps   = "temps"
+	CollectorFans    = "fans"
+	CollectorNetwork = "network"
+	CollectorPower   = "power"
+	CollectorVoltage = "voltages"
+	CollectorProcess = "processes"
+	CollectorCgroup  = "cgroups"
+)
+
+// Sample is one value of a metric. Labels identify the series, e.g. the GPU
+// device or the disk mountpoint.
+type Sample struct {
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// Key returns a string that uniquely identifies the series of the sample
+func (s *Sample) Key() string {
+	if len(s.Labels) == 0 {
+		return s.Metric
+	}
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(s.Metric)
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strconv.Quote(s.Labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Snapshot is the result of one collection round
+type Snapshot struct {
+	Time    time.Time         `json:"time"`
+	Samples []*Sample         `json:"samples"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// Sources are the readers the sampler collects from
+type Sources struct {
+	CPU    cpu.Reader
+	GPU    gpu.Reader
+	Memory memory.Reader
+	Disk   disk.Reader
+	// Devices adds the health of physical disks to the disk collector
+	Devices disk.DeviceReader
+	// IO adds block device throughput to the disk collector; rates cover the time between rounds
+	IO      disk.IOReader
+	Temps   temps.Reader
+	Fans    fan.Controller
+	Network network.Reader
+	Power   power.Reader
+	// Energy adds the energy meter totals to the power collector
+	Energy  *power.Meter
+	Voltage voltage.Reader
+	// Processes adds process counts and the heaviest processes by name
+	Processes process.Reader
+	// Container adds the limits and usage of picoHWMon's own cgroup
+	Container container.Reader
+}
+
+// Config holds the sampler tunables
+type Config struct {
+	Interval time.Duration
+	// Disabled lists collectors that are skipped
+	Disabled map[string]bool
+	// TopProcesses is how many process names are reported per resource
+	TopProcesses int
+}
+
+// Sampler periodically collects all metrics and hands snapshots to subscribers
+type Sampler struct {
+	sources Sources
+
+	mu          sync.RWMutex
+	config      Config
+	latest      *Snapshot
+	subscribers map[int]chan *Snapshot
+	nextID      int
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewSampler creates a sampler over the given sources
+func NewSampler(sources Sources) *Sampler {
+	return &Sampler{
+		sources:     sources,
+		config:      Config{Interval: DefaultInterval},
+		subscribers: make(map[int]chan *Snapshot),
+	}
+}
+
+// Configure replaces the sampler tunables; the next round uses the new interval
+func (s *Sampler) Configure(config Config) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	s.mu.Lock()
+	s.config = config
+	s.mu.Unlock()
+}
+
+// Start begins collecting in the background
+func (s *Sampler) Start() {
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run()
+}
+
+// Stop stops collecting and closes all subscriber channels
+func (s *Sampler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.stopped
+
+	s.mu.Lock()
+	for id, ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, id)
+	}
+	s.mu.Unlock()
+}
+
+// Latest returns the most recent snapshot, or nil before the first round
+func (s *Sampler) Latest() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.latest
+}
+
+// Subscribe returns a channel that receives every new snapshot and a function
+// that cancels the subscription. Slow subscribers miss snapshots rather than
+// delaying collection.
+func (s *Sampler) Subscribe() (<-chan *Snapshot, func()) {
+	ch := make(chan *Snapshot, 1)
+
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = ch
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ch, ok := s.subscribers[id]; ok {
+			close(ch)
+			delete(s.subscribers, id)
+		}
+	}
+}
+
+// run collects snapshots until stopped
+func (s *Sampler) run() {
+	defer close(s.stopped)
+
+	for {
+		s.mu.RLock()
+		config := s.config
+		s.mu.RUnlock()
+
+		snapshot := s.Collect(config)
+
+		s.mu.Lock()
+		s.latest = snapshot
+		for _, ch := range s.subscribers {
+			select {
+			case ch <- snapshot:
+			default:
+			}
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(config.Interval):
+		}
+	}
+}
+
+// Collect reads every enabled collector once
+func (s *Sampler) Collect(config Config) *Snapshot {
+	timeout := config.Interval
+	if timeout < 5*time.Second {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	snapshot := &Snapshot{Time: time.Now(), Samples: []*Sample{}}
+	add := func(metric string, value float64, labels ...string) {
+		sample := &Sample{Metric: metric, Value: value}
+		if len(labels) > 0 {
+			sample.Labels = make(map[string]string, len(labels)/2)
+			for i := 0; i+1 < len(labels); i += 2 {
+				sample.Labels[labels[i]] = labels[i+1]
+			}
+		}
+		snapshot.Samples = append(snapshot.Samples, sample)
+	}
+	fail := func(collector string, err error) {
+		if snapshot.Errors == nil {
+			snapshot.Errors = make(map[string]string)
+		}
+		snapshot.Errors[collector] = err.Error()
+	}
+
+	if !config.Disabled[CollectorCPU] && s.sources.CPU != nil {
+		if info, err := s.sources.CPU.GetInfo(ctx); err != nil {
+			fail(CollectorCPU, err)
+		} else {
+			add("cpu_usage_percent", info.Usage)
+			add("cpu_frequency_mhz", info.Frequency)
+		}
+	}
+
+	if !config.Disabled[CollectorGPU] && s.sources.GPU != nil {
+		if gpus, err := s.sources.GPU.GetInfo(ctx); err != nil {
+			fail(CollectorGPU, err)
+		} else {
+			for i, g := range gpus {
+				device := strconv.Itoa(i)
+				add("gpu_usage_percent", g.Usage, "device", device)
+				add("gpu_memory_usage_percent", g.MemoryUsage, "device", device)
+				add("gpu_temperature_celsius", g.Temperature, "device", device)
+				add("gpu_power_usage_watts", g.PowerUsage, "device", device)
+				add("gpu_clock_core_mhz", float64(g.ClockCore), "device", device)
+				add("gpu_clock_memory_mhz", float64(g.ClockMemory), "device", device)
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorMemory] && s.sources.Memory != nil {
+		if info, err := s.sources.Memory.GetInfo(ctx); err != nil {
+			fail(CollectorMemory, err)
+		} else {
+			add("memory_usage_percent", info.Usage)
+			add("memory_used_mb", float64(info.Used))
+			add("memory_available_mb", float64(info.Available))
+		}
+	}
+
+	if !config.Disabled[CollectorDisk] && s.sources.Disk != nil {
+		if disks, err := s.sources.Disk.GetInfo(ctx); err != nil {
+			fail(CollectorDisk, err)
+		} else {
+			for _, d := range disks {
+				add("disk_usage_percent", d.Usage, "device", d.Device, "mountpoint", d.Mountpoint)
+				add("disk_used_mb", float64(d.Used), "device", d.Device, "mountpoint", d.Mountpoint)
+				add("disk_available_mb", float64(d.Available), "device", d.Device, "mountpoint", d.Mountpoint)
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorDisk] && s.sources.Devices != nil {
+		if devices, err := s.sources.Devices.GetDevices(ctx); err != nil {
+			if _, failed := snapshot.Errors[CollectorDisk]; !failed {
+				fail(CollectorDisk, err)
+			}
+		} else {
+			for _, d := range devices {
+				addDeviceHealth(add, d)
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorDisk] && s.sources.IO != nil {
+		if devices, err := s.sources.IO.GetIO(ctx); err != nil {
+			if _, failed := snapshot.Errors[CollectorDisk]; !failed {
+				fail(CollectorDisk, err)
+			}
+		} else {
+			for _, d := range devices {
+				add("disk_read_bytes_per_second", d.ReadBytesPerSec, "device", d.Device)
+				add("disk_write_bytes_per_second", d.WriteBytesPerSec, "device", d.Device)
+				add("disk_read_iops", d.ReadIOPS, "device", d.Device)
+				add("disk_write_iops", d.WriteIOPS, "device", d.Device)
+				add("disk_read_latency_ms", d.ReadLatency, "device", d.Device)
+				add("disk_write_latency_ms", d.WriteLatency, "device", d.Device)
+				add("disk_queue_depth", d.QueueDepth, "device", d.Device)
+				add("disk_utilization_percent", d.Utilization, "device", d.Device)
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorTemps] && s.sources.Temps != nil {
+		if info, err := s.sources.Temps.GetInfo(ctx); err != nil {
+			fail(CollectorTemps, err)
+		} else {
+			for category, sensors := range map[string][]*temps.Sensor{
+				"cpu": info.CPU, "gpu": info.GPU, "system": info.System, "drives": info.Drives, "external": info.External,
+			} {
+				for _, sensor := range sensors {
+					add("temperature_celsius", sensor.Temperature,
+						"category", category, "sensor", sensor.Name, "label", sensor.Label)
+				}
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorFans] && s.sources.Fans != nil {
+		if fans, err := s.sources.Fans.GetFans(ctx); err != nil {
+			fail(CollectorFans, err)
+		} else {
+			for i, f := range fans {
+				id := strconv.Itoa(i)
+				add("fan_rpm", float64(f.RPM), "fan", id, "name", f.Name)
+				add("fan_speed_percent", float64(f.Speed), "fan", id, "name", f.Name)
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorNetwork] && s.sources.Network != nil {
+		if ifaces, err := s.sources.Network.GetInfo(ctx); err != nil {
+			fail(CollectorNetwork, err)
+		} else {
+			for _, iface := range ifaces {
+				name := iface.Name
+				up := 0.0
+				if iface.Carrier {
+					up = 1
+				}
+				add("network_up", up, "interface", name)
+				add("network_speed_mbps", float64(iface.Speed), "interface", name)
+				add("network_rx_bytes_per_second", iface.RxBytesPerSec, "interface", name)
+				add("network_tx_bytes_per_second", iface.TxBytesPerSec, "interface", name)
+				add("network_rx_packets_per_second", iface.RxPacketsPerSec, "interface", name)
+				add("network_tx_packets_per_second", iface.TxPacketsPerSec, "interface", name)
+				add("network_rx_errors", float64(iface.Counters.RxErrors), "interface", name)
+				add("network_tx_errors", float64(iface.Counters.TxErrors), "interface", name)
+				add("network_rx_dropped", float64(iface.Counters.RxDropped), "interface", name)
+				add("network_tx_dropped", float64(iface.Counters.TxDropped), "interface", name)
+				if iface.Temperature != nil {
+					add("network_temperature_celsius", *iface.Temperature, "interface", name)
+				}
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorPower] && s.sources.Power != nil {
+		if info, err := s.sources.Power.GetInfo(ctx); err != nil {
+			fail(CollectorPower, err)
+		} else {
+			for _, d := range info.Domains {
+				add("power_domain_watts", d.Watts, "domain", d.Name, "package", strconv.Itoa(d.Package))
+			}
+			for _, sensor := range info.Sensors {
+				add("power_sensor_watts", sensor.Watts, "sensor", sensor.Name, "label", sensor.Label)
+			}
+			for _, b := range info.Batteries {
+				add("battery_charge_percent", b.ChargePercent, "battery", b.Name)
+				add("battery_watts", b.Watts, "battery", b.Name)
+				if b.HealthPercent > 0 {
+					add("battery_health_percent", b.HealthPercent, "battery", b.Name)
+				}
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorPower] && s.sources.Energy != nil {
+		if energy := s.sources.Energy.GetEnergy(); energy.Enabled && energy.LastSample != nil {
+			add("energy_watts", energy.Watts)
+			add("energy_kwh_total", energy.TotalKWh)
+			add("energy_cost_total", energy.Cost)
+		}
+	}
+
+	if !config.Disabled[CollectorVoltage] && s.sources.Voltage != nil {
+		if info, err := s.sources.Voltage.GetInfo(ctx); err != nil {
+			fail(CollectorVoltage, err)
+		} else {
+			for _, rail := range info.Rails {
+				outOfRange := 0.0
+				if rail.OutOfRange() {
+					outOfRange = 1
+				}
+				add("voltage_volts", rail.Volts, "chip", rail.Chip, "rail", rail.Label)
+				add("voltage_out_of_range", outOfRange, "chip", rail.Chip, "rail", rail.Label)
+			}
+		}
+	}
+
+	if !config.Disabled[CollectorProcess] && s.sources.Processes != nil {
+		if processes, _, err := s.sources.Processes.GetProcesses(ctx); err != nil {
+			fail(CollectorProcess, err)
+		} else {
+			add("process_count", float64(len(processes)))
+			addTopProcesses(add, processes, config.TopProcesses)
+		}
+	}
+
+	// Outside cgroup v2 there is nothing to report and no error to repeat
+	if !config.Disabled[CollectorCgroup] && s.sources.Container != nil && s.sources.Container.Environment().CgroupVersion == 2 {
+		if stats, err := s.sources.Container.GetOwn(ctx); err != nil {
+			fail(CollectorCgroup, err)
+		} else {
+			add("cgroup_cpu_percent", stats.CPU.Percent)
+			if stats.CPU.LimitCores != nil {
+				add("cgroup_cpu_limit_cores", *stats.CPU.LimitCores)
+			}
+			add("cgroup_cpu_throttled_seconds_total", stats.CPU.ThrottledSeconds)
+			add("cgroup_memory_bytes", float64(stats.Memory.UsedBytes))
+			if stats.Memory.LimitBytes != nil {
+				add("cgroup_memory_limit_bytes", float64(*stats.Memory.LimitBytes))
+			}
+			add("cgroup_io_read_bytes_per_second", stats.IO.ReadBytesPerSec)
+			add("cgroup_io_write_bytes_per_second", stats.IO.WriteBytesPerSec)
+			add("cgroup_pids", float64(stats.Pids.Current))
+		}
+	}
+
+	return snapshot
+}
+
+// addTopProcesses adds the resource use of the n heaviest process names per
+// resource. Processes are summed by name, so that series stay stable while
+// PIDs come and go.
+func addTopProcesses(add func(metric string, value float64, labels ...string), processes []*process.Process, n int) {
+	if n == 0 {
+		return
+	}
+	type usage struct {
+		name                      string
+		cpu, memory, io, gpuBytes float64
+	}
+	byName := make(map[string]*usage)
+	for _, p := range processes {
+		u := byName[p.Name]
+		if u == nil {
+			u = &usage{name: p.Name}
+			byName[p.Name] = u
+		}
+		u.cpu += p.CPUPercent
+		u.memory += float64(p.MemoryBytes)
+		u.io += p.ReadBytesPerSec + p.WriteBytesPerSec
+		u.gpuBytes += float64(p.GPUMemoryBytes)
+	}
+	names := make([]*usage, 0, len(byName))
+	for _, u := range byName {
+		names = append(names, u)
+	}
+
+	for _, resource := range []struct {
+		metric string
+		value  func(u *usage) float64
+	}{
+		{"process_cpu_percent", func(u *usage) float64 { return u.cpu }},
+		{"process_memory_bytes", func(u *usage) float64 { return u.memory }},
+		{"process_io_bytes_per_second", func(u *usage) float64 { return u.io }},
+		{"process_gpu_memory_bytes", func(u *usage) float64 { return u.gpuBytes }},
+	} {
+		sort.Slice(names, func(i, j int) bool {
+			a, b := resource.value(names[i]), resource.value(names[j])
+			if a != b {
+				return a > b
+			}
+			return names[i].name < names[j].name
+		})
+		for _, u := range names[:min(n, len(names))] {
+			if v := resource.value(u); v > 0 {
+				add(resource.metric, v, "process", u.name)
+			}
+		}
+	}
+}
+
+// healthStates maps disk health states to the value of disk_health_state;
+// unknown health is not reported so that it cannot trigger alerts
+var healthStates = map[string]float64{disk.HealthOK: 0, disk.HealthWarning: 1, disk.HealthFailing: 2}
+
+// addDeviceHealth adds the health metrics a physical disk reports
+func addDeviceHealth(add func(metric string, value float64, labels ...string), d *disk.Device) {
+	h := d.Health
+	if h == nil {
+		return
+	}
+	if state, ok := healthStates[h.State]; ok {
+		add("disk_health_state", state, "device", d.Name)
+	}
+	if h.Temperature != nil {
+		add("disk_temperature_celsius", *h.Temperature, "device", d.Name)
+	}
+	for metric, counter := range map[string]*uint64{
+		"disk_percentage_used":     h.PercentageUsed,
+		"disk_media_errors":        h.MediaErrors,
+		"disk_reallocated_sectors": h.ReallocatedSectors,
+		"disk_pending_sectors":     h.PendingSectors,
+		"disk_power_on_hours":      h.PowerOnHours,
+	} {
+		if counter != nil {
+			add(metric, float64(*counter), "device", d.Name)
+		}
+	}
+}
+
+// CollectorOf returns the collector that produces a metric, or "" if unknown
+func CollectorOf(metric string) string {
+	switch {
+	case strings.HasPrefix(metric, "cpu_"):
+		return CollectorCPU
+	case strings.HasPrefix(metric, "gpu_"):
+		return CollectorGPU
+	case strings.HasPrefix(metric, "memory_"):
+		return CollectorMemory
+	case strings.HasPrefix(metric, "disk_"):
+		return CollectorDisk
+	case strings.HasPrefix(metric, "temperature_"):
+		return CollectorTemps
+	case strings.HasPrefix(metric, "fan_"):
+		return CollectorFans
+	case strings.HasPrefix(metric, "network_"):
+		return CollectorNetwork
+	case strings.HasPrefix(metric, "power_"), strings.HasPrefix(metric, "battery_"), strings.HasPrefix(metric, "energy_"):
+		return CollectorPower
+	case strings.HasPrefix(metric, "voltage_"):
+		return CollectorVoltage
+	case strings.HasPrefix(metric, "process_"):
+		return CollectorProcess
+	case strings.HasPrefix(metric, "cgroup_"):
+		return CollectorCgroup
+	default:
+		return ""
+	}
+}
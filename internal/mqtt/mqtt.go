@@ -0,0 +1,725 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/config"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/metrics"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// commandTimeout bounds fan and profile commands received over MQTT
+	commandTimeout = 30 * time.Second
+	// publishTimeout bounds waiting for the broker to accept a publish
+	publishTimeout = 5 * time.Second
+)
+
+// Status describes the MQTT connection
+type Status struct {
+	Enabled     bool       `json:"enabled"`
+	Connected   bool       `json:"connected"`
+	Broker      string     `json:"broker,omitempty"`
+	TopicPrefix string     `json:"topic_prefix,omitempty"`
+	LastPublish *time.Time `json:"last_publish,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Publisher publishes metrics to an MQTT broker, announces them to Home
+// Assistant and applies fan and overclock profile commands
+type Publisher struct {
+	sampler             *metrics.Sampler
+	fanController       fan.Controller
+	overclockController overclock.Controller
+	auditLog            *audit.Log
+	nodeID              string
+
+	mu          sync.Mutex
+	config      config.MQTT
+	prefix      string
+	client      paho.Client
+	discovered  map[string]bool
+	profiles    string
+	fanCurves   map[int]*fan.Settings
+	lastPublish time.Time
+	lastError   string
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewPublisher creates a disabled publisher. Commands are recorded in
+// auditLog, which may be nil.
+func NewPublisher(sampler *metrics.Sampler, fanController fan.Controller, overclockController overclock.Controller, auditLog *audit.Log) *Publisher {
+	host, _ := os.Hostname()
+	return &Publisher{
+		sampler:             sampler,
+		fanController:       fanController,
+		overclockController: overclockController,
+		auditLog:            auditLog,
+		nodeID:              sanitize("picohwmon_" + host),
+		fanCurves:           make(map[int]*fan.Settings),
+	}
+}
+
+// Configure applies a new configuration, reconnecting if the connection
+// settings changed
+func (p *Publisher) Configure(cfg config.MQTT) {
+	p.mu.Lock()
+	old := p.config
+	running := p.stop != nil
+	p.config = cfg
+	p.mu.Unlock()
+
+	if running && cfg.Enabled && connectionEqual(old, cfg) {
+		return
+	}
+	p.Stop()
+	if cfg.Enabled {
+		p.start(cfg)
+	}
+}
+
+// Stop publishes the offline status and disconnects
+func (p *Publisher) Stop() {
+	p.mu.Lock()
+	stop, stopped, client, prefix := p.stop, p.stopped, p.client, p.prefix
+	p.stop, p.stopped, p.client = nil, nil, nil
+	p.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-stopped
+
+	if client.IsConnected() {
+		client.Publish(prefix+"/status", 1, true, "offline").WaitTimeout(publishTimeout)
+	}
+	client.Disconnect(250)
+}
+
+// GetStatus returns the connection state
+func (p *Publisher) GetStatus() *Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := &Status{
+		Enabled:   p.config.Enabled,
+		LastError: p.lastError,
+	}
+	if p.config.Enabled {
+		status.Broker = p.config.Broker
+		status.TopicPrefix = p.prefix
+	}
+	if p.client != nil {
+		status.Connected = p.client.IsConnected()
+	}
+	if !p.lastPublish.IsZero() {
+		lastPublish := p.lastPublish
+		status.LastPublish = &lastPublish
+	}
+	return status
+}
+
+// start connects to the broker and begins publishing
+func (p *Publisher) start(cfg config.MQTT) {
+	prefix := cfg.TopicPrefix
+	if prefix == "" {
+		host, _ := os.Hostname()
+		prefix = "picohwmon/" + sanitize(host)
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = p.nodeID
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetKeepAlive(30*time.Second).
+		SetConnectTimeout(10*time.Second).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// Commands can take seconds; don't hold up the other handlers
+		SetOrderMatters(false).
+		SetWill(prefix+"/status", "offline", 1, true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			p.setError(fmt.Errorf("connection lost: %w", err))
+		})
+
+	p.mu.Lock()
+	p.prefix = prefix
+	p.client = paho.NewClient(opts)
+	p.discovered = make(map[string]bool)
+	p.profiles = ""
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	client, stop, stopped := p.client, p.stop, p.stopped
+	p.mu.Unlock()
+
+	client.Connect()
+	go p.run(stop, stopped)
+}
+
+// onConnect announces availability, subscribes to commands and resends discovery
+func (p *Publisher) onConnect(client paho.Client) {
+	p.mu.Lock()
+	cfg, prefix := p.config, p.prefix
+	p.discovered = make(map[string]bool)
+	p.profiles = ""
+	p.lastError = ""
+	p.mu.Unlock()
+
+	slog.Info("MQTT connected", "broker", cfg.Broker, "prefix", prefix)
+	client.Publish(prefix+"/status", 1, true, "online")
+
+	if cfg.AllowControl {
+		client.Subscribe(prefix+"/fan/+/+/set", 1, p.onFanCommand)
+		client.Subscribe(prefix+"/fan/+/set", 1, p.onFanCommand)
+		client.Subscribe(prefix+"/overclock/profile/set", 1, p.onProfileCommand)
+	}
+	if cfg.HomeAssistant.Enabled {
+		// Home Assistant announces restarts; discovery has to be sent again
+		client.Subscribe(cfg.HomeAssistant.DiscoveryPrefix+"/status", 0, func(_ paho.Client, msg paho.Message) {
+			if string(msg.Payload()) == "online" {
+				p.mu.Lock()
+				p.discovered = make(map[string]bool)
+				p.profiles = ""
+				p.mu.Unlock()
+			}
+		})
+	}
+}
+
+// run publishes at the configured interval until stopped
+func (p *Publisher) run(stop, stopped chan struct{}) {
+	defer close(stopped)
+
+	for {
+		p.mu.Lock()
+		interval := p.config.Interval.Std()
+		client := p.client
+		p.mu.Unlock()
+
+		if client != nil && client.IsConnected() {
+			if err := p.publish(client); err != nil {
+				p.setError(err)
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// publish sends the latest metrics, fan states and discovery payloads
+func (p *Publisher) publish(client paho.Client) error {
+	p.mu.Lock()
+	cfg, prefix := p.config, p.prefix
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	send := func(topic string, retained bool, payload interface{}) error {
+		token := client.Publish(topic, cfg.QoS, retained, payload)
+		if !token.WaitTimeout(publishTimeout) {
+			return fmt.Errorf("publishing %s timed out", topic)
+		}
+		return token.Error()
+	}
+
+	if snapshot := p.sampler.Latest(); snapshot != nil {
+		for _, sample := range snapshot.Samples {
+			topic := prefix + "/" + seriesPath(sample)
+			retain := cfg.Retain && !transient(sample)
+			if err := send(topic, retain, strconv.FormatFloat(sample.Value, 'f', -1, 64)); err != nil {
+				return err
+			}
+			if cfg.HomeAssistant.Enabled {
+				if err := p.discoverSensor(send, sample, topic); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := p.publishFans(ctx, send); err != nil {
+		return err
+	}
+	if err := p.publishProfiles(ctx, send); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.lastPublish = time.Now()
+	p.lastError = ""
+	p.mu.Unlock()
+	return nil
+}
+
+// sender publishes one message
+type sender func(topic string, retained bool, payload interface{}) error
+
+// publishFans publishes the state of every fan and announces fans to Home Assistant
+func (p *Publisher) publishFans(ctx context.Context, send sender) error {
+	p.mu.Lock()
+	cfg, prefix := p.config, p.prefix
+	p.mu.Unlock()
+
+	fans, err := p.fanController.GetFans(ctx)
+	if err != nil {
+		return fmt.Errorf("reading fans: %w", err)
+	}
+
+	for fanID, info := range fans {
+		settings, err := p.fanController.GetSettings(ctx, fanID)
+		if err != nil {
+			continue
+		}
+		if settings.Mode == fan.ModeCurve && len(settings.Curve) > 0 {
+			p.mu.Lock()
+			p.fanCurves[fanID] = settings
+			p.mu.Unlock()
+		}
+
+		base := fmt.Sprintf("%s/fan/%d", prefix, fanID)
+		state, percentage := "ON", info.Speed
+		if settings.Mode == fan.ModeAuto {
+			state = "OFF"
+		}
+		if settings.Mode == fan.ModeFixed {
+			percentage = settings.FixedSpeed
+		}
+		for topic, payload := range map[string]string{
+			base + "/state":      state,
+			base + "/percentage": strconv.Itoa(percentage),
+			base + "/preset":     string(settings.Mode),
+			base + "/rpm":        strconv.Itoa(info.RPM),
+		} {
+			if err := send(topic, cfg.Retain, payload); err != nil {
+				return err
+			}
+		}
+
+		if cfg.HomeAssistant.Enabled && cfg.AllowControl && info.Controllable {
+			if err := p.discoverFan(send, fanID, info.Name, base); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// publishProfiles announces the overclock profile select when the profile list changes
+func (p *Publisher) publishProfiles(ctx context.Context, send sender) error {
+	p.mu.Lock()
+	cfg, prefix, known := p.config, p.prefix, p.profiles
+	p.mu.Unlock()
+
+	if !cfg.HomeAssistant.Enabled || !cfg.AllowControl {
+		return nil
+	}
+
+	profiles, err := p.overclockController.GetProfiles(ctx)
+	if err != nil {
+		return fmt.Errorf("reading overclock profiles: %w", err)
+	}
+	options := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		options = append(options, profile.Name)
+	}
+	sort.Strings(options)
+	if strings.Join(options, "\n") == known || len(options) == 0 {
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"name":               "Overclock profile",
+		"unique_id":          p.nodeID + "_overclock_profile",
+		"object_id":          p.nodeID + "_overclock_profile",
+		"icon":               "mdi:speedometer",
+		"options":            options,
+		"state_topic":        prefix + "/overclock/profile",
+		"command_topic":      prefix + "/overclock/profile/set",
+		"availability_topic": prefix + "/status",
+		"device":             p.device(),
+	}
+	topic := fmt.Sprintf("%s/select/%s/overclock_profile/config", cfg.HomeAssistant.DiscoveryPrefix, p.nodeID)
+	if err := sendJSON(send, topic, payload); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.profiles = strings.Join(options, "\n")
+	p.mu.Unlock()
+	return nil
+}
+
+// discoverSensor sends the Home Assistant discovery payload of a new series.
+// Transient series are not announced, since Home Assistant would keep an
+// entity for each of them.
+func (p *Publisher) discoverSensor(send sender, sample *metrics.Sample, stateTopic string) error {
+	if transient(sample) {
+		return nil
+	}
+	objectID := sanitize(strings.ReplaceAll(seriesPath(sample), "/", "_"))
+
+	p.mu.Lock()
+	cfg, prefix, done := p.config, p.prefix, p.discovered[objectID]
+	p.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"name":               sensorName(sample),
+		"unique_id":          p.nodeID + "_" + objectID,
+		"object_id":          p.nodeID + "_" + objectID,
+		"state_topic":        stateTopic,
+		"state_class":        "measurement",
+		"availability_topic": prefix + "/status",
+		"device":             p.device(),
+	}
+	unit, deviceClass := unitOf(sample.Metric)
+	if unit != "" {
+		payload["unit_of_measurement"] = unit
+	}
+	if deviceClass != "" {
+		payload["device_class"] = deviceClass
+	}
+
+	topic := fmt.Sprintf("%s/sensor/%s/%s/config", cfg.HomeAssistant.DiscoveryPrefix, p.nodeID, objectID)
+	if err := sendJSON(send, topic, payload); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.discovered[objectID] = true
+	p.mu.Unlock()
+	return nil
+}
+
+// discoverFan sends the Home Assistant discovery payload of a controllable fan
+func (p *Publisher) discoverFan(send sender, fanID int, name, base string) error {
+	objectID := fmt.Sprintf("fan_%d", fanID)
+
+	p.mu.Lock()
+	cfg, prefix, done := p.config, p.prefix, p.discovered[objectID]
+	p.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"name":                      "Fan " + name,
+		"unique_id":                 p.nodeID + "_" + objectID,
+		"object_id":                 p.nodeID + "_" + objectID,
+		"state_topic":               base + "/state",
+		"command_topic":             base + "/set",
+		"percentage_state_topic":    base + "/percentage",
+		"percentage_command_topic":  base + "/percentage/set",
+		"preset_mode_state_topic":   base + "/preset",
+		"preset_mode_command_topic": base + "/preset/set",
+		"preset_modes":              []string{string(fan.ModeAuto), string(fan.ModeFixed), string(fan.ModeCurve)},
+		"availability_topic":        prefix + "/status",
+		"device":                    p.device(),
+	}
+	topic := fmt.Sprintf("%s/fan/%s/%s/config", cfg.HomeAssistant.DiscoveryPrefix, p.nodeID, objectID)
+	if err := sendJSON(send, topic, payload); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.discovered[objectID] = true
+	p.mu.Unlock()
+	return nil
+}
+
+// device describes this host in discovery payloads
+func (p *Publisher) device() map[string]interface{} {
+	host, _ := os.Hostname()
+	return map[string]interface{}{
+		"identifiers":  []string{p.nodeID},
+		"name":         "picoHWMon " + host,
+		"manufacturer": "picoHWMon",
+		"model":        platform.GetOS(),
+	}
+}
+
+// onFanCommand handles <prefix>/fan/<id>/set, .../percentage/set and .../preset/set
+func (p *Publisher) onFanCommand(client paho.Client, msg paho.Message) {
+	p.mu.Lock()
+	prefix := p.prefix
+	p.mu.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(msg.Topic(), prefix+"/fan/"), "/")
+	fanID, err := strconv.Atoi(parts[0])
+	if err != nil || fanID < 0 {
+		return
+	}
+	command := "state"
+	if len(parts) == 3 {
+		command = parts[1]
+	}
+	payload := strings.TrimSpace(string(msg.Payload()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	previous, _ := p.fanController.GetSettings(ctx, fanID)
+	settings, err := p.fanSettingsFor(ctx, fanID, command, payload, previous)
+	if err == nil {
+		err = p.fanController.SetSettings(ctx, fanID, settings)
+	}
+
+	p.record(msg.Topic(), fmt.Sprintf("fan:%d", fanID), previous, settings, err)
+	if err != nil {
+		slog.Warn("MQTT fan command failed", "fan", fanID, "command", payload, "error", err)
+	}
+}
+
+// fanSettingsFor converts a fan command into settings
+func (p *Publisher) fanSettingsFor(ctx context.Context, fanID int, command, payload string, current *fan.Settings) (*fan.Settings, error) {
+	currentSpeed := func() int {
+		if current != nil && current.Mode == fan.ModeFixed {
+			return current.FixedSpeed
+		}
+		if fans, err := p.fanController.GetFans(ctx); err == nil && fanID < len(fans) {
+			return fans[fanID].Speed
+		}
+		return 100
+	}
+
+	switch command {
+	case "percentage":
+		speed, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentage %q", payload)
+		}
+		return &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: speed}, nil
+
+	case "preset":
+		switch fan.FanMode(payload) {
+		case fan.ModeAuto:
+			return &fan.Settings{Mode: fan.ModeAuto}, nil
+		case fan.ModeFixed:
+			return &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: currentSpeed()}, nil
+		case fan.ModeCurve:
+			p.mu.Lock()
+			curve := p.fanCurves[fanID]
+			p.mu.Unlock()
+			if curve == nil {
+				return nil, fmt.Errorf("fan %d has no curve to return to; set one through the API first", fanID)
+			}
+			return curve, nil
+		default:
+			return nil, fmt.Errorf("unknown preset %q", payload)
+		}
+
+	case "state":
+		switch strings.ToUpper(payload) {
+		case "OFF":
+			return &fan.Settings{Mode: fan.ModeAuto}, nil
+		case "ON":
+			if current != nil && current.Mode != fan.ModeAuto {
+				return current, nil
+			}
+			return &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: currentSpeed()}, nil
+		default:
+			return nil, fmt.Errorf("unknown state %q", payload)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown fan command %q", command)
+	}
+}
+
+// onProfileCommand loads the overclock profile named in the payload
+func (p *Publisher) onProfileCommand(client paho.Client, msg paho.Message) {
+	name := strings.TrimSpace(string(msg.Payload()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	result, err := p.overclockController.LoadProfile(ctx, name)
+	if err == nil && !result.Success {
+		err = fmt.Errorf("profile %s was only partially applied", name)
+	}
+	p.record(msg.Topic(), "profile:"+name, nil, result, err)
+	if err != nil {
+		slog.Warn("MQTT profile command failed", "profile", name, "error", err)
+		return
+	}
+
+	p.mu.Lock()
+	prefix, cfg := p.prefix, p.config
+	p.mu.Unlock()
+	client.Publish(prefix+"/overclock/profile", cfg.QoS, true, name)
+}
+
+// record writes a command to the audit log
+func (p *Publisher) record(topic, target string, previous, applied interface{}, err error) {
+	if p.auditLog == nil {
+		return
+	}
+
+	p.mu.Lock()
+	cfg := p.config
+	p.mu.Unlock()
+
+	identity := "mqtt"
+	if cfg.Username != "" {
+		identity = "mqtt:" + cfg.Username
+	}
+	entry := &audit.Entry{
+		Time:       time.Now(),
+		ClientAddr: cfg.Broker,
+		Identity:   identity,
+		AuthMethod: "mqtt",
+		Method:     "MQTT",
+		Endpoint:   topic,
+		Target:     target,
+		Result:     audit.ResultSuccess,
+	}
+	if previous != nil {
+		entry.Previous, _ = json.Marshal(previous)
+	}
+	if applied != nil {
+		entry.New, _ = json.Marshal(applied)
+	}
+	if err != nil {
+		entry.Result = audit.ResultFailure
+		entry.Error = err.Error()
+	}
+	if err := p.auditLog.Record(entry); err != nil {
+		slog.Error("Failed to record MQTT command in the audit log", "error", err)
+	}
+}
+
+// setError remembers the last error for the status
+func (p *Publisher) setError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastError != err.Error() {
+		slog.Warn("MQTT", "error", err)
+	}
+	p.lastError = err.Error()
+}
+
+// connectionEqual reports whether two configurations share the same connection settings
+func connectionEqual(a, b config.MQTT) bool {
+	return a.Broker == b.Broker && a.ClientID == b.ClientID && a.Username == b.Username &&
+		a.Password == b.Password && a.TopicPrefix == b.TopicPrefix && a.AllowControl == b.AllowControl &&
+		a.HomeAssistant == b.HomeAssistant
+}
+
+// sendJSON publishes a retained JSON payload
+func sendJSON(send sender, topic string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return send(topic, true, data)
+}
+
+// transient reports whether a series only exists for a while, like the
+// per-process series of the top processes
+func transient(sample *metrics.Sample) bool {
+	_, ok := sample.Labels["process"]
+	return ok
+}
+
+// seriesPath is the topic path of a series: the metric followed by its label
+// values, ordered by label name
+func seriesPath(sample *metrics.Sample) string {
+	names := make([]string, 0, len(sample.Labels))
+	for name := range sample.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{sample.Metric}
+	for _, name := range names {
+		parts = append(parts, sanitize(sample.Labels[name]))
+	}
+	return strings.Join(parts, "/")
+}
+
+// sensorName is a readable name for a series
+func sensorName(sample *metrics.Sample) string {
+	name := strings.ReplaceAll(sample.Metric, "_", " ")
+	for _, suffix := range []string{" percent", " celsius", " mhz", " watts", " mb", " rpm"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	names := make([]string, 0, len(sample.Labels))
+	for label := range sample.Labels {
+		names = append(names, label)
+	}
+	sort.Strings(names)
+	for _, label := range names {
+		if value := sample.Labels[label]; value != "" {
+			name += " " + value
+		}
+	}
+	return name
+}
+
+// unitOf returns the Home Assistant unit and device class of a metric
+func unitOf(metric string) (string, string) {
+	switch {
+	case strings.HasSuffix(metric, "_celsius"):
+		return "°C", "temperature"
+	case strings.HasSuffix(metric, "_percent"):
+		return "%", ""
+	case strings.HasSuffix(metric, "_mhz"):
+		return "MHz", "frequency"
+	case strings.HasSuffix(metric, "_watts"):
+		return "W", "power"
+	case strings.HasSuffix(metric, "_mb"):
+		return "MB", "data_size"
+	case strings.HasSuffix(metric, "_rpm"):
+		return "rpm", ""
+	default:
+		return "", ""
+	}
+}
+
+// sanitize turns a label value into a topic level and object ID: lower case
+// letters, digits and underscores
+func sanitize(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	result := strings.Trim(b.String(), "_")
+	if result == "" {
+		return "root"
+	}
+	return result
+}
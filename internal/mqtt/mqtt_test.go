@@ -0,0 +1,434 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/config"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/metrics"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// message is a publish seen by the broker
+type message struct {
+	payload  string
+	retained bool
+}
+
+// broker stands in for an MQTT broker: it keeps the last message of every
+// topic published through it
+type broker struct {
+	paho.Client
+
+	mu       sync.Mutex
+	messages map[string]message
+	count    int
+}
+
+func newBroker() *broker {
+	return &broker{messages: make(map[string]message)}
+}
+
+func (b *broker) IsConnected() bool { return true }
+
+func (b *broker) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var text string
+	switch p := payload.(type) {
+	case string:
+		text = p
+	case []byte:
+		text = string(p)
+	default:
+		text = fmt.Sprint(p)
+	}
+	b.messages[topic] = message{payload: text, retained: retained}
+	b.count++
+	return doneToken{}
+}
+
+// send publishes like Publisher.publish does
+func (b *broker) send(topic string, retained bool, payload interface{}) error {
+	return b.Publish(topic, 0, retained, payload).Error()
+}
+
+func (b *broker) get(topic string) (message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.messages[topic]
+	return m, ok
+}
+
+func (b *broker) published() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// doneToken is a completed publish
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (doneToken) Error() error { return nil }
+
+// command is a message received on a command topic
+type command struct {
+	topic   string
+	payload string
+}
+
+func (m command) Duplicate() bool   { return false }
+func (m command) Qos() byte         { return 1 }
+func (m command) Retained() bool    { return false }
+func (m command) Topic() string     { return m.topic }
+func (m command) MessageID() uint16 { return 1 }
+func (m command) Payload() []byte   { return []byte(m.payload) }
+func (m command) Ack()              {}
+
+// fans is a fan controller with two fans
+type fans struct {
+	fan.Controller
+
+	settings map[int]*fan.Settings
+	set      map[int]*fan.Settings
+	err      error
+}
+
+func newFans() *fans {
+	return &fans{
+		settings: map[int]*fan.Settings{0: {Mode: fan.ModeAuto}, 1: {Mode: fan.ModeFixed, FixedSpeed: 60}},
+		set:      make(map[int]*fan.Settings),
+	}
+}
+
+func (f *fans) GetFans(ctx context.Context) ([]*fan.Info, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []*fan.Info{
+		{Name: "cpu_fan", RPM: 900, Speed: 35, Controllable: true},
+		{Name: "case_fan", RPM: 1200, Speed: 60, Controllable: true},
+	}, nil
+}
+
+func (f *fans) GetSettings(ctx context.Context, fanID int) (*fan.Settings, error) {
+	settings, ok := f.settings[fanID]
+	if !ok {
+		return nil, fmt.Errorf("fan %d not found", fanID)
+	}
+	copied := *settings
+	return &copied, nil
+}
+
+func (f *fans) SetSettings(ctx context.Context, fanID int, settings *fan.Settings) error {
+	if _, ok := f.settings[fanID]; !ok {
+		return fmt.Errorf("fan %d not found", fanID)
+	}
+	f.settings[fanID] = settings
+	f.set[fanID] = settings
+	return nil
+}
+
+// profiles is an overclock controller with a fixed set of profiles
+type profiles struct {
+	overclock.Controller
+
+	names  []string
+	loaded []string
+	err    error
+}
+
+func (o *profiles) GetProfiles(ctx context.Context) ([]*overclock.Profile, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	result := make([]*overclock.Profile, 0, len(o.names))
+	for _, name := range o.names {
+		result = append(result, &overclock.Profile{Name: name})
+	}
+	return result, nil
+}
+
+func (o *profiles) LoadProfile(ctx context.Context, name string) (*overclock.ProfileResult, error) {
+	for _, known := range o.names {
+		if known == name {
+			o.loaded = append(o.loaded, name)
+			return &overclock.ProfileResult{Profile: name, Success: true}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s'", overclock.ErrProfileNotFound, name)
+}
+
+// newTestPublisher returns a publisher that behaves as if connected with
+// Home Assistant discovery and control enabled
+func newTestPublisher(fanController fan.Controller, overclockController overclock.Controller) *Publisher {
+	p := NewPublisher(nil, fanController, overclockController, nil)
+	p.nodeID = "picohwmon_test"
+	p.prefix = "picohwmon/test"
+	p.discovered = make(map[string]bool)
+	p.config = config.MQTT{
+		Enabled:      true,
+		AllowControl: true,
+		HomeAssistant: config.HomeAssistant{
+			Enabled:         true,
+			DiscoveryPrefix: "homeassistant",
+		},
+	}
+	return p
+}
+
+// discovery decodes a retained discovery payload
+func discovery(t *testing.T, b *broker, topic string) map[string]interface{} {
+	t.Helper()
+	m, ok := b.get(topic)
+	if !ok {
+		t.Fatalf("no discovery payload on %s", topic)
+	}
+	if !m.retained {
+		t.Errorf("discovery payload on %s is not retained", topic)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(m.payload), &payload); err != nil {
+		t.Fatalf("discovery payload on %s is not JSON: %v", topic, err)
+	}
+	return payload
+}
+
+func TestDiscoverSensor(t *testing.T) {
+	b := newBroker()
+	p := newTestPublisher(newFans(), &profiles{})
+
+	sample := &metrics.Sample{Metric: "gpu_temperature_celsius", Labels: map[string]string{"gpu": "0"}, Value: 61}
+	stateTopic := p.prefix + "/" + seriesPath(sample)
+	if err := p.discoverSensor(b.send, sample, stateTopic); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := discovery(t, b, "homeassistant/sensor/picohwmon_test/gpu_temperature_celsius_0/config")
+	want := map[string]interface{}{
+		"name":                "gpu temperature 0",
+		"unique_id":           "picohwmon_test_gpu_temperature_celsius_0",
+		"state_topic":         "picohwmon/test/gpu_temperature_celsius/0",
+		"state_class":         "measurement",
+		"unit_of_measurement": "°C",
+		"device_class":        "temperature",
+		"availability_topic":  "picohwmon/test/status",
+	}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("%s = %v, want %v", key, payload[key], value)
+		}
+	}
+	device, _ := payload["device"].(map[string]interface{})
+	if ids, _ := device["identifiers"].([]interface{}); len(ids) != 1 || ids[0] != "picohwmon_test" {
+		t.Errorf("device identifiers = %v, want [picohwmon_test]", device["identifiers"])
+	}
+
+	// Each series is announced once per connection
+	before := b.published()
+	if err := p.discoverSensor(b.send, sample, stateTopic); err != nil {
+		t.Fatal(err)
+	}
+	if b.published() != before {
+		t.Error("discovery payload was sent again")
+	}
+}
+
+func TestDiscoverSensorSkipsProcesses(t *testing.T) {
+	b := newBroker()
+	p := newTestPublisher(newFans(), &profiles{})
+
+	for _, sample := range []*metrics.Sample{
+		{Metric: "process_cpu_percent", Labels: map[string]string{"process": "firefox"}, Value: 12},
+		{Metric: "process_memory_bytes", Labels: map[string]string{"process": "code"}, Value: 1 << 30},
+	} {
+		if err := p.discoverSensor(b.send, sample, p.prefix+"/"+seriesPath(sample)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := b.published(); n != 0 {
+		t.Errorf("%d discovery payloads sent for per-process series, want none", n)
+	}
+
+	count := &metrics.Sample{Metric: "process_count", Value: 312}
+	if err := p.discoverSensor(b.send, count, p.prefix+"/process_count"); err != nil {
+		t.Fatal(err)
+	}
+	discovery(t, b, "homeassistant/sensor/picohwmon_test/process_count/config")
+}
+
+func TestPublishFans(t *testing.T) {
+	b := newBroker()
+	p := newTestPublisher(newFans(), &profiles{})
+
+	if err := p.publishFans(context.Background(), b.send); err != nil {
+		t.Fatal(err)
+	}
+
+	for topic, want := range map[string]string{
+		"picohwmon/test/fan/0/state":      "OFF",
+		"picohwmon/test/fan/0/percentage": "35",
+		"picohwmon/test/fan/0/preset":     "auto",
+		"picohwmon/test/fan/0/rpm":        "900",
+		"picohwmon/test/fan/1/state":      "ON",
+		"picohwmon/test/fan/1/percentage": "60",
+		"picohwmon/test/fan/1/preset":     "fixed",
+	} {
+		if m, ok := b.get(topic); !ok || m.payload != want {
+			t.Errorf("%s = %q, want %q", topic, m.payload, want)
+		}
+	}
+
+	payload := discovery(t, b, "homeassistant/fan/picohwmon_test/fan_1/config")
+	for key, want := range map[string]string{
+		"name":                      "Fan case_fan",
+		"command_topic":             "picohwmon/test/fan/1/set",
+		"percentage_command_topic":  "picohwmon/test/fan/1/percentage/set",
+		"preset_mode_command_topic": "picohwmon/test/fan/1/preset/set",
+		"state_topic":               "picohwmon/test/fan/1/state",
+	} {
+		if payload[key] != want {
+			t.Errorf("%s = %v, want %s", key, payload[key], want)
+		}
+	}
+
+	// Without control, fans are not announced as controllable entities
+	b = newBroker()
+	p = newTestPublisher(newFans(), &profiles{})
+	p.config.AllowControl = false
+	if err := p.publishFans(context.Background(), b.send); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.get("homeassistant/fan/picohwmon_test/fan_0/config"); ok {
+		t.Error("fan announced without allow_control")
+	}
+}
+
+func TestPublishProfiles(t *testing.T) {
+	b := newBroker()
+	p := newTestPublisher(newFans(), &profiles{names: []string{"quiet", "gaming"}})
+
+	if err := p.publishProfiles(context.Background(), b.send); err != nil {
+		t.Fatal(err)
+	}
+	payload := discovery(t, b, "homeassistant/select/picohwmon_test/overclock_profile/config")
+	options, _ := payload["options"].([]interface{})
+	if len(options) != 2 || options[0] != "gaming" || options[1] != "quiet" {
+		t.Errorf("options = %v, want [gaming quiet]", payload["options"])
+	}
+	if payload["command_topic"] != "picohwmon/test/overclock/profile/set" {
+		t.Errorf("command_topic = %v", payload["command_topic"])
+	}
+
+	// The select is only announced again when the profile list changes
+	before := b.published()
+	if err := p.publishProfiles(context.Background(), b.send); err != nil {
+		t.Fatal(err)
+	}
+	if b.published() != before {
+		t.Error("unchanged profile list was announced again")
+	}
+}
+
+func TestPublishReportsControllerErrors(t *testing.T) {
+	failing := newFans()
+	failing.err = errors.New("hwmon unavailable")
+	p := newTestPublisher(failing, &profiles{})
+	if err := p.publishFans(context.Background(), newBroker().send); !errors.Is(err, failing.err) {
+		t.Errorf("publishFans error = %v, want %v", err, failing.err)
+	}
+
+	broken := &profiles{err: errors.New("profiles directory unreadable")}
+	p = newTestPublisher(newFans(), broken)
+	if err := p.publishProfiles(context.Background(), newBroker().send); !errors.Is(err, broken.err) {
+		t.Errorf("publishProfiles error = %v, want %v", err, broken.err)
+	}
+}
+
+func TestFanCommands(t *testing.T) {
+	curve := &fan.Settings{Mode: fan.ModeCurve, Curve: []fan.CurvePoint{{Temperature: 40, FanSpeed: 30}, {Temperature: 80, FanSpeed: 100}}}
+
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		curves  map[int]*fan.Settings
+		fanID   int
+		want    *fan.Settings
+	}{
+		{name: "percentage", topic: "fan/0/percentage/set", payload: "40", want: &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: 40}},
+		{name: "preset auto", topic: "fan/1/preset/set", payload: "auto", fanID: 1, want: &fan.Settings{Mode: fan.ModeAuto}},
+		{name: "preset fixed keeps speed", topic: "fan/0/preset/set", payload: "fixed", want: &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: 35}},
+		{name: "preset curve", topic: "fan/0/preset/set", payload: "curve", curves: map[int]*fan.Settings{0: curve}, want: curve},
+		{name: "off", topic: "fan/1/set", payload: "OFF", fanID: 1, want: &fan.Settings{Mode: fan.ModeAuto}},
+		{name: "on from auto", topic: "fan/0/set", payload: "ON", want: &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: 35}},
+		{name: "on keeps manual", topic: "fan/1/set", payload: "on", fanID: 1, want: &fan.Settings{Mode: fan.ModeFixed, FixedSpeed: 60}},
+		{name: "invalid percentage", topic: "fan/0/percentage/set", payload: "fast"},
+		{name: "unknown preset", topic: "fan/0/preset/set", payload: "turbo"},
+		{name: "curve never set", topic: "fan/0/preset/set", payload: "curve"},
+		{name: "unknown fan", topic: "fan/x/set", payload: "ON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBroker()
+			controller := newFans()
+			p := newTestPublisher(controller, &profiles{})
+			for id, settings := range tt.curves {
+				p.fanCurves[id] = settings
+			}
+
+			p.onFanCommand(b, command{topic: p.prefix + "/" + tt.topic, payload: tt.payload})
+
+			if tt.want == nil {
+				if len(controller.set) != 0 {
+					t.Errorf("fan settings changed to %+v, want no change", controller.set)
+				}
+				return
+			}
+			got := controller.set[tt.fanID]
+			if got == nil {
+				t.Fatalf("fan %d was not changed", tt.fanID)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("fan %d settings = %s, want %s", tt.fanID, gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestProfileCommand(t *testing.T) {
+	b := newBroker()
+	controller := &profiles{names: []string{"gaming"}}
+	p := newTestPublisher(newFans(), controller)
+
+	p.onProfileCommand(b, command{topic: p.prefix + "/overclock/profile/set", payload: " gaming\n"})
+	if len(controller.loaded) != 1 || controller.loaded[0] != "gaming" {
+		t.Fatalf("loaded profiles = %v, want [gaming]", controller.loaded)
+	}
+	if m, ok := b.get("picohwmon/test/overclock/profile"); !ok || m.payload != "gaming" || !m.retained {
+		t.Errorf("profile state = %+v, want retained gaming", m)
+	}
+
+	// A failed load leaves the reported profile alone
+	p.onProfileCommand(b, command{topic: p.prefix + "/overclock/profile/set", payload: "missing"})
+	if m, _ := b.get("picohwmon/test/overclock/profile"); m.payload != "gaming" {
+		t.Errorf("profile state = %q after a failed load, want gaming", m.payload)
+	}
+}
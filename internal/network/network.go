@@ -0,0 +1,204 @@
+package network
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// minInterval is the shortest window rates are computed over; reads
+	// closer together return the previous result
+	minInterval = time.Second
+	// firstWindow is how long the first read waits for a second sample
+	firstWindow = 250 * time.Millisecond
+)
+
+// Interface is a network interface with its traffic over the last sampling window
+type Interface struct {
+	Name string `json:"name"`
+	MAC  string `json:"mac,omitempty"`
+	MTU  int    `json:"mtu"`
+	// Virtual marks interfaces without hardware: loopback, bridges, veth, tunnels
+	Virtual bool `json:"virtual"`
+	// State is the operational state, e.g. up, down or dormant
+	State   string `json:"state"`
+	Carrier bool   `json:"carrier"`
+	// Speed is the negotiated link speed in Mbit/s; 0 when unknown or without link
+	Speed     int      `json:"speed_mbps"`
+	Duplex    string   `json:"duplex,omitempty"`
+	Addresses []string `json:"addresses"`
+	// Temperature comes from the NIC's hwmon sensor, when it has one
+	Temperature *float64 `json:"temperature_celsius,omitempty"`
+
+	RxBytesPerSec   float64 `json:"rx_bytes_per_second"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_second"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_second"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_second"`
+	// Window is the length of the sampling window in seconds
+	Window float64 `json:"window_seconds"`
+
+	Counters Counters `json:"counters"`
+}
+
+// Counters are cumulative since the interface came up
+type Counters struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxErrors  uint64 `json:"tx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+// Filter selects the reported interfaces. Patterns use filepath.Match syntax.
+type Filter struct {
+	// IncludeVirtual reports loopback, bridge, veth and tunnel interfaces too
+	IncludeVirtual bool
+	// Include lists the only interfaces reported, when not empty; it also
+	// admits virtual interfaces it names
+	Include []string
+	Exclude []string
+}
+
+// matches reports whether an interface passes the filter
+func (f Filter) matches(iface *Interface) bool {
+	if len(f.Include) > 0 {
+		if !matchAny(f.Include, iface.Name) {
+			return false
+		}
+	} else if iface.Virtual && !f.IncludeVirtual {
+		return false
+	}
+	return !matchAny(f.Exclude, iface.Name)
+}
+
+// matchAny reports whether name matches one of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reader interface for network monitoring. Rates are deltas between
+// consecutive reads, so the metrics sampler sets the window.
+type Reader interface {
+	GetInfo(ctx context.Context) ([]*Interface, error)
+	// Configure replaces the filter that selects the reported interfaces
+	Configure(filter Filter)
+}
+
+// NewReader creates a new network reader for the current platform
+func NewReader() Reader {
+	return newPlatformReader()
+}
+
+// tracker turns the cumulative counters of a platform read into rates
+type tracker struct {
+	read func(ctx context.Context) ([]*Interface, error)
+
+	mu       sync.Mutex
+	filter   Filter
+	previous map[string]Counters
+	taken    time.Time
+	latest   []*Interface
+}
+
+// Configure replaces the filter
+func (t *tracker) Configure(filter Filter) {
+	t.mu.Lock()
+	t.filter = filter
+	t.latest = nil
+	t.mu.Unlock()
+}
+
+// GetInfo returns the filtered interfaces with their rates since the
+// previous call. The first call waits briefly for a second sample.
+func (t *tracker) GetInfo(ctx context.Context) ([]*Interface, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.previous == nil {
+		ifaces, err := t.read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		t.previous, t.taken = counters(ifaces), time.Now()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(firstWindow):
+		}
+	} else if t.latest != nil && time.Since(t.taken) < minInterval {
+		return t.latest, nil
+	}
+
+	ifaces, err := t.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	seconds := now.Sub(t.taken).Seconds()
+
+	// Counters that went backwards were reset, e.g. by a driver reload; the
+	// window reads as idle
+	rate := func(before, after uint64) float64 {
+		if after < before {
+			return 0
+		}
+		return float64(after-before) / seconds
+	}
+	reported := []*Interface{}
+	for _, iface := range ifaces {
+		if !t.filter.matches(iface) {
+			continue
+		}
+		if previous, ok := t.previous[iface.Name]; ok {
+			c := iface.Counters
+			iface.RxBytesPerSec = rate(previous.RxBytes, c.RxBytes)
+			iface.TxBytesPerSec = rate(previous.TxBytes, c.TxBytes)
+			iface.RxPacketsPerSec = rate(previous.RxPackets, c.RxPackets)
+			iface.TxPacketsPerSec = rate(previous.TxPackets, c.TxPackets)
+			iface.Window = seconds
+		}
+		reported = append(reported, iface)
+	}
+	sort.Slice(reported, func(i, j int) bool { return reported[i].Name < reported[j].Name })
+
+	t.previous, t.taken, t.latest = counters(ifaces), now, reported
+	return reported, nil
+}
+
+// counters indexes the counters of interfaces by name
+func counters(ifaces []*Interface) map[string]Counters {
+	byName := make(map[string]Counters, len(ifaces))
+	for _, iface := range ifaces {
+		byName[iface.Name] = iface.Counters
+	}
+	return byName
+}
+
+// addresses returns the addresses of an interface in CIDR notation
+func addresses(name string) []string {
+	list := []string{}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return list
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return list
+	}
+	for _, addr := range addrs {
+		list = append(list, addr.String())
+	}
+	return list
+}
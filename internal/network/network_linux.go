@@ -0,0 +1,100 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// LinuxReader implements network monitoring for Linux from /sys/class/net
+type LinuxReader struct {
+	tracker
+}
+
+// newPlatformReader creates a new Linux network reader
+func newPlatformReader() Reader {
+	return &LinuxReader{tracker{read: readInterfaces}}
+}
+
+// readInterfaces reads every interface in /sys/class/net
+func readInterfaces(ctx context.Context) ([]*Interface, error) {
+	entries, err := os.ReadDir(platform.HostSys("class", "net"))
+	if err != nil {
+		return nil, err
+	}
+
+	ifaces := []*Interface{}
+	for _, entry := range entries {
+		name := entry.Name()
+		sysPath := platform.HostSys("class", "net", name)
+		target, err := filepath.EvalSymlinks(sysPath)
+		if err != nil {
+			continue // removed while listing
+		}
+
+		iface := &Interface{
+			Name:      name,
+			MAC:       readSysfs(sysPath, "address"),
+			Virtual:   strings.Contains(target, "/devices/virtual/"),
+			State:     readSysfs(sysPath, "operstate"),
+			Carrier:   readSysfs(sysPath, "carrier") == "1",
+			Addresses: addresses(name),
+		}
+		iface.MTU, _ = strconv.Atoi(readSysfs(sysPath, "mtu"))
+		// speed is -1 or unreadable without link
+		if speed, err := strconv.Atoi(readSysfs(sysPath, "speed")); err == nil && speed > 0 {
+			iface.Speed = speed
+		}
+		if duplex := readSysfs(sysPath, "duplex"); duplex != "unknown" {
+			iface.Duplex = duplex
+		}
+		iface.Temperature = nicTemperature(sysPath)
+
+		counter := func(name string) uint64 {
+			value, _ := strconv.ParseUint(readSysfs(sysPath, "statistics/"+name), 10, 64)
+			return value
+		}
+		iface.Counters = Counters{
+			RxBytes:   counter("rx_bytes"),
+			TxBytes:   counter("tx_bytes"),
+			RxPackets: counter("rx_packets"),
+			TxPackets: counter("tx_packets"),
+			RxErrors:  counter("rx_errors"),
+			TxErrors:  counter("tx_errors"),
+			RxDropped: counter("rx_dropped"),
+			TxDropped: counter("tx_dropped"),
+		}
+		ifaces = append(ifaces, iface)
+	}
+	return ifaces, nil
+}
+
+// nicTemperature reads the first temperature of the NIC's hwmon device, as
+// exposed by drivers such as ixgbe, igb, bnxt_en and atlantic
+func nicTemperature(sysPath string) *float64 {
+	inputs, _ := filepath.Glob(filepath.Join(sysPath, "device/hwmon/hwmon*/temp*_input"))
+	for _, input := range inputs {
+		millidegrees, err := strconv.ParseInt(readSysfs(filepath.Dir(input), filepath.Base(input)), 10, 64)
+		if err != nil {
+			continue
+		}
+		celsius := float64(millidegrees) / 1000
+		return &celsius
+	}
+	return nil
+}
+
+// readSysfs returns the trimmed contents of a sysfs attribute, or ""
+func readSysfs(dir, attribute string) string {
+	data, err := os.ReadFile(filepath.Join(dir, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
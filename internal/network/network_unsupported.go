@@ -0,0 +1,24 @@
+//go:build !linux && !windows
+
+package network
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnsupportedReader is a fallback for unsupported platforms
+type UnsupportedReader struct{}
+
+// newPlatformReader creates a fallback network reader for unsupported platforms
+func newPlatformReader() Reader {
+	return &UnsupportedReader{}
+}
+
+// GetInfo returns an error for unsupported platforms
+func (r *UnsupportedReader) GetInfo(ctx context.Context) ([]*Interface, error) {
+	return nil, fmt.Errorf("network monitoring not supported on this platform")
+}
+
+// Configure does nothing on unsupported platforms
+func (r *UnsupportedReader) Configure(filter Filter) {}
@@ -0,0 +1,92 @@
+//go:build windows
+
+package network
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/StackExchange/wmi"
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// MSFT_NetAdapter is the link state of a network adapter
+type MSFT_NetAdapter struct {
+	Name              string
+	Virtual           bool
+	FullDuplex        bool
+	Speed             uint64
+	MediaConnectState uint32
+}
+
+// mediaConnected is MSFT_NetAdapter.MediaConnectState of an adapter with link
+const mediaConnected = 1
+
+// WindowsReader implements network monitoring for Windows
+type WindowsReader struct {
+	tracker
+}
+
+// newPlatformReader creates a new Windows network reader
+func newPlatformReader() Reader {
+	return &WindowsReader{tracker{read: readInterfaces}}
+}
+
+// readInterfaces reads the interface counters and adds the link state
+// from WMI. Interfaces unknown to WMI, such as the loopback, are virtual.
+func readInterfaces(ctx context.Context) ([]*Interface, error) {
+	stats, err := psnet.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var adapters []MSFT_NetAdapter
+	// Without WMI the counters are still useful; link details stay empty
+	_ = wmi.QueryNamespace("SELECT Name, Virtual, FullDuplex, Speed, MediaConnectState FROM MSFT_NetAdapter",
+		&adapters, `root\StandardCimv2`)
+	byName := make(map[string]MSFT_NetAdapter, len(adapters))
+	for _, a := range adapters {
+		byName[a.Name] = a
+	}
+
+	ifaces := []*Interface{}
+	for _, s := range stats {
+		iface := &Interface{
+			Name:      s.Name,
+			Virtual:   true,
+			State:     "down",
+			Addresses: addresses(s.Name),
+			Counters: Counters{
+				RxBytes:   s.BytesRecv,
+				TxBytes:   s.BytesSent,
+				RxPackets: s.PacketsRecv,
+				TxPackets: s.PacketsSent,
+				RxErrors:  s.Errin,
+				TxErrors:  s.Errout,
+				RxDropped: s.Dropin,
+				TxDropped: s.Dropout,
+			},
+		}
+		if ni, err := net.InterfaceByName(s.Name); err == nil {
+			iface.MAC = strings.ToLower(ni.HardwareAddr.String())
+			iface.MTU = ni.MTU
+			if ni.Flags&net.FlagUp != 0 {
+				iface.State = "up"
+			}
+		}
+		if a, ok := byName[s.Name]; ok {
+			iface.Virtual = a.Virtual
+			iface.Carrier = a.MediaConnectState == mediaConnected
+			if iface.Carrier {
+				iface.Speed = int(a.Speed / 1000000)
+				iface.Duplex = "half"
+				if a.FullDuplex {
+					iface.Duplex = "full"
+				}
+			}
+		}
+		ifaces = append(ifaces, iface)
+	}
+	return ifaces, nil
+}
@@ -0,0 +1,662 @@
+package overclock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/cpu"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+)
+
+// ErrProfileNotFound is returned when a named profile does not exist
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ErrProfileExists is returned when a profile would overwrite an existing one
+var ErrProfileExists = errors.New("profile already exists")
+
+// ErrBlocked is returned for overclock writes while Block is in effect
+var ErrBlocked = errors.New("overclock changes are blocked")
+
+// ErrInvalidSettings is returned when settings or a profile fail validation
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// fallbackRanges bound the fields a device reports no range for, e.g. when
+// the backend cannot query its limits
+var fallbackRanges = gpu.Capabilities{
+	CoreClockOffset:   &gpu.Range{Min: -500, Max: 500},
+	MemoryClockOffset: &gpu.Range{Min: -1000, Max: 1000},
+	PowerLimit:        &gpu.Range{Min: 50, Max: 150},
+	TempLimit:         &gpu.Range{Min: 60, Max: 95},
+	FanSpeed:          &gpu.Range{Min: 0, Max: 100},
+	VoltageOffset:     &gpu.Range{Min: -100, Max: 100},
+}
+
+// currentProfileName stores the last applied GPU settings
+const currentProfileName = "_current"
+
+// GPUController implements overclocking control by delegating to the GPU
+// backends, and stores profiles as JSON files in profilesDir
+type GPUController struct {
+	profilesDir   string
+	gpuReader     gpu.Reader
+	fanController fan.Controller
+	cpuPolicy     cpu.PolicyController
+	transactions  *transactionManager
+
+	// profilesMu serializes profile file changes
+	profilesMu sync.Mutex
+
+	limitsMu sync.RWMutex
+	limits   SafetyLimits
+
+	blockMu sync.Mutex
+	blocked string
+}
+
+// newGPUController creates a controller storing profiles in profilesDir
+func newGPUController(profilesDir string, gpuReader gpu.Reader, fanController fan.Controller, cpuPolicy cpu.PolicyController) *GPUController {
+	// Create profiles directory if it doesn't exist
+	os.MkdirAll(profilesDir, 0755)
+
+	return &GPUController{
+		profilesDir:   profilesDir,
+		gpuReader:     gpuReader,
+		fanController: fanController,
+		cpuPolicy:     cpuPolicy,
+		transactions:  newTransactionManager(),
+	}
+}
+
+// GetSettings reads the current overclocking settings back from the hardware
+func (c *GPUController) GetSettings(ctx context.Context, deviceID int) (*Settings, error) {
+	current, err := c.gpuReader.GetOverclockSettings(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromGPUSettings(deviceID, current), nil
+}
+
+// SetSettings validates and applies overclocking settings to the hardware
+func (c *GPUController) SetSettings(ctx context.Context, settings *Settings) (*gpu.OverclockResult, error) {
+	if err := c.checkBlocked(); err != nil {
+		return nil, err
+	}
+	if err := c.validateSettings(ctx, settings); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSettings, err)
+	}
+
+	return c.applySettings(ctx, settings)
+}
+
+// applySettings applies already validated settings and remembers them as the
+// current settings of the device
+func (c *GPUController) applySettings(ctx context.Context, settings *Settings) (*gpu.OverclockResult, error) {
+	result, err := c.gpuReader.SetOverclockSettings(ctx, settings.DeviceID, toGPUSettings(settings))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.rememberCurrent(settings); err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("failed to save current settings: %v", err))
+	}
+
+	return result, nil
+}
+
+// rememberCurrent records the last applied settings per device in _current.json
+func (c *GPUController) rememberCurrent(settings *Settings) error {
+	c.profilesMu.Lock()
+	defer c.profilesMu.Unlock()
+
+	current, err := c.readProfile(currentProfileName)
+	if err != nil {
+		current = &Profile{Name: currentProfileName, CreatedAt: time.Now()}
+	}
+
+	replaced := false
+	for i, s := range current.GPUs {
+		if s.DeviceID == settings.DeviceID {
+			current.GPUs[i] = settings
+			replaced = true
+		}
+	}
+	if !replaced {
+		current.GPUs = append(current.GPUs, settings)
+	}
+	current.UpdatedAt = time.Now()
+
+	return c.writeProfile(currentProfileName, current)
+}
+
+// GetProfiles returns saved overclocking profiles sorted by name
+func (c *GPUController) GetProfiles(ctx context.Context) ([]*Profile, error) {
+	profiles := []*Profile{}
+
+	files, err := os.ReadDir(c.profilesDir)
+	if err != nil {
+		return profiles, nil // Return empty list if directory doesn't exist
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".json")
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" || ValidateProfileName(name) != nil {
+			continue
+		}
+
+		profile, err := c.readProfile(name)
+		if err != nil {
+			continue
+		}
+		profiles = append(profiles, profile)
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	return profiles, nil
+}
+
+// GetProfile returns a single saved profile
+func (c *GPUController) GetProfile(ctx context.Context, profileName string) (*Profile, error) {
+	if err := ValidateProfileName(profileName); err != nil {
+		return nil, err
+	}
+
+	return c.readProfile(profileName)
+}
+
+// SaveProfile validates and saves an overclocking profile, stamping it with
+// the current hardware fingerprint. Saving over an existing profile keeps its
+// creation time.
+func (c *GPUController) SaveProfile(ctx context.Context, profile *Profile) (*Profile, error) {
+	if err := ValidateProfileName(profile.Name); err != nil {
+		return nil, err
+	}
+
+	profile.CPU = cpuPolicyOrNil(profile.CPU)
+	if err := validateProfileComponents(profile); err != nil {
+		return nil, fmt.Errorf("%w in profile: %w", ErrInvalidSettings, err)
+	}
+	for _, settings := range profile.GPUs {
+		if err := c.validateSettings(ctx, settings); err != nil {
+			return nil, fmt.Errorf("%w in profile: %w", ErrInvalidSettings, err)
+		}
+	}
+
+	c.profilesMu.Lock()
+	defer c.profilesMu.Unlock()
+
+	now := time.Now()
+	profile.SchemaVersion = ProfileSchemaVersion
+	profile.CreatedAt = now
+	profile.UpdatedAt = now
+	if existing, err := c.readProfile(profile.Name); err == nil {
+		profile.CreatedAt = existing.CreatedAt
+	}
+	profile.Hardware = c.fingerprint(ctx)
+
+	if err := c.writeProfile(profile.Name, profile); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// DeleteProfile removes a saved profile
+func (c *GPUController) DeleteProfile(ctx context.Context, profileName string) error {
+	if err := ValidateProfileName(profileName); err != nil {
+		return err
+	}
+
+	c.profilesMu.Lock()
+	defer c.profilesMu.Unlock()
+
+	if err := os.Remove(c.profilePath(profileName)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: '%s'", ErrProfileNotFound, profileName)
+		}
+		return fmt.Errorf("failed to delete profile '%s': %w", profileName, err)
+	}
+
+	return nil
+}
+
+// RenameProfile renames a saved profile without overwriting another one
+func (c *GPUController) RenameProfile(ctx context.Context, profileName, newName string) (*Profile, error) {
+	if err := ValidateProfileName(profileName); err != nil {
+		return nil, err
+	}
+	if err := ValidateProfileName(newName); err != nil {
+		return nil, err
+	}
+
+	c.profilesMu.Lock()
+	defer c.profilesMu.Unlock()
+
+	profile, err := c.readProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if newName == profileName {
+		return profile, nil
+	}
+	if fileExists(c.profilePath(newName)) {
+		return nil, fmt.Errorf("%w: '%s'", ErrProfileExists, newName)
+	}
+
+	profile.Name = newName
+	profile.UpdatedAt = time.Now()
+	if err := c.writeProfile(newName, profile); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(c.profilePath(profileName)); err != nil {
+		return nil, fmt.Errorf("renamed profile saved but failed to remove '%s': %w", profileName, err)
+	}
+
+	return profile, nil
+}
+
+// ExportProfile wraps a saved profile in a portable export document
+func (c *GPUController) ExportProfile(ctx context.Context, profileName string) (*ProfileExport, error) {
+	profile, err := c.GetProfile(ctx, profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProfileExport{
+		Format:     ProfileExportFormat,
+		ExportedAt: time.Now(),
+		Profile:    profile,
+	}, nil
+}
+
+// ImportProfile saves a profile from an export document. The profile keeps
+// the hardware fingerprint it was exported with, so loading it on different
+// hardware produces a warning. newName optionally renames the profile.
+func (c *GPUController) ImportProfile(ctx context.Context, data []byte, newName string, overwrite bool) (*Profile, error) {
+	profile, err := DecodeProfileExport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if newName != "" {
+		profile.Name = newName
+	}
+	if err := ValidateProfileName(profile.Name); err != nil {
+		return nil, err
+	}
+	profile.CPU = cpuPolicyOrNil(profile.CPU)
+	if err := validateProfileComponents(profile); err != nil {
+		return nil, fmt.Errorf("%w in profile: %w", ErrInvalidSettings, err)
+	}
+
+	c.profilesMu.Lock()
+	defer c.profilesMu.Unlock()
+
+	if !overwrite && fileExists(c.profilePath(profile.Name)) {
+		return nil, fmt.Errorf("%w: '%s'", ErrProfileExists, profile.Name)
+	}
+
+	now := time.Now()
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = now
+	}
+	profile.UpdatedAt = now
+
+	if err := c.writeProfile(profile.Name, profile); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// LoadProfile loads an overclocking profile and applies its GPU, fan and CPU
+// settings to the hardware
+func (c *GPUController) LoadProfile(ctx context.Context, profileName string) (*ProfileResult, error) {
+	if err := c.checkBlocked(); err != nil {
+		return nil, err
+	}
+	profile, err := c.prepareProfile(ctx, profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := c.applyProfile(ctx, profile)
+	result.Warnings = append(c.fingerprintWarnings(ctx, profile), result.Warnings...)
+
+	return result, nil
+}
+
+// prepareProfile reads a profile and validates it against the current hardware
+func (c *GPUController) prepareProfile(ctx context.Context, profileName string) (*Profile, error) {
+	profile, err := c.GetProfile(ctx, profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateProfileComponents(profile); err != nil {
+		return nil, fmt.Errorf("%w in profile: %w", ErrInvalidSettings, err)
+	}
+	for _, settings := range profile.GPUs {
+		if err := c.validateSettings(ctx, settings); err != nil {
+			return nil, fmt.Errorf("%w in profile: %w", ErrInvalidSettings, err)
+		}
+	}
+
+	return profile, nil
+}
+
+// applyProfile applies every component of a profile, recording failures per
+// component instead of stopping at the first one
+func (c *GPUController) applyProfile(ctx context.Context, profile *Profile) *ProfileResult {
+	result := &ProfileResult{
+		Profile:    profile.Name,
+		Success:    true,
+		GPUs:       []*gpu.OverclockResult{},
+		Components: []*ComponentResult{},
+		Warnings:   []string{},
+	}
+
+	for _, settings := range profile.GPUs {
+		gpuResult, err := c.applySettings(ctx, settings)
+		if err != nil {
+			gpuResult = &gpu.OverclockResult{
+				DeviceID: settings.DeviceID,
+				Fields:   []*gpu.FieldResult{},
+				Applied:  []string{},
+				Warnings: []string{},
+				Errors:   []string{err.Error()},
+			}
+		}
+		if !gpuResult.Success {
+			result.Success = false
+		}
+		result.GPUs = append(result.GPUs, gpuResult)
+	}
+
+	for _, f := range profile.Fans {
+		component := &ComponentResult{Component: fmt.Sprintf("fan:%d", f.FanID), Success: true}
+		if err := c.fanController.SetSettings(ctx, f.FanID, f.Settings); err != nil {
+			component.Success = false
+			component.Error = err.Error()
+			result.Success = false
+		}
+		result.Components = append(result.Components, component)
+	}
+
+	if profile.CPU != nil {
+		component := &ComponentResult{Component: "cpu", Success: true}
+		if err := c.cpuPolicy.SetPolicy(ctx, profile.CPU); err != nil {
+			component.Success = false
+			component.Error = err.Error()
+			result.Success = false
+		}
+		result.Components = append(result.Components, component)
+	}
+
+	return result
+}
+
+// fingerprint describes the GPUs and fans currently present
+func (c *GPUController) fingerprint(ctx context.Context) *HardwareFingerprint {
+	fingerprint := &HardwareFingerprint{GPUs: []string{}, Fans: []string{}}
+
+	if gpus, err := c.gpuReader.GetInfo(ctx); err == nil {
+		for _, g := range gpus {
+			fingerprint.GPUs = append(fingerprint.GPUs, strings.TrimSpace(string(g.Vendor)+" "+g.Model))
+		}
+	}
+	if fans, err := c.fanController.GetFans(ctx); err == nil {
+		for _, f := range fans {
+			fingerprint.Fans = append(fingerprint.Fans, f.Name)
+		}
+	}
+
+	return fingerprint
+}
+
+// fingerprintWarnings warns when a profile was saved on different hardware
+func (c *GPUController) fingerprintWarnings(ctx context.Context, profile *Profile) []string {
+	if profile.Hardware == nil {
+		return []string{}
+	}
+
+	current := c.fingerprint(ctx)
+	if profile.Hardware.Equal(current) {
+		return []string{}
+	}
+
+	return []string{fmt.Sprintf("profile '%s' was created on different hardware (%s); current hardware is %s",
+		profile.Name, profile.Hardware, current)}
+}
+
+// profilePath returns the file path of a profile
+func (c *GPUController) profilePath(name string) string {
+	return filepath.Join(c.profilesDir, name+".json")
+}
+
+// readProfile reads <name>.json from the profiles directory, migrating older schemas
+func (c *GPUController) readProfile(profileName string) (*Profile, error) {
+	data, err := os.ReadFile(c.profilePath(profileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: '%s'", ErrProfileNotFound, profileName)
+		}
+		return nil, fmt.Errorf("failed to read profile '%s': %w", profileName, err)
+	}
+
+	profile, err := DecodeProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile '%s': %w", profileName, err)
+	}
+	if profile.Name == "" {
+		profile.Name = profileName
+	}
+
+	return profile, nil
+}
+
+// writeProfile atomically writes a profile to <name>.json in the profiles directory
+func (c *GPUController) writeProfile(name string, profile *Profile) error {
+	profile.SchemaVersion = ProfileSchemaVersion
+	if profile.GPUs == nil {
+		profile.GPUs = []*Settings{}
+	}
+
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.profilesDir, ".profile-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.profilePath(name)); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	return nil
+}
+
+// fileExists checks if a file exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// toGPUSettings converts overclocking settings to the GPU backend type
+func toGPUSettings(settings *Settings) *gpu.OverclockSettings {
+	return &gpu.OverclockSettings{
+		CoreClockOffset:   settings.CoreClockOffset,
+		MemoryClockOffset: settings.MemoryClockOffset,
+		PowerLimit:        settings.PowerLimit,
+		TempLimit:         settings.TempLimit,
+		FanSpeed:          settings.FanSpeed,
+		VoltageOffset:     settings.VoltageOffset,
+	}
+}
+
+// fromGPUSettings converts GPU backend settings to overclocking settings
+func fromGPUSettings(deviceID int, settings *gpu.OverclockSettings) *Settings {
+	return &Settings{
+		DeviceID:          deviceID,
+		CoreClockOffset:   settings.CoreClockOffset,
+		MemoryClockOffset: settings.MemoryClockOffset,
+		PowerLimit:        settings.PowerLimit,
+		TempLimit:         settings.TempLimit,
+		FanSpeed:          settings.FanSpeed,
+		VoltageOffset:     settings.VoltageOffset,
+	}
+}
+
+// Configure replaces the safety limits and the watchdog thresholds of
+// transactions started afterwards
+func (c *GPUController) Configure(limits SafetyLimits, watchdog WatchdogConfig) {
+	c.limitsMu.Lock()
+	c.limits = limits
+	c.limitsMu.Unlock()
+
+	c.transactions.mu.Lock()
+	c.transactions.watchdog = watchdog
+	c.transactions.mu.Unlock()
+}
+
+// Block refuses overclock writes while reason is not empty
+func (c *GPUController) Block(reason string) {
+	c.blockMu.Lock()
+	defer c.blockMu.Unlock()
+	c.blocked = reason
+}
+
+// Blocked returns the reason overclock writes are refused
+func (c *GPUController) Blocked() string {
+	c.blockMu.Lock()
+	defer c.blockMu.Unlock()
+	return c.blocked
+}
+
+// checkBlocked returns ErrBlocked while a block is in effect
+func (c *GPUController) checkBlocked() error {
+	if reason := c.Blocked(); reason != "" {
+		return fmt.Errorf("%w: %s", ErrBlocked, reason)
+	}
+	return nil
+}
+
+// ForceSettings applies settings despite a block and without validation
+func (c *GPUController) ForceSettings(ctx context.Context, settings *Settings) (*gpu.OverclockResult, error) {
+	return c.applySettings(ctx, settings)
+}
+
+// checkSafetyLimits validates settings against the configured safety limits
+func (c *GPUController) checkSafetyLimits(settings *Settings) error {
+	c.limitsMu.RLock()
+	limits := c.limits
+	c.limitsMu.RUnlock()
+
+	abs := func(v int) int {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+
+	if limits.MaxCoreClockOffset > 0 && abs(settings.CoreClockOffset) > limits.MaxCoreClockOffset {
+		return fmt.Errorf("core clock offset exceeds the safety limit of ±%d MHz", limits.MaxCoreClockOffset)
+	}
+	if limits.MaxMemoryClockOffset > 0 && abs(settings.MemoryClockOffset) > limits.MaxMemoryClockOffset {
+		return fmt.Errorf("memory clock offset exceeds the safety limit of ±%d MHz", limits.MaxMemoryClockOffset)
+	}
+	if limits.MaxPowerLimit > 0 && settings.PowerLimit > limits.MaxPowerLimit {
+		return fmt.Errorf("power limit exceeds the safety limit of %d%%", limits.MaxPowerLimit)
+	}
+	if limits.MaxTempLimit > 0 && settings.TempLimit > limits.MaxTempLimit {
+		return fmt.Errorf("temperature limit exceeds the safety limit of %d°C", limits.MaxTempLimit)
+	}
+	if limits.MaxVoltageOffset > 0 && (settings.VoltageOffset > limits.MaxVoltageOffset || settings.VoltageOffset < -limits.MaxVoltageOffset) {
+		return fmt.Errorf("voltage offset exceeds the safety limit of ±%g mV", limits.MaxVoltageOffset)
+	}
+
+	return nil
+}
+
+// validateSettings validates overclocking settings against the ranges the
+// device reports, or against fallbackRanges for fields it reports none for.
+// Zero values mean stock/unchanged/auto and are always allowed.
+func (c *GPUController) validateSettings(ctx context.Context, settings *Settings) error {
+	if settings == nil {
+		return fmt.Errorf("settings cannot be nil")
+	}
+
+	if settings.PowerLimit < 0 {
+		return fmt.Errorf("power limit cannot be negative")
+	}
+	if settings.TempLimit < 0 {
+		return fmt.Errorf("temperature limit cannot be negative")
+	}
+	if settings.FanSpeed < 0 || settings.FanSpeed > 100 {
+		return fmt.Errorf("fan speed must be between 0%% and 100%%")
+	}
+	if err := c.checkSafetyLimits(settings); err != nil {
+		return err
+	}
+
+	caps, err := c.gpuReader.GetCapabilities(ctx, settings.DeviceID)
+	if err != nil {
+		// Applying will report a missing device; still bound the values
+		caps = &gpu.Capabilities{}
+	}
+	orFallback := func(rng, fallback *gpu.Range) *gpu.Range {
+		if rng == nil {
+			return fallback
+		}
+		return rng
+	}
+
+	checks := []struct {
+		name  string
+		value float64
+		rng   *gpu.Range
+		unit  string
+	}{
+		{"core clock offset", float64(settings.CoreClockOffset), orFallback(caps.CoreClockOffset, fallbackRanges.CoreClockOffset), " MHz"},
+		{"memory clock offset", float64(settings.MemoryClockOffset), orFallback(caps.MemoryClockOffset, fallbackRanges.MemoryClockOffset), " MHz"},
+		{"power limit", float64(settings.PowerLimit), orFallback(caps.PowerLimit, fallbackRanges.PowerLimit), "%"},
+		{"temperature limit", float64(settings.TempLimit), orFallback(caps.TempLimit, fallbackRanges.TempLimit), "°C"},
+		{"fan speed", float64(settings.FanSpeed), orFallback(caps.FanSpeed, fallbackRanges.FanSpeed), "%"},
+		{"voltage offset", settings.VoltageOffset, orFallback(caps.VoltageOffset, fallbackRanges.VoltageOffset), " mV"},
+	}
+	for _, check := range checks {
+		if check.value == 0 {
+			continue
+		}
+		if !check.rng.Contains(check.value) {
+			return fmt.Errorf("%s must be between %g%s and %g%s for GPU device %d",
+				check.name, check.rng.Min, check.unit, check.rng.Max, check.unit, settings.DeviceID)
+		}
+	}
+
+	return nil
+}
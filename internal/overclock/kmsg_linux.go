@@ -0,0 +1,79 @@
+//go:build linux
+
+package overclock
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// watchKernelLog streams GPU fault messages from /dev/kmsg until stop is closed.
+// Only records written after the call are considered.
+func watchKernelLog(stop <-chan struct{}) (<-chan string, error) {
+	file, err := os.Open("/dev/kmsg")
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the existing ring buffer contents
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	events := make(chan string, 1)
+
+	go func() {
+		<-stop
+		file.Close()
+	}()
+
+	go func() {
+		buf := make([]byte, 8192)
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				// EPIPE means records were overwritten before we read them
+				if errors.Is(err, syscall.EPIPE) {
+					continue
+				}
+				return
+			}
+
+			// Record format: "priority,sequence,timestamp,flags;message"
+			record := string(buf[:n])
+			if i := strings.Index(record, ";"); i >= 0 {
+				record = record[i+1:]
+			}
+			message := strings.TrimSpace(strings.SplitN(record, "\n", 2)[0])
+
+			if isGPUFault(message) {
+				select {
+				case events <- message:
+				default:
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// isGPUFault reports whether a kernel message indicates a GPU hang or reset
+func isGPUFault(message string) bool {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "NVRM: Xid"):
+		return true
+	case strings.Contains(lower, "amdgpu") && strings.Contains(lower, "ring") && strings.Contains(lower, "timeout"):
+		return true
+	case strings.Contains(lower, "amdgpu") && strings.Contains(lower, "gpu recovery"):
+		return true
+	case strings.Contains(lower, "gpu reset"), strings.Contains(lower, "gpu hang"):
+		return true
+	}
+	return false
+}
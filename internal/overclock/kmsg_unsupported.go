@@ -0,0 +1,10 @@
+//go:build !linux
+
+package overclock
+
+import "fmt"
+
+// watchKernelLog is not available on this platform
+func watchKernelLog(stop <-chan struct{}) (<-chan string, error) {
+	return nil, fmt.Errorf("kernel log monitoring not supported on this platform")
+}
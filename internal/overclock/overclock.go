@@ -1,6 +1,13 @@
 package overclock
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/cpu"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+)
 
 // Settings represents overclocking settings
 type Settings struct {
@@ -13,22 +20,69 @@ type Settings struct {
 	VoltageOffset     float64 `json:"voltage_offset_mv"`
 }
 
-// Profile represents an overclocking profile
+// Profile represents an overclocking profile covering any number of GPUs,
+// fans and an optional CPU policy
 type Profile struct {
-	Name     string    `json:"name"`
-	Settings *Settings `json:"settings"`
+	SchemaVersion int                  `json:"schema_version"`
+	Name          string               `json:"name"`
+	Description   string               `json:"description,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	Hardware      *HardwareFingerprint `json:"hardware,omitempty"`
+	GPUs          []*Settings          `json:"gpus"`
+	Fans          []*FanSettings       `json:"fans,omitempty"`
+	CPU           *cpu.Policy          `json:"cpu,omitempty"`
+}
+
+// SafetyLimits cap overclock settings on top of the ranges the hardware
+// reports. Zero fields don't impose a cap.
+type SafetyLimits struct {
+	MaxCoreClockOffset   int
+	MaxMemoryClockOffset int
+	MaxPowerLimit        int
+	MaxTempLimit         int
+	MaxVoltageOffset     float64
 }
 
 // Controller interface for overclocking control
 type Controller interface {
 	GetSettings(ctx context.Context, deviceID int) (*Settings, error)
-	SetSettings(ctx context.Context, settings *Settings) error
+	SetSettings(ctx context.Context, settings *Settings) (*gpu.OverclockResult, error)
+
 	GetProfiles(ctx context.Context) ([]*Profile, error)
-	SaveProfile(ctx context.Context, profile *Profile) error
-	LoadProfile(ctx context.Context, profileName string) error
+	GetProfile(ctx context.Context, profileName string) (*Profile, error)
+	SaveProfile(ctx context.Context, profile *Profile) (*Profile, error)
+	DeleteProfile(ctx context.Context, profileName string) error
+	RenameProfile(ctx context.Context, profileName, newName string) (*Profile, error)
+	ExportProfile(ctx context.Context, profileName string) (*ProfileExport, error)
+	ImportProfile(ctx context.Context, data []byte, newName string, overwrite bool) (*Profile, error)
+	LoadProfile(ctx context.Context, profileName string) (*ProfileResult, error)
+
+	// Two-phase variants that revert unless confirmed within timeout
+	BeginSettings(ctx context.Context, settings *Settings, timeout time.Duration) (*Transaction, error)
+	BeginProfile(ctx context.Context, profileName string, timeout time.Duration) (*Transaction, error)
+	ConfirmTransaction(ctx context.Context, id string) (*Transaction, error)
+	RollbackTransaction(ctx context.Context, id string) (*Transaction, error)
+	GetTransactions(ctx context.Context) ([]*Transaction, error)
+
+	// Configure replaces the safety limits and the watchdog thresholds of
+	// transactions started afterwards
+	Configure(limits SafetyLimits, watchdog WatchdogConfig)
+
+	// Block refuses SetSettings, LoadProfile and new transactions with
+	// ErrBlocked while reason is not empty; Block("") lifts it
+	Block(reason string)
+	// Blocked returns the reason writes are refused, or "" if they are not
+	Blocked() string
+	// ForceSettings applies settings despite a block and without validation.
+	// It is meant for the safety mechanism that holds the block.
+	ForceSettings(ctx context.Context, settings *Settings) (*gpu.OverclockResult, error)
 }
 
 // NewController creates a new overclocking controller for the current platform
-func NewController() Controller {
-	return newPlatformController()
+// that applies GPU settings through gpuReader and profile fan and CPU settings
+// through fanController and cpuPolicy. An empty profilesDir selects the
+// platform default.
+func NewController(gpuReader gpu.Reader, fanController fan.Controller, cpuPolicy cpu.PolicyController, profilesDir string) Controller {
+	return newPlatformController(gpuReader, fanController, cpuPolicy, profilesDir)
 }
@@ -5,13 +5,18 @@ package overclock
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/cpu"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
 )
 
 // UnsupportedController is a fallback for unsupported platforms
 type UnsupportedController struct{}
 
 // newPlatformController creates a fallback overclocking controller for unsupported platforms
-func newPlatformController() Controller {
+func newPlatformController(gpuReader gpu.Reader, fanController fan.Controller, cpuPolicy cpu.PolicyController, profilesDir string) Controller {
 	return &UnsupportedController{}
 }
 
@@ -21,8 +26,8 @@ func (c *UnsupportedController) GetSettings(ctx context.Context, deviceID int) (
 }
 
 // SetSettings returns an error for unsupported platforms
-func (c *UnsupportedController) SetSettings(ctx context.Context, settings *Settings) error {
-	return fmt.Errorf("overclocking control not supported on this platform")
+func (c *UnsupportedController) SetSettings(ctx context.Context, settings *Settings) (*gpu.OverclockResult, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
 }
 
 // GetProfiles returns an error for unsupported platforms
@@ -30,12 +35,78 @@ func (c *UnsupportedController) GetProfiles(ctx context.Context) ([]*Profile, er
 	return nil, fmt.Errorf("overclocking control not supported on this platform")
 }
 
+// GetProfile returns an error for unsupported platforms
+func (c *UnsupportedController) GetProfile(ctx context.Context, profileName string) (*Profile, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
 // SaveProfile returns an error for unsupported platforms
-func (c *UnsupportedController) SaveProfile(ctx context.Context, profile *Profile) error {
+func (c *UnsupportedController) SaveProfile(ctx context.Context, profile *Profile) (*Profile, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// DeleteProfile returns an error for unsupported platforms
+func (c *UnsupportedController) DeleteProfile(ctx context.Context, profileName string) error {
 	return fmt.Errorf("overclocking control not supported on this platform")
 }
 
+// RenameProfile returns an error for unsupported platforms
+func (c *UnsupportedController) RenameProfile(ctx context.Context, profileName, newName string) (*Profile, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// ExportProfile returns an error for unsupported platforms
+func (c *UnsupportedController) ExportProfile(ctx context.Context, profileName string) (*ProfileExport, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// ImportProfile returns an error for unsupported platforms
+func (c *UnsupportedController) ImportProfile(ctx context.Context, data []byte, newName string, overwrite bool) (*Profile, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
 // LoadProfile returns an error for unsupported platforms
-func (c *UnsupportedController) LoadProfile(ctx context.Context, profileName string) error {
-	return fmt.Errorf("overclocking control not supported on this platform")
+func (c *UnsupportedController) LoadProfile(ctx context.Context, profileName string) (*ProfileResult, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// BeginSettings returns an error for unsupported platforms
+func (c *UnsupportedController) BeginSettings(ctx context.Context, settings *Settings, timeout time.Duration) (*Transaction, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// BeginProfile returns an error for unsupported platforms
+func (c *UnsupportedController) BeginProfile(ctx context.Context, profileName string, timeout time.Duration) (*Transaction, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// ConfirmTransaction returns an error for unsupported platforms
+func (c *UnsupportedController) ConfirmTransaction(ctx context.Context, id string) (*Transaction, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// RollbackTransaction returns an error for unsupported platforms
+func (c *UnsupportedController) RollbackTransaction(ctx context.Context, id string) (*Transaction, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// GetTransactions returns an error for unsupported platforms
+func (c *UnsupportedController) GetTransactions(ctx context.Context) ([]*Transaction, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
+}
+
+// Configure does nothing on unsupported platforms
+func (c *UnsupportedController) Configure(limits SafetyLimits, watchdog WatchdogConfig) {}
+
+// Block does nothing on unsupported platforms
+func (c *UnsupportedController) Block(reason string) {}
+
+// Blocked returns no reason on unsupported platforms
+func (c *UnsupportedController) Blocked() string {
+	return ""
+}
+
+// ForceSettings returns an error for unsupported platforms
+func (c *UnsupportedController) ForceSettings(ctx context.Context, settings *Settings) (*gpu.OverclockResult, error) {
+	return nil, fmt.Errorf("overclocking control not supported on this platform")
 }
@@ -0,0 +1,226 @@
+package overclock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/cpu"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+)
+
+// ProfileSchemaVersion is the current on-disk profile schema version
+const ProfileSchemaVersion = 2
+
+// ProfileExportFormat identifies portable profile files
+const ProfileExportFormat = "picohwmon-profile"
+
+// profileNamePattern restricts names to safe file names: no path separators,
+// no leading dot or underscore (underscore names are reserved for internal use)
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}$`)
+
+// ErrInvalidProfileName is returned for names that are not safe file names
+var ErrInvalidProfileName = errors.New("invalid profile name")
+
+// FanSettings binds fan control settings to a fan ID
+type FanSettings struct {
+	FanID    int           `json:"fan_id"`
+	Settings *fan.Settings `json:"settings"`
+}
+
+// HardwareFingerprint identifies the hardware a profile was created on
+type HardwareFingerprint struct {
+	GPUs []string `json:"gpus"`
+	Fans []string `json:"fans"`
+}
+
+// Equal reports whether two fingerprints describe the same hardware
+func (f *HardwareFingerprint) Equal(other *HardwareFingerprint) bool {
+	return f.String() == other.String()
+}
+
+// String returns a human readable summary of the fingerprint
+func (f *HardwareFingerprint) String() string {
+	return fmt.Sprintf("GPUs [%s], fans [%s]", strings.Join(f.GPUs, ", "), strings.Join(f.Fans, ", "))
+}
+
+// ProfileExport represents a portable profile file
+type ProfileExport struct {
+	Format     string    `json:"format"`
+	ExportedAt time.Time `json:"exported_at"`
+	Profile    *Profile  `json:"profile"`
+}
+
+// ComponentResult represents the outcome of applying a fan or CPU setting
+type ComponentResult struct {
+	Component string `json:"component"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProfileResult represents the outcome of loading a profile
+type ProfileResult struct {
+	Profile    string                 `json:"profile"`
+	Success    bool                   `json:"success"`
+	GPUs       []*gpu.OverclockResult `json:"gpus"`
+	Components []*ComponentResult     `json:"components"`
+	Warnings   []string               `json:"warnings"`
+}
+
+// ValidateProfileName checks that a profile name is safe to use as a file name
+func ValidateProfileName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidProfileName)
+	}
+	if strings.HasPrefix(name, "_") {
+		return fmt.Errorf("%w: names starting with '_' are reserved", ErrInvalidProfileName)
+	}
+	if !profileNamePattern.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("%w %q: use up to 64 letters, digits, spaces, '.', '_' or '-'", ErrInvalidProfileName, name)
+	}
+	return nil
+}
+
+// DecodeProfile parses a profile in any supported schema version and migrates
+// it to the current one
+func DecodeProfile(data []byte) (*Profile, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid profile JSON: %w", err)
+	}
+
+	version := 1
+	if v, exists := raw["schema_version"]; exists {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, fmt.Errorf("invalid schema_version: %w", err)
+		}
+	}
+	if version > ProfileSchemaVersion {
+		return nil, fmt.Errorf("profile schema version %d is newer than supported version %d", version, ProfileSchemaVersion)
+	}
+
+	for version < ProfileSchemaVersion {
+		migrate, exists := profileMigrations[version]
+		if !exists {
+			return nil, fmt.Errorf("no migration from profile schema version %d", version)
+		}
+		if err := migrate(raw); err != nil {
+			return nil, fmt.Errorf("failed to migrate profile from schema version %d: %w", version, err)
+		}
+		version++
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile Profile
+	if err := json.Unmarshal(migrated, &profile); err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+	profile.SchemaVersion = ProfileSchemaVersion
+
+	return &profile, nil
+}
+
+// DecodeProfileExport parses a portable profile file
+func DecodeProfileExport(data []byte) (*Profile, error) {
+	var export struct {
+		Format  string          `json:"format"`
+		Profile json.RawMessage `json:"profile"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid profile export: %w", err)
+	}
+	if export.Format != ProfileExportFormat {
+		return nil, fmt.Errorf("unsupported export format %q (expected %q)", export.Format, ProfileExportFormat)
+	}
+	if len(export.Profile) == 0 {
+		return nil, fmt.Errorf("profile export contains no profile")
+	}
+
+	return DecodeProfile(export.Profile)
+}
+
+// profileMigrations upgrade a raw profile from the keyed version to the next one
+var profileMigrations = map[int]func(raw map[string]json.RawMessage) error{
+	// Version 1 profiles held a single "settings" object for one GPU
+	1: func(raw map[string]json.RawMessage) error {
+		if settings, exists := raw["settings"]; exists {
+			if string(settings) != "null" {
+				raw["gpus"] = json.RawMessage("[" + string(settings) + "]")
+			}
+			delete(raw, "settings")
+		}
+		raw["schema_version"] = json.RawMessage("2")
+		return nil
+	},
+}
+
+// validateProfileComponents checks the non-GPU parts of a profile
+func validateProfileComponents(profile *Profile) error {
+	seenGPUs := make(map[int]bool)
+	for _, settings := range profile.GPUs {
+		if settings == nil {
+			return fmt.Errorf("GPU settings cannot be null")
+		}
+		if seenGPUs[settings.DeviceID] {
+			return fmt.Errorf("GPU device %d appears more than once", settings.DeviceID)
+		}
+		seenGPUs[settings.DeviceID] = true
+	}
+
+	if err := ValidateFanSettings(profile.Fans); err != nil {
+		return err
+	}
+
+	if profile.CPU != nil && profile.CPU.MinFrequency > 0 && profile.CPU.MaxFrequency > 0 &&
+		profile.CPU.MinFrequency > profile.CPU.MaxFrequency {
+		return fmt.Errorf("CPU minimum frequency cannot exceed maximum frequency")
+	}
+
+	if len(profile.GPUs) == 0 && len(profile.Fans) == 0 && profile.CPU == nil {
+		return fmt.Errorf("profile must contain GPU, fan or CPU settings")
+	}
+
+	return nil
+}
+
+// ValidateFanSettings checks the fan settings of a profile or rule
+func ValidateFanSettings(fans []*FanSettings) error {
+	seenFans := make(map[int]bool)
+	for _, f := range fans {
+		if f == nil || f.Settings == nil {
+			return fmt.Errorf("fan settings cannot be null")
+		}
+		if seenFans[f.FanID] {
+			return fmt.Errorf("fan %d appears more than once", f.FanID)
+		}
+		seenFans[f.FanID] = true
+
+		switch f.Settings.Mode {
+		case fan.ModeAuto, fan.ModeCurve:
+		case fan.ModeFixed:
+			if f.Settings.FixedSpeed < 0 || f.Settings.FixedSpeed > 100 {
+				return fmt.Errorf("fan %d: fixed speed must be between 0%% and 100%%", f.FanID)
+			}
+		default:
+			return fmt.Errorf("fan %d: unsupported fan mode: %s", f.FanID, f.Settings.Mode)
+		}
+	}
+
+	return nil
+}
+
+// cpuPolicyOrNil avoids storing an empty CPU policy
+func cpuPolicyOrNil(policy *cpu.Policy) *cpu.Policy {
+	if policy == nil || (*policy == cpu.Policy{}) {
+		return nil
+	}
+	return policy
+}
@@ -0,0 +1,397 @@
+package overclock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+)
+
+// TransactionState represents the lifecycle state of an overclock transaction
+type TransactionState string
+
+const (
+	TransactionPending    TransactionState = "pending"
+	TransactionConfirmed  TransactionState = "confirmed"
+	TransactionRolledBack TransactionState = "rolled_back"
+)
+
+const (
+	// MinConfirmTimeout is the shortest confirmation window accepted
+	MinConfirmTimeout = 5 * time.Second
+	// MaxConfirmTimeout is the longest confirmation window accepted
+	MaxConfirmTimeout = 10 * time.Minute
+
+	// maxTransactionHistory is the number of finished transactions kept for inspection
+	maxTransactionHistory = 20
+)
+
+// WatchdogConfig controls the instability checks run while a transaction is pending
+type WatchdogConfig struct {
+	PollInterval time.Duration
+	// TempSpikeDelta is the rise over the pre-apply temperature that triggers a rollback
+	TempSpikeDelta float64
+	// TempCeiling is the absolute GPU temperature that triggers a rollback
+	TempCeiling float64
+}
+
+// DefaultWatchdogConfig returns the default watchdog thresholds
+func DefaultWatchdogConfig() WatchdogConfig {
+	return WatchdogConfig{
+		PollInterval:   2 * time.Second,
+		TempSpikeDelta: 15,
+		TempCeiling:    90,
+	}
+}
+
+// Transaction represents a two-phase overclock change. The previous settings
+// are kept until the client confirms, and restored automatically if the
+// confirmation does not arrive in time or the watchdog detects instability.
+type Transaction struct {
+	ID             string           `json:"id"`
+	State          TransactionState `json:"state"`
+	Source         string           `json:"source"`
+	Previous       *Profile         `json:"previous"`
+	Applied        *Profile         `json:"applied"`
+	Result         *ProfileResult   `json:"result"`
+	CreatedAt      time.Time        `json:"created_at"`
+	ExpiresAt      time.Time        `json:"expires_at"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+	RollbackReason string           `json:"rollback_reason,omitempty"`
+	RollbackResult *ProfileResult   `json:"rollback_result,omitempty"`
+	Warnings       []string         `json:"warnings"`
+
+	timer *time.Timer
+	stop  chan struct{}
+}
+
+// Success returns true if every component applied its settings without errors
+func (t *Transaction) Success() bool {
+	return t.Result != nil && t.Result.Success
+}
+
+// transactionManager tracks pending and recent overclock transactions
+type transactionManager struct {
+	mu       sync.Mutex
+	pending  map[string]*Transaction
+	history  []*Transaction
+	watchdog WatchdogConfig
+}
+
+// newTransactionManager creates a transaction manager with default watchdog settings
+func newTransactionManager() *transactionManager {
+	return &transactionManager{
+		pending:  make(map[string]*Transaction),
+		watchdog: DefaultWatchdogConfig(),
+	}
+}
+
+// newTransactionID returns a random transaction identifier
+func newTransactionID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// snapshot returns a copy of a transaction that is safe to hand out
+func (t *Transaction) snapshot() *Transaction {
+	copied := *t
+	copied.timer = nil
+	copied.stop = nil
+	return &copied
+}
+
+// BeginSettings applies settings as a pending transaction
+func (c *GPUController) BeginSettings(ctx context.Context, settings *Settings, timeout time.Duration) (*Transaction, error) {
+	if err := c.validateSettings(ctx, settings); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSettings, err)
+	}
+
+	profile := &Profile{GPUs: []*Settings{settings}}
+	return c.begin(ctx, "settings", profile, timeout)
+}
+
+// BeginProfile loads a profile and applies it as a pending transaction
+func (c *GPUController) BeginProfile(ctx context.Context, profileName string, timeout time.Duration) (*Transaction, error) {
+	profile, err := c.prepareProfile(ctx, profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.begin(ctx, "profile:"+profile.Name, profile, timeout)
+}
+
+// begin captures the current settings of every component the profile touches,
+// applies the profile and arms the confirmation timer and watchdog
+func (c *GPUController) begin(ctx context.Context, source string, profile *Profile, timeout time.Duration) (*Transaction, error) {
+	if err := c.checkBlocked(); err != nil {
+		return nil, err
+	}
+	if timeout < MinConfirmTimeout || timeout > MaxConfirmTimeout {
+		return nil, fmt.Errorf("confirmation timeout must be between %s and %s", MinConfirmTimeout, MaxConfirmTimeout)
+	}
+
+	tm := c.transactions
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	// Only one pending change per component, otherwise rollbacks would interleave
+	for _, pending := range tm.pending {
+		if conflict := conflictingComponent(pending.Applied, profile); conflict != "" {
+			return nil, fmt.Errorf("%s has a pending transaction %s; confirm or roll it back first", conflict, pending.ID)
+		}
+	}
+
+	// Capture the settings to restore before touching the hardware
+	tx := &Transaction{
+		ID:        newTransactionID(),
+		State:     TransactionPending,
+		Source:    source,
+		Previous:  &Profile{Name: "previous", GPUs: []*Settings{}},
+		Applied:   profile,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(timeout),
+		Warnings:  c.fingerprintWarnings(ctx, profile),
+		stop:      make(chan struct{}),
+	}
+	for _, s := range profile.GPUs {
+		previous, err := c.GetSettings(ctx, s.DeviceID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read current settings of GPU device %d for rollback: %w", s.DeviceID, err)
+		}
+		tx.Previous.GPUs = append(tx.Previous.GPUs, previous)
+	}
+	for _, f := range profile.Fans {
+		previous, err := c.fanController.GetSettings(ctx, f.FanID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read current settings of fan %d for rollback: %w", f.FanID, err)
+		}
+		tx.Previous.Fans = append(tx.Previous.Fans, &FanSettings{FanID: f.FanID, Settings: previous})
+	}
+	if profile.CPU != nil {
+		previous, err := c.cpuPolicy.GetPolicy(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read current CPU policy for rollback: %w", err)
+		}
+		tx.Previous.CPU = previous
+	}
+
+	// Record the pre-apply temperatures for spike detection
+	baseline := make(map[int]float64)
+	if gpus, err := c.gpuReader.GetInfo(ctx); err == nil {
+		for _, s := range profile.GPUs {
+			if s.DeviceID < len(gpus) {
+				baseline[s.DeviceID] = gpus[s.DeviceID].Temperature
+			}
+		}
+	}
+
+	tx.Result = c.applyProfile(ctx, profile)
+	tx.Result.Profile = profile.Name
+
+	tm.pending[tx.ID] = tx
+	tx.timer = time.AfterFunc(timeout, func() {
+		c.rollback(tx.ID, "confirmation timeout expired")
+	})
+	go c.watch(tx, baseline, tm.watchdog)
+
+	return tx.snapshot(), nil
+}
+
+// conflictingComponent returns the first component that both profiles touch
+func conflictingComponent(a, b *Profile) string {
+	for _, x := range a.GPUs {
+		for _, y := range b.GPUs {
+			if x.DeviceID == y.DeviceID {
+				return fmt.Sprintf("GPU device %d", x.DeviceID)
+			}
+		}
+	}
+	for _, x := range a.Fans {
+		for _, y := range b.Fans {
+			if x.FanID == y.FanID {
+				return fmt.Sprintf("fan %d", x.FanID)
+			}
+		}
+	}
+	if a.CPU != nil && b.CPU != nil {
+		return "CPU policy"
+	}
+	return ""
+}
+
+// ConfirmTransaction keeps the settings of a pending transaction
+func (c *GPUController) ConfirmTransaction(ctx context.Context, id string) (*Transaction, error) {
+	tm := c.transactions
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tx, exists := tm.pending[id]
+	if !exists {
+		return nil, c.finishedError(id)
+	}
+
+	tx.timer.Stop()
+	close(tx.stop)
+	tx.State = TransactionConfirmed
+	c.finishLocked(tx)
+
+	return tx.snapshot(), nil
+}
+
+// RollbackTransaction restores the previous settings of a pending transaction
+func (c *GPUController) RollbackTransaction(ctx context.Context, id string) (*Transaction, error) {
+	return c.rollback(id, "rolled back by client")
+}
+
+// GetTransactions returns pending transactions followed by recently finished ones
+func (c *GPUController) GetTransactions(ctx context.Context) ([]*Transaction, error) {
+	tm := c.transactions
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	transactions := []*Transaction{}
+	for _, tx := range tm.pending {
+		transactions = append(transactions, tx.snapshot())
+	}
+	for i := len(tm.history) - 1; i >= 0; i-- {
+		transactions = append(transactions, tm.history[i].snapshot())
+	}
+
+	return transactions, nil
+}
+
+// rollback restores the previous settings of a pending transaction
+func (c *GPUController) rollback(id, reason string) (*Transaction, error) {
+	tm := c.transactions
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tx, exists := tm.pending[id]
+	if !exists {
+		return nil, c.finishedError(id)
+	}
+
+	tx.timer.Stop()
+	close(tx.stop)
+	c.rollbackLocked(tx, reason)
+
+	return tx.snapshot(), nil
+}
+
+// rollbackLocked re-applies the previous settings; the caller holds tm.mu
+func (c *GPUController) rollbackLocked(tx *Transaction, reason string) {
+	slog.Warn("Rolling back overclock transaction", "transaction", tx.ID, "reason", reason)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx.State = TransactionRolledBack
+	tx.RollbackReason = reason
+	tx.RollbackResult = c.applyProfile(ctx, tx.Previous)
+	if !tx.RollbackResult.Success {
+		tx.Warnings = append(tx.Warnings, "some settings could not be restored; see rollback_result")
+	}
+
+	c.finishLocked(tx)
+}
+
+// finishLocked moves a transaction to the history; the caller holds tm.mu
+func (c *GPUController) finishLocked(tx *Transaction) {
+	tm := c.transactions
+	now := time.Now()
+	tx.FinishedAt = &now
+
+	delete(tm.pending, tx.ID)
+	tm.history = append(tm.history, tx)
+	if len(tm.history) > maxTransactionHistory {
+		tm.history = tm.history[len(tm.history)-maxTransactionHistory:]
+	}
+}
+
+// finishedError explains why a transaction can no longer be changed; the caller holds tm.mu
+func (c *GPUController) finishedError(id string) error {
+	for _, tx := range c.transactions.history {
+		if tx.ID == id {
+			return fmt.Errorf("transaction %s is already %s", id, tx.State)
+		}
+	}
+	return fmt.Errorf("transaction %s not found", id)
+}
+
+// watch monitors the GPUs of a pending transaction and rolls it back on
+// driver resets, kernel-reported GPU faults or temperature spikes, using the
+// watchdog thresholds that were in effect when the transaction began
+func (c *GPUController) watch(tx *Transaction, baseline map[int]float64, config WatchdogConfig) {
+	kernelEvents, err := watchKernelLog(tx.stop)
+	if err != nil {
+		c.transactions.mu.Lock()
+		tx.Warnings = append(tx.Warnings, fmt.Sprintf("kernel log monitoring unavailable: %v", err))
+		c.transactions.mu.Unlock()
+	}
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-tx.stop:
+			return
+		case event := <-kernelEvents:
+			c.rollback(tx.ID, fmt.Sprintf("GPU fault in kernel log: %s", event))
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), config.PollInterval*2)
+			gpus, err := c.gpuReader.GetInfo(ctx)
+			cancel()
+
+			if reason := checkInstability(tx, gpus, err, baseline, config, &failures); reason != "" {
+				c.rollback(tx.ID, reason)
+				return
+			}
+		}
+	}
+}
+
+// checkInstability returns a rollback reason if the GPUs of the transaction look unstable
+func checkInstability(tx *Transaction, gpus []*gpu.Info, err error, baseline map[int]float64, config WatchdogConfig, failures *int) string {
+	if len(tx.Applied.GPUs) == 0 {
+		return ""
+	}
+
+	maxID := 0
+	for _, applied := range tx.Applied.GPUs {
+		if applied.DeviceID > maxID {
+			maxID = applied.DeviceID
+		}
+	}
+
+	// A GPU that vanishes usually means the driver reset it; tolerate a single
+	// failed poll since the query tools themselves can fail transiently
+	if err != nil || maxID >= len(gpus) {
+		*failures++
+		if *failures >= 2 {
+			return "GPU stopped responding (driver reset?)"
+		}
+		return ""
+	}
+	*failures = 0
+
+	for _, applied := range tx.Applied.GPUs {
+		temp := gpus[applied.DeviceID].Temperature
+		if temp >= config.TempCeiling {
+			return fmt.Sprintf("GPU device %d reached %.0f°C (ceiling %.0f°C)", applied.DeviceID, temp, config.TempCeiling)
+		}
+		if before, ok := baseline[applied.DeviceID]; ok && before > 0 && temp-before >= config.TempSpikeDelta {
+			return fmt.Sprintf("GPU device %d temperature spiked from %.0f°C to %.0f°C", applied.DeviceID, before, temp)
+		}
+	}
+
+	return ""
+}
@@ -2,6 +2,8 @@ package platform
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 )
 
@@ -31,3 +33,34 @@ func ValidateSupport() error {
 	}
 	return nil
 }
+
+// ConfigDir returns the directory picoHWMon keeps its state in:
+// ~/.config/picohwmon on Linux and %LOCALAPPDATA%\picohwmon on Windows
+func ConfigDir() string {
+	homeDir, _ := os.UserHomeDir()
+	if GetOS() == Windows {
+		return filepath.Join(homeDir, "AppData", "Local", "picohwmon")
+	}
+	return filepath.Join(homeDir, ".config", "picohwmon")
+}
+
+// HostProc returns a path in the host's procfs: /proc, or the mount named by
+// HOST_PROC (e.g. /host/proc) when running in a container. gopsutil reads the
+// same variable.
+func HostProc(elem ...string) string {
+	return hostPath("HOST_PROC", "/proc", elem)
+}
+
+// HostSys returns a path in the host's sysfs: /sys, or the mount named by
+// HOST_SYS (e.g. /host/sys) when running in a container
+func HostSys(elem ...string) string {
+	return hostPath("HOST_SYS", "/sys", elem)
+}
+
+func hostPath(variable, fallback string, elem []string) string {
+	root := os.Getenv(variable)
+	if root == "" {
+		root = fallback
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
@@ -0,0 +1,352 @@
+package power
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+)
+
+const (
+	// DefaultMeterInterval is how often the meter samples power when no interval is configured
+	DefaultMeterInterval = 10 * time.Second
+	// saveInterval is how often the totals are written to the state file
+	saveInterval = time.Minute
+)
+
+// Sources of the system power estimate, from most to least complete
+const (
+	SourcePSU     = "psu"
+	SourcePsys    = "rapl-psys"
+	SourceRAPL    = "rapl"
+	SourceBattery = "battery"
+	SourceGPU     = "gpu"
+)
+
+// MeterConfig holds the meter tunables
+type MeterConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	// PricePerKWh is applied to energy used from now on; past cost is kept
+	PricePerKWh float64
+	Currency    string
+	// StateFile keeps the totals across restarts
+	StateFile string
+}
+
+// Energy is the state of the energy meter
+type Energy struct {
+	// Watts is the latest system power estimate and Source where it came from
+	Enabled     bool       `json:"enabled"`
+	Watts       float64    `json:"watts"`
+	Source      string     `json:"source,omitempty"`
+	TotalKWh    float64    `json:"total_kwh"`
+	Cost        float64    `json:"cost"`
+	Currency    string     `json:"currency,omitempty"`
+	PricePerKWh float64    `json:"price_per_kwh"`
+	Since       time.Time  `json:"since"`
+	LastSample  *time.Time `json:"last_sample,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// meterState is what the state file holds
+type meterState struct {
+	TotalKWh float64   `json:"total_kwh"`
+	Cost     float64   `json:"cost"`
+	Since    time.Time `json:"since"`
+}
+
+// Meter integrates the power drawn by the machine into kWh and cost. The
+// estimate prefers PSU input sensors, then the RAPL platform domain, then
+// RAPL packages and DRAM plus GPU board power, then battery discharge.
+type Meter struct {
+	reader    Reader
+	gpuReader gpu.Reader
+
+	mu         sync.Mutex
+	config     MeterConfig
+	loaded     string
+	state      meterState
+	watts      float64
+	source     string
+	lastSample time.Time
+	lastSaved  time.Time
+	lastError  string
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewMeter creates a meter over the power and GPU readers
+func NewMeter(reader Reader, gpuReader gpu.Reader) *Meter {
+	return &Meter{
+		reader:    reader,
+		gpuReader: gpuReader,
+		config:    MeterConfig{Enabled: true, Interval: DefaultMeterInterval},
+	}
+}
+
+// Configure replaces the meter tunables. A new state file is loaded, after
+// the totals so far were saved to the old one.
+func (m *Meter) Configure(config MeterConfig) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultMeterInterval
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if config.StateFile != m.loaded {
+		if m.loaded != "" {
+			m.save()
+		}
+		m.load(config.StateFile)
+	}
+	m.config = config
+}
+
+// Start begins metering in the background
+func (m *Meter) Start() {
+	m.stop = make(chan struct{})
+	m.stopped = make(chan struct{})
+	go m.run()
+}
+
+// Stop stops metering and saves the totals
+func (m *Meter) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.stopped
+
+	m.mu.Lock()
+	m.save()
+	m.mu.Unlock()
+}
+
+// GetEnergy returns the totals and the latest estimate
+func (m *Meter) GetEnergy() *Energy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	energy := &Energy{
+		Enabled:     m.config.Enabled,
+		Watts:       m.watts,
+		Source:      m.source,
+		TotalKWh:    m.state.TotalKWh,
+		Cost:        m.state.Cost,
+		Currency:    m.config.Currency,
+		PricePerKWh: m.config.PricePerKWh,
+		Since:       m.state.Since,
+		LastError:   m.lastError,
+	}
+	if !m.lastSample.IsZero() {
+		lastSample := m.lastSample
+		energy.LastSample = &lastSample
+	}
+	return energy
+}
+
+// run samples until stopped
+func (m *Meter) run() {
+	defer close(m.stopped)
+
+	for {
+		m.mu.Lock()
+		interval := m.config.Interval
+		enabled := m.config.Enabled
+		if !enabled {
+			// Time while disabled is not billed
+			m.lastSample = time.Time{}
+		}
+		m.mu.Unlock()
+
+		if enabled {
+			m.sample()
+		}
+
+		select {
+		case <-m.stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// sample reads the power draw once and adds the energy since the last sample
+func (m *Meter) sample() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	watts, source, err := m.estimate(ctx)
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.lastError = err.Error()
+		// A gap in the readings is not billed
+		m.lastSample = time.Time{}
+		return
+	}
+	if !m.lastSample.IsZero() {
+		// Average the two readings over the gap, which is capped so that a
+		// suspended machine is not billed for the time it slept
+		elapsed := now.Sub(m.lastSample)
+		if limit := 3 * m.config.Interval; elapsed > limit {
+			elapsed = limit
+		}
+		kWh := (m.watts + watts) / 2 * elapsed.Hours() / 1000
+		m.state.TotalKWh += kWh
+		m.state.Cost += kWh * m.config.PricePerKWh
+	}
+	m.watts, m.source, m.lastSample, m.lastError = watts, source, now, ""
+
+	if now.Sub(m.lastSaved) >= saveInterval {
+		m.save()
+	}
+}
+
+// estimate returns the power drawn by the machine and its source
+func (m *Meter) estimate(ctx context.Context) (float64, string, error) {
+	info, err := m.reader.GetInfo(ctx)
+	if err != nil {
+		info = &Info{}
+	}
+
+	var psu float64
+	var psuFound bool
+	for _, s := range info.Sensors {
+		if s.Input {
+			psu += s.Watts
+			psuFound = true
+		}
+	}
+	if psuFound {
+		return psu, SourcePSU, nil
+	}
+
+	var packages, psys float64
+	var raplFound, psysFound bool
+	for _, d := range info.Domains {
+		switch {
+		case d.Name == "psys":
+			psys += d.Watts
+			psysFound = true
+		case strings.HasPrefix(d.Name, "package") || d.Name == "dram":
+			// core and uncore are part of the package
+			packages += d.Watts
+			raplFound = true
+		}
+	}
+	if psysFound {
+		return psys, SourcePsys, nil
+	}
+
+	var gpuWatts float64
+	if m.gpuReader != nil {
+		if gpus, gpuErr := m.gpuReader.GetInfo(ctx); gpuErr == nil {
+			for _, g := range gpus {
+				gpuWatts += g.PowerUsage
+			}
+		}
+	}
+	if raplFound {
+		return packages + gpuWatts, SourceRAPL, nil
+	}
+
+	if info.OnAC != nil && !*info.OnAC {
+		var battery float64
+		for _, b := range info.Batteries {
+			battery += b.Watts
+		}
+		if battery > 0 {
+			return battery, SourceBattery, nil
+		}
+	}
+	if gpuWatts > 0 {
+		return gpuWatts, SourceGPU, nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return 0, "", errors.New("no power sensors found; RAPL counters are only readable by root")
+}
+
+// load reads the totals from path; a missing file starts from zero. The caller holds m.mu.
+func (m *Meter) load(path string) {
+	m.loaded = path
+	m.state = meterState{Since: time.Now()}
+	m.lastSample = time.Time{}
+	if path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to read energy totals", "error", err)
+		}
+		return
+	}
+	var state meterState
+	if err := json.Unmarshal(data, &state); err != nil {
+		slog.Warn("Failed to read energy totals", "file", path, "error", err)
+		return
+	}
+	if state.Since.IsZero() {
+		state.Since = time.Now()
+	}
+	m.state = state
+}
+
+// save writes the totals to the state file. The caller holds m.mu.
+func (m *Meter) save() {
+	if m.loaded == "" {
+		return
+	}
+	m.lastSaved = time.Now()
+	if err := writeState(m.loaded, m.state); err != nil {
+		slog.Warn("Failed to save energy totals", "error", err)
+	}
+}
+
+// writeState replaces the state file atomically
+func writeState(path string, state meterState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".energy-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package power
+
+import "context"
+
+// Info is the power drawn by the machine, as far as its sensors tell
+type Info struct {
+	// Domains are the RAPL domains: package, core, uncore, dram and psys
+	Domains []*Domain `json:"domains"`
+	// Sensors are hwmon power and energy sensors, PSU sensors among them
+	Sensors   []*Sensor  `json:"sensors"`
+	Batteries []*Battery `json:"batteries"`
+	// OnAC is whether a mains adapter is online; nil on machines without one
+	OnAC *bool `json:"on_ac,omitempty"`
+}
+
+// Domain is a RAPL power domain
+type Domain struct {
+	// Name is e.g. package-0, core, uncore, dram or psys
+	Name string `json:"name"`
+	// Package is the CPU package the domain belongs to
+	Package int     `json:"package"`
+	Watts   float64 `json:"watts"`
+	// EnergyJoules is the raw counter, which wraps at the domain's range
+	EnergyJoules float64 `json:"energy_joules"`
+}
+
+// Sensor is a hwmon power reading
+type Sensor struct {
+	// Name is the hwmon driver, e.g. corsairpsu, pmbus or amdgpu
+	Name  string  `json:"name"`
+	Label string  `json:"label"`
+	Watts float64 `json:"watts"`
+	// PSU marks power supply sensors; Input marks the one measuring the
+	// power drawn from the wall
+	PSU   bool `json:"psu"`
+	Input bool `json:"input,omitempty"`
+}
+
+// Battery is a laptop or UPS battery
+type Battery struct {
+	Name string `json:"name"`
+	// Status is e.g. Charging, Discharging, Full or Not charging
+	Status        string  `json:"status"`
+	ChargePercent float64 `json:"charge_percent"`
+	// Watts is positive while discharging and charging alike; 0 when unknown
+	Watts float64 `json:"watts"`
+	// EnergyWh and FullWh are the current and last full charge
+	EnergyWh float64 `json:"energy_wh,omitempty"`
+	FullWh   float64 `json:"full_wh,omitempty"`
+	// HealthPercent is the last full charge relative to the design capacity
+	HealthPercent float64 `json:"health_percent,omitempty"`
+	Cycles        int     `json:"cycle_count,omitempty"`
+}
+
+// Reader interface for power monitoring. RAPL and hwmon energy counters
+// become watts as deltas between consecutive reads.
+type Reader interface {
+	GetInfo(ctx context.Context) (*Info, error)
+}
+
+// NewReader creates a new power reader for the current platform
+func NewReader() Reader {
+	return newPlatformReader()
+}
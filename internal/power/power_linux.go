@@ -0,0 +1,284 @@
+//go:build linux
+
+package power
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+const (
+	// minInterval is the shortest window energy counters are turned into
+	// watts over; reads closer together return the previous result
+	minInterval = time.Second
+	// firstWindow is how long the first read waits for a second sample
+	firstWindow = 250 * time.Millisecond
+)
+
+// Sysfs locations, variables so they can point at a copy of sysfs
+var (
+	powercapDir    = platform.HostSys("class", "powercap")
+	hwmonDir       = platform.HostSys("class", "hwmon")
+	powerSupplyDir = platform.HostSys("class", "power_supply")
+)
+
+// raplZone matches RAPL zones and subzones and captures the package, e.g.
+// intel-rapl:0 and intel-rapl:0:1. intel-rapl-mmio duplicates the package
+// domain and is left out.
+var raplZone = regexp.MustCompile(`^intel-rapl:(\d+)(:\d+)?$`)
+
+// psuDrivers are hwmon drivers of power supplies
+var psuDrivers = map[string]bool{
+	"pmbus": true, "corsairpsu": true, "corsair-psu": true, "dps920ab": true,
+	"fsp3y": true, "ibm-cffps": true, "inspur-ipsps1": true, "bel-pfe": true,
+}
+
+// counter is the previous value of a cumulative energy counter
+type counter struct {
+	microjoules uint64
+	at          time.Time
+}
+
+// LinuxReader implements power monitoring for Linux from powercap, hwmon
+// and power_supply
+type LinuxReader struct {
+	mu       sync.Mutex
+	counters map[string]counter
+	taken    time.Time
+	latest   *Info
+}
+
+// newPlatformReader creates a new Linux power reader
+func newPlatformReader() Reader {
+	return &LinuxReader{counters: make(map[string]counter)}
+}
+
+// GetInfo returns the power readings. The first call waits briefly so that
+// energy counters can be turned into watts.
+func (r *LinuxReader) GetInfo(ctx context.Context) (*Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.latest != nil && time.Since(r.taken) < minInterval {
+		return r.latest, nil
+	}
+	if r.taken.IsZero() {
+		r.readDomains(time.Now())
+		r.readSensors(time.Now())
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(firstWindow):
+		}
+	}
+
+	now := time.Now()
+	info := &Info{
+		Domains: r.readDomains(now),
+		Sensors: r.readSensors(now),
+	}
+	info.Batteries, info.OnAC = readPowerSupplies()
+
+	r.taken, r.latest = now, info
+	return info, nil
+}
+
+// watts turns a cumulative counter into watts since its previous value.
+// Counters with a range wrap to zero after reaching it; 0 means no wrap.
+func (r *LinuxReader) watts(key string, microjoules, wrapRange uint64, now time.Time) (float64, bool) {
+	previous, ok := r.counters[key]
+	r.counters[key] = counter{microjoules: microjoules, at: now}
+	if !ok {
+		return 0, false
+	}
+	seconds := now.Sub(previous.at).Seconds()
+	if seconds <= 0 {
+		return 0, false
+	}
+
+	delta := microjoules - previous.microjoules
+	if microjoules < previous.microjoules {
+		if wrapRange == 0 {
+			return 0, false // reset
+		}
+		delta = wrapRange - previous.microjoules + microjoules
+	}
+	return float64(delta) / 1e6 / seconds, true
+}
+
+// readDomains reads the RAPL energy counters. They are only readable by
+// root on kernels since 5.10.
+func (r *LinuxReader) readDomains(now time.Time) []*Domain {
+	entries, _ := os.ReadDir(powercapDir)
+	domains := []*Domain{}
+	for _, entry := range entries {
+		match := raplZone.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		dir := filepath.Join(powercapDir, entry.Name())
+		energy, err := strconv.ParseUint(readSysfs(dir, "energy_uj"), 10, 64)
+		if err != nil {
+			continue
+		}
+		wrapRange, _ := strconv.ParseUint(readSysfs(dir, "max_energy_range_uj"), 10, 64)
+
+		domain := &Domain{Name: readSysfs(dir, "name"), EnergyJoules: float64(energy) / 1e6}
+		domain.Package, _ = strconv.Atoi(match[1])
+		domain.Watts, _ = r.watts(dir, energy, wrapRange, now)
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].Package != domains[j].Package {
+			return domains[i].Package < domains[j].Package
+		}
+		return domains[i].Name < domains[j].Name
+	})
+	return domains
+}
+
+// readSensors reads hwmon power*_input and power*_average in microwatts and
+// turns energy*_input counters into watts
+func (r *LinuxReader) readSensors(now time.Time) []*Sensor {
+	dirs, _ := filepath.Glob(filepath.Join(hwmonDir, "hwmon*"))
+	sensors := []*Sensor{}
+	for _, dir := range dirs {
+		name := readSysfs(dir, "name")
+		psu := psuDrivers[name]
+
+		power, _ := filepath.Glob(filepath.Join(dir, "power*_input"))
+		average, _ := filepath.Glob(filepath.Join(dir, "power*_average"))
+		seen := make(map[string]bool)
+		for _, path := range append(power, average...) {
+			file := filepath.Base(path)
+			channel := file[:strings.Index(file, "_")]
+			if seen[channel] {
+				continue // power1_input and power1_average measure the same thing
+			}
+			microwatts, err := strconv.ParseUint(readSysfs(dir, file), 10, 64)
+			if err != nil {
+				continue
+			}
+			seen[channel] = true
+			sensors = append(sensors, newSensor(name, dir, channel, float64(microwatts)/1e6, psu))
+		}
+
+		energy, _ := filepath.Glob(filepath.Join(dir, "energy*_input"))
+		for _, path := range energy {
+			file := filepath.Base(path)
+			channel := file[:strings.Index(file, "_")]
+			microjoules, err := strconv.ParseUint(readSysfs(dir, file), 10, 64)
+			if err != nil {
+				continue
+			}
+			watts, _ := r.watts(path, microjoules, 0, now)
+			sensors = append(sensors, newSensor(name, dir, channel, watts, psu))
+		}
+	}
+	return sensors
+}
+
+// newSensor labels a hwmon power reading and recognises PSU input power
+func newSensor(name, dir, channel string, watts float64, psu bool) *Sensor {
+	label := readSysfs(dir, channel+"_label")
+	if label == "" {
+		label = channel
+	}
+	lower := strings.ToLower(label)
+	return &Sensor{
+		Name:  name,
+		Label: label,
+		Watts: watts,
+		PSU:   psu,
+		// PMBus calls it pin, corsairpsu "power total"
+		Input: psu && (strings.HasPrefix(lower, "pin") || lower == "power total"),
+	}
+}
+
+// readPowerSupplies reads batteries and whether a mains adapter is online.
+// Batteries of peripherals such as mice are left out.
+func readPowerSupplies() ([]*Battery, *bool) {
+	entries, _ := os.ReadDir(powerSupplyDir)
+	batteries := []*Battery{}
+	var onAC *bool
+	for _, entry := range entries {
+		dir := filepath.Join(powerSupplyDir, entry.Name())
+		switch readSysfs(dir, "type") {
+		case "Mains":
+			online := readSysfs(dir, "online") == "1"
+			if onAC == nil || online {
+				onAC = &online
+			}
+		case "Battery":
+			if readSysfs(dir, "scope") == "Device" {
+				continue
+			}
+			batteries = append(batteries, readBattery(entry.Name(), dir))
+		}
+	}
+	return batteries, onAC
+}
+
+// readBattery reads one battery. Drivers report energy in µWh, or charge in
+// µAh that is converted with the voltage.
+func readBattery(name, dir string) *Battery {
+	value := func(attribute string) float64 {
+		v, _ := strconv.ParseFloat(readSysfs(dir, attribute), 64)
+		return v
+	}
+	battery := &Battery{
+		Name:          name,
+		Status:        readSysfs(dir, "status"),
+		ChargePercent: value("capacity"),
+		Cycles:        int(value("cycle_count")),
+	}
+
+	voltage := value("voltage_now") / 1e6
+	design := value("energy_full_design") / 1e6
+	if battery.FullWh = value("energy_full") / 1e6; battery.FullWh > 0 {
+		battery.EnergyWh = value("energy_now") / 1e6
+	} else if nominal := value("voltage_min_design") / 1e6; nominal > 0 || voltage > 0 {
+		if nominal == 0 {
+			nominal = voltage
+		}
+		battery.FullWh = value("charge_full") / 1e6 * nominal
+		battery.EnergyWh = value("charge_now") / 1e6 * nominal
+		design = value("charge_full_design") / 1e6 * nominal
+	}
+	if design > 0 && battery.FullWh > 0 {
+		battery.HealthPercent = battery.FullWh / design * 100
+	}
+
+	if microwatts := value("power_now"); microwatts != 0 {
+		battery.Watts = abs(microwatts) / 1e6
+	} else {
+		battery.Watts = abs(value("current_now")) / 1e6 * voltage
+	}
+	return battery
+}
+
+// abs returns the absolute value; some drivers report discharge as negative
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// readSysfs returns the trimmed contents of a sysfs attribute, or ""
+func readSysfs(dir, attribute string) string {
+	data, err := os.ReadFile(filepath.Join(dir, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,62 @@
+//go:build windows
+
+package power
+
+import (
+	"context"
+
+	"github.com/StackExchange/wmi"
+)
+
+// Win32_Battery is a battery as reported by WMI
+type Win32_Battery struct {
+	Name                     string
+	BatteryStatus            uint16
+	EstimatedChargeRemaining uint16
+}
+
+// Win32_Battery.BatteryStatus values
+const (
+	batteryDischarging = 1
+	batteryOnAC        = 2
+	batteryFull        = 3
+	batteryCharging    = 6
+)
+
+// WindowsReader implements power monitoring for Windows. Windows exposes
+// no RAPL or hwmon power sensors, so only batteries are reported.
+type WindowsReader struct{}
+
+// newPlatformReader creates a new Windows power reader
+func newPlatformReader() Reader {
+	return &WindowsReader{}
+}
+
+// GetInfo returns the power readings
+func (r *WindowsReader) GetInfo(ctx context.Context) (*Info, error) {
+	var batteries []Win32_Battery
+	if err := wmi.Query("SELECT Name, BatteryStatus, EstimatedChargeRemaining FROM Win32_Battery", &batteries); err != nil {
+		return nil, err
+	}
+
+	info := &Info{Domains: []*Domain{}, Sensors: []*Sensor{}, Batteries: []*Battery{}}
+	for _, b := range batteries {
+		battery := &Battery{Name: b.Name, ChargePercent: float64(b.EstimatedChargeRemaining)}
+		switch b.BatteryStatus {
+		case batteryDischarging:
+			battery.Status = "Discharging"
+		case batteryFull:
+			battery.Status = "Full"
+		case batteryOnAC:
+			battery.Status = "Not charging"
+		case batteryCharging:
+			battery.Status = "Charging"
+		default:
+			battery.Status = "Unknown"
+		}
+		onAC := b.BatteryStatus != batteryDischarging
+		info.OnAC = &onAC
+		info.Batteries = append(info.Batteries, battery)
+	}
+	return info, nil
+}
@@ -0,0 +1,261 @@
+package process
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minInterval is the shortest window rates are computed over; reads
+	// closer together return the previous result
+	minInterval = time.Second
+	// firstWindow is how long the first read waits for a second sample
+	firstWindow = 250 * time.Millisecond
+)
+
+// Sort orders
+const (
+	SortCPU       = "cpu"
+	SortMemory    = "memory"
+	SortIO        = "io"
+	SortGPUMemory = "gpu_memory"
+	SortGPU       = "gpu"
+)
+
+// Process is a running process with its resource use over the last sampling window
+type Process struct {
+	PID     int    `json:"pid"`
+	PPID    int    `json:"ppid"`
+	Name    string `json:"name"`
+	Command string `json:"command,omitempty"`
+	User    string `json:"user"`
+	State   string `json:"state,omitempty"`
+	Threads int    `json:"threads"`
+	// CPUPercent is relative to one core, so a busy process on 8 cores reads 800
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryBytes   uint64  `json:"memory_bytes"`
+	MemoryPercent float64 `json:"memory_percent"`
+	// Disk I/O needs root for processes of other users
+	ReadBytesPerSec  float64 `json:"read_bytes_per_second"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_second"`
+	GPUMemoryBytes   uint64  `json:"gpu_memory_bytes,omitempty"`
+	// GPUPercent is the busiest GPU engine's utilization by the process;
+	// nil when the driver doesn't account it (NVIDIA)
+	GPUPercent  *float64 `json:"gpu_percent,omitempty"`
+	Cgroup      string   `json:"cgroup,omitempty"`
+	ContainerID string   `json:"container_id,omitempty"`
+
+	counters counters
+}
+
+// counters are the cumulative values rates are computed from
+type counters struct {
+	// started tells a process from a later one that reuses its PID
+	started    uint64
+	cpuSeconds float64
+	readBytes  uint64
+	writeBytes uint64
+	// engines holds the busy time of GPU engines, e.g. gfx or render
+	engines map[string]engine
+}
+
+// engine is the cumulative busy time of a GPU engine class
+type engine struct {
+	busyNanoseconds uint64
+	// capacity is the number of engines of the class
+	capacity int
+}
+
+// List is the result of a query
+type List struct {
+	Processes []*Process `json:"processes"`
+	// Total is the number of processes that matched before the limit
+	Total int `json:"total"`
+	// Window is the length of the sampling window in seconds
+	Window float64 `json:"window_seconds"`
+}
+
+// Query selects and orders processes. Empty fields match everything.
+type Query struct {
+	// Sort is cpu, memory, io, gpu_memory or gpu; descending
+	Sort string
+	// Limit caps the number of processes returned; 0 returns all
+	Limit int
+	User  string
+	// Name is a shell glob matched against the process name
+	Name string
+	// Container is a container ID prefix; "*" matches any container
+	Container string
+}
+
+// ValidSort reports whether s is a sort order
+func ValidSort(s string) bool {
+	switch s {
+	case SortCPU, SortMemory, SortIO, SortGPUMemory, SortGPU:
+		return true
+	}
+	return false
+}
+
+// matches reports whether a process passes the filters of the query
+func (q Query) matches(p *Process) bool {
+	if q.User != "" && p.User != q.User {
+		return false
+	}
+	if q.Name != "" {
+		if ok, _ := filepath.Match(q.Name, p.Name); !ok {
+			return false
+		}
+	}
+	switch {
+	case q.Container == "":
+	case q.Container == "*":
+		return p.ContainerID != ""
+	default:
+		return p.ContainerID != "" && strings.HasPrefix(p.ContainerID, q.Container)
+	}
+	return true
+}
+
+// key returns the value a process is sorted by
+func key(p *Process, order string) float64 {
+	switch order {
+	case SortMemory:
+		return float64(p.MemoryBytes)
+	case SortIO:
+		return p.ReadBytesPerSec + p.WriteBytesPerSec
+	case SortGPUMemory:
+		return float64(p.GPUMemoryBytes)
+	case SortGPU:
+		if p.GPUPercent == nil {
+			return -1
+		}
+		return *p.GPUPercent
+	default:
+		return p.CPUPercent
+	}
+}
+
+// Select filters, sorts and limits processes and returns them with the
+// number that matched before the limit. Ties are broken by PID.
+func Select(processes []*Process, query Query) ([]*Process, int) {
+	selected := []*Process{}
+	for _, p := range processes {
+		if query.matches(p) {
+			selected = append(selected, p)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		a, b := key(selected[i], query.Sort), key(selected[j], query.Sort)
+		if a != b {
+			return a > b
+		}
+		return selected[i].PID < selected[j].PID
+	})
+	total := len(selected)
+	if query.Limit > 0 && total > query.Limit {
+		selected = selected[:query.Limit]
+	}
+	return selected, total
+}
+
+// Reader interface for process monitoring. Rates are deltas between
+// consecutive reads, so the metrics sampler sets the window.
+type Reader interface {
+	// GetProcesses returns all processes and the window their rates cover
+	GetProcesses(ctx context.Context) ([]*Process, float64, error)
+}
+
+// NewReader creates a new process reader for the current platform
+func NewReader() Reader {
+	return newPlatformReader()
+}
+
+// tracker turns the cumulative counters of a platform read into rates
+type tracker struct {
+	read func(ctx context.Context) ([]*Process, error)
+
+	mu       sync.Mutex
+	previous map[int]counters
+	taken    time.Time
+	window   float64
+	latest   []*Process
+}
+
+// GetProcesses returns the processes with their rates since the previous
+// call. The first call waits briefly for a second sample.
+func (t *tracker) GetProcesses(ctx context.Context) ([]*Process, float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.previous == nil {
+		processes, err := t.read(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.previous, t.taken = byPID(processes), time.Now()
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(firstWindow):
+		}
+	} else if t.latest != nil && time.Since(t.taken) < minInterval {
+		return t.latest, t.window, nil
+	}
+
+	processes, err := t.read(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	seconds := now.Sub(t.taken).Seconds()
+
+	rate := func(before, after uint64) float64 {
+		if after < before {
+			return 0
+		}
+		return float64(after-before) / seconds
+	}
+	for _, p := range processes {
+		previous, ok := t.previous[p.PID]
+		started := !ok || previous.started != p.counters.started
+		if started {
+			// Started during the window: its whole lifetime counts
+			previous = counters{started: p.counters.started}
+		}
+		c := p.counters
+		if c.cpuSeconds > previous.cpuSeconds {
+			p.CPUPercent = (c.cpuSeconds - previous.cpuSeconds) / seconds * 100
+		}
+		p.ReadBytesPerSec = rate(previous.readBytes, c.readBytes)
+		p.WriteBytesPerSec = rate(previous.writeBytes, c.writeBytes)
+		for name, e := range c.engines {
+			before, known := previous.engines[name]
+			if !known && !started {
+				// A GPU client found by a later scan has no baseline yet
+				continue
+			}
+			busy := rate(before.busyNanoseconds, e.busyNanoseconds) / 1e9 / float64(max(e.capacity, 1)) * 100
+			busy = min(busy, 100)
+			if p.GPUPercent == nil || busy > *p.GPUPercent {
+				p.GPUPercent = &busy
+			}
+		}
+	}
+
+	t.previous, t.taken, t.window, t.latest = byPID(processes), now, seconds, processes
+	return processes, seconds, nil
+}
+
+// byPID indexes the counters of processes by PID
+func byPID(processes []*Process) map[int]counters {
+	indexed := make(map[int]counters, len(processes))
+	for _, p := range processes {
+		indexed[p.PID] = p.counters
+	}
+	return indexed
+}
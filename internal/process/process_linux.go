@@ -0,0 +1,385 @@
+//go:build linux
+
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc/<pid>/stat; it is
+// 100 on every architecture Linux supports
+const clockTicks = 100
+
+// procDir is where the kernel lists processes, a variable so it can point
+// at a copy of procfs
+var procDir = platform.HostProc()
+
+// gpuRescan is how often nvidia-smi is queried and every process is checked
+// for DRM clients; in between, only the clients already found are read
+const gpuRescan = 30 * time.Second
+
+// containerID matches the 64 hex digit container IDs that Docker,
+// containerd, CRI-O and Podman put in cgroup paths
+var containerID = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// LinuxReader implements process monitoring for Linux from /proc, with GPU
+// use from nvidia-smi and DRM fdinfo
+type LinuxReader struct {
+	tracker
+
+	usersMu sync.Mutex
+	users   map[uint32]string
+
+	// GPU clients from the last rescan, guarded by tracker.mu
+	scanned time.Time
+	drmFDs  map[int][]string
+	nvidia  map[int]uint64
+}
+
+// newPlatformReader creates a new Linux process reader
+func newPlatformReader() Reader {
+	r := &LinuxReader{users: make(map[uint32]string)}
+	r.read = r.readProcesses
+	return r
+}
+
+// readProcesses reads every process with its cumulative counters
+func (r *LinuxReader) readProcesses(ctx context.Context) ([]*Process, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	memTotal := readMemTotal()
+	pageSize := uint64(os.Getpagesize())
+	_, err = os.Stat("/dev/dri")
+	hasDRM := err == nil
+	rescan := time.Since(r.scanned) >= gpuRescan
+	if rescan {
+		r.scanned = time.Now()
+		r.drmFDs = make(map[int][]string)
+		r.nvidia = nvidiaMemory(ctx)
+	}
+
+	processes := []*Process{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(procDir, entry.Name())
+		p := r.readProcess(pid, dir, pageSize)
+		if p == nil {
+			continue // exited while being read
+		}
+		if memTotal > 0 {
+			p.MemoryPercent = float64(p.MemoryBytes) / float64(memTotal) * 100
+		}
+		if hasDRM && rescan {
+			if fds := findDRMDescriptors(dir); len(fds) > 0 {
+				r.drmFDs[pid] = fds
+			}
+		}
+		if fds, ok := r.drmFDs[pid]; ok {
+			p.GPUMemoryBytes, p.counters.engines = readDRMClients(dir, fds)
+		}
+		p.GPUMemoryBytes += r.nvidia[pid]
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+// readProcess reads the stat, cmdline, io and cgroup files of a process
+func (r *LinuxReader) readProcess(pid int, dir string, pageSize uint64) *Process {
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil
+	}
+	// The name is in parentheses and may itself contain spaces and parentheses
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 22 {
+		return nil
+	}
+	// fields[0] is field 3 of proc(5)
+	field := func(n int) uint64 {
+		v, _ := strconv.ParseUint(fields[n-3], 10, 64)
+		return v
+	}
+
+	p := &Process{
+		PID:         pid,
+		PPID:        int(field(4)),
+		Name:        string(stat[open+1 : end]),
+		State:       fields[0],
+		Threads:     int(field(20)),
+		MemoryBytes: field(24) * pageSize,
+		counters: counters{
+			started:    field(22),
+			cpuSeconds: float64(field(14)+field(15)) / clockTicks,
+		},
+	}
+
+	if cmdline, _ := os.ReadFile(filepath.Join(dir, "cmdline")); len(cmdline) > 0 {
+		p.Command = strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+	} else {
+		p.Command = "[" + p.Name + "]" // kernel thread
+	}
+	if info, err := os.Stat(dir); err == nil {
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			p.User = r.username(st.Uid)
+		}
+	}
+	p.counters.readBytes, p.counters.writeBytes = readIO(dir)
+	p.Cgroup = readCgroup(dir)
+	p.ContainerID = containerID.FindString(p.Cgroup)
+	return p
+}
+
+// username resolves a UID, remembering the answer
+func (r *LinuxReader) username(uid uint32) string {
+	r.usersMu.Lock()
+	defer r.usersMu.Unlock()
+
+	name, ok := r.users[uid]
+	if !ok {
+		name = strconv.FormatUint(uint64(uid), 10)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		r.users[uid] = name
+	}
+	return name
+}
+
+// readIO returns the bytes a process read from and wrote to storage; the
+// file is only readable for own processes unless running as root
+func readIO(dir string) (read, written uint64) {
+	f, err := os.Open(filepath.Join(dir, "io"))
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch name {
+		case "read_bytes":
+			read, _ = strconv.ParseUint(value, 10, 64)
+		case "write_bytes":
+			written, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return read, written
+}
+
+// readCgroup returns the cgroup v2 path of a process, or on cgroup v1 the
+// first hierarchy that places it below the root
+func readCgroup(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup"))
+	if err != nil {
+		return ""
+	}
+	var v1 string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || parts[2] == "/" {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if v1 == "" {
+			v1 = parts[2]
+		}
+	}
+	return v1
+}
+
+// readMemTotal returns the physical memory in bytes
+func readMemTotal() uint64 {
+	data, err := os.ReadFile(filepath.Join(procDir, "meminfo"))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "MemTotal:"); ok {
+			kb, _ := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// nvidiaMemory returns the GPU memory used per PID by compute applications,
+// summed over all NVIDIA GPUs. The driver does not account utilization per
+// process without accounting mode.
+func nvidiaMemory(ctx context.Context) map[int]uint64 {
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return nil
+	}
+	output, err := exec.CommandContext(ctx, "nvidia-smi", "--query-compute-apps=pid,used_memory", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil
+	}
+	memory := make(map[int]uint64)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		pid, mib, ok := strings.Cut(line, ",")
+		if !ok {
+			continue
+		}
+		id, err1 := strconv.Atoi(strings.TrimSpace(pid))
+		used, err2 := strconv.ParseUint(strings.TrimSpace(mib), 10, 64)
+		if err1 == nil && err2 == nil {
+			memory[id] += used << 20
+		}
+	}
+	return memory
+}
+
+// findDRMDescriptors returns the file descriptors a process has open on
+// /dev/dri
+func findDRMDescriptors(dir string) []string {
+	fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+	if err != nil {
+		return nil
+	}
+	var found []string
+	for _, fd := range fds {
+		if target, err := os.Readlink(filepath.Join(dir, "fd", fd.Name())); err == nil && strings.HasPrefix(target, "/dev/dri/") {
+			found = append(found, fd.Name())
+		}
+	}
+	return found
+}
+
+// readDRMClients sums the GPU memory and engine busy time of the DRM
+// clients a process has open, from the fdinfo of its /dev/dri descriptors
+// fds (amdgpu, i915, xe, msm and others). A client opened through several
+// descriptors is counted once.
+func readDRMClients(dir string, fds []string) (uint64, map[string]engine) {
+	var memory uint64
+	var engines map[string]engine
+	seen := make(map[string]bool)
+	for _, fd := range fds {
+		// The descriptor may have been closed or reused since the scan
+		target, err := os.Readlink(filepath.Join(dir, "fd", fd))
+		if err != nil || !strings.HasPrefix(target, "/dev/dri/") {
+			continue
+		}
+		info, err := os.ReadFile(filepath.Join(dir, "fdinfo", fd))
+		if err != nil {
+			continue
+		}
+		client := parseFdinfo(info)
+		if client.id == "" || seen[client.id] {
+			continue
+		}
+		seen[client.id] = true
+
+		memory += client.memory
+		for name, e := range client.engines {
+			if engines == nil {
+				engines = make(map[string]engine)
+			}
+			sum := engines[name]
+			sum.busyNanoseconds += e.busyNanoseconds
+			sum.capacity = max(sum.capacity, e.capacity)
+			engines[name] = sum
+		}
+	}
+	return memory, engines
+}
+
+// drmClient is what the fdinfo of a DRM file descriptor reports
+type drmClient struct {
+	// id is the device and client ID
+	id      string
+	memory  uint64
+	engines map[string]engine
+}
+
+// parseFdinfo parses the drm-* keys of an fdinfo file, see
+// Documentation/gpu/drm-usage-stats.rst. Resident memory is preferred over
+// the older drm-memory-<region> keys when the driver reports both.
+func parseFdinfo(info []byte) drmClient {
+	var client drmClient
+	var device string
+	var resident, legacy uint64
+	capacity := make(map[string]int)
+	for _, line := range strings.Split(string(info), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.HasPrefix(key, "drm-") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "drm-pdev":
+			device = value
+		case key == "drm-client-id":
+			client.id = value
+		case strings.HasPrefix(key, "drm-engine-capacity-"):
+			capacity[strings.TrimPrefix(key, "drm-engine-capacity-")], _ = strconv.Atoi(value)
+		case strings.HasPrefix(key, "drm-engine-"):
+			ns, err := strconv.ParseUint(strings.TrimSuffix(value, " ns"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if client.engines == nil {
+				client.engines = make(map[string]engine)
+			}
+			client.engines[strings.TrimPrefix(key, "drm-engine-")] = engine{busyNanoseconds: ns}
+		case strings.HasPrefix(key, "drm-resident-"):
+			resident += parseMemory(value)
+		case strings.HasPrefix(key, "drm-memory-"):
+			legacy += parseMemory(value)
+		}
+	}
+	for name, e := range client.engines {
+		e.capacity = capacity[name]
+		client.engines[name] = e
+	}
+	client.memory = resident
+	if resident == 0 {
+		client.memory = legacy
+	}
+	if client.id != "" {
+		client.id = device + "/" + client.id
+	}
+	return client
+}
+
+// parseMemory parses a memory size such as "1024 KiB"
+func parseMemory(value string) uint64 {
+	number, unit, _ := strings.Cut(value, " ")
+	v, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return 0
+	}
+	switch unit {
+	case "KiB":
+		return v << 10
+	case "MiB":
+		return v << 20
+	case "GiB":
+		return v << 30
+	}
+	return v
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package process
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnsupportedReader is a fallback for unsupported platforms
+type UnsupportedReader struct{}
+
+// newPlatformReader creates a fallback process reader for unsupported platforms
+func newPlatformReader() Reader {
+	return &UnsupportedReader{}
+}
+
+// GetProcesses returns an error for unsupported platforms
+func (r *UnsupportedReader) GetProcesses(ctx context.Context) ([]*Process, float64, error) {
+	return nil, 0, fmt.Errorf("process monitoring not supported on this platform")
+}
@@ -0,0 +1,100 @@
+//go:build windows
+
+package process
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// WindowsReader implements process monitoring for Windows with gopsutil.
+// Per-process GPU use is not reported.
+type WindowsReader struct {
+	tracker
+
+	// users remembers the owner of a process; looking it up is slow
+	usersMu sync.Mutex
+	users   map[owner]string
+}
+
+// owner identifies a process across PID reuse
+type owner struct {
+	pid     int32
+	created int64
+}
+
+// newPlatformReader creates a new Windows process reader
+func newPlatformReader() Reader {
+	r := &WindowsReader{users: make(map[owner]string)}
+	r.read = r.readProcesses
+	return r
+}
+
+// readProcesses reads every process with its cumulative counters
+func (r *WindowsReader) readProcesses(ctx context.Context) ([]*Process, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var memTotal uint64
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		memTotal = vm.Total
+	}
+
+	processes := []*Process{}
+	users := make(map[owner]string, len(procs))
+	for _, proc := range procs {
+		name, err := proc.NameWithContext(ctx)
+		if err != nil {
+			continue // exited or inaccessible
+		}
+		created, _ := proc.CreateTimeWithContext(ctx)
+		p := &Process{
+			PID:  int(proc.Pid),
+			Name: name,
+			counters: counters{
+				started: uint64(created),
+			},
+		}
+		if ppid, err := proc.PpidWithContext(ctx); err == nil {
+			p.PPID = int(ppid)
+		}
+		if threads, err := proc.NumThreadsWithContext(ctx); err == nil {
+			p.Threads = int(threads)
+		}
+		if p.Command, _ = proc.CmdlineWithContext(ctx); p.Command == "" {
+			p.Command = name
+		}
+		if times, err := proc.TimesWithContext(ctx); err == nil {
+			p.counters.cpuSeconds = times.User + times.System
+		}
+		if memory, err := proc.MemoryInfoWithContext(ctx); err == nil {
+			p.MemoryBytes = memory.RSS
+			if memTotal > 0 {
+				p.MemoryPercent = float64(memory.RSS) / float64(memTotal) * 100
+			}
+		}
+		if io, err := proc.IOCountersWithContext(ctx); err == nil {
+			p.counters.readBytes, p.counters.writeBytes = io.ReadBytes, io.WriteBytes
+		}
+
+		key := owner{pid: proc.Pid, created: created}
+		r.usersMu.Lock()
+		user, ok := r.users[key]
+		r.usersMu.Unlock()
+		if !ok {
+			user, _ = proc.UsernameWithContext(ctx)
+		}
+		p.User, users[key] = user, user
+		processes = append(processes, p)
+	}
+
+	// Forget the owners of processes that exited
+	r.usersMu.Lock()
+	r.users = users
+	r.usersMu.Unlock()
+	return processes, nil
+}
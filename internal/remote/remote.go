@@ -0,0 +1,326 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/config"
+	"github.com/CristiGvl/picoHWMon/internal/temps"
+)
+
+// Defaults for zero values in a source configuration
+const (
+	DefaultInterval   = 10 * time.Second
+	DefaultTimeout    = 5 * time.Second
+	DefaultSensorsKey = "sensors"
+	DefaultNameKey    = "name"
+	DefaultValueKey   = "temperature_celsius"
+
+	// maxBody bounds the JSON documents read from sources
+	maxBody = 256 * 1024
+)
+
+// ErrSourceNotFound is returned for pushes to an unknown source
+var ErrSourceNotFound = errors.New("external sensor source not found")
+
+// SourceStatus describes an external source and its latest readings
+type SourceStatus struct {
+	Name       string          `json:"name"`
+	URL        string          `json:"url,omitempty"`
+	Interval   string          `json:"interval,omitempty"`
+	StaleAfter string          `json:"stale_after"`
+	LastUpdate *time.Time      `json:"last_update,omitempty"`
+	Stale      bool            `json:"stale"`
+	LastError  string          `json:"last_error,omitempty"`
+	Sensors    []*temps.Sensor `json:"sensors"`
+}
+
+// source is one configured device
+type source struct {
+	config     config.ExternalSource
+	interval   time.Duration
+	staleAfter time.Duration
+	sensors    []*temps.Sensor
+	updated    time.Time
+	lastError  string
+}
+
+// stale reports whether the readings are too old to use
+func (s *source) stale(now time.Time) bool {
+	return s.updated.IsZero() || now.Sub(s.updated) > s.staleAfter
+}
+
+// Collector polls external temperature sources over HTTP and accepts pushed
+// readings. Fresh readings appear in temps.Info under External.
+type Collector struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	sources map[string]*source
+	order   []string
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewCollector creates a collector without sources
+func NewCollector() *Collector {
+	return &Collector{
+		client:  &http.Client{},
+		sources: make(map[string]*source),
+	}
+}
+
+// Configure replaces the sources and restarts polling. Readings of sources
+// that keep their name are kept.
+func (c *Collector) Configure(sources []config.ExternalSource) {
+	c.Stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.sources
+	c.sources = make(map[string]*source, len(sources))
+	c.order = nil
+	for _, sc := range sources {
+		s := &source{config: sc, interval: sc.Interval.Std(), staleAfter: sc.StaleAfter.Std()}
+		if s.interval <= 0 {
+			s.interval = DefaultInterval
+		}
+		if s.staleAfter <= 0 {
+			s.staleAfter = 3 * s.interval
+		}
+		if old, ok := previous[sc.Name]; ok {
+			s.sensors, s.updated, s.lastError = old.sensors, old.updated, old.lastError
+		}
+		c.sources[sc.Name] = s
+		c.order = append(c.order, sc.Name)
+	}
+
+	c.stop = make(chan struct{})
+	for _, s := range c.sources {
+		if s.config.URL != "" {
+			c.wg.Add(1)
+			go c.poll(s, c.stop)
+		}
+	}
+}
+
+// Stop stops polling
+func (c *Collector) Stop() {
+	c.mu.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		c.wg.Wait()
+	}
+}
+
+// Push stores readings sent by a source, in the same JSON format that would be polled
+func (c *Collector) Push(name string, body []byte) error {
+	c.mu.Lock()
+	s, ok := c.sources[name]
+	c.mu.Unlock()
+	if !ok {
+		return ErrSourceNotFound
+	}
+
+	sensors, err := parse(s.config, body)
+	c.update(s, sensors, err)
+	return err
+}
+
+// Sensors returns the fresh readings of all sources, in configuration order
+func (c *Collector) Sensors() []*temps.Sensor {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	sensors := []*temps.Sensor{}
+	for _, name := range c.order {
+		if s := c.sources[name]; !s.stale(now) {
+			sensors = append(sensors, s.sensors...)
+		}
+	}
+	return sensors
+}
+
+// Sources returns the state of every source, including stale readings
+func (c *Collector) Sources() []*SourceStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]*SourceStatus, 0, len(c.order))
+	for _, name := range c.order {
+		s := c.sources[name]
+		status := &SourceStatus{
+			Name:       name,
+			URL:        s.config.URL,
+			StaleAfter: s.staleAfter.String(),
+			Stale:      s.stale(now),
+			LastError:  s.lastError,
+			Sensors:    s.sensors,
+		}
+		if s.config.URL != "" {
+			status.Interval = s.interval.String()
+		}
+		if !s.updated.IsZero() {
+			updated := s.updated
+			status.LastUpdate = &updated
+		}
+		if status.Sensors == nil {
+			status.Sensors = []*temps.Sensor{}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// poll fetches a source at its interval until stopped
+func (c *Collector) poll(s *source, stop chan struct{}) {
+	defer c.wg.Done()
+
+	for {
+		timeout := s.config.Timeout.Std()
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		sensors, err := c.fetch(ctx, s.config)
+		cancel()
+		c.update(s, sensors, err)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// fetch reads and parses a source's URL
+func (c *Collector) fetch(ctx context.Context, sc config.ExternalSource) ([]*temps.Sensor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sc.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "picoHWMon")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s answered %s", sc.URL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+	return parse(sc, body)
+}
+
+// update records the outcome of a poll or push
+func (c *Collector) update(s *source, sensors []*temps.Sensor, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		s.lastError = err.Error()
+		return
+	}
+	s.sensors = sensors
+	s.updated = time.Now()
+	s.lastError = ""
+}
+
+// parse extracts readings from a JSON document. Sensors without a numeric
+// value, e.g. a disconnected thermistor reported as null, are skipped.
+func parse(sc config.ExternalSource, body []byte) ([]*temps.Sensor, error) {
+	sensorsKey, nameKey, valueKey := sc.SensorsKey, sc.NameKey, sc.ValueKey
+	if sensorsKey == "" {
+		sensorsKey = DefaultSensorsKey
+	}
+	if nameKey == "" {
+		nameKey = DefaultNameKey
+	}
+	if valueKey == "" {
+		valueKey = DefaultValueKey
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	raw, ok := document[sensorsKey]
+	if !ok {
+		return nil, fmt.Errorf("no %q list in the document", sensorsKey)
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("%q is not a list of objects", sensorsKey)
+	}
+
+	sensors := []*temps.Sensor{}
+	for i, entry := range entries {
+		value, ok := entry[valueKey].(float64)
+		if !ok {
+			continue
+		}
+		label, _ := entry[nameKey].(string)
+		if label == "" {
+			label = fmt.Sprintf("sensor%d", i)
+		}
+		sensor := &temps.Sensor{Name: sc.Name, Label: label, Temperature: value}
+		if critical, ok := entry["critical_celsius"].(float64); ok {
+			sensor.Critical = critical
+		}
+		if max, ok := entry["max_celsius"].(float64); ok {
+			sensor.Max = max
+		}
+		sensors = append(sensors, sensor)
+	}
+	sort.SliceStable(sensors, func(i, j int) bool { return sensors[i].Label < sensors[j].Label })
+	return sensors, nil
+}
+
+// Wrap returns a reader that adds the fresh external readings to the readings of base
+func (c *Collector) Wrap(base temps.Reader) temps.Reader {
+	return &reader{base: base, collector: c}
+}
+
+// reader merges external readings into temps.Info
+type reader struct {
+	base      temps.Reader
+	collector *Collector
+}
+
+// GetInfo reads the local sensors and adds the external ones. A failing local
+// reader is only reported when there are no external readings either.
+func (r *reader) GetInfo(ctx context.Context) (*temps.Info, error) {
+	external := r.collector.Sensors()
+
+	info, err := r.base.GetInfo(ctx)
+	if err != nil {
+		if len(external) == 0 {
+			return nil, err
+		}
+		info = &temps.Info{CPU: []*temps.Sensor{}, GPU: []*temps.Sensor{}, System: []*temps.Sensor{}, Drives: []*temps.Sensor{}}
+	}
+	info.External = external
+	return info, nil
+}
@@ -0,0 +1,576 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+)
+
+// systemProbe reads the platform specific rule inputs
+type systemProbe interface {
+	// processNames returns the lower-cased names of running processes
+	processNames(ctx context.Context) (map[string]bool, error)
+	powerSource(ctx context.Context) (PowerSource, error)
+}
+
+// RuleEngine evaluates rules periodically and applies the profile of the
+// winning rule. The configuration is stored as JSON in configPath.
+type RuleEngine struct {
+	configPath          string
+	probe               systemProbe
+	overclockController overclock.Controller
+	fanController       fan.Controller
+	gpuReader           gpu.Reader
+	auditLog            *audit.Log
+
+	mu     sync.Mutex
+	config *Config
+	events []*Event
+
+	// current is the ID of the rule whose settings are applied, "" for none
+	current       string
+	currentSince  time.Time
+	lastMatched   time.Time
+	activeProfile string
+	lastEvaluated *time.Time
+	inputs        *Inputs
+
+	// savedFans holds the settings of fans from before a rule changed them,
+	// restored once no active rule sets them; only evaluate uses it
+	savedFans map[int]*fan.Settings
+
+	running bool
+	stop    chan struct{}
+	wake    chan struct{}
+}
+
+// newRuleEngine creates an engine storing its configuration in configPath and
+// recording its actions in auditLog, which may be nil
+func newRuleEngine(configPath string, probe systemProbe, overclockController overclock.Controller, fanController fan.Controller, gpuReader gpu.Reader, auditLog *audit.Log) *RuleEngine {
+	e := &RuleEngine{
+		configPath:          configPath,
+		probe:               probe,
+		overclockController: overclockController,
+		fanController:       fanController,
+		gpuReader:           gpuReader,
+		auditLog:            auditLog,
+		events:              []*Event{},
+		savedFans:           make(map[int]*fan.Settings),
+		wake:                make(chan struct{}, 1),
+	}
+
+	config, err := e.readConfig()
+	if err != nil {
+		slog.Error("Rules disabled", "error", err)
+		config = &Config{Enabled: false, Rules: []*Rule{}}
+		config.Validate()
+	}
+	e.config = config
+
+	return e
+}
+
+// Start begins evaluating rules in the background
+func (e *RuleEngine) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.running {
+		return
+	}
+	e.running = true
+	e.stop = make(chan struct{})
+	go e.run(e.stop)
+}
+
+// Stop ends background evaluation
+func (e *RuleEngine) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.running {
+		return
+	}
+	e.running = false
+	close(e.stop)
+}
+
+// GetConfig returns a copy of the rules configuration
+func (e *RuleEngine) GetConfig(ctx context.Context) (*Config, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return copyConfig(e.config), nil
+}
+
+// SetConfig validates, stores and activates a complete rules configuration
+func (e *RuleEngine) SetConfig(ctx context.Context, config *Config) (*Config, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.replaceConfigLocked(config); err != nil {
+		return nil, err
+	}
+	return copyConfig(config), nil
+}
+
+// GetRule returns a single rule
+func (e *RuleEngine) GetRule(ctx context.Context, id string) (*Rule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, rule := range e.config.Rules {
+		if rule.ID == id {
+			copied := *rule
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrRuleNotFound, id)
+}
+
+// SaveRule adds a rule or replaces the rule with the same ID
+func (e *RuleEngine) SaveRule(ctx context.Context, rule *Rule) (*Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	config := copyConfig(e.config)
+	replaced := false
+	for i, existing := range config.Rules {
+		if existing.ID == rule.ID {
+			config.Rules[i] = rule
+			replaced = true
+		}
+	}
+	if !replaced {
+		config.Rules = append(config.Rules, rule)
+	}
+
+	if err := e.replaceConfigLocked(config); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteRule removes a rule. If it was active, the next evaluation switches away from it.
+func (e *RuleEngine) DeleteRule(ctx context.Context, id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	config := copyConfig(e.config)
+	for i, existing := range config.Rules {
+		if existing.ID == id {
+			config.Rules = append(config.Rules[:i], config.Rules[i+1:]...)
+			return e.replaceConfigLocked(config)
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrRuleNotFound, id)
+}
+
+// GetStatus returns the active rule and the last evaluated inputs
+func (e *RuleEngine) GetStatus(ctx context.Context) (*Status, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := &Status{
+		Enabled:       e.config.Enabled,
+		ActiveRule:    e.current,
+		ActiveProfile: e.activeProfile,
+		LastEvaluated: e.lastEvaluated,
+		Inputs:        e.inputs,
+	}
+	if e.current != "" {
+		since := e.currentSince
+		status.ActiveSince = &since
+	}
+	return status, nil
+}
+
+// GetEvents returns the rule log, newest first
+func (e *RuleEngine) GetEvents(ctx context.Context) ([]*Event, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	events := make([]*Event, 0, len(e.events))
+	for i := len(e.events) - 1; i >= 0; i-- {
+		events = append(events, e.events[i])
+	}
+	return events, nil
+}
+
+// replaceConfigLocked persists a validated configuration and wakes the
+// evaluation loop; the caller holds e.mu
+func (e *RuleEngine) replaceConfigLocked(config *Config) error {
+	if err := e.writeConfig(config); err != nil {
+		return err
+	}
+	e.config = config
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// run evaluates rules until stop is closed
+func (e *RuleEngine) run(stop chan struct{}) {
+	for {
+		e.evaluate()
+
+		e.mu.Lock()
+		interval := time.Duration(e.config.Interval) * time.Second
+		e.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-e.wake:
+		case <-time.After(interval):
+		}
+	}
+}
+
+// evaluate gathers inputs, picks the winning rule and applies it if it changed
+func (e *RuleEngine) evaluate() {
+	e.mu.Lock()
+	config := copyConfig(e.config)
+	e.mu.Unlock()
+
+	if !config.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	inputs, processes := e.gatherInputs(ctx, config)
+
+	// Highest priority first; the list order breaks ties
+	candidates := make([]*Rule, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		if rule.Enabled {
+			candidates = append(candidates, rule)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Priority > candidates[j].Priority
+	})
+
+	var winner *Rule
+	for _, rule := range candidates {
+		if rule.matches(inputs, processes) {
+			winner = rule
+			break
+		}
+	}
+
+	e.mu.Lock()
+	now := inputs.Time
+	e.lastEvaluated = &now
+	e.inputs = inputs
+
+	var active *Rule
+	for _, rule := range candidates {
+		if rule.ID == e.current {
+			active = rule
+		}
+	}
+	if active != nil && active.matches(inputs, processes) {
+		e.lastMatched = now
+	}
+
+	target := winner
+	reason := "conditions matched"
+	if active != nil && (winner == nil || (winner.ID != active.ID && winner.Priority <= active.Priority)) {
+		held := now.Sub(e.lastMatched) < time.Duration(active.HoldDown)*time.Second
+		if held || active.matches(inputs, processes) {
+			target = active
+		}
+	}
+	if target == nil && e.current == "" {
+		e.mu.Unlock()
+		return
+	}
+	if target != nil && target.ID == e.current {
+		e.mu.Unlock()
+		return
+	}
+
+	if blocked := e.overclockController.Blocked(); blocked != "" {
+		// Switch on a later evaluation, once the block is lifted
+		inputs.Errors = append(inputs.Errors, "profile switch deferred: "+blocked)
+		e.mu.Unlock()
+		return
+	}
+
+	previous := e.current
+	if target == nil {
+		reason = fmt.Sprintf("rule %s no longer matches", previous)
+	} else if previous != "" {
+		reason = fmt.Sprintf("conditions matched, replacing rule %s", previous)
+	}
+	e.mu.Unlock()
+
+	event := e.activate(ctx, target, config.DefaultProfile, reason)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.current = ""
+	e.activeProfile = event.Profile
+	if target != nil {
+		e.current = target.ID
+		e.currentSince = now
+		e.lastMatched = now
+	}
+	e.events = append(e.events, event)
+	if len(e.events) > maxEvents {
+		e.events = e.events[len(e.events)-maxEvents:]
+	}
+}
+
+// activate applies the settings of a rule, or the default profile if rule is
+// nil. Fans set by the previous rule but not by this one get their earlier
+// settings back first, so the default profile still has the last word.
+func (e *RuleEngine) activate(ctx context.Context, rule *Rule, defaultProfile, reason string) *Event {
+	event := &Event{
+		Time:   time.Now(),
+		Reason: reason,
+	}
+
+	profile := defaultProfile
+	var fans []*overclock.FanSettings
+	if rule != nil {
+		event.Action = "activated"
+		event.RuleID = rule.ID
+		event.RuleName = rule.Name
+		profile = rule.Profile
+		fans = rule.Fans
+	} else {
+		event.Action = "deactivated"
+	}
+	event.Profile = profile
+
+	endpoint := "rules/default"
+	if event.RuleID != "" {
+		endpoint = "rules/" + event.RuleID
+	}
+
+	setByRule := make(map[int]bool)
+	for _, f := range fans {
+		setByRule[f.FanID] = true
+	}
+	for fanID, settings := range e.savedFans {
+		if setByRule[fanID] {
+			continue
+		}
+		err := e.fanController.SetSettings(ctx, fanID, settings)
+		if err != nil {
+			event.Errors = append(event.Errors, fmt.Sprintf("failed to restore fan %d: %v", fanID, err))
+		}
+		e.record(endpoint, fmt.Sprintf("fan:%d", fanID), nil, settings, err, reason)
+		delete(e.savedFans, fanID)
+	}
+
+	if profile != "" {
+		var applied interface{}
+		result, err := e.overclockController.LoadProfile(ctx, profile)
+		if err != nil {
+			event.Errors = append(event.Errors, fmt.Sprintf("failed to load profile '%s': %v", profile, err))
+		} else {
+			event.Result = result
+			applied = result
+			if !result.Success {
+				err = fmt.Errorf("profile %s was only partially applied", profile)
+			}
+		}
+		e.record(endpoint, "profile:"+profile, nil, applied, err, reason)
+	}
+	for _, f := range fans {
+		var previous interface{}
+		if current, err := e.fanController.GetSettings(ctx, f.FanID); err == nil {
+			previous = current
+			if _, saved := e.savedFans[f.FanID]; !saved {
+				e.savedFans[f.FanID] = current
+			}
+		}
+		err := e.fanController.SetSettings(ctx, f.FanID, f.Settings)
+		if err != nil {
+			event.Errors = append(event.Errors, fmt.Sprintf("failed to set fan %d: %v", f.FanID, err))
+		}
+		e.record(endpoint, fmt.Sprintf("fan:%d", f.FanID), previous, f.Settings, err, reason)
+	}
+
+	attrs := []any{"action", event.Action, "reason", reason}
+	if event.RuleID != "" {
+		attrs = append(attrs, "rule", event.RuleID)
+	}
+	if len(event.Errors) > 0 {
+		slog.Warn("Rules applied with errors", append(attrs, "errors", strings.Join(event.Errors, "; "))...)
+	} else {
+		slog.Info("Rules applied", attrs...)
+	}
+	return event
+}
+
+// record writes an action of the engine to the audit log
+func (e *RuleEngine) record(endpoint, target string, previous, applied interface{}, err error, reason string) {
+	if e.auditLog == nil {
+		return
+	}
+
+	entry := &audit.Entry{
+		Time:       time.Now(),
+		ClientAddr: "local",
+		Identity:   "rules",
+		AuthMethod: "internal",
+		Method:     "RULE",
+		Endpoint:   endpoint,
+		Target:     target,
+		Result:     audit.ResultSuccess,
+	}
+	if previous != nil {
+		entry.Previous, _ = json.Marshal(previous)
+	}
+	if applied != nil {
+		entry.New, _ = json.Marshal(applied)
+	}
+	entry.Details, _ = json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		entry.Result = audit.ResultFailure
+		entry.Error = err.Error()
+	}
+	if err := e.auditLog.Record(entry); err != nil {
+		slog.Error("Failed to record rule action in the audit log", "error", err)
+	}
+}
+
+// gatherInputs reads only the inputs that enabled rules refer to
+func (e *RuleEngine) gatherInputs(ctx context.Context, config *Config) (*Inputs, map[string]bool) {
+	inputs := &Inputs{
+		Time:        time.Now(),
+		GPUUsage:    []float64{},
+		PowerSource: PowerUnknown,
+	}
+
+	var needProcesses, needGPU, needPower bool
+	for _, rule := range config.Rules {
+		if !rule.Enabled {
+			continue
+		}
+		needProcesses = needProcesses || len(rule.Conditions.Processes) > 0
+		needGPU = needGPU || rule.Conditions.GPUUsageAbove != nil || rule.Conditions.GPUUsageBelow != nil
+		needPower = needPower || rule.Conditions.PowerSource != ""
+	}
+
+	processes := map[string]bool{}
+	if needProcesses {
+		names, err := e.probe.processNames(ctx)
+		if err != nil {
+			inputs.Errors = append(inputs.Errors, fmt.Sprintf("processes: %v", err))
+		} else {
+			processes = names
+		}
+		inputs.Processes = len(processes)
+	}
+
+	if needGPU {
+		gpus, err := e.gpuReader.GetInfo(ctx)
+		if err != nil {
+			inputs.Errors = append(inputs.Errors, fmt.Sprintf("GPU: %v", err))
+		}
+		for _, g := range gpus {
+			inputs.GPUUsage = append(inputs.GPUUsage, g.Usage)
+		}
+	}
+
+	if needPower {
+		source, err := e.probe.powerSource(ctx)
+		if err != nil {
+			inputs.Errors = append(inputs.Errors, fmt.Sprintf("power source: %v", err))
+		} else {
+			inputs.PowerSource = source
+		}
+	}
+
+	return inputs, processes
+}
+
+// readConfig loads the configuration, returning an empty enabled configuration if none exists
+func (e *RuleEngine) readConfig() (*Config, error) {
+	config := &Config{Enabled: true}
+
+	data, err := os.ReadFile(e.configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", e.configPath, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", e.configPath, err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", e.configPath, err)
+	}
+	return config, nil
+}
+
+// writeConfig atomically writes the configuration file
+func (e *RuleEngine) writeConfig(config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rules: %w", err)
+	}
+
+	dir := filepath.Dir(e.configPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to save rules: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".rules-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save rules: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save rules: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save rules: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), e.configPath); err != nil {
+		return fmt.Errorf("failed to save rules: %w", err)
+	}
+	return nil
+}
+
+// copyConfig returns a copy of the configuration with its own rules slice
+func copyConfig(config *Config) *Config {
+	copied := *config
+	copied.Rules = append([]*Rule{}, config.Rules...)
+	return &copied
+}
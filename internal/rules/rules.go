@@ -0,0 +1,319 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+)
+
+// PowerSource represents where the machine currently draws power from
+type PowerSource string
+
+const (
+	PowerAC      PowerSource = "ac"
+	PowerBattery PowerSource = "battery"
+	PowerUnknown PowerSource = "unknown"
+)
+
+const (
+	// DefaultInterval is how often rules are evaluated when no interval is configured
+	DefaultInterval = 5 * time.Second
+	// MinInterval is the shortest accepted evaluation interval
+	MinInterval = time.Second
+
+	// maxEvents is the number of rule events kept in the log
+	maxEvents = 200
+)
+
+// ErrRuleNotFound is returned when a rule ID does not exist
+var ErrRuleNotFound = errors.New("rule not found")
+
+var (
+	ruleIDPattern  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	timeOfDayRegex = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+	weekdays       = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+)
+
+// TimeWindow matches a time of day range, optionally restricted to weekdays.
+// Windows where End is before Start wrap around midnight.
+type TimeWindow struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
+}
+
+// Conditions are the inputs a rule matches on. Every condition that is set
+// must hold for the rule to match.
+type Conditions struct {
+	// Processes matches if any of the named processes is running (case-insensitive)
+	Processes []string `json:"processes,omitempty"`
+	// GPUDevice selects the GPU for the utilization conditions
+	GPUDevice     int         `json:"gpu_device,omitempty"`
+	GPUUsageAbove *float64    `json:"gpu_usage_above_percent,omitempty"`
+	GPUUsageBelow *float64    `json:"gpu_usage_below_percent,omitempty"`
+	PowerSource   string      `json:"power_source,omitempty"`
+	TimeWindow    *TimeWindow `json:"time_window,omitempty"`
+}
+
+// Rule activates an overclock profile and optional fan settings while its
+// conditions match. Higher priorities win; once active, a rule stays active
+// for HoldDown seconds after its conditions stop matching.
+type Rule struct {
+	ID         string                   `json:"id"`
+	Name       string                   `json:"name"`
+	Enabled    bool                     `json:"enabled"`
+	Priority   int                      `json:"priority"`
+	HoldDown   int                      `json:"hold_down_seconds"`
+	Conditions Conditions               `json:"conditions"`
+	Profile    string                   `json:"profile,omitempty"`
+	Fans       []*overclock.FanSettings `json:"fans,omitempty"`
+}
+
+// Config is the persisted rules configuration
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Interval is the evaluation interval in seconds
+	Interval int `json:"interval_seconds"`
+	// DefaultProfile is loaded when no rule matches
+	DefaultProfile string  `json:"default_profile,omitempty"`
+	Rules          []*Rule `json:"rules"`
+}
+
+// Inputs are the observed values rules were evaluated against
+type Inputs struct {
+	Time        time.Time   `json:"time"`
+	Processes   int         `json:"process_count"`
+	GPUUsage    []float64   `json:"gpu_usage_percent"`
+	PowerSource PowerSource `json:"power_source"`
+	Errors      []string    `json:"errors,omitempty"`
+}
+
+// Event records a rule activation or deactivation
+type Event struct {
+	Time     time.Time                `json:"time"`
+	RuleID   string                   `json:"rule_id,omitempty"`
+	RuleName string                   `json:"rule_name,omitempty"`
+	Action   string                   `json:"action"`
+	Reason   string                   `json:"reason"`
+	Profile  string                   `json:"profile,omitempty"`
+	Result   *overclock.ProfileResult `json:"result,omitempty"`
+	Errors   []string                 `json:"errors,omitempty"`
+}
+
+// Status describes the current state of the rules engine
+type Status struct {
+	Enabled       bool       `json:"enabled"`
+	ActiveRule    string     `json:"active_rule,omitempty"`
+	ActiveSince   *time.Time `json:"active_since,omitempty"`
+	ActiveProfile string     `json:"active_profile,omitempty"`
+	LastEvaluated *time.Time `json:"last_evaluated,omitempty"`
+	Inputs        *Inputs    `json:"inputs,omitempty"`
+}
+
+// Engine interface for automatic profile switching
+type Engine interface {
+	Start()
+	Stop()
+
+	GetConfig(ctx context.Context) (*Config, error)
+	SetConfig(ctx context.Context, config *Config) (*Config, error)
+	GetRule(ctx context.Context, id string) (*Rule, error)
+	SaveRule(ctx context.Context, rule *Rule) (*Rule, error)
+	DeleteRule(ctx context.Context, id string) error
+	GetStatus(ctx context.Context) (*Status, error)
+	GetEvents(ctx context.Context) ([]*Event, error)
+}
+
+// NewEngine creates a new rules engine for the current platform that switches
+// profiles through overclockController and fanController. Rules are stored
+// next to profilesDir, or in the platform default directory when it is empty,
+// and its actions are recorded in auditLog, which may be nil.
+func NewEngine(overclockController overclock.Controller, fanController fan.Controller, gpuReader gpu.Reader, profilesDir string, auditLog *audit.Log) Engine {
+	return newPlatformEngine(overclockController, fanController, gpuReader, profilesDir, auditLog)
+}
+
+// configPath returns where rules.json is stored: in the parent of a
+// configured profiles directory, which is where it sits next to the default
+// one, otherwise defaultPath
+func configPath(profilesDir, defaultPath string) string {
+	if profilesDir == "" {
+		return defaultPath
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(profilesDir)), "rules.json")
+}
+
+// Validate checks a rules configuration and fills in defaults
+func (c *Config) Validate() error {
+	if c.Interval == 0 {
+		c.Interval = int(DefaultInterval / time.Second)
+	}
+	if time.Duration(c.Interval)*time.Second < MinInterval {
+		return fmt.Errorf("interval must be at least %s", MinInterval)
+	}
+	if c.DefaultProfile != "" {
+		if err := overclock.ValidateProfileName(c.DefaultProfile); err != nil {
+			return fmt.Errorf("default profile: %w", err)
+		}
+	}
+	if c.Rules == nil {
+		c.Rules = []*Rule{}
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range c.Rules {
+		if rule == nil {
+			return fmt.Errorf("rules cannot be null")
+		}
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if seen[rule.ID] {
+			return fmt.Errorf("duplicate rule ID %q", rule.ID)
+		}
+		seen[rule.ID] = true
+	}
+
+	return nil
+}
+
+// Validate checks a single rule
+func (r *Rule) Validate() error {
+	if !ruleIDPattern.MatchString(r.ID) {
+		return fmt.Errorf("invalid rule ID %q: use up to 64 letters, digits, '_' or '-'", r.ID)
+	}
+	if r.HoldDown < 0 {
+		return fmt.Errorf("rule %s: hold-down time cannot be negative", r.ID)
+	}
+	if r.Profile == "" && len(r.Fans) == 0 {
+		return fmt.Errorf("rule %s: must activate a profile or fan settings", r.ID)
+	}
+	if r.Profile != "" {
+		if err := overclock.ValidateProfileName(r.Profile); err != nil {
+			return fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+	}
+	if err := overclock.ValidateFanSettings(r.Fans); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+
+	cond := r.Conditions
+	if len(cond.Processes) == 0 && cond.GPUUsageAbove == nil && cond.GPUUsageBelow == nil &&
+		cond.PowerSource == "" && cond.TimeWindow == nil {
+		return fmt.Errorf("rule %s: at least one condition is required", r.ID)
+	}
+	if cond.GPUDevice < 0 {
+		return fmt.Errorf("rule %s: invalid GPU device %d", r.ID, cond.GPUDevice)
+	}
+	switch PowerSource(cond.PowerSource) {
+	case "", PowerAC, PowerBattery:
+	default:
+		return fmt.Errorf("rule %s: power source must be %q or %q", r.ID, PowerAC, PowerBattery)
+	}
+	if w := cond.TimeWindow; w != nil {
+		if !timeOfDayRegex.MatchString(w.Start) || !timeOfDayRegex.MatchString(w.End) {
+			return fmt.Errorf("rule %s: time window start and end must be HH:MM", r.ID)
+		}
+		if w.Start == w.End {
+			return fmt.Errorf("rule %s: time window start and end cannot be the same", r.ID)
+		}
+		for _, day := range w.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("rule %s: unknown weekday %q (use sun, mon, ...)", r.ID, day)
+			}
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the rule's conditions hold for the given inputs
+func (r *Rule) matches(inputs *Inputs, processes map[string]bool) bool {
+	cond := r.Conditions
+
+	if len(cond.Processes) > 0 {
+		found := false
+		for _, name := range cond.Processes {
+			if processes[strings.ToLower(name)] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if cond.GPUUsageAbove != nil || cond.GPUUsageBelow != nil {
+		if cond.GPUDevice >= len(inputs.GPUUsage) {
+			return false
+		}
+		usage := inputs.GPUUsage[cond.GPUDevice]
+		if cond.GPUUsageAbove != nil && usage <= *cond.GPUUsageAbove {
+			return false
+		}
+		if cond.GPUUsageBelow != nil && usage >= *cond.GPUUsageBelow {
+			return false
+		}
+	}
+
+	if cond.PowerSource != "" && PowerSource(cond.PowerSource) != inputs.PowerSource {
+		return false
+	}
+
+	if cond.TimeWindow != nil && !cond.TimeWindow.contains(inputs.Time) {
+		return false
+	}
+
+	return true
+}
+
+// contains reports whether t falls inside the window
+func (w *TimeWindow) contains(t time.Time) bool {
+	start := minutesOfDay(w.Start)
+	end := minutesOfDay(w.End)
+	now := t.Hour()*60 + t.Minute()
+
+	day := t.Weekday()
+	var inside bool
+	if start <= end {
+		inside = now >= start && now < end
+	} else {
+		// Wraps around midnight; the early part belongs to the previous day
+		inside = now >= start || now < end
+		if now < end {
+			day = (day + 6) % 7
+		}
+	}
+	if !inside {
+		return false
+	}
+
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// minutesOfDay converts a validated HH:MM string to minutes since midnight
+func minutesOfDay(value string) int {
+	var hour, minute int
+	fmt.Sscanf(value, "%d:%d", &hour, &minute)
+	return hour*60 + minute
+}
@@ -0,0 +1,95 @@
+//go:build linux
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+	"github.com/CristiGvl/picoHWMon/internal/platform"
+)
+
+// newPlatformEngine creates a Linux rules engine storing rules next to the overclock profiles
+func newPlatformEngine(overclockController overclock.Controller, fanController fan.Controller, gpuReader gpu.Reader, profilesDir string, auditLog *audit.Log) Engine {
+	homeDir, _ := os.UserHomeDir()
+	path := configPath(profilesDir, filepath.Join(homeDir, ".config", "picohwmon", "rules.json"))
+
+	probe := &linuxProbe{procPath: platform.HostProc(), powerSupplyPath: platform.HostSys("class", "power_supply")}
+	return newRuleEngine(path, probe, overclockController, fanController, gpuReader, auditLog)
+}
+
+// linuxProbe reads rule inputs from procfs and sysfs
+type linuxProbe struct {
+	procPath        string
+	powerSupplyPath string
+}
+
+// processNames returns the names of running processes from /proc/<pid>/comm
+// and the executable name from /proc/<pid>/cmdline, since comm is truncated
+// to 15 characters
+func (p *linuxProbe) processNames(ctx context.Context) (map[string]bool, error) {
+	entries, err := os.ReadDir(p.procPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p.procPath, err)
+	}
+
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.Trim(entry.Name(), "0123456789") != "" {
+			continue
+		}
+		pidPath := filepath.Join(p.procPath, entry.Name())
+
+		if comm, err := os.ReadFile(filepath.Join(pidPath, "comm")); err == nil {
+			names[strings.ToLower(strings.TrimSpace(string(comm)))] = true
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(pidPath, "cmdline")); err == nil && len(cmdline) > 0 {
+			argv0 := strings.SplitN(string(cmdline), "\x00", 2)[0]
+			// Wine and Proton games show up with Windows paths
+			argv0 = argv0[strings.LastIndexAny(argv0, `/\`)+1:]
+			if argv0 != "" {
+				names[strings.ToLower(argv0)] = true
+				names[strings.ToLower(strings.TrimSuffix(argv0, ".exe"))] = true
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// powerSource reports AC when any mains supply is online, battery when only batteries exist
+func (p *linuxProbe) powerSource(ctx context.Context) (PowerSource, error) {
+	supplies, err := os.ReadDir(p.powerSupplyPath)
+	if err != nil {
+		return PowerUnknown, fmt.Errorf("failed to read %s: %w", p.powerSupplyPath, err)
+	}
+
+	hasBattery := false
+	for _, supply := range supplies {
+		supplyPath := filepath.Join(p.powerSupplyPath, supply.Name())
+		supplyType, _ := os.ReadFile(filepath.Join(supplyPath, "type"))
+
+		switch strings.TrimSpace(string(supplyType)) {
+		case "Mains", "USB":
+			online, _ := os.ReadFile(filepath.Join(supplyPath, "online"))
+			if strings.TrimSpace(string(online)) == "1" {
+				return PowerAC, nil
+			}
+		case "Battery":
+			hasBattery = true
+		}
+	}
+
+	if hasBattery {
+		return PowerBattery, nil
+	}
+	// Desktops without a battery or mains entry are always on AC
+	return PowerAC, nil
+}
@@ -0,0 +1,62 @@
+//go:build !linux && !windows
+
+package rules
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+)
+
+// UnsupportedEngine is a fallback for unsupported platforms
+type UnsupportedEngine struct{}
+
+// newPlatformEngine creates a fallback rules engine for unsupported platforms
+func newPlatformEngine(overclockController overclock.Controller, fanController fan.Controller, gpuReader gpu.Reader, profilesDir string, auditLog *audit.Log) Engine {
+	return &UnsupportedEngine{}
+}
+
+// Start does nothing on unsupported platforms
+func (e *UnsupportedEngine) Start() {}
+
+// Stop does nothing on unsupported platforms
+func (e *UnsupportedEngine) Stop() {}
+
+// GetConfig returns an error for unsupported platforms
+func (e *UnsupportedEngine) GetConfig(ctx context.Context) (*Config, error) {
+	return nil, fmt.Errorf("profile switching rules not supported on this platform")
+}
+
+// SetConfig returns an error for unsupported platforms
+func (e *UnsupportedEngine) SetConfig(ctx context.Context, config *Config) (*Config, error) {
+	return nil, fmt.Errorf("profile switching rules not supported on this platform")
+}
+
+// GetRule returns an error for unsupported platforms
+func (e *UnsupportedEngine) GetRule(ctx context.Context, id string) (*Rule, error) {
+	return nil, fmt.Errorf("profile switching rules not supported on this platform")
+}
+
+// SaveRule returns an error for unsupported platforms
+func (e *UnsupportedEngine) SaveRule(ctx context.Context, rule *Rule) (*Rule, error) {
+	return nil, fmt.Errorf("profile switching rules not supported on this platform")
+}
+
+// DeleteRule returns an error for unsupported platforms
+func (e *UnsupportedEngine) DeleteRule(ctx context.Context, id string) error {
+	return fmt.Errorf("profile switching rules not supported on this platform")
+}
+
+// GetStatus returns an error for unsupported platforms
+func (e *UnsupportedEngine) GetStatus(ctx context.Context) (*Status, error) {
+	return nil, fmt.Errorf("profile switching rules not supported on this platform")
+}
+
+// GetEvents returns an error for unsupported platforms
+func (e *UnsupportedEngine) GetEvents(ctx context.Context) ([]*Event, error) {
+	return nil, fmt.Errorf("profile switching rules not supported on this platform")
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CristiGvl/picoHWMon/internal/audit"
+	"github.com/CristiGvl/picoHWMon/internal/fan"
+	"github.com/CristiGvl/picoHWMon/internal/gpu"
+	"github.com/CristiGvl/picoHWMon/internal/overclock"
+	"github.com/StackExchange/wmi"
+)
+
+// newPlatformEngine creates a Windows rules engine storing rules next to the overclock profiles
+func newPlatformEngine(overclockController overclock.Controller, fanController fan.Controller, gpuReader gpu.Reader, profilesDir string, auditLog *audit.Log) Engine {
+	homeDir, _ := os.UserHomeDir()
+	path := configPath(profilesDir, filepath.Join(homeDir, "AppData", "Local", "picohwmon", "rules.json"))
+
+	return newRuleEngine(path, &windowsProbe{}, overclockController, fanController, gpuReader, auditLog)
+}
+
+// windowsProbe reads rule inputs through WMI
+type windowsProbe struct{}
+
+// Win32_Process represents a WMI process entry
+type Win32_Process struct {
+	Name string
+}
+
+// Win32_Battery represents a WMI battery entry
+type Win32_Battery struct {
+	BatteryStatus uint16
+}
+
+// processNames returns the names of running processes, with and without the .exe suffix
+func (p *windowsProbe) processNames(ctx context.Context) (map[string]bool, error) {
+	var processes []Win32_Process
+	if err := wmi.Query("SELECT Name FROM Win32_Process", &processes); err != nil {
+		return nil, fmt.Errorf("failed to query processes: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for _, process := range processes {
+		name := strings.ToLower(process.Name)
+		names[name] = true
+		names[strings.TrimSuffix(name, ".exe")] = true
+	}
+	return names, nil
+}
+
+// powerSource reports battery when a battery is discharging (BatteryStatus 1)
+func (p *windowsProbe) powerSource(ctx context.Context) (PowerSource, error) {
+	var batteries []Win32_Battery
+	if err := wmi.Query("SELECT BatteryStatus FROM Win32_Battery", &batteries); err != nil {
+		return PowerUnknown, fmt.Errorf("failed to query batteries: %w", err)
+	}
+
+	for _, battery := range batteries {
+		if battery.BatteryStatus == 1 {
+			return PowerBattery, nil
+		}
+	}
+	return PowerAC, nil
+}
@@ -0,0 +1,163 @@
+package systemd
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalSocket is where journald receives entries in its native protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+// JournalHandler is a slog.Handler that sends records to the journal as
+// structured entries: the message with its priority and source location,
+// and every attribute as a field of its own, e.g. error=... as ERROR=...
+type JournalHandler struct {
+	conn       *net.UnixConn
+	identifier string
+	level      slog.Leveler
+	// fields are the encoded attributes added with WithAttrs
+	fields []byte
+	// prefix is prepended to field names inside groups
+	prefix string
+}
+
+// NewJournalHandler connects to the journal. Entries carry identifier as
+// SYSLOG_IDENTIFIER; records below level are dropped.
+func NewJournalHandler(identifier string, level slog.Leveler) (*JournalHandler, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &JournalHandler{conn: conn, identifier: identifier, level: level}, nil
+}
+
+// Enabled reports whether records of a level are sent
+func (h *JournalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle sends a record as one journal entry. Entries the journal refuses,
+// e.g. because they are too large for a datagram, go to standard error.
+func (h *JournalHandler) Handle(_ context.Context, r slog.Record) error {
+	var entry []byte
+	entry = appendField(entry, "MESSAGE", r.Message)
+	entry = appendField(entry, "PRIORITY", strconv.Itoa(priority(r.Level)))
+	entry = appendField(entry, "SYSLOG_IDENTIFIER", h.identifier)
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry = appendField(entry, "CODE_FILE", frame.File)
+		entry = appendField(entry, "CODE_LINE", strconv.Itoa(frame.Line))
+		entry = appendField(entry, "CODE_FUNC", frame.Function)
+	}
+	entry = append(entry, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		entry = appendAttr(entry, h.prefix, a)
+		return true
+	})
+
+	if _, err := h.conn.Write(entry); err != nil {
+		_, err = fmt.Fprintln(os.Stderr, r.Message)
+		return err
+	}
+	return nil
+}
+
+// WithAttrs returns a handler that adds attrs to every entry
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.fields = append([]byte(nil), h.fields...)
+	for _, a := range attrs {
+		clone.fields = appendAttr(clone.fields, h.prefix, a)
+	}
+	return &clone
+}
+
+// WithGroup returns a handler that prefixes later field names with name
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + fieldName(name) + "_"
+	return &clone
+}
+
+// priority maps a level to a syslog priority
+func priority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// appendAttr encodes an attribute, flattening groups into prefixed names
+func appendAttr(entry []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return entry
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		if a.Key != "" {
+			prefix += fieldName(a.Key) + "_"
+		}
+		for _, member := range a.Value.Group() {
+			entry = appendAttr(entry, prefix, member)
+		}
+		return entry
+	case slog.KindTime:
+		return appendField(entry, prefix+fieldName(a.Key), a.Value.Time().Format(time.RFC3339Nano))
+	default:
+		return appendField(entry, prefix+fieldName(a.Key), a.Value.String())
+	}
+}
+
+// fieldName turns an attribute key into a journal field name: upper case
+// letters, digits and underscores, not starting with an underscore, which
+// marks fields set by the journal itself
+func fieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "F_" + name
+	}
+	return name
+}
+
+// appendField encodes a field in the native protocol. Values with newlines
+// are sent with their length instead of after "=".
+func appendField(entry []byte, name, value string) []byte {
+	if !strings.Contains(value, "\n") {
+		entry = append(entry, name...)
+		entry = append(entry, '=')
+		entry = append(entry, value...)
+		return append(entry, '\n')
+	}
+	entry = append(entry, name...)
+	entry = append(entry, '\n')
+	entry = binary.LittleEndian.AppendUint64(entry, uint64(len(value)))
+	entry = append(entry, value...)
+	return append(entry, '\n')
+}
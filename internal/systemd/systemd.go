@@ -0,0 +1,103 @@
+// Package systemd integrates with the systemd service manager: readiness and
+// watchdog notifications, socket activation, the journal and unit files.
+// Outside a systemd service the notifications do nothing.
+package systemd
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Service states, see sd_notify(3)
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends a state change to the service manager. It reports whether
+// the manager was notified, which is false when not run by systemd.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Status returns a state that sets the status line systemctl status shows
+func Status(format string, args ...any) string {
+	return "STATUS=" + fmt.Sprintf(format, args...)
+}
+
+// Reloading returns the state that announces a configuration reload; the
+// service sends Ready when done
+func Reloading() string {
+	return "RELOADING=1\nMONOTONIC_USEC=" + strconv.FormatInt(monotonicMicroseconds(), 10)
+}
+
+// WatchdogInterval returns how often the service manager expects a
+// Watchdog notification, or 0 when the watchdog is off
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseUint(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec == 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog notifies the watchdog at half its interval for as long as
+// check passes, until stop is closed. While check fails the notifications
+// stop, so the service manager restarts a service whose control loops hang.
+// It returns at once when the watchdog is off.
+func RunWatchdog(stop <-chan struct{}, check func() error) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	var failing bool
+	for {
+		if err := check(); err != nil {
+			if !failing {
+				slog.Warn("Withholding the systemd watchdog notification", "error", err, "restart_after", interval)
+				Notify(Status("Unhealthy: %v", err))
+			}
+			failing = true
+		} else {
+			if failing {
+				slog.Info("Control loops recovered; notifying the systemd watchdog again")
+				Notify(Status("Running"))
+			}
+			failing = false
+			Notify(Watchdog)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package systemd
+
+import "net"
+
+// Listeners returns no sockets; socket activation needs systemd
+func Listeners() ([]net.Listener, error) {
+	return nil, nil
+}
+
+// JournalAvailable reports false; the journal needs systemd
+func JournalAvailable() bool {
+	return false
+}
+
+// monotonicMicroseconds is only needed by systemd
+func monotonicMicroseconds() int64 {
+	return 0
+}
@@ -0,0 +1,123 @@
+package systemd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UnitName is the name of the service and socket units
+const UnitName = "picohwmon"
+
+// StateDirectory is the service's writable home, created by systemd
+const StateDirectory = "/var/lib/" + UnitName
+
+// Unit describes the units install-service writes
+type Unit struct {
+	// ExecStart is the command line, starting with the absolute path of the binary
+	ExecStart []string
+	// Listen is the ListenStream of a socket unit, e.g. 8080 or
+	// 127.0.0.1:8080; empty for no socket unit
+	Listen string
+	// Watchdog is WatchdogSec; 0 turns the watchdog off
+	Watchdog time.Duration
+	// ReadWritePaths are directories besides the state directory the
+	// service writes to, which ProtectSystem=strict would make read-only
+	ReadWritePaths []string
+}
+
+// Service returns the service unit. It runs as root because fan, GPU and
+// CPU settings are written to /sys, and is otherwise locked down.
+// ProtectKernelTunables is left off since it makes /sys read-only, and
+// ProtectKernelLogs since GPU faults after an overclock are read from
+// /dev/kmsg to roll it back.
+func (u Unit) Service() string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=picoHWMon hardware monitor\n")
+	b.WriteString("Documentation=https://github.com/CristiGvl/picoHWMon\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n")
+	if u.Listen != "" {
+		fmt.Fprintf(&b, "Requires=%s.socket\n", UnitName)
+		fmt.Fprintf(&b, "After=%s.socket\n", UnitName)
+	}
+
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=notify\n")
+	b.WriteString("NotifyAccess=main\n")
+	args := make([]string, len(u.ExecStart))
+	for i, arg := range u.ExecStart {
+		args[i] = quote(arg)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5s\n")
+	if u.Watchdog > 0 {
+		fmt.Fprintf(&b, "WatchdogSec=%s\n", u.Watchdog)
+	}
+	b.WriteString("# Profiles, rules, the audit log and TLS files live in $HOME/.config/picohwmon\n")
+	b.WriteString("StateDirectory=picohwmon\n")
+	fmt.Fprintf(&b, "Environment=HOME=%s\n", StateDirectory)
+	b.WriteString("UMask=0077\n")
+
+	b.WriteString("\n# Hardening\n")
+	for _, line := range []string{
+		"NoNewPrivileges=yes",
+		"ProtectSystem=strict",
+		"ProtectHome=yes",
+		"PrivateTmp=yes",
+		"ProtectControlGroups=yes",
+		"ProtectKernelModules=yes",
+		"ProtectClock=yes",
+		"ProtectHostname=yes",
+		"RestrictNamespaces=yes",
+		"RestrictRealtime=yes",
+		"RestrictSUIDSGID=yes",
+		"LockPersonality=yes",
+		"SystemCallArchitectures=native",
+		"RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6 AF_NETLINK",
+		// DAC_OVERRIDE and SYS_PTRACE read other processes' /proc files,
+		// SYS_RAWIO and SYS_ADMIN query SMART and drive GPU tools, SYSLOG
+		// reads /dev/kmsg
+		"CapabilityBoundingSet=CAP_DAC_OVERRIDE CAP_DAC_READ_SEARCH CAP_SYS_PTRACE CAP_SYS_RAWIO CAP_SYS_ADMIN CAP_SYSLOG CAP_NET_BIND_SERVICE",
+	} {
+		b.WriteString(line + "\n")
+	}
+	for _, path := range u.ReadWritePaths {
+		fmt.Fprintf(&b, "ReadWritePaths=%s\n", quote(path))
+	}
+
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// Socket returns the socket unit, or "" without Listen
+func (u Unit) Socket() string {
+	if u.Listen == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=picoHWMon hardware monitor socket\n")
+	b.WriteString("\n[Socket]\n")
+	fmt.Fprintf(&b, "ListenStream=%s\n", u.Listen)
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=sockets.target\n")
+	return b.String()
+}
+
+// quote escapes a command line argument for a unit file, where % starts a
+// specifier and $ a variable
+func quote(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	arg = strings.ReplaceAll(arg, "$", "$$")
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	return `"` + arg + `"`
+}
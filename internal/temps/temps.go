@@ -1,6 +1,9 @@
 package temps
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 // Sensor represents a temperature sensor
 type Sensor struct {
@@ -17,6 +20,37 @@ type Info struct {
 	GPU    []*Sensor `json:"gpu"`
 	System []*Sensor `json:"system"`
 	Drives []*Sensor `json:"drives"`
+	// External holds readings of configured external sources; Name is the source
+	External []*Sensor `json:"external"`
+}
+
+// Find returns the sensor named <category>/<sensor>[/<label>], e.g.
+// external/pico/T1 or cpu/coretemp. Without a label the first sensor matches.
+func (i *Info) Find(name string) *Sensor {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 2 {
+		return nil
+	}
+
+	var sensors []*Sensor
+	switch parts[0] {
+	case "cpu":
+		sensors = i.CPU
+	case "gpu":
+		sensors = i.GPU
+	case "system":
+		sensors = i.System
+	case "drives":
+		sensors = i.Drives
+	case "external":
+		sensors = i.External
+	}
+	for _, sensor := range sensors {
+		if sensor.Name == parts[1] && (len(parts) == 2 || sensor.Label == parts[2]) {
+			return sensor
+		}
+	}
+	return nil
 }
 
 // Reader interface for temperature monitoring
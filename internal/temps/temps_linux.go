@@ -4,7 +4,9 @@ package temps
 
 import (
 	"context"
+	"strings"
 
+	"github.com/CristiGvl/picoHWMon/internal/hwmon"
 	"github.com/shirou/gopsutil/v3/host"
 )
 
@@ -16,11 +18,24 @@ func newPlatformReader() Reader {
 	return &LinuxReader{}
 }
 
-// GetInfo returns temperature information
+// GetInfo returns temperature information from hwmon, or from the thermal
+// zones on systems without hwmon temperatures
 func (r *LinuxReader) GetInfo(ctx context.Context) (*Info, error) {
-	temps, err := host.SensorsTemperaturesWithContext(ctx)
-	if err != nil {
-		return nil, err
+	sensors, found := readHwmon()
+	if !found {
+		temps, err := host.SensorsTemperaturesWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, temp := range temps {
+			sensors = append(sensors, &Sensor{
+				Name:        temp.SensorKey,
+				Label:       temp.SensorKey,
+				Temperature: temp.Temperature,
+				Critical:    temp.Critical,
+				Max:         temp.High,
+			})
+		}
 	}
 
 	info := &Info{
@@ -30,22 +45,14 @@ func (r *LinuxReader) GetInfo(ctx context.Context) (*Info, error) {
 		Drives: []*Sensor{},
 	}
 
-	for _, temp := range temps {
-		sensor := &Sensor{
-			Name:        temp.SensorKey,
-			Label:       temp.SensorKey,
-			Temperature: temp.Temperature,
-			Critical:    temp.Critical,
-			Max:         temp.High,
-		}
-
+	for _, sensor := range sensors {
 		// Categorize sensors based on their names
 		switch {
-		case containsAny(temp.SensorKey, []string{"cpu", "core", "processor"}):
+		case containsAny(sensor.Name, []string{"cpu", "core", "processor"}):
 			info.CPU = append(info.CPU, sensor)
-		case containsAny(temp.SensorKey, []string{"gpu", "nvidia", "amd", "radeon"}):
+		case containsAny(sensor.Name, []string{"gpu", "nvidia", "amd", "radeon"}):
 			info.GPU = append(info.GPU, sensor)
-		case containsAny(temp.SensorKey, []string{"drive", "disk", "nvme", "sda", "sdb"}):
+		case containsAny(sensor.Name, []string{"drive", "disk", "nvme", "sda", "sdb"}):
 			info.Drives = append(info.Drives, sensor)
 		default:
 			info.System = append(info.System, sensor)
@@ -55,6 +62,58 @@ func (r *LinuxReader) GetInfo(ctx context.Context) (*Info, error) {
 	return info, nil
 }
 
+// readHwmon reads the temp*_input channels of the hwmon chips with the
+// lm-sensors configuration applied. Names are the keys gopsutil uses, e.g.
+// coretemp_core_0, so that sensor references keep working; labels are what
+// `sensors` prints. found reports whether there were any channels, ignored
+// ones included.
+func readHwmon() (sensors []*Sensor, found bool) {
+	for _, chip := range hwmon.Chips() {
+		for _, channel := range chip.Channels("temp") {
+			found = true
+			feature := chip.Feature(channel)
+			millidegrees, ok := chip.ReadInt(channel + "_input")
+			if !ok || feature.Ignore {
+				continue
+			}
+			celsius := func(millidegrees int64) float64 {
+				v := float64(millidegrees) / 1000
+				if feature.Compute != nil {
+					v = feature.Compute.Eval(v)
+				}
+				return v
+			}
+
+			name, label := chip.Prefix, chip.Read(channel+"_label")
+			if label != "" {
+				name += "_" + strings.ReplaceAll(strings.ToLower(label), " ", "_")
+			} else {
+				label = channel
+			}
+			if feature.Label != "" {
+				label = feature.Label
+			}
+
+			sensor := &Sensor{Name: name, Label: label, Temperature: celsius(millidegrees)}
+			if v, ok := chip.ReadInt(channel + "_crit"); ok {
+				sensor.Critical = celsius(v)
+			}
+			if v, ok := chip.ReadInt(channel + "_max"); ok {
+				sensor.Max = celsius(v)
+			}
+			// set statements are not written to the chip; they only act as limits
+			if v, ok := feature.Limits["crit"]; ok {
+				sensor.Critical = v
+			}
+			if v, ok := feature.Limits["max"]; ok {
+				sensor.Max = v
+			}
+			sensors = append(sensors, sensor)
+		}
+	}
+	return sensors, found
+}
+
 func containsAny(str string, substrings []string) bool {
 	for _, substr := range substrings {
 		if len(str) >= len(substr) {
@@ -0,0 +1,127 @@
+package voltage
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"github.com/CristiGvl/picoHWMon/internal/hwmon"
+)
+
+// Rail states
+const (
+	StatusOK = "ok"
+	// StatusLow and StatusHigh mean the reading is outside its limits
+	StatusLow  = "low"
+	StatusHigh = "high"
+	// StatusAlarm means the chip raised its alarm flag for the rail
+	StatusAlarm = "alarm"
+)
+
+// Rail is a voltage input of a hwmon chip, such as Vcore or +12V
+type Rail struct {
+	// Chip is the lm-sensors chip name, e.g. nct6775-isa-0290
+	Chip string `json:"chip"`
+	// Input is the chip's channel, e.g. in1
+	Input string  `json:"input"`
+	Label string  `json:"label"`
+	Volts float64 `json:"volts"`
+	// Min and Max are the limits from the chip or the configuration
+	Min *float64 `json:"min_volts,omitempty"`
+	Max *float64 `json:"max_volts,omitempty"`
+	// Nominal is the voltage a label such as +12V names; without limits the
+	// rail must stay within 5% of it (10% for negative rails)
+	Nominal *float64 `json:"nominal_volts,omitempty"`
+	Status  string   `json:"status"`
+}
+
+// OutOfRange reports whether the rail is outside its limits or in alarm
+func (r *Rail) OutOfRange() bool {
+	return r.Status != StatusOK
+}
+
+// Info holds the voltage rails of all chips
+type Info struct {
+	Rails []*Rail `json:"rails"`
+	// OutOfRange counts the rails that are not ok
+	OutOfRange int `json:"out_of_range"`
+}
+
+// Override adjusts the rails of matching chips, like a chip block of
+// sensors.conf. Chip is an lm-sensors chip pattern such as nct6775-*.
+type Override struct {
+	Chip  string
+	Input string
+	Label string
+	// Compute converts the raw reading to volts, e.g. @*(1+120/56); it is
+	// also applied to the chip's limits
+	Compute *hwmon.Expr
+	// Min and Max replace the chip's limits and alarm flag
+	Min, Max *float64
+	Ignore   bool
+}
+
+// Reader interface for voltage monitoring
+type Reader interface {
+	GetInfo(ctx context.Context) (*Info, error)
+	// Configure replaces the overrides applied to the rails
+	Configure(overrides []Override)
+}
+
+// NewReader creates a new voltage reader for the current platform
+func NewReader() Reader {
+	return newPlatformReader()
+}
+
+// nominalLabel matches labels that name their voltage: +12V, -5V, +3.3V,
+// 3VSB (3.3V standby) or 5VCC
+var nominalLabel = regexp.MustCompile(`^([+-]?)(\d+(?:\.\d+)?)\s*V(SB|CC|DD)?$`)
+
+// nominal returns the voltage named by a label
+func nominal(label string) (float64, bool) {
+	m := nominalLabel.FindStringSubmatch(label)
+	if m == nil {
+		return 0, false
+	}
+	v, _ := strconv.ParseFloat(m[2], 64)
+	if v == 3 {
+		v = 3.3
+	}
+	if m[1] == "-" {
+		v = -v
+	}
+	return v, v != 0
+}
+
+// evaluate sets the status of a rail. Chip limits that are unset or
+// inverted are ignored and the nominal voltage, if any, is used instead.
+func (r *Rail) evaluate(alarm bool) {
+	if r.Min != nil && r.Max != nil && *r.Min >= *r.Max {
+		r.Min, r.Max = nil, nil
+	}
+	if v, ok := nominal(r.Label); ok {
+		r.Nominal = &v
+		if r.Min == nil && r.Max == nil {
+			tolerance := 0.05
+			if v < 0 {
+				tolerance = 0.10
+			}
+			low, high := v*(1-tolerance), v*(1+tolerance)
+			if v < 0 {
+				low, high = high, low
+			}
+			r.Min, r.Max = &low, &high
+		}
+	}
+
+	switch {
+	case alarm:
+		r.Status = StatusAlarm
+	case r.Min != nil && r.Volts < *r.Min:
+		r.Status = StatusLow
+	case r.Max != nil && r.Volts > *r.Max:
+		r.Status = StatusHigh
+	default:
+		r.Status = StatusOK
+	}
+}
@@ -0,0 +1,126 @@
+//go:build linux
+
+package voltage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/CristiGvl/picoHWMon/internal/hwmon"
+)
+
+// LinuxReader implements voltage monitoring for Linux from the in*_input
+// channels of hwmon chips
+type LinuxReader struct {
+	mu        sync.RWMutex
+	overrides []Override
+}
+
+// newPlatformReader creates a new Linux voltage reader
+func newPlatformReader() Reader {
+	return &LinuxReader{}
+}
+
+// Configure replaces the overrides applied to the rails
+func (r *LinuxReader) Configure(overrides []Override) {
+	r.mu.Lock()
+	r.overrides = overrides
+	r.mu.Unlock()
+}
+
+// GetInfo returns the voltage rails of all chips
+func (r *LinuxReader) GetInfo(ctx context.Context) (*Info, error) {
+	r.mu.RLock()
+	overrides := r.overrides
+	r.mu.RUnlock()
+
+	info := &Info{Rails: []*Rail{}}
+	for _, chip := range hwmon.Chips() {
+		for _, input := range chip.Channels("in") {
+			if rail := readRail(chip, input, overrides); rail != nil {
+				info.Rails = append(info.Rails, rail)
+				if rail.OutOfRange() {
+					info.OutOfRange++
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// readRail reads one voltage channel in millivolts and applies the
+// lm-sensors configuration, then the matching overrides; later overrides
+// win. It returns nil for ignored rails.
+func readRail(chip *hwmon.Chip, input string, overrides []Override) *Rail {
+	feature := chip.Feature(input)
+	millivolts, ok := chip.ReadInt(input + "_input")
+	if !ok || feature.Ignore {
+		return nil
+	}
+	label := feature.Label
+	if label == "" {
+		label = chip.Read(input + "_label")
+	}
+	if label == "" {
+		label = input
+	}
+	compute := feature.Compute
+	var min, max *float64
+	// set statements are not written to the chip; they only act as limits
+	if v, ok := feature.Limits["min"]; ok {
+		min = &v
+	}
+	if v, ok := feature.Limits["max"]; ok {
+		max = &v
+	}
+	for _, o := range overrides {
+		if o.Input != input || !hwmon.MatchChip(o.Chip, chip.Name) {
+			continue
+		}
+		if o.Ignore {
+			return nil
+		}
+		if o.Label != "" {
+			label = o.Label
+		}
+		if o.Compute != nil {
+			compute = o.Compute
+		}
+		if o.Min != nil {
+			min = o.Min
+		}
+		if o.Max != nil {
+			max = o.Max
+		}
+	}
+
+	volts := func(millivolts int64) float64 {
+		v := float64(millivolts) / 1000
+		if compute != nil {
+			v = compute.Eval(v)
+		}
+		return v
+	}
+	rail := &Rail{Chip: chip.Name, Input: input, Label: label, Volts: volts(millivolts)}
+	var alarm bool
+	if min != nil || max != nil {
+		// Configured limits replace the chip's, whose alarm no longer applies
+		rail.Min, rail.Max = min, max
+	} else {
+		if v, ok := chip.ReadInt(input + "_min"); ok {
+			low := volts(v)
+			rail.Min = &low
+		}
+		if v, ok := chip.ReadInt(input + "_max"); ok {
+			high := volts(v)
+			rail.Max = &high
+		}
+		// A negative compute factor turns the limits around
+		if rail.Min != nil && rail.Max != nil && *rail.Min > *rail.Max {
+			rail.Min, rail.Max = rail.Max, rail.Min
+		}
+		alarm = chip.Read(input+"_alarm") == "1"
+	}
+	rail.evaluate(alarm)
+	return rail
+}
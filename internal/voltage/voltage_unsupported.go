@@ -0,0 +1,24 @@
+//go:build !linux
+
+package voltage
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnsupportedReader is a fallback for platforms without hwmon
+type UnsupportedReader struct{}
+
+// newPlatformReader creates a fallback voltage reader for unsupported platforms
+func newPlatformReader() Reader {
+	return &UnsupportedReader{}
+}
+
+// Configure does nothing on unsupported platforms
+func (r *UnsupportedReader) Configure(overrides []Override) {}
+
+// GetInfo returns an error for unsupported platforms
+func (r *UnsupportedReader) GetInfo(ctx context.Context) (*Info, error) {
+	return nil, fmt.Errorf("voltage monitoring not supported on this platform")
+}
@@ -1,47 +1,184 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/CristiGvl/picoHWMon/api"
+	"github.com/CristiGvl/picoHWMon/internal/config"
 	"github.com/CristiGvl/picoHWMon/internal/platform"
+	"github.com/CristiGvl/picoHWMon/internal/systemd"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "install-service" {
+		if err := installService(os.Args[2:]); err != nil {
+			log.Fatalf("install-service: %v", err)
+		}
+		return
+	}
+
 	// Parse command line flags
-	port := flag.String("port", "8080", "Port to run the server on")
-	bind := flag.String("bind", "0.0.0.0", "IP address to bind the server to")
+	configPath := flag.String("config", "", "Path to the YAML configuration file; flags given explicitly override it")
+	port := flag.Int("port", 8080, "Port to run the server on")
+	bind := flag.String("bind", "127.0.0.1", "IP address to bind the server to; 0.0.0.0 for all interfaces")
+	tokensFile := flag.String("tokens", "", "Path to the API tokens file (JSON); without it only localhost may change settings")
+	useTLS := flag.Bool("tls", false, "Serve HTTPS (a self-signed certificate is generated if the certificate files don't exist)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file (implies --tls; default <config dir>/tls/cert.pem)")
+	tlsKey := flag.String("tls-key", "", "TLS private key file (implies --tls; default <config dir>/tls/key.pem)")
+	tlsClientCA := flag.String("tls-client-ca", "", "CA bundle for verifying client certificates (enables mutual TLS)")
+	requireClientCert := flag.Bool("tls-require-client-cert", false, "Reject TLS clients without a valid client certificate")
+	corsOrigins := flag.String("cors-origins", "", "Comma-separated browser origins allowed to call the API (e.g. http://dashboard.local:3000)")
 	flag.Parse()
 
+	// Under systemd, log to the journal with priorities and source locations
+	if systemd.JournalAvailable() {
+		if handler, err := systemd.NewJournalHandler("picoHWMon", slog.LevelInfo); err == nil {
+			slog.SetDefault(slog.New(handler))
+		}
+	}
+
 	// Check platform support
 	// Validate platform support
 	if err := platform.ValidateSupport(); err != nil {
-		log.Fatalf("Platform validation failed: %v", err)
+		fatal("Platform validation failed", "error", err)
+	}
+
+	// Flags given on the command line take precedence over the config file,
+	// including after a reload
+	overrides := make(map[string]func(*config.Config))
+	overrides["port"] = func(c *config.Config) { c.Server.Port = *port }
+	overrides["bind"] = func(c *config.Config) { c.Server.Bind = *bind }
+	overrides["tokens"] = func(c *config.Config) { c.Server.TokensFile = *tokensFile }
+	overrides["tls"] = func(c *config.Config) { c.Server.TLS.Enabled = *useTLS }
+	overrides["tls-cert"] = func(c *config.Config) { c.Server.TLS.CertFile, c.Server.TLS.Enabled = *tlsCert, true }
+	overrides["tls-key"] = func(c *config.Config) { c.Server.TLS.KeyFile, c.Server.TLS.Enabled = *tlsKey, true }
+	overrides["tls-client-ca"] = func(c *config.Config) { c.Server.TLS.ClientCAFile, c.Server.TLS.Enabled = *tlsClientCA, true }
+	overrides["tls-require-client-cert"] = func(c *config.Config) { c.Server.TLS.RequireClientCert = *requireClientCert }
+	overrides["cors-origins"] = func(c *config.Config) {
+		c.Server.CORSOrigins = nil
+		for _, origin := range strings.Split(*corsOrigins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.Server.CORSOrigins = append(c.Server.CORSOrigins, origin)
+			}
+		}
+	}
+
+	var setFlags []string
+	flag.Visit(func(f *flag.Flag) { setFlags = append(setFlags, f.Name) })
+
+	loadConfig := func() (*config.Config, error) {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range setFlags {
+			if override, ok := overrides[name]; ok {
+				override(cfg)
+			}
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid configuration after applying command line flags: %w", err)
+		}
+		return cfg, nil
+	}
+
+	// Take the sockets passed by systemd before starting helpers that could inherit them
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		fatal("Failed to use the sockets passed by systemd", "error", err)
 	}
 
 	// Create and start the API server
-	server, err := api.NewServer()
+	server, err := api.NewServer(*configPath, loadConfig)
 	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+		fatal("Failed to create server", "error", err)
 	}
 
+	// Reload the configuration, tokens and certificates on SIGHUP. Signals are
+	// subscribed to before READY=1, so systemctl reload can't kill the service.
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			notify(systemd.Reloading())
+			restartRequired, err := server.Reload()
+			if err != nil {
+				slog.Error("Reload failed, previous settings kept where loading failed", "error", err)
+				notify(systemd.Ready + "\n" + systemd.Status("Reload failed: %v", err))
+				continue
+			}
+			notify(systemd.Ready + "\n" + systemd.Status("Reloaded"))
+			slog.Info("Reloaded configuration, tokens and certificates")
+			if len(restartRequired) > 0 {
+				slog.Warn("Some changes take effect after a restart", "settings", strings.Join(restartRequired, ", "))
+			}
+		}
+	}()
+
+	// Notify the systemd watchdog while the control loops keep running
+	stopWatchdog := make(chan struct{})
+	go systemd.RunWatchdog(stopWatchdog, func() error {
+		if stalled := server.StalledLoops(); len(stalled) > 0 {
+			return errors.New(strings.Join(stalled, "; "))
+		}
+		return nil
+	})
+
 	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
+		notify(systemd.Stopping)
+		close(stopWatchdog)
 		if err := server.Shutdown(); err != nil {
-			log.Printf("Error during shutdown: %v", err)
+			slog.Error("Error during shutdown", "error", err)
 		}
 		os.Exit(0)
 	}()
 
 	// Start the server
-	log.Printf("Starting picoHWMon server on %s:%s", *bind, *port)
-	log.Fatal(server.Start(*bind + ":" + *port))
+	var ln net.Listener
+	if len(listeners) > 0 {
+		ln = listeners[0]
+		for _, unused := range listeners[1:] {
+			slog.Warn("Ignoring a socket passed by systemd; only one is served", "address", unused.Addr().String())
+			unused.Close()
+		}
+		slog.Info("Starting picoHWMon server on a socket passed by systemd", "address", ln.Addr().String())
+	} else {
+		if ln, err = server.Listen(); err != nil {
+			fatal("Failed to listen", "error", err)
+		}
+		slog.Info("Starting picoHWMon server", "url", server.URL())
+	}
+	notify(systemd.Ready + "\n" + systemd.Status("Serving on %s", ln.Addr()))
+	if err := server.Serve(ln); err != nil {
+		fatal("Server failed", "error", err)
+	}
+	// Serve returns when shutdown begins; the signal handler exits once it is done
+	select {}
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// notify tells systemd about a state change; it does nothing when not run by systemd
+func notify(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
+	}
 }